@@ -0,0 +1,71 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"text/tabwriter"
+
+	k8s "github.com/alexieff-io/consul-sync/internal/kubernetes"
+	"github.com/alexieff-io/consul-sync/internal/reconciler"
+)
+
+// auditExitDiscrepancies is the exit status of the audit subcommand when the
+// cluster doesn't match the catalog.
+const auditExitDiscrepancies = 3
+
+// runAudit implements the audit subcommand: it fetches the catalog once,
+// compares it with the cluster as the controller would reconcile it, and
+// prints the discrepancies without changing anything. It returns the process
+// exit status.
+func runAudit(ctx context.Context, args []string, source reconciler.Source, syncer *k8s.Syncer) int {
+	fs := flag.NewFlagSet("audit", flag.ContinueOnError)
+	asJSON := fs.Bool("json", false, "Print the report as JSON")
+	if err := fs.Parse(args); err != nil {
+		return 2
+	}
+
+	states, err := source.FetchAllServices(ctx)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "audit: fetching services: %v\n", err)
+		return 1
+	}
+	report, err := syncer.Audit(ctx, states)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "audit: %v\n", err)
+		return 1
+	}
+
+	if *asJSON {
+		if report.Discrepancies == nil {
+			report.Discrepancies = []k8s.Discrepancy{}
+		}
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		enc.Encode(report)
+	} else {
+		printAuditReport(os.Stdout, report)
+	}
+
+	if len(report.Discrepancies) > 0 {
+		return auditExitDiscrepancies
+	}
+	return 0
+}
+
+func printAuditReport(out io.Writer, report k8s.AuditReport) {
+	fmt.Fprintf(out, "audited %d services: %d discrepancies\n", report.Services, len(report.Discrepancies))
+	if len(report.Discrepancies) == 0 {
+		return
+	}
+	fmt.Fprintln(out)
+	tw := tabwriter.NewWriter(out, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "KIND\tNAME\tPROBLEM\tDETAIL")
+	for _, d := range report.Discrepancies {
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%s\n", d.Kind, d.Name, d.Problem, d.Detail)
+	}
+	tw.Flush()
+}
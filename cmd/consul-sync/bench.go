@@ -0,0 +1,170 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"sort"
+	"text/tabwriter"
+	"time"
+
+	k8stesting "k8s.io/client-go/testing"
+
+	"github.com/alexieff-io/consul-sync/consulsynctest"
+	"github.com/alexieff-io/consul-sync/internal/consul"
+	k8s "github.com/alexieff-io/consul-sync/internal/kubernetes"
+)
+
+// runBench implements the bench subcommand: it syncs synthetic services from
+// an in-memory Consul into fake clientsets and reports reconcile timings and
+// API call counts, so Syncer performance regressions show up before release.
+func runBench(args []string) error {
+	fs := flag.NewFlagSet("bench", flag.ContinueOnError)
+	services := fs.Int("services", 500, "Number of synthetic services")
+	instances := fs.Int("instances", 3, "Healthy instances per service")
+	iterations := fs.Int("iterations", 5, "Steady-state reconciles to run after the initial one")
+	churn := fs.Float64("churn", 0.1, "Fraction of services deregistered before the final reconcile")
+	routes := fs.Bool("routes", true, "Tag every other service for an internal HTTPRoute")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if *services < 1 || *instances < 1 || *iterations < 0 || *churn < 0 || *churn > 1 {
+		return fmt.Errorf("invalid bench parameters")
+	}
+
+	// The Syncer logs every service it touches; keep only problems.
+	slog.SetDefault(slog.New(slog.NewTextHandler(os.Stderr, &slog.HandlerOptions{Level: slog.LevelWarn})))
+
+	srv := consulsynctest.NewServer()
+	defer srv.Close()
+	for i := range *services {
+		name := fmt.Sprintf("bench-%d", i)
+		tags := []string{"kubernetes"}
+		if *routes && i%2 == 0 {
+			tags = append(tags, "internal")
+		}
+		for j := range *instances {
+			srv.Register(name, consulsynctest.Instance{
+				ID:      fmt.Sprintf("%s-%d", name, j),
+				Address: fmt.Sprintf("10.%d.%d.%d", i/256%256, i%256, j%256),
+				Port:    8080,
+				Tags:    tags,
+			})
+		}
+	}
+
+	client, dynClient := consulsynctest.NewFakeClients()
+	watcher := consul.NewWatcher(srv.URL(), "", "kubernetes", consul.Options{SkipServices: []string{"consul"}})
+	syncer := k8s.NewSyncer(client, dynClient, "bench", k8s.HTTPRouteConfig{
+		Enabled:          *routes,
+		DomainSuffix:     "bench.example.com",
+		InternalGateway:  "envoy-internal",
+		ExternalGateway:  "envoy-external",
+		GatewayNamespace: "bench",
+		GatewayListeners: []string{"https"},
+		InternalTag:      "internal",
+		ExternalTag:      "external",
+	}, k8s.Options{})
+
+	ctx := context.Background()
+	var results []benchResult
+	run := func(phase string) error {
+		client.ClearActions()
+		dynClient.ClearActions()
+		consulBefore := srv.Requests("/v1/")
+
+		start := time.Now()
+		states, err := watcher.FetchAllServices(ctx)
+		if err != nil {
+			return fmt.Errorf("%s: fetching services: %w", phase, err)
+		}
+		fetched := time.Since(start)
+		if _, err := syncer.Sync(ctx, states); err != nil {
+			return fmt.Errorf("%s: syncing: %w", phase, err)
+		}
+
+		results = append(results, benchResult{
+			phase:    phase,
+			services: len(states),
+			fetch:    fetched,
+			total:    time.Since(start),
+			consul:   srv.Requests("/v1/") - consulBefore,
+			calls:    countActions(append(client.Actions(), dynClient.Actions()...)),
+		})
+		return nil
+	}
+
+	if err := run("initial"); err != nil {
+		return err
+	}
+	for i := range *iterations {
+		if err := run(fmt.Sprintf("steady-%d", i+1)); err != nil {
+			return err
+		}
+	}
+	if removed := int(float64(*services) * *churn); removed > 0 {
+		for i := range removed {
+			name := fmt.Sprintf("bench-%d", i)
+			for j := range *instances {
+				srv.Deregister(name, fmt.Sprintf("%s-%d", name, j))
+			}
+		}
+		if err := run("churn"); err != nil {
+			return err
+		}
+	}
+
+	fmt.Printf("bench: %d services x %d instances, routes=%t\n\n", *services, *instances, *routes)
+	printBenchResults(os.Stdout, results)
+	return nil
+}
+
+type benchResult struct {
+	phase    string
+	services int
+	fetch    time.Duration
+	total    time.Duration
+	consul   int
+	calls    map[string]int // verb/resource → count
+}
+
+// countActions tallies recorded fake-client actions by verb and resource.
+func countActions(actions []k8stesting.Action) map[string]int {
+	counts := make(map[string]int)
+	for _, a := range actions {
+		counts[a.GetVerb()+"/"+a.GetResource().Resource]++
+	}
+	return counts
+}
+
+func printBenchResults(out io.Writer, results []benchResult) {
+	tw := tabwriter.NewWriter(out, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "PHASE\tSERVICES\tFETCH\tTOTAL\tSERVICES/S\tCONSUL CALLS\tK8S CALLS")
+	for _, r := range results {
+		var k8sCalls int
+		for _, n := range r.calls {
+			k8sCalls += n
+		}
+		fmt.Fprintf(tw, "%s\t%d\t%s\t%s\t%.0f\t%d\t%d\n",
+			r.phase, r.services, r.fetch.Round(time.Millisecond), r.total.Round(time.Millisecond),
+			float64(r.services)/r.total.Seconds(), r.consul, k8sCalls)
+	}
+	tw.Flush()
+
+	fmt.Fprintln(out, "\nKubernetes API calls by phase:")
+	for _, r := range results {
+		keys := make([]string, 0, len(r.calls))
+		for k := range r.calls {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		fmt.Fprintf(out, "  %s:", r.phase)
+		for _, k := range keys {
+			fmt.Fprintf(out, " %s=%d", k, r.calls[k])
+		}
+		fmt.Fprintln(out)
+	}
+}
@@ -0,0 +1,68 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+
+	"github.com/go-logr/logr"
+	"k8s.io/client-go/rest"
+	ctrllog "sigs.k8s.io/controller-runtime/pkg/log"
+	"sigs.k8s.io/controller-runtime/pkg/manager"
+	metricsserver "sigs.k8s.io/controller-runtime/pkg/metrics/server"
+
+	k8s "github.com/alexieff-io/consul-sync/internal/kubernetes"
+)
+
+// runLeader calls lead and returns its error. With leader election enabled,
+// lead runs under a controller-runtime manager once this replica acquires the
+// Lease, and runLeader returns an error if the Lease is lost so the pod
+// restarts as a standby. The syncer then lists the objects it manages from
+// the manager's informer cache, which only starts watching them on the
+// leader's first sync. Without leader election there is no manager, and the
+// syncer lists from the API server. Either way the syncs themselves are run
+// by lead, not by a controller of the manager, and runLeader returns once ctx
+// is done and lead has returned.
+func runLeader(ctx context.Context, restCfg *rest.Config, cfg config, syncer *k8s.Syncer, lead func(context.Context) error) error {
+	if !cfg.leaderElection {
+		return lead(ctx)
+	}
+
+	ctrllog.SetLogger(logr.FromSlogHandler(slog.Default().Handler()))
+	opts := manager.Options{
+		LeaderElection:          true,
+		LeaderElectionID:        cfg.leaderElectionID,
+		LeaderElectionNamespace: cfg.leaderElectionNamespace,
+		// lead returns as soon as ctx is cancelled, so the Lease can be
+		// released for a standby to take over without waiting for it to
+		// expire.
+		LeaderElectionReleaseOnCancel: true,
+		// The manager's metrics are served by the health server, next to
+		// consul-sync's own, and so are its probes.
+		Metrics:                metricsserver.Options{BindAddress: "0"},
+		HealthProbeBindAddress: "0",
+	}
+	cacheOpts, cached := syncer.CacheOptions()
+	if cached {
+		opts.Cache = cacheOpts
+	}
+	mgr, err := manager.New(restCfg, opts)
+	if err != nil {
+		return fmt.Errorf("creating manager: %w", err)
+	}
+	if cached {
+		syncer.UseCache(mgr.GetCache())
+	}
+	if err := mgr.Add(manager.RunnableFunc(func(ctx context.Context) error {
+		slog.InfoContext(ctx, "acquired leader lease", "lease", cfg.leaderElectionNamespace+"/"+cfg.leaderElectionID)
+		return lead(ctx)
+	})); err != nil {
+		return fmt.Errorf("adding reconciler to manager: %w", err)
+	}
+
+	slog.InfoContext(ctx, "waiting for leader lease", "lease", cfg.leaderElectionNamespace+"/"+cfg.leaderElectionID)
+	if err := mgr.Start(ctx); err != nil {
+		return fmt.Errorf("running manager: %w", err)
+	}
+	return nil
+}
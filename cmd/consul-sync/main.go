@@ -5,20 +5,33 @@ import (
 	"flag"
 	"fmt"
 	"log/slog"
+	"maps"
+	"net/http"
 	"os"
 	"os/signal"
+	"slices"
+	"strconv"
 	"strings"
+	"sync/atomic"
 	"syscall"
 	"time"
 
+	"k8s.io/apimachinery/pkg/util/validation"
 	"k8s.io/client-go/dynamic"
 	"k8s.io/client-go/kubernetes"
 	"k8s.io/client-go/rest"
 	"k8s.io/client-go/tools/clientcmd"
 
+	"github.com/alexieff-io/consul-sync/internal/admin"
+	"github.com/alexieff-io/consul-sync/internal/backup"
 	"github.com/alexieff-io/consul-sync/internal/consul"
+	"github.com/alexieff-io/consul-sync/internal/dns"
 	"github.com/alexieff-io/consul-sync/internal/health"
 	k8s "github.com/alexieff-io/consul-sync/internal/kubernetes"
+	"github.com/alexieff-io/consul-sync/internal/logctx"
+	"github.com/alexieff-io/consul-sync/internal/metrics"
+	"github.com/alexieff-io/consul-sync/internal/nomad"
+	"github.com/alexieff-io/consul-sync/internal/probe"
 	"github.com/alexieff-io/consul-sync/internal/reconciler"
 )
 
@@ -30,49 +43,306 @@ var (
 
 func main() {
 	showVersion := flag.Bool("version", false, "Print version and exit")
+	impersonateUser := flag.String("as", "", "Username to impersonate for Kubernetes API calls")
+	var impersonateGroups stringList
+	flag.Var(&impersonateGroups, "as-group", "Group to impersonate for Kubernetes API calls (repeatable)")
 	flag.Parse()
+	if len(impersonateGroups) > 0 && *impersonateUser == "" {
+		fmt.Fprintln(os.Stderr, "--as-group requires --as")
+		os.Exit(2)
+	}
 
 	if *showVersion {
 		fmt.Printf("consul-sync %s (commit: %s)\n", version, commit)
 		os.Exit(0)
 	}
 
-	slog.SetDefault(slog.New(slog.NewJSONHandler(os.Stdout, &slog.HandlerOptions{Level: slog.LevelInfo})))
+	// audit runs a single comparison with the regular configuration and
+	// exits, so it shares the startup below.
+	audit := false
+	switch flag.Arg(0) {
+	case "":
+	case "audit":
+		audit = true
+	case "bench":
+		if err := runBench(flag.Args()[1:]); err != nil {
+			fmt.Fprintf(os.Stderr, "bench: %v\n", err)
+			os.Exit(1)
+		}
+		return
+	default:
+		fmt.Fprintf(os.Stderr, "unknown command %q\n", flag.Arg(0))
+		os.Exit(2)
+	}
+
+	logOut := os.Stdout
+	if audit {
+		logOut = os.Stderr // keep stdout for the report
+	}
+	slog.SetDefault(slog.New(logctx.NewHandler(slog.NewJSONHandler(logOut, &slog.HandlerOptions{Level: slog.LevelInfo}))))
 
 	cfg := loadConfig()
+	if *impersonateUser != "" && len(cfg.tenantServiceAccounts) > 0 {
+		// Tenant clients impersonate their ServiceAccount instead, which
+		// would escape the impersonated identity's RBAC in their namespaces.
+		fmt.Fprintln(os.Stderr, "--as can't be combined with TENANT_SERVICE_ACCOUNTS")
+		os.Exit(1)
+	}
 	slog.Info("starting consul-sync",
 		"version", version,
 		"commit", commit,
+		"source", cfg.source,
+		"run_mode", cfg.runMode,
+		"node_name", cfg.nodeName,
 		"consul_addr", cfg.consulAddr,
+		"nomad_addr", cfg.nomadAddr,
+		"nomad_namespace", cfg.nomadNamespace,
 		"consul_tag", cfg.consulTag,
+		"consul_filter", cfg.consulFilter,
+		"consul_watch_mode", cfg.watchMode,
+		"skip_services", cfg.skipServices,
+		"consul_skip_kinds", cfg.skipKinds,
 		"target_namespace", cfg.targetNamespace,
+		"allowed_namespaces", cfg.allowedNamespaces,
+		"watch_profiles", len(cfg.watchProfiles),
 		"metrics_addr", cfg.metricsAddr,
+		"health_addr", cfg.healthAddr,
+		"ready_file", cfg.readyFile,
+		"route_config_source", cfg.routeConfigSource,
+		"static_services_file", cfg.staticServicesFile,
+		"heartbeat_lease", cfg.heartbeatLease,
+		"admin_grpc_addr", cfg.admin.Addr,
+		"backup_bucket", cfg.backup.Bucket,
 		"resync_interval", cfg.resyncInterval,
+		"resync_interval_healthy", healthyResync(cfg.healthyResync),
+		"drift_detection", cfg.driftDetection,
+		"max_deletions_per_sync", cfg.maxDeletionsPerSync,
+		"kube_write_rate", cfg.writeRate,
+		"service_failure_threshold", cfg.failureThreshold,
+		"quiet_bootstrap", cfg.quietBootstrap,
+		"service_only", cfg.serviceOnly,
+		"external_services", cfg.externalServices,
+		"endpoints_mode", cfg.endpointsMode,
+		"hostname_addresses", cfg.hostnames,
+		"hostname_resolve_interval", cfg.hostnameRefresh,
+		"endpoint_drain_period", cfg.drainPeriod,
+		"service_mode", cfg.serviceMode,
+		"loadbalancer_class", cfg.loadBalancer.Class,
+		"meta_annotation_prefix", cfg.metaAnnotationPrefix,
+		"tag_label_prefix", cfg.tagLabelPrefix,
+		"route_label_tags", cfg.routeLabelTags,
+		"conflict_policy", cfg.conflictPolicies,
+		"adopt", cfg.adopt,
+		"cleanup_on_exit", cfg.cleanupOnExit,
+		"adopt_field_managers", cfg.adoptFieldManagers,
+		"name_sanitizer", cfg.names,
 		"enable_httproutes", cfg.routeCfg.Enabled,
+		"monitor_httproute_status", cfg.monitorRouteStatus,
+		"audit_only", cfg.auditOnly,
+		"probe_interval", cfg.probe.Interval,
 		"domain_suffix", cfg.routeCfg.DomainSuffix,
 		"internal_gateway", cfg.routeCfg.InternalGateway,
 		"external_gateway", cfg.routeCfg.ExternalGateway,
 		"gateway_namespace", cfg.routeCfg.GatewayNamespace,
-		"gateway_listener", cfg.routeCfg.GatewayListener,
+		"gateway_listener", cfg.routeCfg.GatewayListeners,
 		"internal_tag", cfg.routeCfg.InternalTag,
 		"external_tag", cfg.routeCfg.ExternalTag,
+		"hostname_layout", cfg.routeCfg.HostnameLayout,
+		"tenant_service_accounts", cfg.tenantServiceAccounts,
+		"consul_tls_source", cfg.consulTLSSource,
+		"consul_cacert", cfg.consulTLSFiles.CACert,
+		"consul_client_cert", cfg.consulTLSFiles.ClientCert,
+		"consul_tls_server_name", cfg.consulTLSServerName,
+		"consul_tls_skip_verify", cfg.consulTLSSkipVerify,
+		// Only the names: the values may be credentials.
+		"consul_headers", slices.Sorted(maps.Keys(cfg.consulHeaders)),
+		"leader_election", cfg.leaderElection,
+		"consul_token_file", cfg.consulTokenFile,
+		"consul_login", cfg.consulLogin,
+		"consul_vault_role", vaultRole(cfg.consulVault),
+		"consul_datacenters", cfg.consulDatacenters,
+		"consul_namespace", cfg.consulNamespace,
+		"consul_peering", cfg.consulPeering,
+		"consul_strict", cfg.consulStrict != nil,
+		"consul_stale", cfg.consulStale,
+		"consul_cache", cfg.consulCache,
+		"consul_streaming", cfg.consulStreaming,
+		"consul_prepared_queries", cfg.consulPreparedQueries,
+		"consul_ingress_gateways", cfg.consulIngressGateways,
+		"kube_api_server", cfg.kubeAPIServer,
 	)
 
 	ctx, cancel := signal.NotifyContext(context.Background(), syscall.SIGTERM, syscall.SIGINT)
 	defer cancel()
 
 	// Kubernetes client
-	k8sClient, dynClient, err := newKubernetesClients()
+	restCfg, err := newRESTConfig(cfg)
+	if err != nil {
+		slog.Error("failed to load kubernetes config", "error", err)
+		os.Exit(1)
+	}
+	if *impersonateUser != "" || len(impersonateGroups) > 0 {
+		restCfg.Impersonate = rest.ImpersonationConfig{
+			UserName: *impersonateUser,
+			Groups:   impersonateGroups,
+		}
+		slog.Info("impersonating kubernetes identity", "user", *impersonateUser, "groups", []string(impersonateGroups))
+	}
+	k8sClient, dynClient, err := newKubernetesClients(restCfg)
 	if err != nil {
 		slog.Error("failed to create kubernetes client", "error", err)
 		os.Exit(1)
 	}
+	tenantClients, err := k8s.NewTenantClients(restCfg, cfg.tenantServiceAccounts)
+	if err != nil {
+		slog.Error("failed to create tenant clients", "error", err)
+		os.Exit(1)
+	}
 
 	// Components
-	watcher := consul.NewWatcher(cfg.consulAddr, cfg.consulToken, cfg.consulTag)
-	syncer := k8s.NewSyncer(k8sClient, dynClient, cfg.targetNamespace, cfg.routeCfg)
-	healthSrv := health.NewServer(cfg.metricsAddr, version, commit)
-	rec := reconciler.New(watcher, syncer, healthSrv, cfg.resyncInterval)
+	if cfg.source != "nomad" && cfg.runMode != "node" && cfg.faults != nil {
+		slog.Warn("FAULT INJECTION ENABLED: consul responses will be corrupted; never run this in production",
+			"error_rate", cfg.faults.ErrorRate, "flap_rate", cfg.faults.FlapRate, "max_delay", cfg.faults.MaxDelay)
+	}
+	if cfg.consulTLSSkipVerify {
+		slog.Warn("consul server certificates are not verified (CONSUL_TLS_SKIP_VERIFY)")
+	}
+	if cfg.admin.Addr != "" && cfg.admin.CertFile == "" && !cfg.admin.Loopback() {
+		slog.Warn("ADMIN API SERVED WITHOUT TLS: the bearer token and every call, Uninstall included, cross the network in plaintext (ADMIN_GRPC_PLAINTEXT)",
+			"addr", cfg.admin.Addr)
+	}
+	source, watcher, err := newSource(ctx, k8sClient, cfg, cfg.consulTag)
+	if err != nil {
+		slog.Error("failed to create service source", "error", err)
+		os.Exit(1)
+	}
+	profiles := []reconciler.Profile{{Name: "default", Source: source}}
+	for _, p := range cfg.watchProfiles {
+		src, _, err := newSource(ctx, k8sClient, cfg, p.Tag)
+		if err != nil {
+			slog.Error("failed to create service source", "profile", p.Name, "error", err)
+			os.Exit(1)
+		}
+		profiles = append(profiles, reconciler.Profile{Name: p.Name, Namespace: p.Namespace, Source: src})
+	}
+	if len(cfg.watchProfiles) > 0 {
+		slog.Info("loaded watch profiles", "count", len(cfg.watchProfiles))
+	}
+	if len(cfg.consulIngressGateways) > 0 {
+		// The re-exported services are merged in like a profile's, after
+		// the services registered for consul-sync, which win on names.
+		ingress := consul.NewIngressWatcher(cfg.consulAddr, cfg.consulToken, cfg.consulIngressGateways, consul.Options{
+			PollInterval: cfg.pollInterval,
+			TokenFile:    cfg.consulTokenFile,
+			Login:        cfg.consulLogin,
+			Vault:        cfg.consulVault,
+			Headers:      cfg.consulHeaders,
+		})
+		if err := loadConsulTLS(ctx, k8sClient, ingress, cfg); err != nil {
+			slog.Error("failed to create service source", "profile", "ingress-gateways", "error", err)
+			os.Exit(1)
+		}
+		profiles = append(profiles, reconciler.Profile{Name: "ingress-gateways", Source: ingress})
+	}
+	if len(profiles) > 1 {
+		source = reconciler.WithProfiles(profiles)
+	}
+	recorder, stopRecorder := k8s.NewEventRecorder(k8sClient)
+	defer stopRecorder()
+	// Profiles sync into their own namespace, so the syncer manages those
+	// too.
+	namespaces := slices.Clone(cfg.allowedNamespaces)
+	for _, p := range cfg.watchProfiles {
+		if !slices.Contains(namespaces, p.Namespace) {
+			namespaces = append(namespaces, p.Namespace)
+		}
+	}
+	syncOpts := k8s.Options{
+		TenantClients:       tenantClients,
+		Recorder:            recorder,
+		ServiceOnly:         cfg.serviceOnly,
+		ExternalServices:    cfg.externalServices,
+		EndpointsMode:       cfg.endpointsMode,
+		SliceSuffix:         cfg.sliceSuffix,
+		SliceNaming:         cfg.sliceNaming,
+		Hostnames:           cfg.hostnames,
+		MaxDeletionsPerSync: cfg.maxDeletionsPerSync,
+		WriteRate:           cfg.writeRate,
+		WriteBurst:          cfg.writeBurst,
+		Names:               cfg.names,
+		DrainPeriod:         cfg.drainPeriod,
+		ServiceMode:         cfg.serviceMode,
+		LoadBalancer:        cfg.loadBalancer,
+		AnnotationPrefix:    cfg.metaAnnotationPrefix,
+		TagLabelPrefix:      cfg.tagLabelPrefix,
+		RouteLabelTags:      cfg.routeLabelTags,
+		NodeName:            cfg.nodeName,
+		Namespaces:          namespaces,
+		Adopt:               cfg.adopt,
+		AdoptFieldManagers:  cfg.adoptFieldManagers,
+		ConflictPolicies:    cfg.conflictPolicies,
+		FailureThreshold:    cfg.failureThreshold,
+		FailureBackoff:      cfg.failureBackoff,
+		MaxFailureBackoff:   cfg.maxFailureBackoff,
+		QuietBootstrap:      cfg.quietBootstrap,
+	}
+	syncer := k8s.NewSyncer(k8sClient, dynClient, cfg.targetNamespace, cfg.routeCfg, syncOpts)
+	if len(cfg.watchProfiles) > 0 {
+		syncer.SetRouteOverrides(reconciler.RouteOverrides(cfg.watchProfiles, nil))
+	}
+	if cfg.routeConfigSource != "" {
+		if err := loadRouteConfigSource(ctx, k8sClient, syncer, cfg); err != nil {
+			slog.Error("failed to load route config source", "error", err)
+			os.Exit(1)
+		}
+	}
+	if cfg.staticServicesFile != "" {
+		static, err := reconciler.LoadStaticServices(cfg.staticServicesFile)
+		if err != nil {
+			slog.Error("failed to load static services", "error", err)
+			os.Exit(1)
+		}
+		slog.Info("loaded static services", "count", len(static))
+		source = reconciler.WithStaticServices(source, static)
+	}
+	if cfg.hostnames == k8s.HostnamePolicyResolve {
+		resolver, err := dns.NewResolver(cfg.hostnameResolvers)
+		if err != nil {
+			slog.Error("failed to set up hostname resolution", "error", err)
+			os.Exit(1)
+		}
+		source = reconciler.WithResolvedHostnames(source, resolver.Lookup, cfg.hostnameRefresh)
+	}
+	if audit {
+		os.Exit(runAudit(ctx, flag.Args()[1:], source, syncer))
+	}
+
+	metrics.SetBuildInfo(version, commit)
+	healthSrv := health.NewServer(cfg.metricsAddr, version, commit, health.Options{
+		ReadyFile:  cfg.readyFile,
+		HealthAddr: cfg.healthAddr,
+	})
+	if watcher != nil {
+		healthSrv.Handle("GET /debug/consul", watcher)
+	}
+	var monitor *k8s.RouteStatusMonitor
+	if cfg.routeCfg.Enabled && cfg.monitorRouteStatus && !cfg.auditOnly {
+		monitor = k8s.NewRouteStatusMonitor(k8sClient, dynClient, cfg.targetNamespace, recorder)
+		healthSrv.Handle("GET /debug/httproutes", monitor)
+	}
+	rec := reconciler.New(source, reconciler.NewKubernetesSink("kubernetes", syncer), healthSrv, cfg.resyncInterval)
+	healthSrv.Handle("GET /status", rec)
+	rec.SetAuditOnly(cfg.auditOnly)
+	if cfg.healthyResync != nil {
+		rec.SetHealthyResync(*cfg.healthyResync)
+	}
+	if cfg.heartbeatLease != "" {
+		rec.SetHeartbeat(newHeartbeat(k8sClient, cfg))
+	}
+	if err := addSinks(rec, cfg, syncOpts); err != nil {
+		slog.Error("failed to create sinks", "error", err)
+		os.Exit(1)
+	}
 
 	// Start health/metrics server
 	go func() {
@@ -82,12 +352,77 @@ func main() {
 		}
 	}()
 
-	// Run reconciler (blocks until context cancelled)
-	if err := rec.Run(ctx); err != nil && ctx.Err() == nil {
+	// lead runs everything that writes to the cluster or acts on it, so with
+	// leader election only the leading replica does. On shutdown the manager
+	// may give up waiting for it before it returns, so it records that it
+	// started and closes leadDone once it has returned.
+	var led atomic.Bool
+	leadDone := make(chan struct{})
+	lead := func(ctx context.Context) error {
+		led.Store(true)
+		defer close(leadDone)
+		if monitor != nil {
+			go monitor.Run(ctx)
+		}
+		if cfg.driftDetection && !cfg.auditOnly {
+			go syncer.WatchDrift(ctx, rec.TriggerSync)
+		}
+		if cfg.routeCfg.Enabled && cfg.probe.Interval > 0 && !cfg.auditOnly {
+			go probe.New(cfg.probe, dynClient, cfg.targetNamespace).Run(ctx)
+		}
+
+		if cfg.admin.Addr != "" {
+			adminSrv, err := admin.NewServer(cfg.admin, rec)
+			if err != nil {
+				return fmt.Errorf("creating admin server: %w", err)
+			}
+			go func() {
+				if err := adminSrv.ListenAndServe(); err != nil {
+					slog.Error("admin server error", "error", err)
+					cancel()
+				}
+			}()
+			defer adminSrv.Stop()
+		}
+
+		if cfg.backup.Bucket != "" {
+			b, err := backup.New(cfg.backup, version, cfg.targetNamespace, rec, syncer)
+			if err != nil {
+				return fmt.Errorf("creating state backup: %w", err)
+			}
+			go b.Run(ctx)
+		}
+
+		// Run reconciler (blocks until context cancelled)
+		if err := rec.Run(ctx); err != nil && ctx.Err() == nil {
+			return fmt.Errorf("running reconciler: %w", err)
+		}
+		return nil
+	}
+	if err := runLeader(ctx, restCfg, cfg, syncer, lead); err != nil {
 		slog.Error("reconciler failed", "error", err)
 		os.Exit(1)
 	}
 
+	if cfg.cleanupOnExit && led.Load() {
+		// The signal context is done by now; the pass gets its own deadline,
+		// within the pod's termination grace period. It waits for the
+		// reconciler to stop, as the syncer isn't safe for concurrent use.
+		cleanupCtx, cleanupCancel := context.WithTimeout(context.Background(), cfg.cleanupTimeout)
+		select {
+		case <-leadDone:
+			deleted, err := syncer.Uninstall(cleanupCtx)
+			if err != nil {
+				slog.Error("failed to delete managed resources on exit", "deleted", deleted, "error", err)
+			} else {
+				slog.Info("deleted managed resources on exit", "deleted", deleted)
+			}
+		case <-cleanupCtx.Done():
+			slog.Error("reconciler did not stop in time, managed resources left in place", "timeout", cfg.cleanupTimeout)
+		}
+		cleanupCancel()
+	}
+
 	// Gracefully shut down the health server
 	shutdownCtx, shutdownCancel := context.WithTimeout(context.Background(), 5*time.Second)
 	defer shutdownCancel()
@@ -102,10 +437,201 @@ type config struct {
 	consulAddr      string
 	consulToken     string
 	consulTag       string
+	consulFilter    string // filter expression selecting services, see consul.Options.Filter
+	skipServices    []string
+	skipKinds       []string // service kinds never synced, see consul.Options.SkipKinds
+	consulTLSSource string
+	consulTLSCAKey  string
 	targetNamespace string
 	metricsAddr     string
+	readyFile       string
 	resyncInterval  time.Duration
 	routeCfg        k8s.HTTPRouteConfig
+	admin           admin.Config
+	adminPlaintext  bool
+
+	backup backup.Config
+
+	// probe configures synthetic requests to generated hostnames. A zero
+	// Interval disables probing.
+	probe probe.Config
+
+	watchMode    consul.WatchMode
+	pollInterval time.Duration
+
+	// source is "consul" or "nomad".
+	source         string
+	runMode        string
+	nodeName       string
+	nomadAddr      string
+	nomadToken     string
+	nomadNamespace string
+
+	// faults enables Consul fault injection for staging chaos tests.
+	faults *consul.FaultConfig
+
+	// routeConfigSource names the ConfigMap with per-namespace HTTPRoute
+	// overrides, as [namespace/]name.
+	routeConfigSource string
+
+	// staticServicesFile is a YAML file of services synced regardless of
+	// the catalog.
+	staticServicesFile string
+
+	// auditOnly reports discrepancies between Consul and the cluster
+	// instead of syncing.
+	auditOnly bool
+
+	// monitorRouteStatus watches generated HTTPRoutes for conditions the
+	// gateway reports as not True.
+	monitorRouteStatus bool
+
+	// heartbeatLease names the Lease renewed after each successful
+	// reconcile, as [namespace/]name.
+	heartbeatLease string
+
+	// tenantServiceAccounts maps a namespace to the ServiceAccount impersonated
+	// when writing into it.
+	tenantServiceAccounts map[string]string
+
+	// kubeAPIServer, when set, is the API server to manage instead of the
+	// in-cluster or kubeconfig one, trusted with kubeCAFile (or the system
+	// roots) and authenticated with the bearer token in kubeTokenFile.
+	kubeAPIServer     string
+	kubeCAFile        string
+	kubeTokenFile     string
+	kubeTLSServerName string
+
+	maxDeletionsPerSync int
+	failureThreshold    int
+	failureBackoff      time.Duration
+	maxFailureBackoff   time.Duration
+	quietBootstrap      bool
+	serviceOnly         bool
+	endpointsMode       k8s.EndpointsMode
+	sliceSuffix         string
+	sliceNaming         k8s.SliceNaming
+	hostnames           k8s.HostnamePolicy
+	hostnameResolvers   []string
+	hostnameRefresh     time.Duration
+	names               k8s.NameSanitizer
+	drainPeriod         time.Duration
+	serviceMode         k8s.ServiceMode
+	loadBalancer        k8s.LoadBalancerConfig
+	conflictPolicies    k8s.ConflictPolicies
+
+	// writeRate and writeBurst pace Kubernetes writes, see
+	// k8s.Options.WriteRate.
+	writeRate  float64
+	writeBurst int
+
+	// metaAnnotationPrefix selects the service meta copied as annotations,
+	// see k8s.Options.AnnotationPrefix.
+	metaAnnotationPrefix string
+
+	// tagLabelPrefix labels Services with their Consul tags, see
+	// k8s.Options.TagLabelPrefix, and routeLabelTags the HTTPRoutes with
+	// the listed tags.
+	tagLabelPrefix string
+	routeLabelTags []string
+
+	// cleanupOnExit deletes every managed object on shutdown, within
+	// cleanupTimeout.
+	cleanupOnExit  bool
+	cleanupTimeout time.Duration
+
+	// adopt force-applies every object to take over objects previously
+	// managed by another tool, dropping adoptFieldManagers from them.
+	adopt              bool
+	adoptFieldManagers []string
+
+	// allowedNamespaces are the namespaces services may be placed in with
+	// k8s-namespace meta, besides targetNamespace.
+	allowedNamespaces []string
+
+	// watchProfiles are extra tags watched alongside consulTag, each synced
+	// into its own namespace.
+	watchProfiles []reconciler.WatchProfile
+
+	// leaderElection runs the reconciler only on the replica holding the
+	// leaderElectionID Lease in leaderElectionNamespace.
+	leaderElection          bool
+	leaderElectionID        string
+	leaderElectionNamespace string
+
+	// consulTLSFiles are PEM files with the Consul CA and client certificate,
+	// an alternative to consulTLSSource.
+	consulTLSFiles      consul.TLSFiles
+	consulTLSServerName string
+	consulTLSSkipVerify bool
+
+	// consulHeaders are sent with every Consul request, see
+	// consul.Options.Headers.
+	consulHeaders http.Header
+
+	// consulTokenFile holds the Consul ACL token, re-read as it is rotated.
+	// consulToken is its contents at startup.
+	consulTokenFile string
+
+	// consulLogin, when set, logs in to Consul with an auth method instead
+	// of using consulToken.
+	consulLogin *consul.LoginConfig
+
+	// healthAddr, when set, serves the health probes apart from metricsAddr.
+	healthAddr string
+
+	// consulVault, when set, reads Consul tokens from Vault instead of
+	// using consulToken.
+	consulVault *consul.VaultConfig
+
+	// consulDatacenters lists the Consul datacenters watched, empty for the
+	// agent's own.
+	consulDatacenters []string
+
+	// consulNamespace is the Consul Enterprise namespace watched, or
+	// consul.AllNamespaces for every namespace.
+	consulNamespace string
+
+	// consulPeering also syncs the services imported from cluster peers,
+	// named with consulPeerNameFormat.
+	consulPeering        bool
+	consulPeerNameFormat string
+
+	// consulStrict, when set, rejects catalog and health responses failing
+	// sanity checks.
+	consulStrict *consul.StrictConfig
+
+	// sinkKubeconfig, sinkFile and sinkWebhook configure the sinks mirroring
+	// the services elsewhere, see addSinks.
+	sinkKubeconfig string
+	sinkFile       string
+	sinkWebhook    reconciler.WebhookConfig
+
+	// consulStale and consulCache relax the consistency of catalog and
+	// health queries to take load off the Consul servers.
+	consulStale       bool
+	consulCache       bool
+	consulCacheMaxAge time.Duration
+	// consulStreaming sends health queries so the agent answers them from
+	// its streaming backend.
+	consulStreaming bool
+	// consulPreparedQueries replaces the catalog with the services returned
+	// by these prepared queries, see consul.QueryWatcher.
+	consulPreparedQueries []string
+	// consulIngressGateways re-exports the services linked to these
+	// ingress gateways, see consul.IngressWatcher.
+	consulIngressGateways []string
+
+	// externalServices leaves Services to another controller, see
+	// ENABLE_SERVICES.
+	externalServices bool
+
+	// driftDetection watches the managed objects and resyncs when they are
+	// changed or deleted outside consul-sync.
+	driftDetection bool
+	// healthyResync, when set, replaces resyncInterval while the Consul
+	// watch is healthy; zero skips scheduled resyncs then.
+	healthyResync *time.Duration
 }
 
 func loadConfig() config {
@@ -115,22 +641,128 @@ func loadConfig() config {
 		consulAddr:      os.Getenv("CONSUL_ADDR"),
 		consulToken:     os.Getenv("CONSUL_TOKEN"),
 		consulTag:       envOrDefault("CONSUL_TAG", "kubernetes"),
+		skipServices:    splitList(envOrDefault("SKIP_SERVICES", "consul")),
+		consulTLSSource: os.Getenv("CONSUL_TLS_SOURCE"),
+		consulTLSCAKey:  envOrDefault("CONSUL_TLS_CA_KEY", "ca.crt"),
 		targetNamespace: targetNamespace,
 		metricsAddr:     envOrDefault("METRICS_ADDR", ":8080"),
+		readyFile:       os.Getenv("READY_FILE"),
+		healthAddr:      os.Getenv("HEALTH_ADDR"),
 		routeCfg: k8s.HTTPRouteConfig{
 			Enabled:          strings.ToLower(envOrDefault("ENABLE_HTTPROUTES", "true")) == "true",
 			DomainSuffix:     envOrDefault("DOMAIN_SUFFIX", "k8s.alexieff.io"),
 			InternalGateway:  envOrDefault("INTERNAL_GATEWAY", "envoy-internal"),
 			ExternalGateway:  envOrDefault("EXTERNAL_GATEWAY", "envoy-external"),
 			GatewayNamespace: envOrDefault("GATEWAY_NAMESPACE", targetNamespace),
-			GatewayListener:  envOrDefault("GATEWAY_LISTENER", "https"),
+			GatewayListeners: k8s.ParseListeners(envOrDefault("GATEWAY_LISTENER", "https")),
 			InternalTag:      envOrDefault("INTERNAL_TAG", "internal"),
 			ExternalTag:      envOrDefault("EXTERNAL_TAG", "external"),
 		},
 	}
 
-	if cfg.consulAddr == "" {
-		fmt.Fprintln(os.Stderr, "CONSUL_ADDR is required")
+	// An explicitly empty CONSUL_TAG disables tag filtering and syncs the
+	// whole catalog, so it can't go through envOrDefault.
+	// CONSUL_FILTER replaces the default tag unless CONSUL_TAG is set too.
+	cfg.consulFilter = os.Getenv("CONSUL_FILTER")
+	if cfg.consulFilter != "" {
+		cfg.consulTag = ""
+	}
+	if tag, ok := os.LookupEnv("CONSUL_TAG"); ok {
+		cfg.consulTag = tag
+	}
+	// An empty CONSUL_SKIP_KINDS syncs services of every kind.
+	cfg.skipKinds = consul.DefaultSkipKinds
+	if kinds, ok := os.LookupEnv("CONSUL_SKIP_KINDS"); ok {
+		cfg.skipKinds = splitList(kinds)
+	}
+
+	cfg.consulTLSFiles = consul.TLSFiles{
+		CACert:     os.Getenv("CONSUL_CACERT"),
+		ClientCert: os.Getenv("CONSUL_CLIENT_CERT"),
+		ClientKey:  os.Getenv("CONSUL_CLIENT_KEY"),
+	}
+	if err := cfg.consulTLSFiles.Validate(); err != nil {
+		fmt.Fprintf(os.Stderr, "invalid CONSUL_CLIENT_CERT/CONSUL_CLIENT_KEY: %v\n", err)
+		os.Exit(1)
+	}
+	if cfg.consulTLSSource != "" && !cfg.consulTLSFiles.IsZero() {
+		fmt.Fprintln(os.Stderr, "CONSUL_TLS_SOURCE and CONSUL_CACERT/CONSUL_CLIENT_CERT are mutually exclusive")
+		os.Exit(1)
+	}
+	cfg.consulTokenFile = os.Getenv("CONSUL_TOKEN_FILE")
+	if cfg.consulTokenFile != "" {
+		if cfg.consulToken != "" {
+			fmt.Fprintln(os.Stderr, "CONSUL_TOKEN and CONSUL_TOKEN_FILE are mutually exclusive")
+			os.Exit(1)
+		}
+		token, err := consul.ReadTokenFile(cfg.consulTokenFile)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "invalid CONSUL_TOKEN_FILE: %v\n", err)
+			os.Exit(1)
+		}
+		cfg.consulToken = token
+	}
+	if method := os.Getenv("CONSUL_LOGIN_AUTH_METHOD"); method != "" {
+		if cfg.consulToken != "" {
+			fmt.Fprintln(os.Stderr, "CONSUL_LOGIN_AUTH_METHOD can't be combined with CONSUL_TOKEN or CONSUL_TOKEN_FILE")
+			os.Exit(1)
+		}
+		cfg.consulLogin = &consul.LoginConfig{
+			AuthMethod:      method,
+			BearerTokenFile: envOrDefault("CONSUL_LOGIN_BEARER_TOKEN_FILE", consul.DefaultBearerTokenFile),
+		}
+	}
+	if role := os.Getenv("CONSUL_VAULT_ROLE"); role != "" {
+		if cfg.consulToken != "" || cfg.consulLogin != nil {
+			fmt.Fprintln(os.Stderr, "CONSUL_VAULT_ROLE can't be combined with CONSUL_TOKEN, CONSUL_TOKEN_FILE or CONSUL_LOGIN_AUTH_METHOD")
+			os.Exit(1)
+		}
+		vault := &consul.VaultConfig{
+			Addr:      envOrDefault("VAULT_ADDR", "http://127.0.0.1:8200"),
+			Mount:     envOrDefault("CONSUL_VAULT_MOUNT", consul.DefaultVaultMount),
+			Role:      role,
+			Namespace: os.Getenv("VAULT_NAMESPACE"),
+			Token:     os.Getenv("VAULT_TOKEN"),
+			TokenFile: os.Getenv("VAULT_TOKEN_FILE"),
+		}
+		var err error
+		vault.TLS, err = consul.TLSFiles{CACert: os.Getenv("VAULT_CACERT")}.Load()
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "invalid VAULT_CACERT: %v\n", err)
+			os.Exit(1)
+		}
+		vault.TLS.ServerName = os.Getenv("VAULT_TLS_SERVER_NAME")
+		vault.TLS.InsecureSkipVerify = strings.ToLower(envOrDefault("VAULT_SKIP_VERIFY", "false")) == "true"
+		if err := vault.Validate(); err != nil {
+			fmt.Fprintf(os.Stderr, "invalid consul vault configuration: %v\n", err)
+			os.Exit(1)
+		}
+		cfg.consulVault = vault
+	}
+	if cfg.healthAddr != "" && cfg.healthAddr == cfg.metricsAddr {
+		fmt.Fprintln(os.Stderr, "HEALTH_ADDR must differ from METRICS_ADDR")
+		os.Exit(1)
+	}
+	cfg.consulTLSServerName = os.Getenv("CONSUL_TLS_SERVER_NAME")
+	cfg.consulTLSSkipVerify = strings.ToLower(envOrDefault("CONSUL_TLS_SKIP_VERIFY", "false")) == "true"
+
+	cfg.source = strings.ToLower(envOrDefault("SOURCE", "consul"))
+	cfg.nomadAddr = envOrDefault("NOMAD_ADDR", "http://127.0.0.1:4646")
+	cfg.nomadToken = os.Getenv("NOMAD_TOKEN")
+	cfg.nomadNamespace = envOrDefault("NOMAD_NAMESPACE", "default")
+	switch cfg.source {
+	case "consul":
+		if cfg.consulAddr == "" {
+			fmt.Fprintln(os.Stderr, "CONSUL_ADDR is required")
+			os.Exit(1)
+		}
+	case "nomad":
+		if cfg.consulTLSSource != "" || !cfg.consulTLSFiles.IsZero() || cfg.consulTLSServerName != "" || cfg.consulTLSSkipVerify || os.Getenv("FAULT_INJECTION") != "" {
+			fmt.Fprintln(os.Stderr, "CONSUL_TLS_SOURCE, CONSUL_CACERT, CONSUL_CLIENT_CERT, CONSUL_TLS_SERVER_NAME, CONSUL_TLS_SKIP_VERIFY and FAULT_INJECTION are only supported with SOURCE=consul")
+			os.Exit(1)
+		}
+	default:
+		fmt.Fprintf(os.Stderr, "invalid SOURCE %q: expected consul or nomad\n", cfg.source)
 		os.Exit(1)
 	}
 
@@ -142,6 +774,485 @@ func loadConfig() config {
 		os.Exit(1)
 	}
 
+	cfg.watchMode = consul.WatchMode(strings.ToLower(envOrDefault("CONSUL_WATCH_MODE", string(consul.WatchModeAuto))))
+	if cfg.watchMode != consul.WatchModeAuto && cfg.watchMode != consul.WatchModePoll && cfg.watchMode != consul.WatchModeService {
+		fmt.Fprintf(os.Stderr, "invalid CONSUL_WATCH_MODE %q: expected auto, poll or service\n", cfg.watchMode)
+		os.Exit(1)
+	}
+	pollStr := envOrDefault("CONSUL_POLL_INTERVAL", "30s")
+	cfg.pollInterval, err = time.ParseDuration(pollStr)
+	if err != nil || cfg.pollInterval <= 0 {
+		fmt.Fprintf(os.Stderr, "invalid CONSUL_POLL_INTERVAL %q: must be a positive duration\n", pollStr)
+		os.Exit(1)
+	}
+
+	maxDeletionsStr := envOrDefault("MAX_DELETIONS_PER_SYNC", "0")
+	cfg.maxDeletionsPerSync, err = strconv.Atoi(maxDeletionsStr)
+	if err != nil || cfg.maxDeletionsPerSync < 0 {
+		fmt.Fprintf(os.Stderr, "invalid MAX_DELETIONS_PER_SYNC %q: must be a non-negative integer\n", maxDeletionsStr)
+		os.Exit(1)
+	}
+
+	writeRateStr := envOrDefault("KUBE_WRITE_RATE", "0")
+	cfg.writeRate, err = strconv.ParseFloat(writeRateStr, 64)
+	if err != nil || cfg.writeRate < 0 {
+		fmt.Fprintf(os.Stderr, "invalid KUBE_WRITE_RATE %q: must be a non-negative number\n", writeRateStr)
+		os.Exit(1)
+	}
+	writeBurstStr := envOrDefault("KUBE_WRITE_BURST", strconv.Itoa(k8s.DefaultWriteBurst))
+	cfg.writeBurst, err = strconv.Atoi(writeBurstStr)
+	if err != nil || cfg.writeBurst <= 0 {
+		fmt.Fprintf(os.Stderr, "invalid KUBE_WRITE_BURST %q: must be a positive integer\n", writeBurstStr)
+		os.Exit(1)
+	}
+
+	thresholdStr := envOrDefault("SERVICE_FAILURE_THRESHOLD", "5")
+	cfg.failureThreshold, err = strconv.Atoi(thresholdStr)
+	if err != nil || cfg.failureThreshold < 0 {
+		fmt.Fprintf(os.Stderr, "invalid SERVICE_FAILURE_THRESHOLD %q: must be a non-negative integer\n", thresholdStr)
+		os.Exit(1)
+	}
+	backoffStr := envOrDefault("SERVICE_FAILURE_BACKOFF", "1m")
+	cfg.failureBackoff, err = time.ParseDuration(backoffStr)
+	if err != nil || cfg.failureBackoff <= 0 {
+		fmt.Fprintf(os.Stderr, "invalid SERVICE_FAILURE_BACKOFF %q: must be a positive duration\n", backoffStr)
+		os.Exit(1)
+	}
+	maxBackoffStr := envOrDefault("SERVICE_FAILURE_BACKOFF_MAX", "1h")
+	cfg.maxFailureBackoff, err = time.ParseDuration(maxBackoffStr)
+	if err != nil || cfg.maxFailureBackoff < cfg.failureBackoff {
+		fmt.Fprintf(os.Stderr, "invalid SERVICE_FAILURE_BACKOFF_MAX %q: must be a duration of at least SERVICE_FAILURE_BACKOFF\n", maxBackoffStr)
+		os.Exit(1)
+	}
+
+	cfg.admin = admin.Config{
+		Addr:     os.Getenv("ADMIN_GRPC_ADDR"),
+		Token:    os.Getenv("ADMIN_GRPC_TOKEN"),
+		CertFile: os.Getenv("ADMIN_GRPC_TLS_CERT"),
+		KeyFile:  os.Getenv("ADMIN_GRPC_TLS_KEY"),
+	}
+	if path := os.Getenv("ADMIN_GRPC_TOKEN_FILE"); path != "" {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "reading ADMIN_GRPC_TOKEN_FILE: %v\n", err)
+			os.Exit(1)
+		}
+		cfg.admin.Token = strings.TrimSpace(string(data))
+	}
+	if cfg.admin.Addr != "" && cfg.admin.Token == "" {
+		fmt.Fprintln(os.Stderr, "ADMIN_GRPC_TOKEN or ADMIN_GRPC_TOKEN_FILE is required when ADMIN_GRPC_ADDR is set")
+		os.Exit(1)
+	}
+	if (cfg.admin.CertFile == "") != (cfg.admin.KeyFile == "") {
+		fmt.Fprintln(os.Stderr, "ADMIN_GRPC_TLS_CERT and ADMIN_GRPC_TLS_KEY must be set together")
+		os.Exit(1)
+	}
+	// Without TLS the bearer token, and whoever holds it can Uninstall, is
+	// readable by anyone on the path.
+	cfg.adminPlaintext = strings.ToLower(envOrDefault("ADMIN_GRPC_PLAINTEXT", "false")) == "true"
+	if cfg.admin.Addr != "" && cfg.admin.CertFile == "" && !cfg.admin.Loopback() && !cfg.adminPlaintext {
+		fmt.Fprintf(os.Stderr, "ADMIN_GRPC_ADDR %q is not a loopback address: set ADMIN_GRPC_TLS_CERT and ADMIN_GRPC_TLS_KEY, or ADMIN_GRPC_PLAINTEXT=true to send the token unencrypted\n", cfg.admin.Addr)
+		os.Exit(1)
+	}
+
+	cfg.routeConfigSource = os.Getenv("ROUTE_CONFIG_SOURCE")
+	cfg.staticServicesFile = os.Getenv("STATIC_SERVICES_FILE")
+	cfg.heartbeatLease = os.Getenv("HEARTBEAT_LEASE")
+	cfg.auditOnly = strings.ToLower(envOrDefault("AUDIT_ONLY", "false")) == "true"
+	cfg.monitorRouteStatus = strings.ToLower(envOrDefault("MONITOR_HTTPROUTE_STATUS", "true")) == "true"
+	if strings.HasSuffix(cfg.heartbeatLease, "/") {
+		fmt.Fprintf(os.Stderr, "invalid HEARTBEAT_LEASE %q: missing name\n", cfg.heartbeatLease)
+		os.Exit(1)
+	}
+	// SERVICE_ONLY=true predates ENABLE_ENDPOINTS=false and is kept as an
+	// alias.
+	cfg.serviceOnly = strings.ToLower(envOrDefault("SERVICE_ONLY", "false")) == "true" ||
+		strings.ToLower(envOrDefault("ENABLE_ENDPOINTS", "true")) != "true"
+	cfg.externalServices = strings.ToLower(envOrDefault("ENABLE_SERVICES", "true")) != "true"
+	cfg.quietBootstrap = strings.ToLower(envOrDefault("QUIET_BOOTSTRAP", "true")) == "true"
+	if cfg.externalServices && cfg.serviceOnly && !cfg.routeCfg.Enabled {
+		fmt.Fprintln(os.Stderr, "ENABLE_SERVICES, ENABLE_ENDPOINTS and ENABLE_HTTPROUTES are all false: nothing to sync")
+		os.Exit(1)
+	}
+	cfg.endpointsMode, err = k8s.ParseEndpointsMode(strings.ToLower(os.Getenv("ENDPOINTS_MODE")))
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "invalid ENDPOINTS_MODE: %v\n", err)
+		os.Exit(1)
+	}
+	cfg.sliceSuffix = envOrDefault("ENDPOINTSLICE_SUFFIX", k8s.DefaultSliceSuffix)
+	if err := k8s.ValidateSliceSuffix(cfg.sliceSuffix); err != nil {
+		fmt.Fprintf(os.Stderr, "invalid ENDPOINTSLICE_SUFFIX: %v\n", err)
+		os.Exit(1)
+	}
+	cfg.sliceNaming, err = k8s.ParseSliceNaming(strings.ToLower(os.Getenv("ENDPOINTSLICE_NAMING")))
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "invalid ENDPOINTSLICE_NAMING: %v\n", err)
+		os.Exit(1)
+	}
+	cfg.hostnames, err = k8s.ParseHostnamePolicy(strings.ToLower(os.Getenv("HOSTNAME_ADDRESSES")))
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "invalid HOSTNAME_ADDRESSES: %v\n", err)
+		os.Exit(1)
+	}
+	cfg.hostnameResolvers = splitList(os.Getenv("HOSTNAME_RESOLVERS"))
+	hostnameRefreshStr := envOrDefault("HOSTNAME_RESOLVE_INTERVAL", "60s")
+	cfg.hostnameRefresh, err = time.ParseDuration(hostnameRefreshStr)
+	if err != nil || cfg.hostnameRefresh < 5*time.Second {
+		fmt.Fprintf(os.Stderr, "invalid HOSTNAME_RESOLVE_INTERVAL %q: must be a duration of at least 5s\n", hostnameRefreshStr)
+		os.Exit(1)
+	}
+
+	cfg.routeCfg.HostnameLayout, err = k8s.ParseHostnameLayout(strings.ToLower(os.Getenv("HOSTNAME_LAYOUT")))
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "invalid HOSTNAME_LAYOUT: %v\n", err)
+		os.Exit(1)
+	}
+	cfg.serviceMode, err = k8s.ParseServiceMode(strings.ToLower(os.Getenv("SERVICE_MODE")))
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "invalid SERVICE_MODE: %v\n", err)
+		os.Exit(1)
+	}
+	if cfg.serviceMode == k8s.ServiceModeExternalName {
+		// Only services registered with a DNS name can be one.
+		fmt.Fprintln(os.Stderr, "SERVICE_MODE=externalname is not supported, set k8s-service-mode=externalname meta on the services registered with a DNS name")
+		os.Exit(1)
+	}
+	cfg.loadBalancer.Class = os.Getenv("LOADBALANCER_CLASS")
+	cfg.loadBalancer.Annotations, err = parseKeyValues(os.Getenv("LOADBALANCER_ANNOTATIONS"))
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "invalid LOADBALANCER_ANNOTATIONS: %v\n", err)
+		os.Exit(1)
+	}
+	cfg.metaAnnotationPrefix = os.Getenv("META_ANNOTATION_PREFIX")
+	cfg.tagLabelPrefix = os.Getenv("TAG_LABEL_PREFIX")
+	if cfg.tagLabelPrefix != "" {
+		if err := k8s.ValidateTagLabelPrefix(cfg.tagLabelPrefix); err != nil {
+			fmt.Fprintf(os.Stderr, "invalid TAG_LABEL_PREFIX: %v\n", err)
+			os.Exit(1)
+		}
+	}
+	cfg.routeLabelTags = splitList(os.Getenv("ROUTE_LABEL_TAGS"))
+
+	drainPeriodStr := envOrDefault("ENDPOINT_DRAIN_PERIOD", "0s")
+	cfg.drainPeriod, err = time.ParseDuration(drainPeriodStr)
+	if err != nil || cfg.drainPeriod < 0 {
+		fmt.Fprintf(os.Stderr, "invalid ENDPOINT_DRAIN_PERIOD %q: must be a non-negative duration\n", drainPeriodStr)
+		os.Exit(1)
+	}
+
+	cfg.cleanupOnExit = strings.ToLower(envOrDefault("CLEANUP_ON_EXIT", "false")) == "true"
+	cleanupTimeoutStr := envOrDefault("CLEANUP_ON_EXIT_TIMEOUT", "20s")
+	cfg.cleanupTimeout, err = time.ParseDuration(cleanupTimeoutStr)
+	if err != nil || cfg.cleanupTimeout <= 0 {
+		fmt.Fprintf(os.Stderr, "invalid CLEANUP_ON_EXIT_TIMEOUT %q: must be a positive duration\n", cleanupTimeoutStr)
+		os.Exit(1)
+	}
+	if cfg.cleanupOnExit && cfg.auditOnly {
+		fmt.Fprintln(os.Stderr, "CLEANUP_ON_EXIT can't be combined with AUDIT_ONLY")
+		os.Exit(1)
+	}
+
+	cfg.conflictPolicies, err = k8s.ParseConflictPolicies(strings.ToLower(os.Getenv("CONFLICT_POLICY")))
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "invalid CONFLICT_POLICY: %v\n", err)
+		os.Exit(1)
+	}
+
+	cfg.adopt = strings.ToLower(envOrDefault("ADOPT_EXISTING", "false")) == "true"
+	cfg.adoptFieldManagers = splitList(os.Getenv("ADOPT_FIELD_MANAGERS"))
+	if len(cfg.adoptFieldManagers) > 0 && !cfg.adopt {
+		fmt.Fprintln(os.Stderr, "ADOPT_FIELD_MANAGERS requires ADOPT_EXISTING=true")
+		os.Exit(1)
+	}
+
+	cfg.runMode = strings.ToLower(envOrDefault("RUN_MODE", "central"))
+	cfg.nodeName = os.Getenv("NODE_NAME")
+	switch cfg.runMode {
+	case "central":
+		cfg.nodeName = ""
+	case "node":
+		switch {
+		case cfg.nodeName == "":
+			fmt.Fprintln(os.Stderr, "NODE_NAME is required when RUN_MODE=node")
+			os.Exit(1)
+		case cfg.source != "consul":
+			fmt.Fprintln(os.Stderr, "RUN_MODE=node is only supported with SOURCE=consul")
+			os.Exit(1)
+		case os.Getenv("FAULT_INJECTION") != "":
+			fmt.Fprintln(os.Stderr, "FAULT_INJECTION is not supported with RUN_MODE=node")
+			os.Exit(1)
+		case cfg.serviceOnly || cfg.endpointsMode != k8s.EndpointsModeSlices:
+			// Nodes tell which Services others still serve from their
+			// EndpointSlices, and can't share a single Endpoints object.
+			fmt.Fprintln(os.Stderr, "RUN_MODE=node requires ENDPOINTS_MODE=slices and SERVICE_ONLY=false")
+			os.Exit(1)
+		case cfg.serviceMode == k8s.ServiceModeExternalIPs:
+			fmt.Fprintln(os.Stderr, "SERVICE_MODE=externalips is not supported with RUN_MODE=node")
+			os.Exit(1)
+		case cfg.externalServices:
+			// Nodes share the Services they serve, and clean them up.
+			fmt.Fprintln(os.Stderr, "ENABLE_SERVICES=false is not supported with RUN_MODE=node")
+			os.Exit(1)
+		}
+	default:
+		fmt.Fprintf(os.Stderr, "invalid RUN_MODE %q: expected central or node\n", cfg.runMode)
+		os.Exit(1)
+	}
+
+	cfg.consulDatacenters = splitList(os.Getenv("CONSUL_DATACENTERS"))
+	cfg.consulHeaders, err = consul.ParseHeaders(os.Getenv("CONSUL_HEADERS"))
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "invalid CONSUL_HEADERS: %v\n", err)
+		os.Exit(1)
+	}
+	if len(cfg.consulHeaders) > 0 && cfg.source != "consul" {
+		fmt.Fprintln(os.Stderr, "CONSUL_HEADERS is only supported with SOURCE=consul")
+		os.Exit(1)
+	}
+	if len(cfg.consulDatacenters) > 0 && (cfg.source != "consul" || cfg.runMode == "node") {
+		// The local agent only knows its own datacenter's services.
+		fmt.Fprintln(os.Stderr, "CONSUL_DATACENTERS is only supported with SOURCE=consul and RUN_MODE=central")
+		os.Exit(1)
+	}
+	for i, dc := range cfg.consulDatacenters {
+		if slices.Contains(cfg.consulDatacenters[:i], dc) {
+			fmt.Fprintf(os.Stderr, "invalid CONSUL_DATACENTERS: %s is listed twice\n", dc)
+			os.Exit(1)
+		}
+	}
+
+	if cfg.consulFilter != "" && (cfg.source != "consul" || cfg.runMode == "node") {
+		// The agent and Nomad endpoints used don't take filter expressions.
+		fmt.Fprintln(os.Stderr, "CONSUL_FILTER is only supported with SOURCE=consul and RUN_MODE=central")
+		os.Exit(1)
+	}
+
+	cfg.consulNamespace = os.Getenv("CONSUL_NAMESPACE")
+	if cfg.consulNamespace != "" && (cfg.source != "consul" || cfg.runMode == "node") {
+		fmt.Fprintln(os.Stderr, "CONSUL_NAMESPACE is only supported with SOURCE=consul and RUN_MODE=central")
+		os.Exit(1)
+	}
+
+	cfg.consulPeering = strings.ToLower(envOrDefault("CONSUL_PEERING", "false")) == "true"
+	cfg.consulPeerNameFormat = envOrDefault("CONSUL_PEER_NAME_FORMAT", consul.DefaultPeerNameFormat)
+	if cfg.consulPeering && (cfg.source != "consul" || cfg.runMode == "node") {
+		fmt.Fprintln(os.Stderr, "CONSUL_PEERING is only supported with SOURCE=consul and RUN_MODE=central")
+		os.Exit(1)
+	}
+	if err := consul.ValidatePeerNameFormat(cfg.consulPeerNameFormat); err != nil {
+		fmt.Fprintf(os.Stderr, "invalid CONSUL_PEER_NAME_FORMAT: %v\n", err)
+		os.Exit(1)
+	}
+
+	if strings.ToLower(envOrDefault("CONSUL_STRICT", "false")) == "true" {
+		if cfg.source != "consul" || cfg.runMode == "node" {
+			fmt.Fprintln(os.Stderr, "CONSUL_STRICT is only supported with SOURCE=consul and RUN_MODE=central")
+			os.Exit(1)
+		}
+		maxStr := envOrDefault("CONSUL_STRICT_MAX_INSTANCES", strconv.Itoa(consul.DefaultStrictMaxInstances))
+		maxInstances, err := strconv.Atoi(maxStr)
+		if err != nil || maxInstances <= 0 {
+			fmt.Fprintf(os.Stderr, "invalid CONSUL_STRICT_MAX_INSTANCES %q: must be a positive integer\n", maxStr)
+			os.Exit(1)
+		}
+		cfg.consulStrict = &consul.StrictConfig{MaxInstances: maxInstances}
+	}
+
+	cfg.sinkKubeconfig = os.Getenv("SINK_KUBECONFIG")
+	cfg.sinkFile = os.Getenv("SINK_FILE")
+	cfg.sinkWebhook.URL = os.Getenv("SINK_WEBHOOK_URL")
+	cfg.sinkWebhook.TokenFile = os.Getenv("SINK_WEBHOOK_TOKEN_FILE")
+	if u := cfg.sinkWebhook.URL; u != "" && !strings.HasPrefix(u, "http://") && !strings.HasPrefix(u, "https://") {
+		fmt.Fprintf(os.Stderr, "invalid SINK_WEBHOOK_URL %q: must be an http or https URL\n", u)
+		os.Exit(1)
+	}
+	if (cfg.sinkKubeconfig != "" || cfg.sinkFile != "" || cfg.sinkWebhook.URL != "") && cfg.auditOnly {
+		fmt.Fprintln(os.Stderr, "SINK_KUBECONFIG, SINK_FILE and SINK_WEBHOOK_URL are not supported with AUDIT_ONLY")
+		os.Exit(1)
+	}
+
+	cfg.consulStale = strings.ToLower(envOrDefault("CONSUL_STALE", "false")) == "true"
+	cfg.consulCache = strings.ToLower(envOrDefault("CONSUL_CACHE", "false")) == "true"
+	if maxAgeStr := os.Getenv("CONSUL_CACHE_MAX_AGE"); maxAgeStr != "" {
+		cfg.consulCacheMaxAge, err = time.ParseDuration(maxAgeStr)
+		if err != nil || cfg.consulCacheMaxAge < time.Second {
+			fmt.Fprintf(os.Stderr, "invalid CONSUL_CACHE_MAX_AGE %q: must be a duration of at least 1s\n", maxAgeStr)
+			os.Exit(1)
+		}
+		if !cfg.consulCache {
+			fmt.Fprintln(os.Stderr, "CONSUL_CACHE_MAX_AGE requires CONSUL_CACHE=true")
+			os.Exit(1)
+		}
+	}
+	if (cfg.consulStale || cfg.consulCache) && (cfg.source != "consul" || cfg.runMode == "node") {
+		// The agent endpoints used are always answered locally.
+		fmt.Fprintln(os.Stderr, "CONSUL_STALE and CONSUL_CACHE are only supported with SOURCE=consul and RUN_MODE=central")
+		os.Exit(1)
+	}
+	cfg.consulStreaming = strings.ToLower(envOrDefault("CONSUL_STREAMING", "false")) == "true"
+	if cfg.consulStreaming && (cfg.source != "consul" || cfg.runMode == "node") {
+		fmt.Fprintln(os.Stderr, "CONSUL_STREAMING is only supported with SOURCE=consul and RUN_MODE=central")
+		os.Exit(1)
+	}
+
+	cfg.consulPreparedQueries = splitList(os.Getenv("CONSUL_PREPARED_QUERIES"))
+	if len(cfg.consulPreparedQueries) > 0 {
+		if cfg.source != "consul" || cfg.runMode == "node" {
+			fmt.Fprintln(os.Stderr, "CONSUL_PREPARED_QUERIES is only supported with SOURCE=consul and RUN_MODE=central")
+			os.Exit(1)
+		}
+		// The queries pick the services and instances themselves.
+		if len(cfg.consulDatacenters) > 0 || cfg.consulFilter != "" || cfg.consulNamespace != "" || cfg.consulPeering ||
+			cfg.consulStrict != nil || cfg.consulStale || cfg.consulCache || cfg.consulStreaming || cfg.watchMode == consul.WatchModeService {
+			fmt.Fprintln(os.Stderr, "CONSUL_PREPARED_QUERIES is not supported with CONSUL_DATACENTERS, CONSUL_FILTER, CONSUL_NAMESPACE, CONSUL_PEERING, CONSUL_STRICT, CONSUL_STALE, CONSUL_CACHE, CONSUL_STREAMING or CONSUL_WATCH_MODE=service")
+			os.Exit(1)
+		}
+		for i, q := range cfg.consulPreparedQueries {
+			if slices.Contains(cfg.consulPreparedQueries[:i], q) {
+				fmt.Fprintf(os.Stderr, "invalid CONSUL_PREPARED_QUERIES: %s is listed twice\n", q)
+				os.Exit(1)
+			}
+		}
+	}
+
+	cfg.consulIngressGateways = splitList(os.Getenv("CONSUL_INGRESS_GATEWAYS"))
+	if len(cfg.consulIngressGateways) > 0 && (cfg.source != "consul" || cfg.runMode == "node") {
+		fmt.Fprintln(os.Stderr, "CONSUL_INGRESS_GATEWAYS is only supported with SOURCE=consul and RUN_MODE=central")
+		os.Exit(1)
+	}
+	for i, gw := range cfg.consulIngressGateways {
+		if slices.Contains(cfg.consulIngressGateways[:i], gw) {
+			fmt.Fprintf(os.Stderr, "invalid CONSUL_INGRESS_GATEWAYS: %s is listed twice\n", gw)
+			os.Exit(1)
+		}
+	}
+
+	cfg.driftDetection = strings.ToLower(envOrDefault("DRIFT_DETECTION", "false")) == "true"
+	if cfg.driftDetection && cfg.runMode == "node" {
+		// Nodes delete their own EndpointSlices of Services others still
+		// serve, which would look like drift to every other node.
+		fmt.Fprintln(os.Stderr, "DRIFT_DETECTION is not supported with RUN_MODE=node")
+		os.Exit(1)
+	}
+	if healthyStr := os.Getenv("RESYNC_INTERVAL_HEALTHY"); healthyStr != "" {
+		d, err := time.ParseDuration(healthyStr)
+		if err != nil || d < 0 {
+			fmt.Fprintf(os.Stderr, "invalid RESYNC_INTERVAL_HEALTHY %q: must be a duration, 0 to skip scheduled resyncs\n", healthyStr)
+			os.Exit(1)
+		}
+		if !cfg.driftDetection {
+			// Nothing else would notice objects changed in the cluster.
+			fmt.Fprintln(os.Stderr, "RESYNC_INTERVAL_HEALTHY requires DRIFT_DETECTION=true")
+			os.Exit(1)
+		}
+		cfg.healthyResync = &d
+	}
+
+	cfg.names = k8s.NameSanitizer{
+		Replacement:    os.Getenv("NAME_REPLACEMENT"),
+		DotReplacement: os.Getenv("NAME_DOT_REPLACEMENT"),
+		Case:           k8s.CasePolicy(strings.ToLower(os.Getenv("NAME_CASE"))),
+		Truncate:       k8s.TruncateStrategy(strings.ToLower(os.Getenv("NAME_TRUNCATE"))),
+	}
+	if err := cfg.names.Validate(); err != nil {
+		fmt.Fprintf(os.Stderr, "invalid name sanitization settings: %v\n", err)
+		os.Exit(1)
+	}
+
+	probeIntervalStr := envOrDefault("PROBE_INTERVAL", "0s")
+	cfg.probe.Interval, err = time.ParseDuration(probeIntervalStr)
+	if err != nil || cfg.probe.Interval < 0 {
+		fmt.Fprintf(os.Stderr, "invalid PROBE_INTERVAL %q: must be a non-negative duration\n", probeIntervalStr)
+		os.Exit(1)
+	}
+	probeTimeoutStr := envOrDefault("PROBE_TIMEOUT", "5s")
+	cfg.probe.Timeout, err = time.ParseDuration(probeTimeoutStr)
+	if err != nil || cfg.probe.Timeout <= 0 {
+		fmt.Fprintf(os.Stderr, "invalid PROBE_TIMEOUT %q: must be a positive duration\n", probeTimeoutStr)
+		os.Exit(1)
+	}
+	cfg.probe.Path = envOrDefault("PROBE_PATH", "/")
+	cfg.probe.Scheme = strings.ToLower(envOrDefault("PROBE_SCHEME", "https"))
+	if cfg.probe.Scheme != "http" && cfg.probe.Scheme != "https" {
+		fmt.Fprintf(os.Stderr, "invalid PROBE_SCHEME %q: expected http or https\n", cfg.probe.Scheme)
+		os.Exit(1)
+	}
+	if !strings.HasPrefix(cfg.probe.Path, "/") {
+		fmt.Fprintf(os.Stderr, "invalid PROBE_PATH %q: must start with /\n", cfg.probe.Path)
+		os.Exit(1)
+	}
+	cfg.probe.InsecureSkipVerify = strings.ToLower(envOrDefault("PROBE_INSECURE_SKIP_VERIFY", "false")) == "true"
+	cfg.probe.GatewayAddrs, err = parseKeyValues(os.Getenv("PROBE_GATEWAY_ADDRS"))
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "invalid PROBE_GATEWAY_ADDRS: %v\n", err)
+		os.Exit(1)
+	}
+
+	cfg.backup = backup.Config{
+		Endpoint:     envOrDefault("BACKUP_S3_ENDPOINT", "https://s3.amazonaws.com"),
+		Region:       envOrDefault("BACKUP_S3_REGION", "us-east-1"),
+		Bucket:       os.Getenv("BACKUP_S3_BUCKET"),
+		Prefix:       os.Getenv("BACKUP_S3_PREFIX"),
+		AccessKey:    os.Getenv("AWS_ACCESS_KEY_ID"),
+		SecretKey:    os.Getenv("AWS_SECRET_ACCESS_KEY"),
+		SessionToken: os.Getenv("AWS_SESSION_TOKEN"),
+	}
+	backupIntervalStr := envOrDefault("BACKUP_INTERVAL", "1h")
+	cfg.backup.Interval, err = time.ParseDuration(backupIntervalStr)
+	if err != nil || cfg.backup.Interval <= 0 {
+		fmt.Fprintf(os.Stderr, "invalid BACKUP_INTERVAL %q: must be a positive duration\n", backupIntervalStr)
+		os.Exit(1)
+	}
+
+	if spec := os.Getenv("FAULT_INJECTION"); spec != "" {
+		faults, err := consul.ParseFaultConfig(spec)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "invalid FAULT_INJECTION: %v\n", err)
+			os.Exit(1)
+		}
+		cfg.faults = &faults
+	}
+
+	cfg.kubeAPIServer = os.Getenv("KUBE_API_SERVER")
+	cfg.kubeCAFile = os.Getenv("KUBE_CA_FILE")
+	cfg.kubeTokenFile = os.Getenv("KUBE_TOKEN_FILE")
+	cfg.kubeTLSServerName = os.Getenv("KUBE_TLS_SERVER_NAME")
+	if cfg.kubeAPIServer == "" && (cfg.kubeCAFile != "" || cfg.kubeTokenFile != "" || cfg.kubeTLSServerName != "") {
+		fmt.Fprintln(os.Stderr, "KUBE_CA_FILE, KUBE_TOKEN_FILE and KUBE_TLS_SERVER_NAME require KUBE_API_SERVER")
+		os.Exit(1)
+	}
+
+	cfg.allowedNamespaces = splitList(os.Getenv("ALLOWED_NAMESPACES"))
+	for _, ns := range cfg.allowedNamespaces {
+		if errs := validation.IsDNS1123Label(ns); len(errs) > 0 {
+			fmt.Fprintf(os.Stderr, "invalid ALLOWED_NAMESPACES entry %q: %s\n", ns, strings.Join(errs, ", "))
+			os.Exit(1)
+		}
+	}
+
+	if path := os.Getenv("WATCH_PROFILES_FILE"); path != "" {
+		cfg.watchProfiles, err = reconciler.LoadWatchProfiles(path, cfg.targetNamespace)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "invalid WATCH_PROFILES_FILE: %v\n", err)
+			os.Exit(1)
+		}
+		if len(cfg.consulPreparedQueries) > 0 {
+			// Profiles select services by tag, which prepared queries ignore.
+			fmt.Fprintln(os.Stderr, "WATCH_PROFILES_FILE is not supported with CONSUL_PREPARED_QUERIES")
+			os.Exit(1)
+		}
+	}
+
+	cfg.leaderElection = strings.ToLower(envOrDefault("LEADER_ELECTION", "false")) == "true"
+	cfg.leaderElectionID = envOrDefault("LEADER_ELECTION_ID", "consul-sync")
+	cfg.leaderElectionNamespace = envOrDefault("LEADER_ELECTION_NAMESPACE", cfg.targetNamespace)
+
+	cfg.tenantServiceAccounts, err = parseKeyValues(os.Getenv("TENANT_SERVICE_ACCOUNTS"))
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "invalid TENANT_SERVICE_ACCOUNTS: %v\n", err)
+		os.Exit(1)
+	}
+
 	return cfg
 }
 
@@ -152,7 +1263,226 @@ func envOrDefault(key, defaultVal string) string {
 	return defaultVal
 }
 
-func newKubernetesClients() (kubernetes.Interface, dynamic.Interface, error) {
+// vaultRole returns the role Consul tokens are read from Vault for, if any,
+// for logging without the Vault token.
+func vaultRole(vault *consul.VaultConfig) string {
+	if vault == nil {
+		return ""
+	}
+	return vault.Mount + "/" + vault.Role
+}
+
+// healthyResync returns the resync interval while the Consul watch is
+// healthy, if set, for logging.
+func healthyResync(d *time.Duration) string {
+	if d == nil {
+		return ""
+	}
+	return d.String()
+}
+
+// loadConsulTLS applies the configured TLS settings to the watcher, from the
+// CONSUL_TLS_SOURCE object or the CONSUL_CACERT and CONSUL_CLIENT_CERT/KEY
+// files, and keeps them updated as the material is rotated.
+func loadConsulTLS(ctx context.Context, client kubernetes.Interface, watcher interface{ SetTLS(consul.TLSConfig) error }, cfg config) error {
+	// The server name and verification settings apply to every reload.
+	setTLS := func(tlsCfg consul.TLSConfig) error {
+		tlsCfg.ServerName = cfg.consulTLSServerName
+		tlsCfg.InsecureSkipVerify = cfg.consulTLSSkipVerify
+		return watcher.SetTLS(tlsCfg)
+	}
+
+	switch {
+	case cfg.consulTLSSource != "":
+		if err := loadConsulTLSSource(ctx, client, setTLS, cfg); err != nil {
+			return fmt.Errorf("loading consul tls source: %w", err)
+		}
+	case !cfg.consulTLSFiles.IsZero():
+		tlsCfg, err := cfg.consulTLSFiles.Load()
+		if err != nil {
+			return fmt.Errorf("loading consul tls files: %w", err)
+		}
+		if err := setTLS(tlsCfg); err != nil {
+			return fmt.Errorf("applying consul tls files: %w", err)
+		}
+		go consul.WatchTLSFiles(ctx, cfg.consulTLSFiles, tlsCfg, setTLS)
+	case cfg.consulTLSServerName != "" || cfg.consulTLSSkipVerify:
+		if err := setTLS(consul.TLSConfig{}); err != nil {
+			return fmt.Errorf("applying consul tls settings: %w", err)
+		}
+	}
+	return nil
+}
+
+// loadConsulTLSSource applies the TLS material from the configured ConfigMap or
+// Secret with setTLS and keeps it updated as the object is rotated.
+func loadConsulTLSSource(ctx context.Context, client kubernetes.Interface, setTLS func(consul.TLSConfig) error, cfg config) error {
+	src, err := k8s.ParseTLSSource(cfg.consulTLSSource, cfg.targetNamespace, cfg.consulTLSCAKey)
+	if err != nil {
+		return fmt.Errorf("parsing CONSUL_TLS_SOURCE: %w", err)
+	}
+
+	tlsCfg, resourceVersion, err := k8s.LoadTLSSource(ctx, client, src)
+	if err != nil {
+		return err
+	}
+	if err := setTLS(tlsCfg); err != nil {
+		return fmt.Errorf("applying tls material from %s: %w", src, err)
+	}
+
+	go k8s.WatchTLSSource(ctx, client, src, resourceVersion, setTLS)
+	return nil
+}
+
+// loadRouteConfigSource applies the per-namespace HTTPRoute overrides from the
+// configured ConfigMap to the syncer and keeps them updated as it changes.
+func loadRouteConfigSource(ctx context.Context, client kubernetes.Interface, syncer *k8s.Syncer, cfg config) error {
+	src, err := k8s.ParseRouteConfigSource(cfg.routeConfigSource, cfg.targetNamespace)
+	if err != nil {
+		return fmt.Errorf("parsing ROUTE_CONFIG_SOURCE: %w", err)
+	}
+
+	overrides, resourceVersion, err := k8s.LoadRouteOverrides(ctx, client, src)
+	if err != nil {
+		return err
+	}
+	// Overrides from the ConfigMap take precedence over the watch profiles'
+	// route settings for the same namespace.
+	apply := func(overrides map[string]k8s.HTTPRouteOverride) {
+		syncer.SetRouteOverrides(reconciler.RouteOverrides(cfg.watchProfiles, overrides))
+	}
+	apply(overrides)
+
+	go k8s.WatchRouteOverrides(ctx, client, src, resourceVersion, apply)
+	return nil
+}
+
+// newSource returns the service source for tag, along with the Consul watcher
+// when blocking queries are used.
+func newSource(ctx context.Context, client kubernetes.Interface, cfg config, tag string) (reconciler.Source, *consul.Watcher, error) {
+	switch {
+	case cfg.source == "nomad":
+		return nomad.NewWatcher(cfg.nomadAddr, cfg.nomadToken, tag, nomad.Options{
+			Namespace:    cfg.nomadNamespace,
+			SkipServices: cfg.skipServices,
+		}), nil, nil
+	case cfg.runMode == "node":
+		agent := consul.NewAgentWatcher(cfg.consulAddr, cfg.consulToken, tag, consul.Options{
+			PollInterval: cfg.pollInterval,
+			SkipServices: cfg.skipServices,
+			SkipKinds:    cfg.skipKinds,
+			TokenFile:    cfg.consulTokenFile,
+			Login:        cfg.consulLogin,
+			Vault:        cfg.consulVault,
+			Headers:      cfg.consulHeaders,
+		})
+		if err := loadConsulTLS(ctx, client, agent, cfg); err != nil {
+			return nil, nil, err
+		}
+		return agent, nil, nil
+	case len(cfg.consulPreparedQueries) > 0:
+		queries := consul.NewQueryWatcher(cfg.consulAddr, cfg.consulToken, cfg.consulPreparedQueries, consul.Options{
+			PollInterval: cfg.pollInterval,
+			TokenFile:    cfg.consulTokenFile,
+			Login:        cfg.consulLogin,
+			Vault:        cfg.consulVault,
+			Headers:      cfg.consulHeaders,
+		})
+		if err := loadConsulTLS(ctx, client, queries, cfg); err != nil {
+			return nil, nil, err
+		}
+		return queries, nil, nil
+	default:
+		watcher := consul.NewWatcher(cfg.consulAddr, cfg.consulToken, tag, consul.Options{
+			WatchMode:      cfg.watchMode,
+			PollInterval:   cfg.pollInterval,
+			SkipServices:   cfg.skipServices,
+			SkipKinds:      cfg.skipKinds,
+			Filter:         cfg.consulFilter,
+			Datacenters:    cfg.consulDatacenters,
+			Namespace:      cfg.consulNamespace,
+			Peering:        cfg.consulPeering,
+			PeerNameFormat: cfg.consulPeerNameFormat,
+			Strict:         cfg.consulStrict,
+			Stale:          cfg.consulStale,
+			Cache:          cfg.consulCache,
+			CacheMaxAge:    cfg.consulCacheMaxAge,
+			Streaming:      cfg.consulStreaming,
+			TokenFile:      cfg.consulTokenFile,
+			Login:          cfg.consulLogin,
+			Vault:          cfg.consulVault,
+			Faults:         cfg.faults,
+			Headers:        cfg.consulHeaders,
+		})
+		if err := loadConsulTLS(ctx, client, watcher, cfg); err != nil {
+			return nil, nil, err
+		}
+		return watcher, watcher, nil
+	}
+}
+
+// splitList splits a comma-separated list, dropping empty entries.
+func splitList(s string) []string {
+	var out []string
+	for _, item := range strings.Split(s, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			out = append(out, item)
+		}
+	}
+	return out
+}
+
+// stringList is a flag.Value collecting repeated occurrences of a flag.
+type stringList []string
+
+func (l *stringList) String() string {
+	return strings.Join(*l, ",")
+}
+
+func (l *stringList) Set(v string) error {
+	*l = append(*l, v)
+	return nil
+}
+
+// parseKeyValues parses a comma-separated list of key=value pairs.
+func parseKeyValues(s string) (map[string]string, error) {
+	out := make(map[string]string)
+	for _, pair := range strings.Split(s, ",") {
+		pair = strings.TrimSpace(pair)
+		if pair == "" {
+			continue
+		}
+		k, v, ok := strings.Cut(pair, "=")
+		k, v = strings.TrimSpace(k), strings.TrimSpace(v)
+		if !ok || k == "" {
+			return nil, fmt.Errorf("expected key=value, got %q", pair)
+		}
+		out[k] = v
+	}
+	return out, nil
+}
+
+// newRESTConfig returns the explicitly configured API server connection if
+// KUBE_API_SERVER is set, otherwise the in-cluster or kubeconfig one.
+func newRESTConfig(c config) (*rest.Config, error) {
+	if c.kubeAPIServer != "" {
+		if c.kubeTokenFile != "" {
+			if _, err := os.Stat(c.kubeTokenFile); err != nil {
+				return nil, fmt.Errorf("reading KUBE_TOKEN_FILE: %w", err)
+			}
+		}
+		// The token file is reread periodically, so rotated tokens are
+		// picked up without a restart.
+		return &rest.Config{
+			Host:            c.kubeAPIServer,
+			BearerTokenFile: c.kubeTokenFile,
+			TLSClientConfig: rest.TLSClientConfig{
+				CAFile:     c.kubeCAFile,
+				ServerName: c.kubeTLSServerName,
+			},
+		}, nil
+	}
+
 	cfg, err := rest.InClusterConfig()
 	if err != nil {
 		// Fallback to kubeconfig for local development
@@ -162,10 +1492,13 @@ func newKubernetesClients() (kubernetes.Interface, dynamic.Interface, error) {
 		}
 		cfg, err = clientcmd.BuildConfigFromFlags("", kubeconfigPath)
 		if err != nil {
-			return nil, nil, fmt.Errorf("building kubeconfig: %w", err)
+			return nil, fmt.Errorf("building kubeconfig: %w", err)
 		}
 	}
+	return cfg, nil
+}
 
+func newKubernetesClients(cfg *rest.Config) (kubernetes.Interface, dynamic.Interface, error) {
 	k8sClient, err := kubernetes.NewForConfig(cfg)
 	if err != nil {
 		return nil, nil, fmt.Errorf("creating kubernetes client: %w", err)
@@ -178,3 +1511,45 @@ func newKubernetesClients() (kubernetes.Interface, dynamic.Interface, error) {
 
 	return k8sClient, dynClient, nil
 }
+
+// addSinks registers the sinks configured with SINK_* on rec. The secondary
+// cluster of SINK_KUBECONFIG is synced with the options of the primary one,
+// under the controller identity, without Events.
+func addSinks(rec *reconciler.Reconciler, cfg config, opts k8s.Options) error {
+	if cfg.sinkKubeconfig != "" {
+		restCfg, err := clientcmd.BuildConfigFromFlags("", cfg.sinkKubeconfig)
+		if err != nil {
+			return fmt.Errorf("building SINK_KUBECONFIG: %w", err)
+		}
+		client, dynClient, err := newKubernetesClients(restCfg)
+		if err != nil {
+			return err
+		}
+		opts.TenantClients, opts.Recorder = nil, nil
+		opts.Secondary = true
+		syncer := k8s.NewSyncer(client, dynClient, cfg.targetNamespace, cfg.routeCfg, opts)
+		rec.AddSink(reconciler.NewKubernetesSink("secondary", syncer))
+	}
+	if cfg.sinkFile != "" {
+		rec.AddSink(reconciler.NewFileSink(cfg.sinkFile))
+	}
+	if cfg.sinkWebhook.URL != "" {
+		rec.AddSink(reconciler.NewWebhookSink(cfg.sinkWebhook))
+	}
+	return nil
+}
+
+// newHeartbeat builds the heartbeat Lease from HEARTBEAT_LEASE. The holder is
+// the pod name, falling back to the hostname, and the Lease is considered
+// stale after two missed resyncs.
+func newHeartbeat(client kubernetes.Interface, cfg config) *k8s.Heartbeat {
+	ns, name, ok := strings.Cut(cfg.heartbeatLease, "/")
+	if !ok {
+		ns, name = cfg.targetNamespace, cfg.heartbeatLease
+	}
+	identity := os.Getenv("POD_NAME")
+	if identity == "" {
+		identity, _ = os.Hostname()
+	}
+	return k8s.NewHeartbeat(client, ns, name, identity, 2*cfg.resyncInterval)
+}
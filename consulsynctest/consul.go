@@ -0,0 +1,312 @@
+// Package consulsynctest provides helpers for exercising consul-sync without a
+// real Consul agent or Kubernetes API server: an in-memory Consul HTTP server
+// with blocking query semantics, and fake Kubernetes clients preconfigured for
+// the resources the Syncer manages.
+package consulsynctest
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Check statuses reported by the fake server.
+const (
+	StatusPassing  = "passing"
+	StatusWarning  = "warning"
+	StatusCritical = "critical"
+)
+
+// Instance is a single service instance registered in the fake Consul.
+type Instance struct {
+	ID          string
+	Node        string
+	NodeAddress string
+	Address     string
+	Port        int
+	Tags        []string
+	Meta        map[string]string
+	// Status is the aggregate check status. Empty means passing.
+	Status string
+}
+
+// Server is an in-memory fake of the Consul catalog and health HTTP APIs.
+// Every mutation bumps the index, waking any blocking queries in flight.
+type Server struct {
+	// Token, if set, must be presented in X-Consul-Token on every request.
+	Token string
+	// NoIndex, if set, leaves X-Consul-Index out of every response, the way
+	// some proxies in front of Consul do.
+	NoIndex bool
+
+	srv *httptest.Server
+
+	mu       sync.Mutex
+	index    uint64
+	changed  chan struct{}
+	services map[string][]Instance
+	requests map[string]int
+}
+
+// NewServer starts a fake Consul server. Callers must Close it when done.
+func NewServer() *Server {
+	s := &Server{
+		index:    1,
+		changed:  make(chan struct{}),
+		services: make(map[string][]Instance),
+		requests: make(map[string]int),
+	}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /v1/catalog/services", s.handleCatalogServices)
+	mux.HandleFunc("GET /v1/health/service/{name}", s.handleHealthService)
+	s.srv = httptest.NewServer(s.authorize(mux))
+	return s
+}
+
+// URL returns the base address to pass as CONSUL_ADDR.
+func (s *Server) URL() string {
+	return s.srv.URL
+}
+
+// Close shuts the server down, releasing any blocked queries.
+func (s *Server) Close() {
+	s.srv.CloseClientConnections()
+	s.srv.Close()
+}
+
+// Index returns the current Consul index.
+func (s *Server) Index() uint64 {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.index
+}
+
+// Requests returns how many requests were served for the given path prefix,
+// e.g. "/v1/health/service/".
+func (s *Server) Requests(prefix string) int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var n int
+	for path, count := range s.requests {
+		if strings.HasPrefix(path, prefix) {
+			n += count
+		}
+	}
+	return n
+}
+
+// Register adds or replaces an instance of the named service, keyed by ID.
+func (s *Server) Register(service string, inst Instance) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if inst.ID == "" {
+		inst.ID = service
+	}
+	instances := s.services[service]
+	i := slices.IndexFunc(instances, func(existing Instance) bool { return existing.ID == inst.ID })
+	if i >= 0 {
+		instances[i] = inst
+	} else {
+		instances = append(instances, inst)
+	}
+	s.services[service] = instances
+	s.bumpLocked()
+}
+
+// Deregister removes an instance by ID. The service disappears from the
+// catalog once its last instance is removed.
+func (s *Server) Deregister(service, id string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	instances := slices.DeleteFunc(s.services[service], func(inst Instance) bool { return inst.ID == id })
+	if len(instances) == 0 {
+		delete(s.services, service)
+	} else {
+		s.services[service] = instances
+	}
+	s.bumpLocked()
+}
+
+// SetStatus updates the aggregate check status of an instance.
+func (s *Server) SetStatus(service, id, status string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for i, inst := range s.services[service] {
+		if inst.ID == id {
+			s.services[service][i].Status = status
+		}
+	}
+	s.bumpLocked()
+}
+
+// bumpLocked advances the index and wakes blocking queries.
+func (s *Server) bumpLocked() {
+	s.index++
+	close(s.changed)
+	s.changed = make(chan struct{})
+}
+
+func (s *Server) authorize(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		s.mu.Lock()
+		s.requests[r.URL.Path]++
+		s.mu.Unlock()
+
+		if s.Token != "" && r.Header.Get("X-Consul-Token") != s.Token {
+			http.Error(w, "ACL not found", http.StatusForbidden)
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// block implements Consul's blocking query semantics: when the client's index
+// is at or past the current one, wait for a change or the wait time to elapse.
+func (s *Server) block(r *http.Request) {
+	index, _ := strconv.ParseUint(r.URL.Query().Get("index"), 10, 64)
+	if index == 0 {
+		return
+	}
+
+	wait := 5 * time.Minute
+	if d, err := time.ParseDuration(r.URL.Query().Get("wait")); err == nil && d > 0 {
+		wait = min(d, wait)
+	}
+
+	s.mu.Lock()
+	current, changed := s.index, s.changed
+	s.mu.Unlock()
+	if index < current {
+		return
+	}
+
+	timer := time.NewTimer(wait)
+	defer timer.Stop()
+	select {
+	case <-changed:
+	case <-timer.C:
+	case <-r.Context().Done():
+	}
+}
+
+func (s *Server) handleCatalogServices(w http.ResponseWriter, r *http.Request) {
+	s.block(r)
+	tag := r.URL.Query().Get("tag")
+
+	s.mu.Lock()
+	out := map[string][]string{}
+	if tag == "" {
+		// Consul's own service has no tags, so any tag filter drops it.
+		out["consul"] = []string{}
+	}
+	for name, instances := range s.services {
+		var tags []string
+		for _, inst := range instances {
+			for _, t := range inst.Tags {
+				if !slices.Contains(tags, t) {
+					tags = append(tags, t)
+				}
+			}
+		}
+		if tag != "" && !slices.Contains(tags, tag) {
+			continue
+		}
+		if tags == nil {
+			tags = []string{}
+		}
+		out[name] = tags
+	}
+	index := s.index
+	s.mu.Unlock()
+
+	s.writeJSON(w, index, out)
+}
+
+type healthEntry struct {
+	Node    healthNode    `json:"Node"`
+	Service healthService `json:"Service"`
+	Checks  []healthCheck `json:"Checks"`
+}
+
+type healthNode struct {
+	Node    string `json:"Node"`
+	Address string `json:"Address"`
+}
+
+type healthService struct {
+	ID      string            `json:"ID"`
+	Service string            `json:"Service"`
+	Address string            `json:"Address"`
+	Port    int               `json:"Port"`
+	Tags    []string          `json:"Tags"`
+	Meta    map[string]string `json:"Meta"`
+}
+
+type healthCheck struct {
+	Node      string `json:"Node"`
+	CheckID   string `json:"CheckID"`
+	Name      string `json:"Name"`
+	Status    string `json:"Status"`
+	ServiceID string `json:"ServiceID"`
+}
+
+func (s *Server) handleHealthService(w http.ResponseWriter, r *http.Request) {
+	s.block(r)
+	name := r.PathValue("name")
+	passingOnly := r.URL.Query().Has("passing") && r.URL.Query().Get("passing") != "false"
+
+	s.mu.Lock()
+	entries := []healthEntry{}
+	for _, inst := range s.services[name] {
+		status := inst.Status
+		if status == "" {
+			status = StatusPassing
+		}
+		if passingOnly && status != StatusPassing {
+			continue
+		}
+		node := inst.Node
+		if node == "" {
+			node = "node-" + inst.ID
+		}
+		entries = append(entries, healthEntry{
+			Node: healthNode{Node: node, Address: inst.NodeAddress},
+			Service: healthService{
+				ID:      inst.ID,
+				Service: name,
+				Address: inst.Address,
+				Port:    inst.Port,
+				Tags:    inst.Tags,
+				Meta:    inst.Meta,
+			},
+			Checks: []healthCheck{{
+				Node:      node,
+				CheckID:   "service:" + inst.ID,
+				Name:      "Service '" + name + "' check",
+				Status:    status,
+				ServiceID: inst.ID,
+			}},
+		})
+	}
+	index := s.index
+	s.mu.Unlock()
+
+	s.writeJSON(w, index, entries)
+}
+
+func (s *Server) writeJSON(w http.ResponseWriter, index uint64, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	if !s.NoIndex {
+		w.Header().Set("X-Consul-Index", strconv.FormatUint(index, 10))
+	}
+	json.NewEncoder(w).Encode(v)
+}
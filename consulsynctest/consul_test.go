@@ -0,0 +1,124 @@
+package consulsynctest_test
+
+import (
+	"encoding/json"
+	"maps"
+	"net/http"
+	"slices"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/alexieff-io/consul-sync/consulsynctest"
+)
+
+// get fetches path from srv and decodes its JSON body into v, returning the
+// X-Consul-Index header.
+func get(t *testing.T, srv *consulsynctest.Server, path string, v any) string {
+	t.Helper()
+	resp, err := http.Get(srv.URL() + path)
+	if err != nil {
+		t.Fatalf("GET %s: %v", path, err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("GET %s: status %d", path, resp.StatusCode)
+	}
+	if err := json.NewDecoder(resp.Body).Decode(v); err != nil {
+		t.Fatalf("decoding %s: %v", path, err)
+	}
+	return resp.Header.Get("X-Consul-Index")
+}
+
+func TestCatalogServicesFiltersByTag(t *testing.T) {
+	srv := consulsynctest.NewServer()
+	defer srv.Close()
+	srv.Register("web", consulsynctest.Instance{ID: "web-1", Tags: []string{"kubernetes"}})
+	srv.Register("db", consulsynctest.Instance{ID: "db-1", Tags: []string{"internal"}})
+
+	for _, tt := range []struct {
+		query string
+		want  []string
+	}{
+		{"", []string{"consul", "db", "web"}},
+		{"?tag=kubernetes", []string{"web"}},
+		{"?tag=internal", []string{"db"}},
+		{"?tag=missing", nil},
+	} {
+		var catalog map[string][]string
+		get(t, srv, "/v1/catalog/services"+tt.query, &catalog)
+		if got := slices.Sorted(maps.Keys(catalog)); !slices.Equal(got, tt.want) {
+			t.Errorf("services%s = %v, want %v", tt.query, got, tt.want)
+		}
+	}
+}
+
+func TestHealthServiceFiltersPassing(t *testing.T) {
+	srv := consulsynctest.NewServer()
+	defer srv.Close()
+	srv.Register("web", consulsynctest.Instance{ID: "web-1"})
+	srv.Register("web", consulsynctest.Instance{ID: "web-2", Status: consulsynctest.StatusWarning})
+	srv.Register("web", consulsynctest.Instance{ID: "web-3"})
+	srv.SetStatus("web", "web-3", consulsynctest.StatusCritical)
+
+	for _, tt := range []struct {
+		query string
+		want  []string
+	}{
+		{"", []string{"web-1", "web-2", "web-3"}},
+		{"?passing=true", []string{"web-1"}},
+		{"?passing", []string{"web-1"}},
+		{"?passing=false", []string{"web-1", "web-2", "web-3"}},
+	} {
+		var entries []struct {
+			Service struct{ ID string }
+		}
+		get(t, srv, "/v1/health/service/web"+tt.query, &entries)
+		var got []string
+		for _, e := range entries {
+			got = append(got, e.Service.ID)
+		}
+		slices.Sort(got)
+		if !slices.Equal(got, tt.want) {
+			t.Errorf("instances%s = %v, want %v", tt.query, got, tt.want)
+		}
+	}
+}
+
+func TestBlockingQueryWaitsForChange(t *testing.T) {
+	srv := consulsynctest.NewServer()
+	defer srv.Close()
+	srv.Register("web", consulsynctest.Instance{ID: "web-1"})
+	index := srv.Index()
+
+	// An index behind the current one answers at once.
+	var catalog map[string][]string
+	if got := get(t, srv, "/v1/catalog/services?index="+strconv.FormatUint(index-1, 10), &catalog); got != strconv.FormatUint(index, 10) {
+		t.Errorf("index = %s, want %d", got, index)
+	}
+
+	done := make(chan string)
+	go func() {
+		resp, err := http.Get(srv.URL() + "/v1/catalog/services?wait=5s&index=" + strconv.FormatUint(index, 10))
+		if err != nil {
+			done <- err.Error()
+			return
+		}
+		resp.Body.Close()
+		done <- resp.Header.Get("X-Consul-Index")
+	}()
+	select {
+	case <-done:
+		t.Fatal("blocking query answered before any change")
+	case <-time.After(100 * time.Millisecond):
+	}
+	srv.Register("api", consulsynctest.Instance{ID: "api-1"})
+	select {
+	case got := <-done:
+		if got != strconv.FormatUint(index+1, 10) {
+			t.Errorf("index after change = %s, want %d", got, index+1)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("blocking query not woken by the change")
+	}
+}
@@ -0,0 +1,83 @@
+package consulsynctest
+
+import (
+	"encoding/json"
+	"fmt"
+
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/types"
+	dynamicfake "k8s.io/client-go/dynamic/fake"
+	"k8s.io/client-go/kubernetes/fake"
+	k8stesting "k8s.io/client-go/testing"
+)
+
+// Gateway API resources the Syncer reads or writes through the dynamic client.
+var (
+	HTTPRouteGVR = schema.GroupVersionResource{Group: "gateway.networking.k8s.io", Version: "v1", Resource: "httproutes"}
+	GatewayGVR   = schema.GroupVersionResource{Group: "gateway.networking.k8s.io", Version: "v1", Resource: "gateways"}
+)
+
+// NewFakeClients returns a typed clientset with server-side apply support and
+// a dynamic client that knows about the Gateway API kinds. The objects are
+// split between the two by whether they are unstructured.
+func NewFakeClients(objects ...runtime.Object) (*fake.Clientset, *dynamicfake.FakeDynamicClient) {
+	var typed, dynamic []runtime.Object
+	for _, obj := range objects {
+		if _, ok := obj.(*unstructured.Unstructured); ok {
+			dynamic = append(dynamic, obj)
+		} else {
+			typed = append(typed, obj)
+		}
+	}
+
+	client := fake.NewClientset(typed...)
+	dynClient := dynamicfake.NewSimpleDynamicClientWithCustomListKinds(runtime.NewScheme(),
+		map[schema.GroupVersionResource]string{
+			HTTPRouteGVR: "HTTPRouteList",
+			GatewayGVR:   "GatewayList",
+		},
+		dynamic...,
+	)
+	dynClient.PrependReactor("patch", "*", applyReactor(dynClient.Tracker()))
+	return client, dynClient
+}
+
+// applyReactor handles server-side apply for the dynamic fake, which otherwise
+// refuses to apply objects that don't exist yet. The applied object replaces
+// the stored one wholesale; there is no field ownership tracking.
+func applyReactor(tracker k8stesting.ObjectTracker) k8stesting.ReactionFunc {
+	return func(action k8stesting.Action) (bool, runtime.Object, error) {
+		patch, ok := action.(k8stesting.PatchAction)
+		if !ok || patch.GetPatchType() != types.ApplyPatchType {
+			return false, nil, nil
+		}
+
+		obj := &unstructured.Unstructured{}
+		if err := json.Unmarshal(patch.GetPatch(), &obj.Object); err != nil {
+			return true, nil, fmt.Errorf("decoding apply patch: %w", err)
+		}
+		gvr, ns := patch.GetResource(), patch.GetNamespace()
+
+		existing, err := tracker.Get(gvr, ns, patch.GetName())
+		switch {
+		case apierrors.IsNotFound(err):
+			err = tracker.Create(gvr, obj, ns)
+		case err != nil:
+			return true, nil, err
+		default:
+			if status, ok := existing.(*unstructured.Unstructured).Object["status"]; ok {
+				obj.Object["status"] = status
+			}
+			err = tracker.Update(gvr, obj, ns)
+		}
+		if err != nil {
+			return true, nil, err
+		}
+		stored, err := tracker.Get(gvr, ns, patch.GetName(), metav1.GetOptions{})
+		return true, stored, err
+	}
+}
@@ -0,0 +1,225 @@
+// Package admin exposes an authenticated gRPC API for driving the controller
+// programmatically.
+//
+// The service is registered by hand using protobuf well-known types rather
+// than generated stubs, so any gRPC client can call it without a .proto file:
+//
+//	consulsync.admin.v1.Admin/GetStatus   google.protobuf.Empty       → google.protobuf.Struct
+//	consulsync.admin.v1.Admin/TriggerSync google.protobuf.Empty       → google.protobuf.Empty
+//	consulsync.admin.v1.Admin/Pause       google.protobuf.Empty       → google.protobuf.Empty
+//	consulsync.admin.v1.Admin/Resume      google.protobuf.Empty       → google.protobuf.Empty
+//	consulsync.admin.v1.Admin/GetService  google.protobuf.StringValue → google.protobuf.Struct
+//	consulsync.admin.v1.Admin/Uninstall   google.protobuf.Empty       → google.protobuf.Empty
+//
+// Every call must carry "authorization: Bearer <token>" metadata.
+package admin
+
+import (
+	"context"
+	"crypto/subtle"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net"
+	"net/netip"
+	"strings"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/known/emptypb"
+	"google.golang.org/protobuf/types/known/structpb"
+	"google.golang.org/protobuf/types/known/wrapperspb"
+
+	"github.com/alexieff-io/consul-sync/internal/reconciler"
+)
+
+const serviceName = "consulsync.admin.v1.Admin"
+
+// Config holds the admin API listener settings.
+type Config struct {
+	Addr     string
+	Token    string
+	CertFile string
+	KeyFile  string
+}
+
+// Loopback reports whether Addr only accepts connections from this host, so
+// calls served without TLS never cross the network. An address without a
+// host listens on every interface.
+func (c Config) Loopback() bool {
+	host, _, err := net.SplitHostPort(c.Addr)
+	if err != nil || host == "" {
+		return false
+	}
+	if host == "localhost" {
+		return true
+	}
+	ip, err := netip.ParseAddr(host)
+	return err == nil && ip.IsLoopback()
+}
+
+// Server serves the admin gRPC API.
+type Server struct {
+	cfg        Config
+	reconciler *reconciler.Reconciler
+	grpc       *grpc.Server
+}
+
+// NewServer creates a new admin API server backed by the given reconciler.
+func NewServer(cfg Config, rec *reconciler.Reconciler) (*Server, error) {
+	if cfg.Token == "" {
+		return nil, fmt.Errorf("admin API requires a token")
+	}
+
+	opts := []grpc.ServerOption{grpc.UnaryInterceptor(authInterceptor(cfg.Token))}
+	if cfg.CertFile != "" {
+		creds, err := credentials.NewServerTLSFromFile(cfg.CertFile, cfg.KeyFile)
+		if err != nil {
+			return nil, fmt.Errorf("loading admin TLS certificate: %w", err)
+		}
+		opts = append(opts, grpc.Creds(creds))
+	}
+
+	s := &Server{cfg: cfg, reconciler: rec, grpc: grpc.NewServer(opts...)}
+	s.grpc.RegisterService(&serviceDesc, s)
+	return s, nil
+}
+
+// ListenAndServe starts serving the admin API. It blocks until Stop is called.
+func (s *Server) ListenAndServe() error {
+	lis, err := net.Listen("tcp", s.cfg.Addr)
+	if err != nil {
+		return fmt.Errorf("listening on %s: %w", s.cfg.Addr, err)
+	}
+	return s.grpc.Serve(lis)
+}
+
+// Stop gracefully stops the server.
+func (s *Server) Stop() {
+	s.grpc.GracefulStop()
+}
+
+func (s *Server) getStatus(_ context.Context, _ *emptypb.Empty) (*structpb.Struct, error) {
+	st := s.reconciler.Status()
+	var last string
+	if !st.LastReconcile.IsZero() {
+		last = st.LastReconcile.UTC().Format(time.RFC3339)
+	}
+	return toStruct(map[string]any{
+		"paused":          st.Paused,
+		"reconciles":      st.Reconciles,
+		"lastReconcile":   last,
+		"lastReconcileId": st.LastReconcileID,
+		"lastTrigger":     st.LastTrigger,
+		"lastError":       st.LastError,
+		"services":        st.Services,
+		"appliedIndex":    st.AppliedIndex,
+	})
+}
+
+func (s *Server) triggerSync(_ context.Context, _ *emptypb.Empty) (*emptypb.Empty, error) {
+	slog.Info("admin: sync requested")
+	s.reconciler.TriggerSync()
+	return &emptypb.Empty{}, nil
+}
+
+func (s *Server) pause(_ context.Context, _ *emptypb.Empty) (*emptypb.Empty, error) {
+	slog.Info("admin: pause requested")
+	s.reconciler.Pause()
+	return &emptypb.Empty{}, nil
+}
+
+func (s *Server) resume(_ context.Context, _ *emptypb.Empty) (*emptypb.Empty, error) {
+	slog.Info("admin: resume requested")
+	s.reconciler.Resume()
+	return &emptypb.Empty{}, nil
+}
+
+func (s *Server) uninstall(ctx context.Context, _ *emptypb.Empty) (*emptypb.Empty, error) {
+	slog.Warn("admin: uninstall requested")
+	if err := s.reconciler.Uninstall(ctx); err != nil {
+		return nil, status.Errorf(codes.Internal, "uninstalling: %v", err)
+	}
+	return &emptypb.Empty{}, nil
+}
+
+func (s *Server) getService(_ context.Context, req *wrapperspb.StringValue) (*structpb.Struct, error) {
+	svc, ok := s.reconciler.Service(req.GetValue())
+	if !ok {
+		return nil, status.Errorf(codes.NotFound, "service %q not found", req.GetValue())
+	}
+	return toStruct(svc)
+}
+
+// toStruct converts any JSON-serializable value into a protobuf Struct.
+func toStruct(v any) (*structpb.Struct, error) {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return nil, status.Errorf(codes.Internal, "encoding response: %v", err)
+	}
+	out := &structpb.Struct{}
+	if err := protojson.Unmarshal(data, out); err != nil {
+		return nil, status.Errorf(codes.Internal, "encoding response: %v", err)
+	}
+	return out, nil
+}
+
+func authInterceptor(token string) grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+		md, _ := metadata.FromIncomingContext(ctx)
+		var presented string
+		for _, v := range md.Get("authorization") {
+			if t, ok := strings.CutPrefix(v, "Bearer "); ok {
+				presented = t
+			}
+		}
+		if subtle.ConstantTimeCompare([]byte(presented), []byte(token)) != 1 {
+			slog.Warn("admin: rejected unauthenticated call", "method", info.FullMethod)
+			return nil, status.Error(codes.Unauthenticated, "invalid or missing bearer token")
+		}
+		return handler(ctx, req)
+	}
+}
+
+// unary adapts a typed handler to the grpc.MethodDesc signature.
+func unary[Req any, PReq interface {
+	*Req
+	proto.Message
+}, Resp any](method string, fn func(*Server, context.Context, PReq) (Resp, error)) grpc.MethodDesc {
+	return grpc.MethodDesc{
+		MethodName: method,
+		Handler: func(srv any, ctx context.Context, dec func(any) error, interceptor grpc.UnaryServerInterceptor) (any, error) {
+			in := PReq(new(Req))
+			if err := dec(in); err != nil {
+				return nil, err
+			}
+			call := func(ctx context.Context, req any) (any, error) {
+				return fn(srv.(*Server), ctx, req.(PReq))
+			}
+			if interceptor == nil {
+				return call(ctx, in)
+			}
+			info := &grpc.UnaryServerInfo{Server: srv, FullMethod: "/" + serviceName + "/" + method}
+			return interceptor(ctx, in, info, call)
+		},
+	}
+}
+
+var serviceDesc = grpc.ServiceDesc{
+	ServiceName: serviceName,
+	HandlerType: (*any)(nil),
+	Methods: []grpc.MethodDesc{
+		unary("GetStatus", (*Server).getStatus),
+		unary("TriggerSync", (*Server).triggerSync),
+		unary("Pause", (*Server).pause),
+		unary("Resume", (*Server).resume),
+		unary("GetService", (*Server).getService),
+		unary("Uninstall", (*Server).uninstall),
+	},
+}
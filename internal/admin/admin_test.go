@@ -0,0 +1,71 @@
+package admin
+
+import (
+	"context"
+	"testing"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+func TestAuthInterceptor(t *testing.T) {
+	intercept := authInterceptor("s3cret")
+	info := &grpc.UnaryServerInfo{FullMethod: "/" + serviceName + "/Uninstall"}
+
+	for _, tt := range []struct {
+		name string
+		md   metadata.MD
+		want codes.Code
+	}{
+		{"no metadata", nil, codes.Unauthenticated},
+		{"no authorization", metadata.Pairs("x-other", "s3cret"), codes.Unauthenticated},
+		{"empty token", metadata.Pairs("authorization", "Bearer "), codes.Unauthenticated},
+		{"wrong token", metadata.Pairs("authorization", "Bearer wrong"), codes.Unauthenticated},
+		{"token prefix", metadata.Pairs("authorization", "Bearer s3cre"), codes.Unauthenticated},
+		{"not bearer", metadata.Pairs("authorization", "Basic s3cret"), codes.Unauthenticated},
+		{"bare token", metadata.Pairs("authorization", "s3cret"), codes.Unauthenticated},
+		{"valid", metadata.Pairs("authorization", "Bearer s3cret"), codes.OK},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := context.Background()
+			if tt.md != nil {
+				ctx = metadata.NewIncomingContext(ctx, tt.md)
+			}
+			called := false
+			handler := func(context.Context, any) (any, error) {
+				called = true
+				return "ok", nil
+			}
+
+			_, err := intercept(ctx, nil, info, handler)
+			if got := status.Code(err); got != tt.want {
+				t.Errorf("code = %s, want %s", got, tt.want)
+			}
+			if called != (tt.want == codes.OK) {
+				t.Errorf("handler called = %t, want %t", called, tt.want == codes.OK)
+			}
+		})
+	}
+}
+
+func TestConfigLoopback(t *testing.T) {
+	for _, tt := range []struct {
+		addr string
+		want bool
+	}{
+		{"127.0.0.1:9090", true},
+		{"[::1]:9090", true},
+		{"localhost:9090", true},
+		{":9090", false},
+		{"0.0.0.0:9090", false},
+		{"10.0.0.1:9090", false},
+		{"admin.example.com:9090", false},
+		{"127.0.0.1", false},
+	} {
+		if got := (Config{Addr: tt.addr}).Loopback(); got != tt.want {
+			t.Errorf("Loopback(%q) = %t, want %t", tt.addr, got, tt.want)
+		}
+	}
+}
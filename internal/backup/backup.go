@@ -0,0 +1,137 @@
+// Package backup periodically uploads a snapshot of the controller's view of
+// the world — the Consul service inventory and the Kubernetes objects generated
+// from it — to S3-compatible object storage. The snapshot is for humans to
+// consult when Consul and the cluster are both unhealthy; nothing reads it back
+// automatically.
+package backup
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/alexieff-io/consul-sync/internal/consul"
+	k8s "github.com/alexieff-io/consul-sync/internal/kubernetes"
+	"github.com/alexieff-io/consul-sync/internal/metrics"
+	"github.com/alexieff-io/consul-sync/internal/reconciler"
+)
+
+// Config holds the object storage destination and schedule.
+type Config struct {
+	Endpoint     string // e.g. https://s3.us-east-1.amazonaws.com or https://storage.googleapis.com
+	Region       string
+	Bucket       string
+	Prefix       string
+	AccessKey    string
+	SecretKey    string
+	SessionToken string
+	Interval     time.Duration
+}
+
+// Snapshot is the document uploaded on each backup.
+type Snapshot struct {
+	TakenAt   time.Time             `json:"takenAt"`
+	Version   string                `json:"version"`
+	Namespace string                `json:"namespace"`
+	Services  []consul.ServiceState `json:"services"`
+	Manifests []any                 `json:"manifests"`
+}
+
+// Backup uploads snapshots on an interval.
+type Backup struct {
+	cfg        Config
+	version    string
+	namespace  string
+	reconciler *reconciler.Reconciler
+	syncer     *k8s.Syncer
+	client     *s3Client
+}
+
+// New creates a Backup writing to the configured bucket.
+func New(cfg Config, version, namespace string, rec *reconciler.Reconciler, syncer *k8s.Syncer) (*Backup, error) {
+	endpoint, err := url.Parse(cfg.Endpoint)
+	if err != nil || endpoint.Scheme == "" || endpoint.Host == "" {
+		return nil, fmt.Errorf("invalid endpoint %q", cfg.Endpoint)
+	}
+	if cfg.AccessKey == "" || cfg.SecretKey == "" {
+		return nil, fmt.Errorf("backup requires access key credentials")
+	}
+
+	return &Backup{
+		cfg:        cfg,
+		version:    version,
+		namespace:  namespace,
+		reconciler: rec,
+		syncer:     syncer,
+		client: &s3Client{
+			endpoint:     endpoint,
+			region:       cfg.Region,
+			accessKey:    cfg.AccessKey,
+			secretKey:    cfg.SecretKey,
+			sessionToken: cfg.SessionToken,
+			http:         &http.Client{Timeout: time.Minute},
+		},
+	}, nil
+}
+
+// Run uploads a snapshot every interval until the context is cancelled. The
+// first upload happens one interval after start, once the reconciler has had
+// a chance to observe Consul.
+func (b *Backup) Run(ctx context.Context) {
+	ticker := time.NewTicker(b.cfg.Interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if err := b.upload(ctx); err != nil {
+				slog.Error("state backup failed", "error", err)
+				metrics.BackupTotal.WithLabelValues("error").Inc()
+				continue
+			}
+			metrics.BackupTotal.WithLabelValues("success").Inc()
+			metrics.LastBackupTimestamp.SetToCurrentTime()
+		}
+	}
+}
+
+// upload writes the snapshot under a timestamped key and as latest.json.
+func (b *Backup) upload(ctx context.Context) error {
+	manifests, err := b.syncer.ManagedObjects(ctx)
+	if err != nil {
+		return err
+	}
+	snap := Snapshot{
+		TakenAt:   time.Now().UTC(),
+		Version:   b.version,
+		Namespace: b.namespace,
+		Services:  b.reconciler.Services(),
+		Manifests: manifests,
+	}
+	data, err := json.Marshal(snap)
+	if err != nil {
+		return fmt.Errorf("encoding snapshot: %w", err)
+	}
+
+	prefix := strings.TrimSuffix(b.cfg.Prefix, "/")
+	if prefix != "" {
+		prefix += "/"
+	}
+	key := prefix + snap.TakenAt.Format("2006/01/02/150405Z") + ".json"
+	for _, k := range []string{key, prefix + "latest.json"} {
+		if err := b.client.put(ctx, b.cfg.Bucket, k, "application/json", data); err != nil {
+			return err
+		}
+	}
+
+	slog.Info("uploaded state backup", "bucket", b.cfg.Bucket, "key", key,
+		"services", len(snap.Services), "manifests", len(snap.Manifests), "bytes", len(data))
+	return nil
+}
@@ -0,0 +1,305 @@
+package consul
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"slices"
+	"sync"
+	"time"
+)
+
+// AgentWatcher reads the services registered with a single Consul agent,
+// for running one consul-sync per node as a DaemonSet. It polls the agent's
+// local state instead of the catalog, so Consul servers see no load from it.
+type AgentWatcher struct {
+	addr      string
+	tag       string
+	client    *http.Client
+	transport *swappableTransport
+	opts      Options
+
+	// nodeAddr is the agent's own address, used for services registered
+	// without one. It is looked up once, on first need.
+	nodeAddrMu sync.Mutex
+	nodeAddr   string
+}
+
+// NewAgentWatcher creates a watcher for the Consul agent at addr. An empty
+// tag selects every local service except those in opts.SkipServices.
+// opts.WatchMode and opts.Faults are ignored: the agent API has no blocking
+// list queries, so it is always polled.
+func NewAgentWatcher(addr, token, tag string, opts Options) *AgentWatcher {
+	transport := newSwappableTransport()
+	return &AgentWatcher{
+		addr:      addr,
+		tag:       tag,
+		transport: transport,
+		opts:      opts,
+		client: &http.Client{
+			Transport: newTokenTransport(withHeaders(transport, opts.Headers), addr, token, opts),
+			Timeout:   30 * time.Second,
+		},
+	}
+}
+
+// agentService is a single entry from /v1/agent/services.
+type agentService struct {
+	ID      string            `json:"ID"`
+	Kind    string            `json:"Kind"`
+	Service string            `json:"Service"`
+	Address string            `json:"Address"`
+	Port    int               `json:"Port"`
+	Tags    []string          `json:"Tags"`
+	Meta    map[string]string `json:"Meta"`
+	Weights serviceWeights    `json:"Weights"`
+}
+
+// agentCheck is a single entry from /v1/agent/checks. Node-level checks have
+// an empty ServiceID.
+type agentCheck struct {
+	Node      string `json:"Node"`
+	Name      string `json:"Name"`
+	Status    string `json:"Status"`
+	Output    string `json:"Output"`
+	ServiceID string `json:"ServiceID"`
+}
+
+// agentSelf is the part of /v1/agent/self used to find the node address.
+type agentSelf struct {
+	Member struct {
+		Addr string `json:"Addr"`
+	} `json:"Member"`
+}
+
+// SetTLS replaces the TLS configuration used for new connections to the agent.
+func (w *AgentWatcher) SetTLS(cfg TLSConfig) error {
+	tlsCfg, err := cfg.build()
+	if err != nil {
+		return err
+	}
+
+	t := http.DefaultTransport.(*http.Transport).Clone()
+	t.TLSClientConfig = tlsCfg
+	if old := w.transport.swap(t); old != nil {
+		old.CloseIdleConnections()
+	}
+	return nil
+}
+
+// WatchServices polls the agent every PollInterval and sends a snapshot
+// whenever the local services or their health changed.
+func (w *AgentWatcher) WatchServices(ctx context.Context) (<-chan Snapshot, error) {
+	ch := make(chan Snapshot, 1)
+
+	go func() {
+		defer close(ch)
+
+		var lastKey string
+		for first := true; ; first = false {
+			if !first {
+				select {
+				case <-ctx.Done():
+					return
+				case <-time.After(w.pollInterval()):
+				}
+			}
+
+			snap := Snapshot{DetectedAt: time.Now()}
+			states, err := w.FetchAllServices(ctx)
+			if err != nil {
+				if ctx.Err() != nil {
+					return
+				}
+				slog.Error("failed to read consul agent services", "error", err)
+				continue
+			}
+
+			// The agent API has no index to compare, so compare the
+			// services themselves.
+			key, err := json.Marshal(states)
+			if err != nil {
+				slog.Error("failed to encode consul agent services", "error", err)
+				continue
+			}
+			if string(key) == lastKey {
+				continue
+			}
+			lastKey = string(key)
+
+			slog.Info("consul agent services changed", "services", len(states))
+			snap.Services = states
+			select {
+			case ch <- snap:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+
+	return ch, nil
+}
+
+func (w *AgentWatcher) pollInterval() time.Duration {
+	if w.opts.PollInterval > 0 {
+		return w.opts.PollInterval
+	}
+	return defaultPollInterval
+}
+
+// FetchService returns the healthy local instances of a single service.
+func (w *AgentWatcher) FetchService(ctx context.Context, name string) (ServiceState, error) {
+	states, err := w.FetchAllServices(ctx)
+	if err != nil {
+		return ServiceState{}, err
+	}
+	for _, st := range states {
+		if st.Name == name {
+			return st, nil
+		}
+	}
+	return ServiceState{Name: name}, nil
+}
+
+// FetchAllServices returns every tagged service registered with the agent
+// and its healthy local instances. An instance is healthy when all of its
+// checks and all node-level checks are passing, as with the catalog's
+// ?passing=true filter. Services with no healthy instance are still
+// returned, with nil instances, so they aren't treated as removed.
+func (w *AgentWatcher) FetchAllServices(ctx context.Context) ([]ServiceState, error) {
+	var services map[string]agentService
+	if err := w.get(ctx, "/v1/agent/services", &services); err != nil {
+		return nil, err
+	}
+	var checks map[string]agentCheck
+	if err := w.get(ctx, "/v1/agent/checks", &checks); err != nil {
+		return nil, err
+	}
+
+	var nodeFailing []Check
+	failing := make(map[string][]Check)
+	// Go through the checks in order so snapshots compare equal.
+	checkIDs := make([]string, 0, len(checks))
+	for id := range checks {
+		checkIDs = append(checkIDs, id)
+	}
+	slices.Sort(checkIDs)
+	for _, id := range checkIDs {
+		c := checks[id]
+		if c.Status == "passing" {
+			continue
+		}
+		check := Check{Node: c.Node, ServiceID: c.ServiceID, Name: c.Name, Status: c.Status, Output: c.Output}
+		if c.ServiceID == "" {
+			nodeFailing = append(nodeFailing, check)
+		} else {
+			failing[c.ServiceID] = append(failing[c.ServiceID], check)
+		}
+	}
+
+	ids := make([]string, 0, len(services))
+	for id := range services {
+		ids = append(ids, id)
+	}
+	slices.Sort(ids)
+
+	byName := make(map[string]*ServiceState)
+	var names []string
+	for _, id := range ids {
+		svc := services[id]
+		if slices.Contains(w.opts.SkipServices, svc.Service) || slices.Contains(w.opts.SkipKinds, svc.Kind) {
+			continue
+		}
+		if w.tag != "" && !slices.Contains(svc.Tags, w.tag) {
+			continue
+		}
+		st, ok := byName[svc.Service]
+		if !ok {
+			st = &ServiceState{Name: svc.Service}
+			byName[svc.Service] = st
+			names = append(names, svc.Service)
+		}
+		st.Registered++
+
+		addr := svc.Address
+		if addr == "" {
+			var err error
+			if addr, err = w.nodeAddress(ctx); err != nil {
+				return nil, err
+			}
+		}
+		if len(nodeFailing) > 0 || len(failing[id]) > 0 {
+			for _, checks := range [][]Check{failing[id], nodeFailing} {
+				for _, c := range checks {
+					c.Address = addr
+					st.FailingChecks = append(st.FailingChecks, c)
+				}
+			}
+			continue
+		}
+		st.Instances = append(st.Instances, ServiceInstance{
+			ServiceName: svc.Service,
+			ID:          svc.ID,
+			Address:     addr,
+			Port:        svc.Port,
+			Tags:        internTags(svc.Tags),
+			Meta:        svc.Meta,
+			Weight:      svc.Weights.Passing,
+		})
+	}
+
+	slices.Sort(names)
+	states := make([]ServiceState, 0, len(names))
+	for _, name := range names {
+		st := byName[name]
+		st.Tags = collectTags(st.Instances)
+		st.Meta = collectMeta(st.Instances)
+		states = append(states, *st)
+	}
+	return states, nil
+}
+
+// nodeAddress returns the agent's own address.
+func (w *AgentWatcher) nodeAddress(ctx context.Context) (string, error) {
+	w.nodeAddrMu.Lock()
+	defer w.nodeAddrMu.Unlock()
+	if w.nodeAddr != "" {
+		return w.nodeAddr, nil
+	}
+
+	var self agentSelf
+	if err := w.get(ctx, "/v1/agent/self", &self); err != nil {
+		return "", fmt.Errorf("looking up node address: %w", err)
+	}
+	if self.Member.Addr == "" {
+		return "", fmt.Errorf("consul agent reported no node address")
+	}
+	w.nodeAddr = self.Member.Addr
+	return w.nodeAddr, nil
+}
+
+// get decodes the JSON response of a GET to the agent API path into out.
+func (w *AgentWatcher) get(ctx context.Context, path string, out any) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, w.addr+path, nil)
+	if err != nil {
+		return fmt.Errorf("creating request: %w", err)
+	}
+
+	resp, err := w.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("querying consul agent: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+		return fmt.Errorf("consul agent returned %d: %s", resp.StatusCode, string(body))
+	}
+	if err := json.NewDecoder(resp.Body).Decode(out); err != nil {
+		return fmt.Errorf("decoding response: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,330 @@
+package consul
+
+import (
+	"context"
+	"log/slog"
+	"maps"
+	"slices"
+	"sync"
+	"time"
+)
+
+// childSet holds the child watchers of a Watcher, one per namespace or peer,
+// created as they appear.
+type childSet struct {
+	mu       sync.Mutex
+	children map[string]*Watcher
+}
+
+// get returns the child called name, creating it with create on first use.
+func (c *childSet) get(name string, create func() *Watcher) *Watcher {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if child, ok := c.children[name]; ok {
+		return child
+	}
+	if c.children == nil {
+		c.children = make(map[string]*Watcher)
+	}
+	child := create()
+	c.children[name] = child
+	return child
+}
+
+// all returns the children seen so far, by name.
+func (c *childSet) all() map[string]*Watcher {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return maps.Clone(c.children)
+}
+
+// drop forgets the child called name once it is gone from Consul.
+func (c *childSet) drop(name string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	delete(c.children, name)
+}
+
+// healthy reports whether the watch loop of every child is.
+func (c *childSet) healthy() bool {
+	for _, child := range c.all() {
+		if !child.WatchHealthy() {
+			return false
+		}
+	}
+	return true
+}
+
+// states returns the state of every child's watch loop, or nil without
+// children.
+func (c *childSet) states() map[string]WatcherState {
+	children := c.all()
+	if len(children) == 0 {
+		return nil
+	}
+	states := make(map[string]WatcherState, len(children))
+	for name, child := range children {
+		states[name] = child.State()
+	}
+	return states
+}
+
+// newChild returns a watcher sharing w's client, transport and options,
+// querying namespace ns and peer.
+func (w *Watcher) newChild(ns, peer string) *Watcher {
+	return &Watcher{
+		addr:      w.addr,
+		tag:       w.tag,
+		cache:     make(map[string]cachedService),
+		transport: w.transport,
+		opts:      w.opts,
+		client:    w.client,
+		dc:        w.dc,
+		ns:        ns,
+		peer:      peer,
+	}
+}
+
+// childSnapshot is a snapshot of child, or of the base watch when child is
+// nil.
+type childSnapshot struct {
+	child *Watcher
+	name  string
+	snap  Snapshot
+}
+
+// watchChildren runs the watch loop of one child of set for every name on
+// the lists received, and sends the services of all of them, combined by
+// merge, whenever one changes. With base set, its services are passed to
+// merge too, under the empty name. As in watchDatacenters, nothing is sent
+// until the list, base and every listed child have reported once, and an
+// unreachable child keeps its last services; those of a child whose name
+// leaves the list are dropped.
+func (w *Watcher) watchChildren(ctx context.Context, kind string, lists <-chan []string, set *childSet, create func(name string) *Watcher, base <-chan Snapshot, merge func(map[string][]ServiceState) []ServiceState) <-chan Snapshot {
+	in := make(chan childSnapshot)
+	if base != nil {
+		go func() {
+			for snap := range base {
+				select {
+				case in <- childSnapshot{snap: snap}:
+				case <-ctx.Done():
+					return
+				}
+			}
+		}()
+	}
+
+	out := make(chan Snapshot, 1)
+	go func() {
+		defer close(out)
+		running := make(map[string]*Watcher)
+		cancels := make(map[string]context.CancelFunc)
+		latest := make(map[string][]ServiceState)
+		indexes := make(map[string]uint64)
+		listed := false
+		for {
+			var detectedAt time.Time
+			select {
+			case names := <-lists:
+				listed = true
+				detectedAt = time.Now()
+				for name, cancel := range cancels {
+					if slices.Contains(names, name) {
+						continue
+					}
+					slog.Info("consul "+kind+" removed", kind, name)
+					cancel()
+					delete(cancels, name)
+					delete(running, name)
+					delete(latest, name)
+					delete(indexes, name)
+					set.drop(name)
+				}
+				for _, name := range names {
+					if _, ok := running[name]; ok {
+						continue
+					}
+					child := set.get(name, func() *Watcher { return create(name) })
+					childCtx, cancel := context.WithCancel(ctx)
+					ch, err := child.WatchServices(childCtx)
+					if err != nil {
+						cancel()
+						slog.Error("failed to watch consul "+kind, kind, name, "error", err)
+						continue
+					}
+					running[name], cancels[name] = child, cancel
+					go func() {
+						for snap := range ch {
+							select {
+							case in <- childSnapshot{child: child, name: name, snap: snap}:
+							case <-childCtx.Done():
+								return
+							}
+						}
+					}()
+				}
+			case s := <-in:
+				// Skip late snapshots of a child removed since.
+				if s.child != nil && running[s.name] != s.child {
+					continue
+				}
+				latest[s.name] = s.snap.Services
+				indexes[s.name] = s.snap.Index
+				detectedAt = s.snap.DetectedAt
+			case <-ctx.Done():
+				return
+			}
+			want := len(running)
+			if base != nil {
+				want++
+			}
+			if !listed || len(latest) < want {
+				continue
+			}
+
+			snap := Snapshot{Services: merge(latest), DetectedAt: detectedAt}
+			// Namespaces and peers are all served by the same servers, so
+			// their indexes compare.
+			for _, index := range indexes {
+				snap.Index = max(snap.Index, index)
+			}
+			select {
+			case out <- snap:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+	return out
+}
+
+// watchList sends the names returned by list on lists whenever they change,
+// with the blocking queries, polling fallback and backoff of WatchServices,
+// recording its queries in debug.
+func (w *Watcher) watchList(ctx context.Context, kind string, debug *watchDebug, list func(context.Context, uint64) ([]string, uint64, error), lists chan<- []string) {
+	var waitIndex uint64
+	var lastNames string
+	var sent bool
+	var heldFailures int
+	backoff := time.Second
+	polling := w.opts.WatchMode == WatchModePoll
+	first := true
+	var retryAt time.Time
+	var probing bool
+	fallBack := func() {
+		polling, probing = true, false
+		retryAt = time.Now().Add(blockingRetryInterval)
+		debug.update(func(st *WatcherState) {
+			st.Polling, st.HeldFailures = true, heldFailures
+		})
+	}
+
+	for {
+		if polling && !first {
+			select {
+			case <-ctx.Done():
+				return
+			case <-time.After(w.pollInterval()):
+			}
+		}
+		first = false
+		if polling && !retryAt.IsZero() && time.Now().After(retryAt) {
+			slog.Info("trying blocking " + kind + " queries again")
+			polling, probing, heldFailures = false, true, 0
+		}
+
+		index := waitIndex
+		if polling {
+			index = 0
+		}
+		start := time.Now()
+		names, newIndex, err := list(ctx, index)
+		debug.update(func(st *WatcherState) {
+			st.LastQuery = &QueryState{
+				Started:  start,
+				Duration: time.Since(start).String(),
+				Index:    index,
+				NewIndex: newIndex,
+			}
+			if err != nil {
+				st.LastQuery.Error = err.Error()
+			}
+		})
+		if err != nil {
+			if ctx.Err() != nil {
+				return
+			}
+			if !polling && time.Since(start) >= heldQueryThreshold {
+				heldFailures++
+				if probing {
+					slog.Info("blocking "+kind+" query still fails after being held, polling again",
+						"retry_in", blockingRetryInterval)
+					fallBack()
+					continue
+				}
+				if heldFailures >= heldFailuresBeforePolling {
+					slog.Warn("blocking "+kind+" queries keep failing after being held, falling back to polling",
+						"failures", heldFailures, "poll_interval", w.pollInterval())
+					fallBack()
+					continue
+				}
+			} else {
+				heldFailures = 0
+			}
+			slog.Error("failed to list consul "+kind+"s", "error", err, "backoff", backoff)
+			debug.update(func(st *WatcherState) {
+				st.HeldFailures = heldFailures
+				st.Retry = &RetryState{
+					Backoff: backoff.String(),
+					Next:    time.Now().Add(backoff),
+					Error:   err.Error(),
+				}
+			})
+			select {
+			case <-ctx.Done():
+				return
+			case <-time.After(backoff):
+			}
+			backoff = min(backoff*2, 30*time.Second)
+			continue
+		}
+		backoff = time.Second
+		heldFailures = 0
+
+		switch {
+		case !polling && newIndex == 0 && probing:
+			slog.Info("consul "+kind+" response still has no X-Consul-Index, polling again",
+				"retry_in", blockingRetryInterval)
+			fallBack()
+		case !polling && newIndex == 0:
+			slog.Warn("consul "+kind+" response has no X-Consul-Index, falling back to polling",
+				"poll_interval", w.pollInterval())
+			fallBack()
+		case probing && time.Since(start) >= heldQueryThreshold:
+			slog.Info("blocking " + kind + " queries work again")
+			probing, retryAt = false, time.Time{}
+		}
+		debug.update(func(st *WatcherState) {
+			st.Polling, st.HeldFailures, st.Retry = polling, 0, nil
+		})
+
+		if !polling {
+			waitIndex = newIndex
+		}
+		key := namesKey(names)
+		if sent && key == lastNames {
+			continue
+		}
+		sent, lastNames = true, key
+		debug.update(func(st *WatcherState) {
+			st.WaitIndex = waitIndex
+		})
+
+		slog.Info("consul "+kind+"s changed", kind+"s", names, "index", newIndex)
+		select {
+		case lists <- names:
+		case <-ctx.Done():
+			return
+		}
+	}
+}
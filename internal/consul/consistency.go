@@ -0,0 +1,45 @@
+package consul
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+
+	"github.com/alexieff-io/consul-sync/internal/metrics"
+)
+
+// setConsistency adds the consistency and agent cache parameters of
+// Options.Stale and Options.Cache to a catalog or health query.
+func (w *Watcher) setConsistency(query url.Values) {
+	if w.opts.Stale {
+		query.Set("stale", "")
+	}
+	if w.opts.Cache {
+		query.Set("cached", "")
+	}
+}
+
+// setCacheControl asks the agent cache to answer req from a result at most
+// Options.CacheMaxAge old. Blocking queries are kept fresh by the cache's
+// background refresh instead, and ignore it.
+func (w *Watcher) setCacheControl(req *http.Request) {
+	if w.opts.Cache && w.opts.CacheMaxAge > 0 {
+		req.Header.Set("Cache-Control", fmt.Sprintf("max-age=%d", int(w.opts.CacheMaxAge/time.Second)))
+	}
+}
+
+// observeLastContact exports how long ago the server answering a query to
+// endpoint last heard from the leader, as reported in X-Consul-LastContact.
+// It is 0 for the leader itself and for consistent reads.
+func observeLastContact(endpoint string, resp *http.Response) {
+	if resp == nil {
+		return
+	}
+	ms, err := strconv.ParseUint(resp.Header.Get("X-Consul-LastContact"), 10, 64)
+	if err != nil {
+		return
+	}
+	metrics.ConsulLastContact.WithLabelValues(endpoint).Set(float64(ms) / 1000)
+}
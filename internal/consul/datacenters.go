@@ -0,0 +1,128 @@
+package consul
+
+import (
+	"context"
+	"fmt"
+	"slices"
+)
+
+// dcSnapshot is a snapshot of the datacenter at index in Watcher.dcs.
+type dcSnapshot struct {
+	index int
+	snap  Snapshot
+}
+
+// watchDatacenters runs the watch loop of every datacenter and sends the
+// merged services of all of them whenever one changes. Nothing is sent until
+// every datacenter has reported once, so services of a datacenter that is
+// slow to answer aren't orphaned; while one is unreachable, its last services
+// are kept.
+func (w *Watcher) watchDatacenters(ctx context.Context) (<-chan Snapshot, error) {
+	in := make(chan dcSnapshot)
+	for i, dc := range w.dcs {
+		ch, err := dc.WatchServices(ctx)
+		if err != nil {
+			return nil, fmt.Errorf("watching datacenter %s: %w", dc.dc, err)
+		}
+		go func() {
+			for snap := range ch {
+				select {
+				case in <- dcSnapshot{index: i, snap: snap}:
+				case <-ctx.Done():
+					return
+				}
+			}
+		}()
+	}
+
+	out := make(chan Snapshot, 1)
+	go func() {
+		defer close(out)
+		latest := make([][]ServiceState, len(w.dcs))
+		seen := make([]bool, len(w.dcs))
+		pending := len(w.dcs)
+		for {
+			var ds dcSnapshot
+			select {
+			case ds = <-in:
+			case <-ctx.Done():
+				return
+			}
+			latest[ds.index] = ds.snap.Services
+			if !seen[ds.index] {
+				seen[ds.index] = true
+				pending--
+			}
+			if pending > 0 {
+				continue
+			}
+
+			snap := Snapshot{Services: mergeDatacenters(latest), DetectedAt: ds.snap.DetectedAt}
+			select {
+			case out <- snap:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+	return out, nil
+}
+
+func (w *Watcher) fetchAllDatacenters(ctx context.Context) ([]ServiceState, error) {
+	all := make([][]ServiceState, len(w.dcs))
+	for i, dc := range w.dcs {
+		states, err := dc.FetchAllServices(ctx)
+		if err != nil {
+			return nil, fmt.Errorf("fetching datacenter %s: %w", dc.dc, err)
+		}
+		all[i] = states
+	}
+	return mergeDatacenters(all), nil
+}
+
+func (w *Watcher) fetchServiceDatacenters(ctx context.Context, name string) (ServiceState, error) {
+	all := make([][]ServiceState, len(w.dcs))
+	for i, dc := range w.dcs {
+		st, err := dc.FetchService(ctx, name)
+		if err != nil {
+			return ServiceState{}, fmt.Errorf("fetching datacenter %s: %w", dc.dc, err)
+		}
+		if st.Registered > 0 {
+			all[i] = []ServiceState{st}
+		}
+	}
+	merged := mergeDatacenters(all)
+	if len(merged) == 0 {
+		return ServiceState{Name: name}, nil
+	}
+	return merged[0], nil
+}
+
+// mergeDatacenters merges the services of each datacenter, in datacenter
+// order, into one ServiceState per name. Instances and failing checks are
+// concatenated and keep their datacenter; tags are their union, and meta
+// keys set in several datacenters keep the first datacenter's value.
+func mergeDatacenters(all [][]ServiceState) []ServiceState {
+	index := make(map[string]int)
+	var merged []ServiceState
+	for _, states := range all {
+		for _, st := range states {
+			i, ok := index[st.Name]
+			if !ok {
+				index[st.Name] = len(merged)
+				merged = append(merged, st)
+				continue
+			}
+			m := &merged[i]
+			// Instance slices are shared with the watchers' caches, so
+			// merging must not append to them in place.
+			m.Instances = slices.Concat(m.Instances, st.Instances)
+			m.FailingChecks = slices.Concat(m.FailingChecks, st.FailingChecks)
+			m.Datacenters = slices.Concat(m.Datacenters, st.Datacenters)
+			m.Registered += st.Registered
+			m.Tags = collectTags(m.Instances)
+			m.Meta = collectMeta(m.Instances)
+		}
+	}
+	return merged
+}
@@ -0,0 +1,153 @@
+package consul
+
+import (
+	"encoding/json"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// Endpoint names used as keys of WatcherState.Endpoints.
+const (
+	endpointCatalogServices = "/v1/catalog/services"
+	endpointHealthService   = "/v1/health/service"
+	endpointNamespaces      = "/v1/namespaces"
+	endpointPeerings        = "/v1/peerings"
+)
+
+// WatcherState is a point-in-time view of the watch loop's internals, served
+// on /debug/consul to diagnose blocking queries that hang or get cut short.
+type WatcherState struct {
+	// WaitIndex is the index the next blocking query waits on.
+	WaitIndex uint64 `json:"waitIndex"`
+	// Polling is true once blocking queries were abandoned for polling.
+	Polling bool `json:"polling"`
+	// HeldFailures counts consecutive blocking queries that failed after
+	// being held open, see heldFailuresBeforePolling.
+	HeldFailures int `json:"heldFailures"`
+
+	LastQuery *QueryState `json:"lastQuery,omitempty"`
+	Retry     *RetryState `json:"retry,omitempty"`
+
+	// Endpoints holds the last response of each Consul endpoint.
+	Endpoints map[string]EndpointState `json:"endpoints"`
+
+	// Datacenters holds the state of each datacenter's watch loop when
+	// several are watched, in place of the fields above.
+	Datacenters map[string]WatcherState `json:"datacenters,omitempty"`
+	// Namespaces holds the state of each namespace's watch loop when every
+	// namespace is watched; the fields above then describe the namespace
+	// list query.
+	Namespaces map[string]WatcherState `json:"namespaces,omitempty"`
+	// Peers holds the state of each peer's watch loop with peering, and
+	// PeerList that of the peer list query.
+	Peers    map[string]WatcherState `json:"peers,omitempty"`
+	PeerList *WatcherState           `json:"peerList,omitempty"`
+}
+
+// QueryState describes the last catalog query of the watch loop.
+type QueryState struct {
+	Started  time.Time `json:"started"`
+	Duration string    `json:"duration"`
+	Index    uint64    `json:"index"`    // index the query waited on, 0 when not blocking
+	NewIndex uint64    `json:"newIndex"` // X-Consul-Index of the response
+	Error    string    `json:"error,omitempty"`
+}
+
+// RetryState describes the backoff after a failed catalog query.
+type RetryState struct {
+	Backoff string    `json:"backoff"`
+	Next    time.Time `json:"next"`
+	Error   string    `json:"error"`
+}
+
+// EndpointState is the last response of a Consul endpoint. Status is 0 when
+// the request failed without a response.
+type EndpointState struct {
+	Status int       `json:"status"`
+	Error  string    `json:"error,omitempty"`
+	At     time.Time `json:"at"`
+}
+
+// watchDebug records WatcherState as the watch loop runs.
+type watchDebug struct {
+	mu    sync.Mutex
+	state WatcherState
+}
+
+func (d *watchDebug) update(f func(*WatcherState)) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	f(&d.state)
+}
+
+// healthy reports whether the loop is running on blocking queries and its
+// last query succeeded.
+func (d *watchDebug) healthy() bool {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	st := d.state
+	return !st.Polling && st.Retry == nil && st.LastQuery != nil && st.LastQuery.Error == ""
+}
+
+// recordResponse records the outcome of a request to endpoint.
+func (d *watchDebug) recordResponse(endpoint string, resp *http.Response, err error) {
+	e := EndpointState{At: time.Now()}
+	if resp != nil {
+		e.Status = resp.StatusCode
+	}
+	if err != nil {
+		e.Error = err.Error()
+	}
+	d.update(func(s *WatcherState) {
+		if s.Endpoints == nil {
+			s.Endpoints = make(map[string]EndpointState)
+		}
+		s.Endpoints[endpoint] = e
+	})
+}
+
+// State returns the current state of the watch loop.
+func (w *Watcher) State() WatcherState {
+	state := w.debug.snapshot()
+	if len(w.dcs) > 0 {
+		state.Datacenters = make(map[string]WatcherState, len(w.dcs))
+		for _, dc := range w.dcs {
+			state.Datacenters[dc.dc] = dc.State()
+		}
+	}
+	state.Namespaces = w.nss.states()
+	state.Peers = w.peers.states()
+	if w.peering() {
+		list := w.peerList.snapshot()
+		state.PeerList = &list
+	}
+	return state
+}
+
+// snapshot returns a copy of the recorded state.
+func (d *watchDebug) snapshot() WatcherState {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	state := d.state
+	if state.LastQuery != nil {
+		q := *state.LastQuery
+		state.LastQuery = &q
+	}
+	if state.Retry != nil {
+		r := *state.Retry
+		state.Retry = &r
+	}
+	endpoints := make(map[string]EndpointState, len(state.Endpoints))
+	for k, v := range state.Endpoints {
+		endpoints[k] = v
+	}
+	state.Endpoints = endpoints
+	return state
+}
+
+// ServeHTTP writes the current state of the watch loop as JSON.
+func (w *Watcher) ServeHTTP(rw http.ResponseWriter, _ *http.Request) {
+	rw.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(rw).Encode(w.State())
+}
@@ -0,0 +1,141 @@
+package consul
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math/rand/v2"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/alexieff-io/consul-sync/internal/metrics"
+)
+
+// FaultConfig injects artificial failures into Consul responses so the
+// controller's safety behaviors can be exercised against a real cluster. It
+// is meant for staging only.
+type FaultConfig struct {
+	// ErrorRate is the probability that a request fails outright.
+	ErrorRate float64
+	// FlapRate is the probability that a service is dropped from a catalog
+	// response, or that a health response reports no passing instances.
+	FlapRate float64
+	// MaxDelay delays each request by a random duration up to this long.
+	MaxDelay time.Duration
+}
+
+// ParseFaultConfig parses a spec such as "errors=0.1,flaps=0.05,delay=2s".
+func ParseFaultConfig(spec string) (FaultConfig, error) {
+	var cfg FaultConfig
+	for _, pair := range strings.Split(spec, ",") {
+		pair = strings.TrimSpace(pair)
+		if pair == "" {
+			continue
+		}
+		k, v, ok := strings.Cut(pair, "=")
+		if !ok {
+			return FaultConfig{}, fmt.Errorf("expected key=value, got %q", pair)
+		}
+
+		var err error
+		switch strings.TrimSpace(k) {
+		case "errors":
+			cfg.ErrorRate, err = parseRate(v)
+		case "flaps":
+			cfg.FlapRate, err = parseRate(v)
+		case "delay":
+			cfg.MaxDelay, err = time.ParseDuration(strings.TrimSpace(v))
+		default:
+			err = errors.New("unknown fault")
+		}
+		if err != nil {
+			return FaultConfig{}, fmt.Errorf("%s: %w", pair, err)
+		}
+	}
+	return cfg, nil
+}
+
+func parseRate(s string) (float64, error) {
+	rate, err := strconv.ParseFloat(strings.TrimSpace(s), 64)
+	if err != nil {
+		return 0, err
+	}
+	if rate < 0 || rate > 1 {
+		return 0, errors.New("rate must be between 0 and 1")
+	}
+	return rate, nil
+}
+
+// faultTransport wraps the real transport and injects the configured faults.
+type faultTransport struct {
+	next http.RoundTripper
+	cfg  FaultConfig
+}
+
+func (t *faultTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if t.cfg.MaxDelay > 0 {
+		metrics.InjectedFaults.WithLabelValues("delay").Inc()
+		select {
+		case <-time.After(rand.N(t.cfg.MaxDelay)):
+		case <-req.Context().Done():
+			return nil, req.Context().Err()
+		}
+	}
+
+	if rand.Float64() < t.cfg.ErrorRate {
+		metrics.InjectedFaults.WithLabelValues("error").Inc()
+		return nil, errors.New("injected fault: request failed")
+	}
+
+	resp, err := t.next.RoundTrip(req)
+	if err != nil || resp.StatusCode != http.StatusOK || t.cfg.FlapRate == 0 {
+		return resp, err
+	}
+
+	switch {
+	case strings.HasPrefix(req.URL.Path, "/v1/health/service/"):
+		if rand.Float64() < t.cfg.FlapRate {
+			metrics.InjectedFaults.WithLabelValues("flap").Inc()
+			// Drop the index so the empty result isn't cached and reused
+			// once the flap is over.
+			resp.Header.Del("X-Consul-Index")
+			replaceBody(resp, []byte("[]"))
+		}
+	case req.URL.Path == "/v1/catalog/services":
+		return t.flapCatalog(resp)
+	}
+	return resp, nil
+}
+
+// flapCatalog drops each service from a catalog response with FlapRate
+// probability.
+func (t *faultTransport) flapCatalog(resp *http.Response) (*http.Response, error) {
+	defer resp.Body.Close()
+	var catalog catalogServicesResponse
+	if err := json.NewDecoder(resp.Body).Decode(&catalog); err != nil {
+		return nil, fmt.Errorf("injected fault: decoding catalog: %w", err)
+	}
+	for name := range catalog {
+		if rand.Float64() < t.cfg.FlapRate {
+			metrics.InjectedFaults.WithLabelValues("flap").Inc()
+			delete(catalog, name)
+		}
+	}
+	data, err := json.Marshal(catalog)
+	if err != nil {
+		return nil, fmt.Errorf("injected fault: encoding catalog: %w", err)
+	}
+	replaceBody(resp, data)
+	return resp, nil
+}
+
+func replaceBody(resp *http.Response, data []byte) {
+	resp.Body.Close()
+	resp.Body = io.NopCloser(bytes.NewReader(data))
+	resp.ContentLength = int64(len(data))
+	resp.Header.Del("Content-Length")
+}
@@ -0,0 +1,71 @@
+package consul
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+)
+
+// headerTransport adds Options.Headers to every request, without replacing
+// the headers consul-sync sets itself, such as X-Consul-Token.
+type headerTransport struct {
+	next    http.RoundTripper
+	headers http.Header
+}
+
+// withHeaders returns next wrapped to send headers, or next as is when there
+// are none.
+func withHeaders(next http.RoundTripper, headers http.Header) http.RoundTripper {
+	if len(headers) == 0 {
+		return next
+	}
+	return &headerTransport{next: next, headers: headers}
+}
+
+func (t *headerTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	req = req.Clone(req.Context())
+	for name, values := range t.headers {
+		if _, ok := req.Header[name]; !ok {
+			req.Header[name] = values
+		}
+	}
+	return t.next.RoundTrip(req)
+}
+
+// ParseHeaders parses comma-separated Name=value pairs into the headers of
+// Options.Headers. Values may contain = but not commas.
+func ParseHeaders(s string) (http.Header, error) {
+	headers := make(http.Header)
+	for _, pair := range strings.Split(s, ",") {
+		pair = strings.TrimSpace(pair)
+		if pair == "" {
+			continue
+		}
+		name, value, ok := strings.Cut(pair, "=")
+		name, value = strings.TrimSpace(name), strings.TrimSpace(value)
+		if !ok || !validHeaderName(name) {
+			return nil, fmt.Errorf("expected Name=value with a valid header name, got %q", name)
+		}
+		if strings.ContainsAny(value, "\r\n") {
+			return nil, fmt.Errorf("value of header %s spans several lines", name)
+		}
+		if strings.EqualFold(name, "X-Consul-Token") {
+			return nil, fmt.Errorf("header %s is set from the ACL token options", name)
+		}
+		headers.Add(name, value)
+	}
+	return headers, nil
+}
+
+// validHeaderName reports whether name is an RFC 9110 token.
+func validHeaderName(name string) bool {
+	if name == "" {
+		return false
+	}
+	for _, c := range name {
+		if c > 0x7e || c <= ' ' || strings.ContainsRune(`"(),/:;<=>?@[\]{}`, c) {
+			return false
+		}
+	}
+	return true
+}
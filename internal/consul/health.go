@@ -0,0 +1,209 @@
+package consul
+
+import (
+	"context"
+	"log/slog"
+	"maps"
+	"slices"
+	"time"
+)
+
+// catalogChange is sent by the catalog loop of watchCatalog to watchHealth
+// in WatchModeService whenever the services listed change.
+type catalogChange struct {
+	names []string
+	// states holds the services fetched by the catalog loop once polling,
+	// which stops the health watches. It is nil while blocking queries are
+	// used.
+	states []ServiceState
+	// index is the index of the catalog query listing names.
+	index      uint64
+	detectedAt time.Time
+}
+
+// serviceChange is sent by the health watch of a service when its health
+// index moves.
+type serviceChange struct {
+	name string
+	// shape reports whether the tags or meta of the service changed too,
+	// which may change its objects beyond its endpoints.
+	shape      bool
+	detectedAt time.Time
+}
+
+// watchHealth runs a blocking health query for every service on the lists
+// received from catalog and sends a snapshot on out whenever the list or the
+// health of one service changes. Snapshots are built from the cache, kept
+// current by the health watches, so each change only fetches its service.
+// A snapshot for a single service whose tags and meta are unchanged says so
+// in Changed. Once the catalog loop polls, the health watches are stopped
+// and its snapshots are passed on.
+func (w *Watcher) watchHealth(ctx context.Context, catalog <-chan catalogChange, out chan<- Snapshot) {
+	defer close(out)
+	changes := make(chan serviceChange)
+	watches := make(map[string]context.CancelFunc)
+	defer func() {
+		for _, cancel := range watches {
+			cancel()
+		}
+	}()
+
+	var names []string
+	var catalogIndex uint64
+	for {
+		var snap Snapshot
+		select {
+		case c, ok := <-catalog:
+			if !ok {
+				return
+			}
+			names, catalogIndex = c.names, c.index
+			if c.states != nil {
+				for name, cancel := range watches {
+					cancel()
+					delete(watches, name)
+				}
+				snap = Snapshot{Services: c.states, DetectedAt: c.detectedAt}
+				break
+			}
+			var removed []string
+			for name, cancel := range watches {
+				if !slices.Contains(names, name) {
+					cancel()
+					delete(watches, name)
+					removed = append(removed, name)
+				}
+			}
+			w.dropCache(removed)
+			for _, name := range names {
+				if _, ok := watches[name]; ok {
+					continue
+				}
+				watchCtx, cancel := context.WithCancel(ctx)
+				watches[name] = cancel
+				go w.watchServiceHealth(watchCtx, name, changes)
+			}
+			snap = Snapshot{Services: w.cachedStates(names), DetectedAt: c.detectedAt}
+		case c := <-changes:
+			// Skip late changes of a service removed since.
+			if _, ok := watches[c.name]; !ok {
+				continue
+			}
+			snap = Snapshot{Services: w.cachedStates(names), DetectedAt: c.detectedAt}
+			if !c.shape {
+				snap.Changed = []string{c.name}
+			}
+		case <-ctx.Done():
+			return
+		}
+		snap.Index = max(catalogIndex, w.cachedIndex(names))
+
+		select {
+		case out <- snap:
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+// watchServiceHealth runs blocking queries on the health of the service
+// name, waiting on the index of its cached result, and sends a change on
+// changes whenever the index moves. A service whose health responses carry
+// no index isn't cached, so it is left to be fetched with the catalog.
+func (w *Watcher) watchServiceHealth(ctx context.Context, name string, changes chan<- serviceChange) {
+	backoff := time.Second
+	for {
+		w.cacheMu.Lock()
+		cached, ok := w.cache[name]
+		w.cacheMu.Unlock()
+
+		svc, err := w.getServiceAt(ctx, name, cached.index)
+		if err != nil {
+			if ctx.Err() != nil {
+				return
+			}
+			slog.Warn("failed to watch consul service health", "service", name, "error", err, "backoff", backoff)
+			select {
+			case <-ctx.Done():
+				return
+			case <-time.After(backoff):
+			}
+			backoff = min(backoff*2, 30*time.Second)
+			continue
+		}
+		backoff = time.Second
+
+		if svc.index == 0 {
+			slog.Warn("consul health response has no X-Consul-Index, not watching service", "service", name)
+			return
+		}
+		if ok && svc.index == cached.index {
+			continue
+		}
+		change := serviceChange{
+			name:       name,
+			shape:      !ok || !slices.Equal(svc.tags, cached.tags) || !maps.Equal(svc.meta, cached.meta),
+			detectedAt: time.Now(),
+		}
+		slog.Debug("consul service health changed", "service", name, "index", svc.index)
+		select {
+		case changes <- change:
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+// fetchMissing fetches the named services that aren't cached yet, which
+// their health watches then keep current.
+func (w *Watcher) fetchMissing(ctx context.Context, names []string) {
+	for _, name := range names {
+		w.cacheMu.Lock()
+		_, ok := w.cache[name]
+		w.cacheMu.Unlock()
+		if ok {
+			continue
+		}
+		if _, err := w.getService(ctx, name); err != nil {
+			slog.ErrorContext(ctx, "failed to get service instances", "service", name, "error", err)
+		}
+	}
+}
+
+// cachedStates builds the states of the named services from the cache.
+func (w *Watcher) cachedStates(names []string) []ServiceState {
+	w.cacheMu.Lock()
+	defer w.cacheMu.Unlock()
+	states := make([]ServiceState, 0, len(names))
+	for _, name := range names {
+		if c, ok := w.cache[name]; ok {
+			if c.skipKind {
+				continue
+			}
+			states = append(states, c.state(name))
+		} else {
+			states = append(states, w.unfetchedState(name))
+		}
+	}
+	return states
+}
+
+// cachedIndex returns the highest health index cached for names.
+func (w *Watcher) cachedIndex(names []string) uint64 {
+	w.cacheMu.Lock()
+	defer w.cacheMu.Unlock()
+	var index uint64
+	for _, name := range names {
+		index = max(index, w.cache[name].index)
+	}
+	return index
+}
+
+// dropCache forgets the cached results of services no longer listed.
+func (w *Watcher) dropCache(names []string) {
+	w.cacheMu.Lock()
+	defer w.cacheMu.Unlock()
+	for _, name := range names {
+		delete(w.cache, name)
+	}
+}
@@ -0,0 +1,263 @@
+package consul
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
+	"maps"
+	"net/http"
+	"net/url"
+	"time"
+)
+
+// hostnameMetaKey is the service meta key the syncer takes a route's
+// hostname from, see the kubernetes package.
+const hostnameMetaKey = "k8s-hostname"
+
+// IngressWatcher re-exports the services exposed through Consul ingress
+// gateways. Each service linked to a listener of a gateway becomes a service
+// named <service>-<gateway>, whose instances are the gateway's healthy
+// instances on the listener's port, so the service is reached through the
+// gateway without being registered for consul-sync itself. The first of the
+// listener's hosts becomes its route hostname. The gateways are polled.
+type IngressWatcher struct {
+	addr      string
+	gateways  []string
+	client    *http.Client
+	transport *swappableTransport
+	opts      Options
+}
+
+// NewIngressWatcher creates a watcher of the named ingress gateways on the
+// Consul agent at addr. Of opts, only PollInterval and the token settings are
+// used.
+func NewIngressWatcher(addr, token string, gateways []string, opts Options) *IngressWatcher {
+	transport := newSwappableTransport()
+	return &IngressWatcher{
+		addr:      addr,
+		gateways:  gateways,
+		transport: transport,
+		opts:      opts,
+		client: &http.Client{
+			Transport: newTokenTransport(withHeaders(transport, opts.Headers), addr, token, opts),
+			Timeout:   30 * time.Second,
+		},
+	}
+}
+
+// gatewayService is a single entry from /v1/catalog/gateway-services/<gateway>.
+type gatewayService struct {
+	Service struct {
+		Name string `json:"Name"`
+	} `json:"Service"`
+	GatewayKind string   `json:"GatewayKind"`
+	Port        int      `json:"Port"`
+	Protocol    string   `json:"Protocol"`
+	Hosts       []string `json:"Hosts"`
+}
+
+// SetTLS replaces the TLS configuration used for new connections to Consul.
+func (w *IngressWatcher) SetTLS(cfg TLSConfig) error {
+	tlsCfg, err := cfg.build()
+	if err != nil {
+		return err
+	}
+
+	t := http.DefaultTransport.(*http.Transport).Clone()
+	t.TLSClientConfig = tlsCfg
+	if old := w.transport.swap(t); old != nil {
+		old.CloseIdleConnections()
+	}
+	return nil
+}
+
+// WatchServices reads the gateways every PollInterval and sends a snapshot
+// whenever their services or instances changed.
+func (w *IngressWatcher) WatchServices(ctx context.Context) (<-chan Snapshot, error) {
+	ch := make(chan Snapshot, 1)
+
+	go func() {
+		defer close(ch)
+
+		var lastKey string
+		for first := true; ; first = false {
+			if !first {
+				select {
+				case <-ctx.Done():
+					return
+				case <-time.After(w.pollInterval()):
+				}
+			}
+
+			snap := Snapshot{DetectedAt: time.Now()}
+			states, err := w.FetchAllServices(ctx)
+			if err != nil {
+				if ctx.Err() != nil {
+					return
+				}
+				slog.Error("failed to read consul ingress gateways", "error", err)
+				continue
+			}
+
+			key, err := json.Marshal(states)
+			if err != nil {
+				slog.Error("failed to encode consul ingress gateway services", "error", err)
+				continue
+			}
+			if string(key) == lastKey {
+				continue
+			}
+			lastKey = string(key)
+
+			slog.Info("consul ingress gateway services changed", "services", len(states))
+			snap.Services = states
+			select {
+			case ch <- snap:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+
+	return ch, nil
+}
+
+func (w *IngressWatcher) pollInterval() time.Duration {
+	if w.opts.PollInterval > 0 {
+		return w.opts.PollInterval
+	}
+	return defaultPollInterval
+}
+
+// FetchService returns the re-exported service name.
+func (w *IngressWatcher) FetchService(ctx context.Context, name string) (ServiceState, error) {
+	states, err := w.FetchAllServices(ctx)
+	if err != nil {
+		return ServiceState{}, err
+	}
+	for _, st := range states {
+		if st.Name == name {
+			return st, nil
+		}
+	}
+	return ServiceState{Name: name}, nil
+}
+
+// FetchAllServices returns the services of every gateway. A gateway that
+// can't be read fails the whole fetch, so its services aren't treated as
+// removed.
+func (w *IngressWatcher) FetchAllServices(ctx context.Context) ([]ServiceState, error) {
+	var states []ServiceState
+	for _, gateway := range w.gateways {
+		gwStates, err := w.fetchGateway(ctx, gateway)
+		if err != nil {
+			return nil, fmt.Errorf("reading ingress gateway %s: %w", gateway, err)
+		}
+		states = append(states, gwStates...)
+	}
+	return states, nil
+}
+
+// fetchGateway returns the services linked to the listeners of gateway.
+// Wildcard links can't name a service and are skipped, and so are the links
+// of gateways of another kind.
+func (w *IngressWatcher) fetchGateway(ctx context.Context, gateway string) ([]ServiceState, error) {
+	var links []gatewayService
+	if err := w.get(ctx, "/v1/catalog/gateway-services/"+url.PathEscape(gateway), &links); err != nil {
+		return nil, err
+	}
+	var entries []healthServiceEntry
+	if err := w.get(ctx, "/v1/health/service/"+url.PathEscape(gateway), &entries); err != nil {
+		return nil, err
+	}
+
+	states := make([]ServiceState, 0, len(links))
+	for _, link := range links {
+		if link.GatewayKind != "ingress-gateway" {
+			slog.DebugContext(ctx, "skipping service of a non-ingress gateway", "gateway", gateway, "kind", link.GatewayKind)
+			continue
+		}
+		if link.Service.Name == "*" {
+			slog.DebugContext(ctx, "skipping wildcard ingress gateway listener", "gateway", gateway, "port", link.Port)
+			continue
+		}
+		states = append(states, ingressState(gateway, link, entries))
+	}
+	return states, nil
+}
+
+// ingressState builds the service re-exporting link of gateway, with one
+// instance per gateway instance whose checks all pass.
+func ingressState(gateway string, link gatewayService, entries []healthServiceEntry) ServiceState {
+	st := ServiceState{Name: link.Service.Name + "-" + gateway, Registered: len(entries)}
+	for _, e := range entries {
+		addr := e.Service.Address
+		if addr == "" {
+			addr = e.Node.Address
+		}
+		passing := true
+		for _, c := range e.Checks {
+			if c.Status == "passing" {
+				continue
+			}
+			passing = false
+			st.FailingChecks = append(st.FailingChecks, Check{
+				Node:      e.Node.Node,
+				ServiceID: c.ServiceID,
+				Address:   addr,
+				Name:      c.Name,
+				Status:    c.Status,
+				Output:    c.Output,
+			})
+		}
+		if !passing {
+			continue
+		}
+
+		meta := maps.Clone(e.Service.Meta)
+		if len(link.Hosts) > 0 {
+			if meta == nil {
+				meta = make(map[string]string, 1)
+			}
+			meta[hostnameMetaKey] = link.Hosts[0]
+		}
+		st.Instances = append(st.Instances, ServiceInstance{
+			ServiceName: link.Service.Name,
+			ID:          e.Service.ID,
+			Node:        e.Node.Node,
+			Address:     addr,
+			Port:        link.Port,
+			Tags:        internTags(e.Service.Tags),
+			Meta:        meta,
+			Weight:      e.Service.Weights.Passing,
+		})
+	}
+	st.Tags = collectTags(st.Instances)
+	st.Meta = collectMeta(st.Instances)
+	return st
+}
+
+// get decodes the JSON response of a GET to the Consul API path into out.
+func (w *IngressWatcher) get(ctx context.Context, path string, out any) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, w.addr+path, nil)
+	if err != nil {
+		return fmt.Errorf("creating request: %w", err)
+	}
+
+	resp, err := w.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("querying consul: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+		return fmt.Errorf("consul returned %d: %s", resp.StatusCode, string(body))
+	}
+	if err := json.NewDecoder(resp.Body).Decode(out); err != nil {
+		return fmt.Errorf("decoding response: %w", err)
+	}
+	return nil
+}
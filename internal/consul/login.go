@@ -0,0 +1,126 @@
+package consul
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"os"
+	"sync"
+	"time"
+)
+
+// DefaultBearerTokenFile is where Kubernetes mounts the pod's ServiceAccount
+// token.
+const DefaultBearerTokenFile = "/var/run/secrets/kubernetes.io/serviceaccount/token"
+
+// LoginConfig logs in to Consul with an ACL auth method instead of using a
+// static token.
+type LoginConfig struct {
+	// AuthMethod is the name of the auth method, typically of the
+	// kubernetes type.
+	AuthMethod string
+	// BearerTokenFile holds the JWT presented to the auth method. It is
+	// re-read on every login, so a rotated projected token is picked up.
+	BearerTokenFile string
+}
+
+// loginToken is a token obtained from POST /v1/acl/login. It logs in on first
+// use, again once two thirds of an expiring token's lifetime have passed, and
+// whenever Consul rejects the current token.
+type loginToken struct {
+	client *http.Client
+	addr   string
+	cfg    LoginConfig
+
+	mu      sync.Mutex
+	secret  string
+	renewAt time.Time // zero when the token doesn't expire
+}
+
+// aclLoginResponse is the part of the /v1/acl/login response used here.
+type aclLoginResponse struct {
+	AccessorID     string     `json:"AccessorID"`
+	SecretID       string     `json:"SecretID"`
+	CreateTime     time.Time  `json:"CreateTime"`
+	ExpirationTime *time.Time `json:"ExpirationTime"`
+}
+
+func (l *loginToken) token(ctx context.Context) (string, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.secret != "" && (l.renewAt.IsZero() || time.Now().Before(l.renewAt)) {
+		return l.secret, nil
+	}
+	if err := l.login(ctx); err != nil {
+		return "", err
+	}
+	return l.secret, nil
+}
+
+func (l *loginToken) refresh(ctx context.Context, rejected string) (string, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	// Another request may have logged in again since rejected was sent.
+	if l.secret != rejected {
+		return l.secret, nil
+	}
+	if err := l.login(ctx); err != nil {
+		return "", err
+	}
+	return l.secret, nil
+}
+
+// login exchanges the bearer token for a Consul token. l.mu must be held.
+func (l *loginToken) login(ctx context.Context) error {
+	jwt, err := os.ReadFile(l.cfg.BearerTokenFile)
+	if err != nil {
+		return fmt.Errorf("reading bearer token: %w", err)
+	}
+	body, err := json.Marshal(map[string]string{
+		"AuthMethod":  l.cfg.AuthMethod,
+		"BearerToken": string(bytes.TrimSpace(jwt)),
+	})
+	if err != nil {
+		return fmt.Errorf("encoding login request: %w", err)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, l.addr+"/v1/acl/login", bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("creating login request: %w", err)
+	}
+	resp, err := l.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("logging in to consul: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		msg, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+		return fmt.Errorf("consul login with auth method %s returned %d: %s", l.cfg.AuthMethod, resp.StatusCode, string(msg))
+	}
+
+	var token aclLoginResponse
+	if err := json.NewDecoder(resp.Body).Decode(&token); err != nil {
+		return fmt.Errorf("decoding login response: %w", err)
+	}
+	if token.SecretID == "" {
+		return fmt.Errorf("consul login with auth method %s returned no token", l.cfg.AuthMethod)
+	}
+
+	l.secret = token.SecretID
+	l.renewAt = time.Time{}
+	attrs := []any{"auth_method", l.cfg.AuthMethod, "accessor", token.AccessorID}
+	if token.ExpirationTime != nil {
+		created := token.CreateTime
+		if created.IsZero() {
+			created = time.Now()
+		}
+		l.renewAt = created.Add(token.ExpirationTime.Sub(created) * 2 / 3)
+		attrs = append(attrs, "expires", *token.ExpirationTime)
+	}
+	slog.InfoContext(ctx, "logged in to consul", attrs...)
+	return nil
+}
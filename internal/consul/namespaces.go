@@ -0,0 +1,138 @@
+package consul
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// AllNamespaces, as Options.Namespace, watches every Consul Enterprise
+// namespace the token can list.
+const AllNamespaces = "*"
+
+// namespaceEntry is a single entry from /v1/namespaces.
+type namespaceEntry struct {
+	Name string `json:"Name"`
+}
+
+// allNamespaces reports whether w lists the namespaces and merges the
+// services of one child watcher per namespace.
+func (w *Watcher) allNamespaces() bool {
+	return w.opts.Namespace == AllNamespaces && w.ns == ""
+}
+
+// namespaceWatcher returns the watcher of namespace ns, creating it on first
+// use.
+func (w *Watcher) namespaceWatcher(ns string) *Watcher {
+	return w.nss.get(ns, func() *Watcher { return w.newChild(ns, "") })
+}
+
+// ListNamespaces returns the names of the namespaces the token can list,
+// along with the Consul index for blocking queries, or 0 when none was
+// reported.
+func (w *Watcher) ListNamespaces(ctx context.Context, waitIndex uint64) ([]string, uint64, error) {
+	query := url.Values{}
+	if w.dc != "" {
+		query.Set("dc", w.dc)
+	}
+	query.Set("index", strconv.FormatUint(waitIndex, 10))
+	query.Set("wait", "5m")
+	reqURL := w.addr + "/v1/namespaces?" + query.Encode()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, reqURL, nil)
+	if err != nil {
+		return nil, 0, fmt.Errorf("creating request: %w", err)
+	}
+
+	resp, err := w.client.Do(req)
+	w.debug.recordResponse(endpointNamespaces, resp, err)
+	if err != nil {
+		return nil, 0, fmt.Errorf("querying consul: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+		return nil, 0, fmt.Errorf("consul returned %d: %s", resp.StatusCode, string(body))
+	}
+
+	newIndex, _ := strconv.ParseUint(resp.Header.Get("X-Consul-Index"), 10, 64)
+
+	var entries []namespaceEntry
+	if err := json.NewDecoder(resp.Body).Decode(&entries); err != nil {
+		return nil, 0, fmt.Errorf("decoding response: %w", err)
+	}
+
+	names := make([]string, 0, len(entries))
+	for _, e := range entries {
+		names = append(names, e.Name)
+	}
+	return names, newIndex, nil
+}
+
+// watchNamespaces watches the namespace list and runs the watch loop of
+// every namespace on it, see watchChildren.
+func (w *Watcher) watchNamespaces(ctx context.Context) (<-chan Snapshot, error) {
+	lists := make(chan []string)
+	go w.watchList(ctx, "namespace", &w.debug, w.ListNamespaces, lists)
+	create := func(ns string) *Watcher { return w.newChild(ns, "") }
+	return w.watchChildren(ctx, "namespace", lists, &w.nss, create, nil, mergeNamespaces), nil
+}
+
+func (w *Watcher) fetchAllNamespaces(ctx context.Context) ([]ServiceState, error) {
+	names, _, err := w.ListNamespaces(ctx, 0)
+	if err != nil {
+		return nil, fmt.Errorf("listing namespaces: %w", err)
+	}
+	all := make(map[string][]ServiceState, len(names))
+	for _, ns := range names {
+		states, err := w.namespaceWatcher(ns).FetchAllServices(ctx)
+		if err != nil {
+			return nil, fmt.Errorf("fetching namespace %s: %w", ns, err)
+		}
+		all[ns] = states
+	}
+	return mergeNamespaces(all), nil
+}
+
+// fetchServiceNamespaces fetches a service by its name qualified with its
+// namespace, see ServiceState.Namespace.
+func (w *Watcher) fetchServiceNamespaces(ctx context.Context, name string) (ServiceState, error) {
+	i := strings.LastIndexByte(name, '.')
+	if i < 0 {
+		return ServiceState{Name: name}, nil
+	}
+	st, err := w.namespaceWatcher(name[i+1:]).FetchService(ctx, name[:i])
+	if err != nil {
+		return ServiceState{}, err
+	}
+	st.Name = name
+	return st, nil
+}
+
+// mergeNamespaces qualifies the services of each namespace with it, as
+// <service>.<namespace>, and returns them sorted by namespace.
+func mergeNamespaces(all map[string][]ServiceState) []ServiceState {
+	namespaces := make([]string, 0, len(all))
+	n := 0
+	for ns, states := range all {
+		namespaces = append(namespaces, ns)
+		n += len(states)
+	}
+	slices.Sort(namespaces)
+
+	merged := make([]ServiceState, 0, n)
+	for _, ns := range namespaces {
+		for _, st := range all[ns] {
+			st.Name += "." + ns
+			merged = append(merged, st)
+		}
+	}
+	return merged
+}
@@ -0,0 +1,209 @@
+package consul
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// DefaultPeerNameFormat names the services imported from a cluster peer,
+// see Options.PeerNameFormat.
+const DefaultPeerNameFormat = "{service}-{peer}"
+
+// ValidatePeerNameFormat checks that f names every imported service apart:
+// it must hold {service} exactly once and {peer} at least once.
+func ValidatePeerNameFormat(f string) error {
+	if strings.Count(f, "{service}") != 1 {
+		return fmt.Errorf("peer name format %q must contain {service} exactly once", f)
+	}
+	if !strings.Contains(f, "{peer}") {
+		return fmt.Errorf("peer name format %q must contain {peer}", f)
+	}
+	return nil
+}
+
+// peeringEntry is a single entry from /v1/peerings.
+type peeringEntry struct {
+	Name  string `json:"Name"`
+	State string `json:"State"`
+}
+
+// peering reports whether w watches the services imported from its peers
+// alongside its own.
+func (w *Watcher) peering() bool {
+	return w.opts.Peering && w.peer == ""
+}
+
+// peerWatcher returns the watcher of the services imported from peer,
+// creating it on first use.
+func (w *Watcher) peerWatcher(peer string) *Watcher {
+	return w.peers.get(peer, func() *Watcher { return w.newChild(w.ns, peer) })
+}
+
+// peerName returns the name of service imported from peer.
+func (w *Watcher) peerName(service, peer string) string {
+	f := w.opts.PeerNameFormat
+	if f == "" {
+		f = DefaultPeerNameFormat
+	}
+	return strings.ReplaceAll(strings.ReplaceAll(f, "{service}", service), "{peer}", peer)
+}
+
+// ListPeers returns the names of the cluster peers, along with the Consul
+// index for blocking queries, or 0 when none was reported. Peerings being
+// deleted or terminated are left out, so their services are dropped.
+func (w *Watcher) ListPeers(ctx context.Context, waitIndex uint64) ([]string, uint64, error) {
+	query := url.Values{}
+	if w.dc != "" {
+		query.Set("dc", w.dc)
+	}
+	query.Set("index", strconv.FormatUint(waitIndex, 10))
+	query.Set("wait", "5m")
+	reqURL := w.addr + "/v1/peerings?" + query.Encode()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, reqURL, nil)
+	if err != nil {
+		return nil, 0, fmt.Errorf("creating request: %w", err)
+	}
+
+	resp, err := w.client.Do(req)
+	w.peerList.recordResponse(endpointPeerings, resp, err)
+	if err != nil {
+		return nil, 0, fmt.Errorf("querying consul: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+		return nil, 0, fmt.Errorf("consul returned %d: %s", resp.StatusCode, string(body))
+	}
+
+	newIndex, _ := strconv.ParseUint(resp.Header.Get("X-Consul-Index"), 10, 64)
+
+	var entries []peeringEntry
+	if err := json.NewDecoder(resp.Body).Decode(&entries); err != nil {
+		return nil, 0, fmt.Errorf("decoding response: %w", err)
+	}
+
+	names := make([]string, 0, len(entries))
+	for _, e := range entries {
+		if e.State == "DELETING" || e.State == "TERMINATED" {
+			continue
+		}
+		names = append(names, e.Name)
+	}
+	return names, newIndex, nil
+}
+
+// watchPeers watches w's own services and the peer list, and runs the watch
+// loop of every peer on it, see watchChildren.
+func (w *Watcher) watchPeers(ctx context.Context) (<-chan Snapshot, error) {
+	lists := make(chan []string)
+	go w.watchList(ctx, "peer", &w.peerList, w.ListPeers, lists)
+	create := func(peer string) *Watcher { return w.newChild(w.ns, peer) }
+	return w.watchChildren(ctx, "peer", lists, &w.peers, create, w.watchCatalog(ctx), w.mergePeers), nil
+}
+
+func (w *Watcher) fetchAllPeers(ctx context.Context) ([]ServiceState, error) {
+	peers, _, err := w.ListPeers(ctx, 0)
+	if err != nil {
+		return nil, fmt.Errorf("listing peers: %w", err)
+	}
+	names, _, err := w.ListServices(ctx, 0)
+	if err != nil {
+		return nil, err
+	}
+	all := map[string][]ServiceState{"": w.fetchStates(ctx, names)}
+	for _, peer := range peers {
+		states, err := w.peerWatcher(peer).FetchAllServices(ctx)
+		if err != nil {
+			return nil, fmt.Errorf("fetching peer %s: %w", peer, err)
+		}
+		all[peer] = states
+	}
+	return w.mergePeers(all), nil
+}
+
+// fetchServicePeers fetches a service by its name: a local service of that
+// name, as mergePeers prefers them, or else the imported service it is the
+// PeerNameFormat name of.
+func (w *Watcher) fetchServicePeers(ctx context.Context, name string) (ServiceState, error) {
+	svc, err := w.getService(ctx, name)
+	if err != nil {
+		return ServiceState{}, err
+	}
+	if svc.registered > 0 {
+		return svc.state(name), nil
+	}
+	peers, _, err := w.ListPeers(ctx, 0)
+	if err != nil {
+		return ServiceState{}, fmt.Errorf("listing peers: %w", err)
+	}
+	for _, peer := range peers {
+		service, ok := w.peerService(name, peer)
+		if !ok {
+			continue
+		}
+		st, err := w.peerWatcher(peer).FetchService(ctx, service)
+		if err != nil {
+			return ServiceState{}, err
+		}
+		if st.Registered > 0 {
+			st.Name = name
+			return st, nil
+		}
+	}
+	return svc.state(name), nil
+}
+
+// peerService returns the service imported from peer that name is given by
+// PeerNameFormat, if any.
+func (w *Watcher) peerService(name, peer string) (string, bool) {
+	prefix, suffix, _ := strings.Cut(w.peerName("\x00", peer), "\x00")
+	if len(name) <= len(prefix)+len(suffix) || !strings.HasPrefix(name, prefix) || !strings.HasSuffix(name, suffix) {
+		return "", false
+	}
+	return name[len(prefix) : len(name)-len(suffix)], true
+}
+
+// mergePeers names the services imported from each peer with
+// PeerNameFormat and returns them after the local services, under the
+// empty peer, sorted by peer. An imported service whose name is already
+// taken, locally or by another peer, is left out.
+func (w *Watcher) mergePeers(all map[string][]ServiceState) []ServiceState {
+	peers := make([]string, 0, len(all))
+	n := 0
+	for peer, states := range all {
+		peers = append(peers, peer)
+		n += len(states)
+	}
+	slices.Sort(peers)
+
+	merged := make([]ServiceState, 0, n)
+	taken := make(map[string]string, n)
+	for _, peer := range peers {
+		for _, st := range all[peer] {
+			if peer != "" {
+				st.Name = w.peerName(st.Name, peer)
+			}
+			if other, ok := taken[st.Name]; ok {
+				if other == "" {
+					other = "local"
+				}
+				slog.Warn("skipping imported service, its name is already taken",
+					"service", st.Name, "peer", peer, "taken_by", other)
+				continue
+			}
+			taken[st.Name] = peer
+			merged = append(merged, st)
+		}
+	}
+	return merged
+}
@@ -0,0 +1,212 @@
+package consul
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"time"
+)
+
+// QueryWatcher reads services from Consul prepared queries instead of the
+// catalog, so the failover, nearness and health policies of each query,
+// computed by Consul, pick the instances. Each query becomes a service named
+// after it, holding the instances its execution returns. Prepared queries
+// have no blocking form, so they are polled.
+type QueryWatcher struct {
+	addr      string
+	queries   []string
+	client    *http.Client
+	transport *swappableTransport
+	opts      Options
+}
+
+// NewQueryWatcher creates a watcher executing the prepared queries, given by
+// name or ID, on the Consul agent at addr. Of opts, only PollInterval and the
+// token settings are used.
+func NewQueryWatcher(addr, token string, queries []string, opts Options) *QueryWatcher {
+	transport := newSwappableTransport()
+	return &QueryWatcher{
+		addr:      addr,
+		queries:   queries,
+		transport: transport,
+		opts:      opts,
+		client: &http.Client{
+			Transport: newTokenTransport(withHeaders(transport, opts.Headers), addr, token, opts),
+			Timeout:   30 * time.Second,
+		},
+	}
+}
+
+// preparedQueryResponse is the response of /v1/query/<query>/execute.
+type preparedQueryResponse struct {
+	Service string               `json:"Service"`
+	Nodes   []healthServiceEntry `json:"Nodes"`
+	// Datacenter is the datacenter that answered, which differs from the
+	// agent's own after a failover, counted in Failovers.
+	Datacenter string `json:"Datacenter"`
+	Failovers  int    `json:"Failovers"`
+}
+
+// SetTLS replaces the TLS configuration used for new connections to Consul.
+func (w *QueryWatcher) SetTLS(cfg TLSConfig) error {
+	tlsCfg, err := cfg.build()
+	if err != nil {
+		return err
+	}
+
+	t := http.DefaultTransport.(*http.Transport).Clone()
+	t.TLSClientConfig = tlsCfg
+	if old := w.transport.swap(t); old != nil {
+		old.CloseIdleConnections()
+	}
+	return nil
+}
+
+// WatchServices executes the queries every PollInterval and sends a snapshot
+// whenever their results changed.
+func (w *QueryWatcher) WatchServices(ctx context.Context) (<-chan Snapshot, error) {
+	ch := make(chan Snapshot, 1)
+
+	go func() {
+		defer close(ch)
+
+		var lastKey string
+		for first := true; ; first = false {
+			if !first {
+				select {
+				case <-ctx.Done():
+					return
+				case <-time.After(w.pollInterval()):
+				}
+			}
+
+			snap := Snapshot{DetectedAt: time.Now()}
+			states, err := w.FetchAllServices(ctx)
+			if err != nil {
+				if ctx.Err() != nil {
+					return
+				}
+				slog.Error("failed to execute consul prepared queries", "error", err)
+				continue
+			}
+
+			// Prepared queries return no index to compare, so compare the
+			// services themselves.
+			key, err := json.Marshal(states)
+			if err != nil {
+				slog.Error("failed to encode consul prepared query results", "error", err)
+				continue
+			}
+			if string(key) == lastKey {
+				continue
+			}
+			lastKey = string(key)
+
+			slog.Info("consul prepared query results changed", "services", len(states))
+			snap.Services = states
+			select {
+			case ch <- snap:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+
+	return ch, nil
+}
+
+func (w *QueryWatcher) pollInterval() time.Duration {
+	if w.opts.PollInterval > 0 {
+		return w.opts.PollInterval
+	}
+	return defaultPollInterval
+}
+
+// FetchService executes the prepared query name.
+func (w *QueryWatcher) FetchService(ctx context.Context, name string) (ServiceState, error) {
+	return w.execute(ctx, name)
+}
+
+// FetchAllServices executes every prepared query. A query that fails to
+// execute is still returned, with nil instances, so it isn't treated as
+// removed.
+func (w *QueryWatcher) FetchAllServices(ctx context.Context) ([]ServiceState, error) {
+	states := make([]ServiceState, 0, len(w.queries))
+	for _, query := range w.queries {
+		st, err := w.execute(ctx, query)
+		if err != nil {
+			if ctx.Err() != nil {
+				return nil, ctx.Err()
+			}
+			slog.ErrorContext(ctx, "failed to execute consul prepared query", "query", query, "error", err)
+			st = ServiceState{Name: query}
+		}
+		states = append(states, st)
+	}
+	return states, nil
+}
+
+// execute runs the prepared query and builds the service named after it.
+// Every instance returned is kept: the query's own health settings, such as
+// OnlyPassing and IgnoreCheckIDs, already decided which ones qualify.
+func (w *QueryWatcher) execute(ctx context.Context, query string) (ServiceState, error) {
+	reqURL := fmt.Sprintf("%s/v1/query/%s/execute", w.addr, url.PathEscape(query))
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, reqURL, nil)
+	if err != nil {
+		return ServiceState{}, fmt.Errorf("creating request: %w", err)
+	}
+
+	resp, err := w.client.Do(req)
+	if err != nil {
+		return ServiceState{}, fmt.Errorf("querying consul: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+		return ServiceState{}, fmt.Errorf("consul returned %d: %s", resp.StatusCode, string(body))
+	}
+
+	var result preparedQueryResponse
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return ServiceState{}, fmt.Errorf("decoding response: %w", err)
+	}
+
+	instances := make([]ServiceInstance, 0, len(result.Nodes))
+	for _, e := range result.Nodes {
+		addr := e.Service.Address
+		if addr == "" {
+			addr = e.Node.Address
+		}
+		instances = append(instances, ServiceInstance{
+			ServiceName: e.Service.Service,
+			ID:          e.Service.ID,
+			Node:        e.Node.Node,
+			Address:     addr,
+			Port:        e.Service.Port,
+			Tags:        internTags(e.Service.Tags),
+			Meta:        e.Service.Meta,
+			Datacenter:  result.Datacenter,
+			Weight:      e.Service.Weights.Passing,
+		})
+	}
+	if result.Failovers > 0 {
+		slog.DebugContext(ctx, "consul prepared query failed over", "query", query,
+			"datacenter", result.Datacenter, "failovers", result.Failovers)
+	}
+
+	st := ServiceState{
+		Name:      query,
+		Instances: instances,
+		Tags:      collectTags(instances),
+		Meta:      collectMeta(instances),
+	}
+	if result.Datacenter != "" {
+		st.Datacenters = []string{result.Datacenter}
+	}
+	return st, nil
+}
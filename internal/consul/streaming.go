@@ -0,0 +1,51 @@
+package consul
+
+import (
+	"log/slog"
+	"net/http"
+
+	"github.com/alexieff-io/consul-sync/internal/metrics"
+)
+
+// queryBackendStreaming is the X-Consul-Query-Backend of health responses
+// answered from the agent's streaming view, as opposed to "blocking-query".
+const queryBackendStreaming = "streaming"
+
+// streamingFetchWait bounds the wait of the plain fetches Options.Streaming
+// turns into blocking queries on index 1, which any service past its first
+// Raft write answers at once.
+const streamingFetchWait = "10s"
+
+// healthWait returns the index and wait of a health query waiting on
+// waitIndex. With Options.Streaming, plain fetches wait on index 1 too: the
+// agent only answers blocking queries from its streaming view, so this keeps
+// first fetches, resyncs and polls off the servers as well.
+func (w *Watcher) healthWait(waitIndex uint64) (uint64, string) {
+	if waitIndex != 0 {
+		return waitIndex, "5m"
+	}
+	if w.opts.Streaming {
+		return 1, streamingFetchWait
+	}
+	return 0, ""
+}
+
+// observeQueryBackend counts the backend that answered a health query, from
+// its X-Consul-Query-Backend header, and warns once when Options.Streaming
+// is set but the agent answered with a blocking query on the servers.
+func (w *Watcher) observeQueryBackend(resp *http.Response) {
+	if resp == nil {
+		return
+	}
+	backend := resp.Header.Get("X-Consul-Query-Backend")
+	if backend == "" {
+		return
+	}
+	metrics.ConsulQueryBackend.WithLabelValues(backend).Inc()
+	if w.opts.Streaming && backend != queryBackendStreaming {
+		w.streamingWarning.Do(func() {
+			slog.Warn("consul agent is not answering health queries from its streaming backend, check use_streaming_backend on the agent and rpc.enable_streaming on the servers",
+				"backend", backend)
+		})
+	}
+}
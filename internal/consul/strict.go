@@ -0,0 +1,130 @@
+package consul
+
+import (
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/netip"
+	"strings"
+
+	"github.com/alexieff-io/consul-sync/internal/metrics"
+)
+
+// DefaultStrictMaxInstances is the default StrictConfig.MaxInstances.
+const DefaultStrictMaxInstances = 1000
+
+// StrictConfig enables sanity checks of the catalog and health responses,
+// so a misbehaving or compromised Consul can't empty or redirect the
+// endpoints with garbage. A rejected health response leaves its service on
+// the instances of its last accepted one, and a rejected catalog response is
+// retried like a failed query, keeping the services of the last snapshot.
+type StrictConfig struct {
+	// MaxInstances rejects health responses listing more instances than
+	// this. Zero means DefaultStrictMaxInstances.
+	MaxInstances int
+}
+
+// Reasons reported on the rejected response metric.
+const (
+	rejectNull    = "null"
+	rejectName    = "name"
+	rejectPort    = "port"
+	rejectAddress = "address"
+	rejectCount   = "instance_count"
+)
+
+// rejectedError is a response failing the checks of StrictConfig.
+type rejectedError struct {
+	reason string
+	detail string
+}
+
+func (e *rejectedError) Error() string {
+	return "rejected suspicious consul response: " + e.detail
+}
+
+func reject(reason, format string, args ...any) error {
+	return &rejectedError{reason: reason, detail: fmt.Sprintf(format, args...)}
+}
+
+// isRejected reports whether err is a response rejected by the strict
+// checks, rather than a failed query.
+func isRejected(err error) bool {
+	var rejected *rejectedError
+	return errors.As(err, &rejected)
+}
+
+// countRejected counts a response from endpoint rejected with err.
+func countRejected(endpoint string, err error) {
+	var rejected *rejectedError
+	if errors.As(err, &rejected) {
+		metrics.RejectedConsulResponses.WithLabelValues(endpoint, rejected.reason).Inc()
+	}
+}
+
+// checkCatalog checks a /v1/catalog/services response.
+func (w *Watcher) checkCatalog(catalog catalogServicesResponse) error {
+	if catalog == nil {
+		return reject(rejectNull, "catalog is null")
+	}
+	if _, ok := catalog[""]; ok {
+		return reject(rejectName, "catalog lists a service without a name")
+	}
+	return nil
+}
+
+// checkHealth checks a /v1/health/service response for the service name:
+// every entry must be a registration of that service with an ID, a node, a
+// port and an IP address, and there must be at most MaxInstances of them.
+func (w *Watcher) checkHealth(name string, entries []healthServiceEntry) error {
+	if entries == nil {
+		return reject(rejectNull, "health of %s is null", name)
+	}
+	maxInstances := w.opts.Strict.MaxInstances
+	if maxInstances <= 0 {
+		maxInstances = DefaultStrictMaxInstances
+	}
+	if len(entries) > maxInstances {
+		return reject(rejectCount, "%s has %d instances, more than %d", name, len(entries), maxInstances)
+	}
+	for _, e := range entries {
+		if e.Service.ID == "" || e.Node.Node == "" {
+			return reject(rejectNull, "%s has an instance without a service ID or node", name)
+		}
+		if !strings.EqualFold(e.Service.Service, name) {
+			return reject(rejectName, "health of %s lists instance %s of service %q", name, e.Service.ID, e.Service.Service)
+		}
+		if e.Service.Port <= 0 || e.Service.Port > 65535 {
+			return reject(rejectPort, "%s instance %s has port %d", name, e.Service.ID, e.Service.Port)
+		}
+		addr := e.Service.Address
+		if addr == "" {
+			addr = e.Node.Address
+		}
+		if _, err := netip.ParseAddr(addr); err != nil {
+			return reject(rejectAddress, "%s instance %s has address %q", name, e.Service.ID, addr)
+		}
+	}
+	return nil
+}
+
+// quarantine handles a health response of the service name rejected with
+// err: the service keeps its last accepted instances, cached under the
+// response's index so blocking queries move on, or fails to fetch when it
+// has none.
+func (w *Watcher) quarantine(name string, index uint64, err error) (cachedService, error) {
+	countRejected(endpointHealthService, err)
+
+	w.cacheMu.Lock()
+	defer w.cacheMu.Unlock()
+	cached, ok := w.cache[name]
+	if !ok {
+		return cachedService{}, err
+	}
+	slog.Warn("keeping last instances of service, consul response rejected", "service", name, "error", err)
+	if index != 0 {
+		cached.index = index
+		w.cache[name] = cached
+	}
+	return cached, nil
+}
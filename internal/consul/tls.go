@@ -0,0 +1,86 @@
+package consul
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"net/http"
+	"sync/atomic"
+)
+
+// TLSConfig holds the PEM-encoded material used to talk to Consul over HTTPS.
+type TLSConfig struct {
+	CACert     []byte
+	ClientCert []byte
+	ClientKey  []byte
+
+	// ServerName overrides the name the server certificate is verified
+	// against, for when Consul is addressed by IP or through a proxy.
+	ServerName string
+	// InsecureSkipVerify disables server certificate verification.
+	InsecureSkipVerify bool
+}
+
+func (c TLSConfig) build() (*tls.Config, error) {
+	cfg := &tls.Config{
+		MinVersion:         tls.VersionTLS12,
+		ServerName:         c.ServerName,
+		InsecureSkipVerify: c.InsecureSkipVerify,
+	}
+
+	if len(c.CACert) > 0 {
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(c.CACert) {
+			return nil, errors.New("no valid certificates in CA bundle")
+		}
+		cfg.RootCAs = pool
+	}
+
+	if len(c.ClientCert) > 0 || len(c.ClientKey) > 0 {
+		cert, err := tls.X509KeyPair(c.ClientCert, c.ClientKey)
+		if err != nil {
+			return nil, fmt.Errorf("loading client certificate: %w", err)
+		}
+		cfg.Certificates = []tls.Certificate{cert}
+	}
+
+	return cfg, nil
+}
+
+// SetTLS replaces the TLS configuration used for new connections to Consul.
+// Requests already in flight, including long-running blocking queries, finish
+// on their existing connections.
+func (w *Watcher) SetTLS(cfg TLSConfig) error {
+	tlsCfg, err := cfg.build()
+	if err != nil {
+		return err
+	}
+
+	t := http.DefaultTransport.(*http.Transport).Clone()
+	t.TLSClientConfig = tlsCfg
+	if old := w.transport.swap(t); old != nil {
+		old.CloseIdleConnections()
+	}
+	return nil
+}
+
+// swappableTransport delegates to an *http.Transport that can be replaced at
+// runtime, so rotated certificates take effect without recreating the client.
+type swappableTransport struct {
+	current atomic.Pointer[http.Transport]
+}
+
+func newSwappableTransport() *swappableTransport {
+	t := &swappableTransport{}
+	t.current.Store(http.DefaultTransport.(*http.Transport).Clone())
+	return t
+}
+
+func (t *swappableTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	return t.current.Load().RoundTrip(req)
+}
+
+func (t *swappableTransport) swap(next *http.Transport) *http.Transport {
+	return t.current.Swap(next)
+}
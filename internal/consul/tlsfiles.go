@@ -0,0 +1,95 @@
+package consul
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"time"
+)
+
+// tlsFilesPollInterval is how often TLS files are re-read for changes.
+const tlsFilesPollInterval = 30 * time.Second
+
+// TLSFiles names PEM files holding the material used to talk to Consul over
+// HTTPS, as mounted from a Secret or written by an agent such as Vault's.
+type TLSFiles struct {
+	CACert     string
+	ClientCert string
+	ClientKey  string
+}
+
+// IsZero reports whether no file is set.
+func (f TLSFiles) IsZero() bool {
+	return f == TLSFiles{}
+}
+
+// Validate checks that the client certificate and key are set together.
+func (f TLSFiles) Validate() error {
+	if (f.ClientCert == "") != (f.ClientKey == "") {
+		return errors.New("client certificate and key must be set together")
+	}
+	return nil
+}
+
+// Load reads the files into a TLSConfig.
+func (f TLSFiles) Load() (TLSConfig, error) {
+	var cfg TLSConfig
+	for _, file := range []struct {
+		path string
+		dst  *[]byte
+	}{
+		{f.CACert, &cfg.CACert},
+		{f.ClientCert, &cfg.ClientCert},
+		{f.ClientKey, &cfg.ClientKey},
+	} {
+		if file.path == "" {
+			continue
+		}
+		data, err := os.ReadFile(file.path)
+		if err != nil {
+			return TLSConfig{}, fmt.Errorf("reading tls file: %w", err)
+		}
+		*file.dst = data
+	}
+	return cfg, nil
+}
+
+// WatchTLSFiles re-reads the files every tlsFilesPollInterval and calls apply
+// with the new material whenever their contents changed since current. Files
+// are compared by content rather than modification time, since Kubernetes
+// rotates mounted Secrets by swapping a symlink. Material that fails to load
+// or apply is logged and the previous material stays in effect. It blocks
+// until the context is cancelled.
+func WatchTLSFiles(ctx context.Context, files TLSFiles, current TLSConfig, apply func(TLSConfig) error) {
+	ticker := time.NewTicker(tlsFilesPollInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		next, err := files.Load()
+		if err != nil {
+			slog.Error("failed to reload consul tls files", "error", err)
+			continue
+		}
+		if bytes.Equal(next.CACert, current.CACert) &&
+			bytes.Equal(next.ClientCert, current.ClientCert) &&
+			bytes.Equal(next.ClientKey, current.ClientKey) {
+			continue
+		}
+		if err := apply(next); err != nil {
+			// A certificate and key rotated separately can be caught in
+			// between; the next poll sees the matching pair.
+			slog.Error("failed to reload consul tls files", "error", err)
+			continue
+		}
+		current = next
+		slog.Info("reloaded consul tls files")
+	}
+}
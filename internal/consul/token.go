@@ -0,0 +1,147 @@
+package consul
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+// tokenFileRefresh is how often a token file is re-read.
+const tokenFileRefresh = 30 * time.Second
+
+// tokenTransport sets the ACL token on every request. When Consul rejects a
+// request with 403, the token is refreshed and the request retried once if
+// the token changed, so a rotated or expired token is replaced without
+// restarting, even while blocking queries hold the previous one.
+type tokenTransport struct {
+	next   http.RoundTripper
+	tokens tokenSource
+}
+
+// tokenSource supplies the ACL token sent with each request.
+type tokenSource interface {
+	// token returns the token to send.
+	token(ctx context.Context) (string, error)
+	// refresh returns a new token after Consul rejected rejected.
+	refresh(ctx context.Context, rejected string) (string, error)
+}
+
+// newTokenTransport returns next wrapped to send the ACL token: one obtained
+// by logging in with opts.Login, one read from opts.Vault, the contents of
+// opts.TokenFile, or token.
+// next is returned as is when none is set. addr is Consul's address, for
+// logins.
+func newTokenTransport(next http.RoundTripper, addr, token string, opts Options) http.RoundTripper {
+	var tokens tokenSource
+	switch {
+	case opts.Login != nil:
+		tokens = &loginToken{
+			client: &http.Client{Transport: next, Timeout: 30 * time.Second},
+			addr:   addr,
+			cfg:    *opts.Login,
+		}
+	case opts.Vault != nil:
+		tokens = newVaultToken(*opts.Vault)
+	case opts.TokenFile != "":
+		tokens = &fileToken{file: opts.TokenFile, secret: token, readAt: time.Now()}
+	case token != "":
+		tokens = staticToken(token)
+	default:
+		return next
+	}
+	return &tokenTransport{next: next, tokens: tokens}
+}
+
+func (t *tokenTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	ctx := req.Context()
+	token, err := t.tokens.token(ctx)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := t.next.RoundTrip(withToken(req, token))
+	if err != nil || resp.StatusCode != http.StatusForbidden || req.Body != nil {
+		return resp, err
+	}
+
+	next, err := t.tokens.refresh(ctx, token)
+	if err != nil {
+		slog.ErrorContext(ctx, "failed to refresh consul token", "error", err)
+		return resp, nil
+	}
+	if next == token {
+		return resp, nil
+	}
+	resp.Body.Close()
+	return t.next.RoundTrip(withToken(req, next))
+}
+
+func withToken(req *http.Request, token string) *http.Request {
+	req = req.Clone(req.Context())
+	req.Header.Set("X-Consul-Token", token)
+	return req
+}
+
+// staticToken is a token that never changes.
+type staticToken string
+
+func (s staticToken) token(context.Context) (string, error) { return string(s), nil }
+
+func (s staticToken) refresh(context.Context, string) (string, error) { return string(s), nil }
+
+// ReadTokenFile returns the token in file, without surrounding whitespace.
+func ReadTokenFile(file string) (string, error) {
+	data, err := os.ReadFile(file)
+	if err != nil {
+		return "", fmt.Errorf("reading token file: %w", err)
+	}
+	token := strings.TrimSpace(string(data))
+	if token == "" {
+		return "", fmt.Errorf("token file %s is empty", file)
+	}
+	return token, nil
+}
+
+// fileToken is a token read from a file, re-read every tokenFileRefresh and
+// on refresh. A file that can't be read leaves the previous token in use.
+type fileToken struct {
+	file string
+
+	mu     sync.Mutex
+	secret string
+	readAt time.Time
+}
+
+func (f *fileToken) token(context.Context) (string, error) {
+	return f.current(false), nil
+}
+
+func (f *fileToken) refresh(context.Context, string) (string, error) {
+	return f.current(true), nil
+}
+
+// current returns the token, re-reading the file first if it is due or
+// force is set.
+func (f *fileToken) current(force bool) string {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if !force && time.Since(f.readAt) < tokenFileRefresh {
+		return f.secret
+	}
+
+	f.readAt = time.Now()
+	token, err := ReadTokenFile(f.file)
+	if err != nil {
+		slog.Error("failed to reload consul token", "file", f.file, "error", err)
+		return f.secret
+	}
+	if token != f.secret {
+		slog.Info("reloaded consul token", "file", f.file)
+		f.secret = token
+	}
+	return f.secret
+}
@@ -1,16 +1,81 @@
 package consul
 
+import "time"
+
 // ServiceInstance represents a single healthy instance of a Consul service.
 type ServiceInstance struct {
 	ServiceName string
 	Address     string
 	Port        int
 	Tags        []string
+	Meta        map[string]string
+	// Datacenter is the Consul datacenter the instance was read from, set
+	// when Options.Datacenters lists the datacenters to watch, and by
+	// QueryWatcher to the datacenter its prepared query was answered by.
+	Datacenter string
+	// ID is the service ID of the instance, unique on its Node, and kept
+	// across address changes. Node is empty for sources with a single
+	// node, such as the local agent, and both are empty for static
+	// services.
+	ID   string
+	Node string
+	// Weight is the instance's Consul DNS weight while its checks pass, or
+	// zero when the source doesn't report weights. Only passing instances
+	// are kept, so the warning weight never applies.
+	Weight int
 }
 
 // ServiceState represents a Consul service and all its healthy instances.
 type ServiceState struct {
 	Name      string
 	Instances []ServiceInstance
-	Tags      []string // union of tags across all instances
+	Tags      []string          // union of tags across all instances
+	Meta      map[string]string // merged meta; on conflicts the first instance wins
+
+	// Registered counts every registered instance, healthy or not, or is
+	// zero when the source doesn't report unhealthy instances.
+	Registered int
+	// FailingChecks lists the checks that aren't passing, which keep the
+	// instances they belong to out of Instances.
+	FailingChecks []Check
+	// Datacenters lists the datacenters the service is registered in, in
+	// Options.Datacenters order, when that lists the datacenters to watch.
+	// For a prepared query, it is the datacenter that answered it.
+	Datacenters []string
+	// Namespace is the Consul Enterprise namespace of the service, set when
+	// Options.Namespace is. With AllNamespaces, Name is qualified with it
+	// as <service>.<namespace>, keeping services of the same name in
+	// different namespaces apart.
+	Namespace string
+	// Peer is the cluster peer the service is imported from, empty for
+	// local services. Name is then formatted with Options.PeerNameFormat.
+	Peer string
+}
+
+// Check is a health check that isn't passing.
+type Check struct {
+	Datacenter string // like ServiceInstance.Datacenter
+	Node       string
+	ServiceID  string // empty for node-level checks
+	Address    string // address of the instance the check keeps out
+	Name       string
+	Status     string // warning or critical
+	Output     string
+}
+
+// Snapshot is the full set of services sent by WatchServices after a change.
+type Snapshot struct {
+	Services []ServiceState
+	// DetectedAt is when the change was detected, before the services'
+	// instances were fetched.
+	DetectedAt time.Time
+	// Changed lists the services whose instances or health checks are all
+	// that changed since the previous snapshot, so only they need syncing.
+	// Nil means any service may have changed.
+	Changed []string
+	// Index is the highest Consul index the snapshot reflects, of the
+	// catalog query and the health responses of its services, so snapshots
+	// of one cluster can be ordered. It is zero when unknown, and for
+	// several datacenters, whose indexes aren't comparable.
+	Index uint64
 }
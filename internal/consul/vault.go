@@ -0,0 +1,233 @@
+package consul
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+// DefaultVaultMount is where Vault's Consul secrets engine is usually
+// mounted.
+const DefaultVaultMount = "consul"
+
+// vaultRetry is how long a token whose lease couldn't be renewed or replaced
+// keeps being used before Vault is tried again.
+const vaultRetry = 10 * time.Second
+
+// VaultConfig obtains the ACL token from the Consul secrets engine of Vault
+// instead of using a static token.
+type VaultConfig struct {
+	// Addr is Vault's address, such as https://vault.example.com:8200.
+	Addr string
+	// Mount is the path the Consul secrets engine is mounted at. Defaults
+	// to DefaultVaultMount.
+	Mount string
+	// Role is the secrets engine role credentials are generated for.
+	Role string
+	// Namespace is the Vault Enterprise namespace, if any.
+	Namespace string
+
+	// Token authenticates to Vault. TokenFile, when set, is read instead on
+	// every request, so a token kept fresh by Vault Agent is picked up.
+	Token     string
+	TokenFile string
+
+	// TLS verifies Vault's certificate. Only CACert, ServerName and
+	// InsecureSkipVerify are used.
+	TLS TLSConfig
+}
+
+// Validate checks that the role and a Vault token are set and that the TLS
+// material loads.
+func (c VaultConfig) Validate() error {
+	if c.Addr == "" {
+		return errors.New("vault address is required")
+	}
+	if c.Role == "" {
+		return errors.New("vault role is required")
+	}
+	if c.Token == "" && c.TokenFile == "" {
+		return errors.New("vault token or token file is required")
+	}
+	if _, err := c.TLS.build(); err != nil {
+		return fmt.Errorf("vault tls: %w", err)
+	}
+	return nil
+}
+
+// vaultToken is a token read from Vault's Consul secrets engine. Its lease is
+// renewed once two thirds of it have passed, and new credentials are read
+// when the lease can't be renewed, nears its max TTL, or Consul rejects the
+// token. Leases of replaced tokens are left to expire.
+type vaultToken struct {
+	client *http.Client
+	cfg    VaultConfig
+
+	mu        sync.Mutex
+	secret    string
+	leaseID   string
+	lease     time.Duration // of the credentials when read
+	renewAt   time.Time     // zero when the lease doesn't expire
+	renewable bool
+}
+
+// vaultSecret is the part of Vault's response to reading credentials or
+// renewing a lease used here.
+type vaultSecret struct {
+	LeaseID       string `json:"lease_id"`
+	LeaseDuration int    `json:"lease_duration"`
+	Renewable     bool   `json:"renewable"`
+	Data          struct {
+		Token    string `json:"token"`
+		Accessor string `json:"accessor"`
+	} `json:"data"`
+}
+
+func newVaultToken(cfg VaultConfig) *vaultToken {
+	if cfg.Mount == "" {
+		cfg.Mount = DefaultVaultMount
+	}
+	cfg.Addr = strings.TrimSuffix(cfg.Addr, "/")
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	// Validate has checked the material already.
+	transport.TLSClientConfig, _ = cfg.TLS.build()
+	return &vaultToken{
+		client: &http.Client{Transport: transport, Timeout: 30 * time.Second},
+		cfg:    cfg,
+	}
+}
+
+func (v *vaultToken) token(ctx context.Context) (string, error) {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	if v.secret != "" && (v.renewAt.IsZero() || time.Now().Before(v.renewAt)) {
+		return v.secret, nil
+	}
+	if v.secret != "" && v.renewable {
+		err := v.renew(ctx)
+		if err == nil {
+			return v.secret, nil
+		}
+		slog.WarnContext(ctx, "failed to renew consul token lease, reading new credentials", "lease", v.leaseID, "error", err)
+	}
+	if err := v.read(ctx); err != nil {
+		if v.secret == "" {
+			return "", err
+		}
+		// The lease may well outlive a Vault outage.
+		slog.ErrorContext(ctx, "failed to replace consul token, keeping the current one", "error", err)
+		v.renewAt = time.Now().Add(vaultRetry)
+	}
+	return v.secret, nil
+}
+
+func (v *vaultToken) refresh(ctx context.Context, rejected string) (string, error) {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	// New credentials may have been read since rejected was sent.
+	if v.secret != rejected {
+		return v.secret, nil
+	}
+	if err := v.read(ctx); err != nil {
+		return "", err
+	}
+	return v.secret, nil
+}
+
+// read reads new credentials for the role. v.mu must be held.
+func (v *vaultToken) read(ctx context.Context) error {
+	var secret vaultSecret
+	if err := v.do(ctx, http.MethodGet, "/v1/"+v.cfg.Mount+"/creds/"+v.cfg.Role, nil, &secret); err != nil {
+		return fmt.Errorf("reading consul credentials from vault: %w", err)
+	}
+	if secret.Data.Token == "" {
+		return fmt.Errorf("vault role %s returned no consul token", v.cfg.Role)
+	}
+
+	v.secret = secret.Data.Token
+	v.leaseID = secret.LeaseID
+	v.renewable = secret.Renewable && secret.LeaseID != ""
+	v.lease = time.Duration(secret.LeaseDuration) * time.Second
+	v.renewAt = time.Time{}
+	if v.lease > 0 {
+		v.renewAt = time.Now().Add(v.lease * 2 / 3)
+	}
+	slog.InfoContext(ctx, "read consul token from vault",
+		"role", v.cfg.Role, "accessor", secret.Data.Accessor, "lease", v.leaseID, "lease_duration", v.lease)
+	return nil
+}
+
+// renew extends the lease of the current credentials by their original
+// duration. A lease extended by less than a third of that is close to its max
+// TTL, so it is reported as an error for new credentials to be read. v.mu
+// must be held.
+func (v *vaultToken) renew(ctx context.Context) error {
+	body, err := json.Marshal(map[string]any{
+		"lease_id":  v.leaseID,
+		"increment": int(v.lease.Seconds()),
+	})
+	if err != nil {
+		return fmt.Errorf("encoding renew request: %w", err)
+	}
+	var secret vaultSecret
+	if err := v.do(ctx, http.MethodPut, "/v1/sys/leases/renew", body, &secret); err != nil {
+		return err
+	}
+	granted := time.Duration(secret.LeaseDuration) * time.Second
+	if granted < v.lease/3 {
+		return fmt.Errorf("lease renewed for %s only, max ttl is near", granted)
+	}
+	v.renewAt = time.Now().Add(granted * 2 / 3)
+	slog.DebugContext(ctx, "renewed consul token lease", "lease", v.leaseID, "lease_duration", granted)
+	return nil
+}
+
+// do sends a request to Vault and decodes the response into out.
+func (v *vaultToken) do(ctx context.Context, method, path string, body []byte, out any) error {
+	vaultToken := v.cfg.Token
+	if v.cfg.TokenFile != "" {
+		token, err := ReadTokenFile(v.cfg.TokenFile)
+		if err != nil {
+			return fmt.Errorf("reading vault token: %w", err)
+		}
+		vaultToken = token
+	}
+
+	var reader io.Reader
+	if body != nil {
+		reader = bytes.NewReader(body)
+	}
+	req, err := http.NewRequestWithContext(ctx, method, v.cfg.Addr+path, reader)
+	if err != nil {
+		return fmt.Errorf("creating vault request: %w", err)
+	}
+	req.Header.Set("X-Vault-Token", vaultToken)
+	if v.cfg.Namespace != "" {
+		req.Header.Set("X-Vault-Namespace", v.cfg.Namespace)
+	}
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+
+	resp, err := v.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		msg, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+		return fmt.Errorf("vault %s %s returned %d: %s", method, path, resp.StatusCode, strings.TrimSpace(string(msg)))
+	}
+	if err := json.NewDecoder(resp.Body).Decode(out); err != nil {
+		return fmt.Errorf("decoding vault response: %w", err)
+	}
+	return nil
+}
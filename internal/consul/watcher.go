@@ -7,28 +7,233 @@ import (
 	"io"
 	"log/slog"
 	"net/http"
+	"net/url"
+	"reflect"
+	"slices"
 	"strconv"
+	"strings"
+	"sync"
 	"time"
+	"unique"
 )
 
+// WatchMode selects how WatchServices detects catalog changes.
+type WatchMode string
+
+const (
+	// WatchModeAuto uses blocking queries and falls back to polling when they
+	// turn out to be unusable.
+	WatchModeAuto WatchMode = "auto"
+	// WatchModePoll always polls on PollInterval.
+	WatchModePoll WatchMode = "poll"
+	// WatchModeService is WatchModeAuto plus a blocking query on the health
+	// of every listed service, so a health change refetches only that
+	// service. Each of them holds a connection to Consul.
+	WatchModeService WatchMode = "service"
+)
+
+// DefaultSkipKinds are the service kinds of Consul's service mesh: sidecar
+// proxies and gateways, which route for other services and have nothing to
+// sync themselves.
+var DefaultSkipKinds = []string{"connect-proxy", "mesh-gateway", "terminating-gateway", "ingress-gateway"}
+
+const (
+	defaultPollInterval = 30 * time.Second
+
+	// Blocking queries that fail after being held at least this long, this
+	// many times in a row, switch WatchModeAuto to polling.
+	heldQueryThreshold        = 10 * time.Second
+	heldFailuresBeforePolling = 3
+
+	// After falling back to polling, WatchModeAuto tries a blocking query
+	// again this often, in case whatever broke them has been fixed.
+	blockingRetryInterval = 10 * time.Minute
+)
+
+// Options holds optional Watcher behavior.
+type Options struct {
+	// WatchMode selects blocking queries or polling. Empty means auto.
+	WatchMode WatchMode
+	// PollInterval is the interval between polls once polling. Defaults to 30s.
+	PollInterval time.Duration
+
+	// SkipServices lists service names never handed to the syncer, such as
+	// Consul's own built-in "consul" service.
+	SkipServices []string
+	// SkipKinds lists service kinds, such as connect-proxy, whose instances
+	// are never handed to the syncer. A service whose instances are all of
+	// these kinds is left out entirely. See DefaultSkipKinds.
+	SkipKinds []string
+
+	// Filter is a Consul filter expression selecting services on top of the
+	// tag, e.g. ServiceMeta.expose == "true" and "prod" in ServiceTags. It
+	// is evaluated by /v1/catalog/services against each instance, and a
+	// service is synced with all its instances when any of them matches.
+	Filter string
+
+	// Datacenters lists the Consul datacenters to watch, through the agent
+	// at the watcher's address. Services registered under the same name in
+	// several of them are merged into one ServiceState, with each instance
+	// attributed to its datacenter. Empty watches the agent's own
+	// datacenter only.
+	Datacenters []string
+
+	// Namespace is the Consul Enterprise namespace to watch, or
+	// AllNamespaces to watch every namespace the token can list. Empty
+	// leaves it to Consul, which uses the token's namespace or default.
+	Namespace string
+
+	// Peering also watches the services imported from every cluster peer
+	// listed on /v1/peerings, named with PeerNameFormat.
+	Peering bool
+	// PeerNameFormat names imported services, with {service} and {peer}
+	// replaced by the service and peer names. Defaults to
+	// DefaultPeerNameFormat.
+	PeerNameFormat string
+
+	// TokenFile, when set, holds the ACL token. It is re-read periodically
+	// and whenever Consul answers 403, so rotated tokens are picked up
+	// without a restart. The token passed to the constructor is used until
+	// the first re-read.
+	TokenFile string
+	// Login, when set, obtains the ACL token by logging in with an auth
+	// method instead, see LoginConfig.
+	Login *LoginConfig
+	// Vault, when set, reads short-lived ACL tokens from Vault's Consul
+	// secrets engine instead, see VaultConfig.
+	Vault *VaultConfig
+
+	// Stale lets any Consul server answer catalog and health queries from
+	// its local state, which may lag the leader, instead of forwarding them
+	// to the leader.
+	Stale bool
+	// Cache has the local agent answer catalog and health queries from its
+	// cache, refreshed in the background while blocking queries wait.
+	// CacheMaxAge, when set, bounds the age of the results non-blocking
+	// queries accept.
+	Cache       bool
+	CacheMaxAge time.Duration
+	// Streaming sends every health query as a blocking query, the only kind
+	// client agents with use_streaming_backend answer from the materialized
+	// view they keep current with the servers' event stream, instead of
+	// running the query on the servers.
+	Streaming bool
+
+	// Strict, when set, rejects catalog and health responses failing its
+	// sanity checks, see StrictConfig.
+	Strict *StrictConfig
+
+	// Faults, when set, injects artificial failures into every Consul
+	// response. Never set this in production.
+	Faults *FaultConfig
+
+	// Headers are sent with every Consul request, including ACL logins,
+	// e.g. for a reverse proxy in front of Consul authenticating them. See
+	// ParseHeaders.
+	Headers http.Header
+}
+
 // Watcher watches Consul for service changes using blocking queries.
 type Watcher struct {
-	addr   string
-	token  string
-	tag    string
-	client *http.Client
+	addr      string
+	tag       string
+	client    *http.Client
+	transport *swappableTransport
+	opts      Options
+
+	// dc is the datacenter queried, empty for the agent's own. With several
+	// Options.Datacenters, each is watched by one of dcs, sharing client and
+	// transport, and w only merges their results.
+	dc  string
+	dcs []*Watcher
+
+	// ns is the Consul namespace queried, empty to leave it to Consul. With
+	// Options.Namespace set to AllNamespaces, each namespace is watched by
+	// one of nss, created as namespaces appear, and w only lists the
+	// namespaces and merges their results.
+	ns  string
+	nss childSet
+
+	// peer is the cluster peer whose imported services are queried, empty
+	// for local ones. With Options.Peering, each peer is watched by one of
+	// peers, created as peers appear, while w watches the local services
+	// and records the peer list query in peerList.
+	peer     string
+	peers    childSet
+	peerList watchDebug
+
+	// cache holds the last decoded instances per service, keyed by the
+	// X-Consul-Index of the health response that produced them. Unchanged
+	// services are reused as-is instead of being decoded again every cycle.
+	cacheMu sync.Mutex
+	cache   map[string]cachedService
+	gen     uint64
+
+	// streamingWarning warns once of health queries not answered from the
+	// streaming backend with Options.Streaming.
+	streamingWarning sync.Once
+
+	debug watchDebug
+}
+
+type cachedService struct {
+	dc         string
+	ns         string
+	peer       string
+	index      uint64
+	gen        uint64
+	instances  []ServiceInstance
+	tags       []string
+	meta       map[string]string
+	registered int
+	failing    []Check
+	// skipKind is set when every instance is of one of Options.SkipKinds,
+	// leaving the service out of snapshots.
+	skipKind bool
 }
 
-// NewWatcher creates a new Consul watcher.
-func NewWatcher(addr, token, tag string) *Watcher {
-	return &Watcher{
-		addr:  addr,
-		token: token,
-		tag:   tag,
+// NewWatcher creates a new Consul watcher. An empty tag selects every service
+// in the catalog except those in opts.SkipServices.
+func NewWatcher(addr, token, tag string, opts Options) *Watcher {
+	transport := newSwappableTransport()
+	rt := withHeaders(transport, opts.Headers)
+	if opts.Faults != nil {
+		rt = &faultTransport{next: rt, cfg: *opts.Faults}
+	}
+	rt = newTokenTransport(rt, addr, token, opts)
+	w := &Watcher{
+		addr:      addr,
+		tag:       tag,
+		cache:     make(map[string]cachedService),
+		transport: transport,
+		opts:      opts,
 		client: &http.Client{
-			Timeout: 6 * time.Minute, // longer than Consul's max wait (5m)
+			Transport: rt,
+			Timeout:   6 * time.Minute, // longer than Consul's max wait (5m)
 		},
 	}
+	if opts.Namespace != AllNamespaces {
+		w.ns = opts.Namespace
+	}
+	switch len(opts.Datacenters) {
+	case 0:
+	case 1:
+		w.dc = opts.Datacenters[0]
+	default:
+		for _, dc := range opts.Datacenters {
+			w.dcs = append(w.dcs, &Watcher{
+				addr:      addr,
+				tag:       tag,
+				cache:     make(map[string]cachedService),
+				transport: transport,
+				opts:      opts,
+				client:    w.client,
+				dc:        dc,
+				ns:        w.ns,
+			})
+		}
+	}
+	return w
 }
 
 // catalogServicesResponse is the JSON response from /v1/catalog/services.
@@ -39,33 +244,75 @@ type catalogServicesResponse map[string][]string
 type healthServiceEntry struct {
 	Node    healthNode    `json:"Node"`
 	Service healthService `json:"Service"`
+	Checks  []healthCheck `json:"Checks"`
 }
 
 type healthNode struct {
+	Node    string `json:"Node"`
 	Address string `json:"Address"`
 }
 
 type healthService struct {
-	Service string   `json:"Service"`
-	Address string   `json:"Address"`
-	Port    int      `json:"Port"`
-	Tags    []string `json:"Tags"`
+	ID      string            `json:"ID"`
+	Kind    string            `json:"Kind"`
+	Service string            `json:"Service"`
+	Address string            `json:"Address"`
+	Port    int               `json:"Port"`
+	Tags    []string          `json:"Tags"`
+	Meta    map[string]string `json:"Meta"`
+	Weights serviceWeights    `json:"Weights"`
+}
+
+// serviceWeights are the DNS weights of a service instance: Passing while
+// its checks pass, Warning while one warns.
+type serviceWeights struct {
+	Passing int `json:"Passing"`
+	Warning int `json:"Warning"`
+}
+
+type healthCheck struct {
+	Name      string `json:"Name"`
+	Status    string `json:"Status"`
+	Output    string `json:"Output"`
+	ServiceID string `json:"ServiceID"`
 }
 
 // ListServices returns the list of service names matching the configured tag,
-// along with the Consul index for blocking queries.
+// along with the Consul index for blocking queries, or 0 when Consul (or a
+// proxy in front of it) didn't report one.
 func (w *Watcher) ListServices(ctx context.Context, waitIndex uint64) ([]string, uint64, error) {
-	url := fmt.Sprintf("%s/v1/catalog/services?tag=%s&index=%d&wait=5m", w.addr, w.tag, waitIndex)
+	query := url.Values{}
+	if w.tag != "" {
+		query.Set("tag", w.tag)
+	}
+	if w.opts.Filter != "" {
+		query.Set("filter", w.opts.Filter)
+	}
+	if w.dc != "" {
+		query.Set("dc", w.dc)
+	}
+	if w.ns != "" {
+		query.Set("ns", w.ns)
+	}
+	if w.peer != "" {
+		query.Set("peer", w.peer)
+	}
+	w.setConsistency(query)
+	query.Set("index", strconv.FormatUint(waitIndex, 10))
+	query.Set("wait", "5m")
+	reqURL := w.addr + "/v1/catalog/services?" + query.Encode()
 
-	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, reqURL, nil)
 	if err != nil {
 		return nil, 0, fmt.Errorf("creating request: %w", err)
 	}
-	if w.token != "" {
-		req.Header.Set("X-Consul-Token", w.token)
+	if waitIndex == 0 {
+		w.setCacheControl(req)
 	}
 
 	resp, err := w.client.Do(req)
+	w.debug.recordResponse(endpointCatalogServices, resp, err)
+	observeLastContact(endpointCatalogServices, resp)
 	if err != nil {
 		return nil, 0, fmt.Errorf("querying consul: %w", err)
 	}
@@ -76,22 +323,24 @@ func (w *Watcher) ListServices(ctx context.Context, waitIndex uint64) ([]string,
 		return nil, 0, fmt.Errorf("consul returned %d: %s", resp.StatusCode, string(body))
 	}
 
-	newIndex, err := strconv.ParseUint(resp.Header.Get("X-Consul-Index"), 10, 64)
-	if err != nil || newIndex == 0 {
-		// Consul indexes start at 1; using 0 would make the next blocking
-		// query return immediately, causing a tight loop.
-		newIndex = 1
-	}
+	// A missing or zero index means blocking queries can't be used; callers
+	// must not pass 0 back as a wait index or they'd spin in a tight loop.
+	newIndex, _ := strconv.ParseUint(resp.Header.Get("X-Consul-Index"), 10, 64)
 
 	var catalog catalogServicesResponse
 	if err := json.NewDecoder(resp.Body).Decode(&catalog); err != nil {
 		return nil, 0, fmt.Errorf("decoding response: %w", err)
 	}
+	if w.opts.Strict != nil {
+		if err := w.checkCatalog(catalog); err != nil {
+			countRejected(endpointCatalogServices, err)
+			return nil, 0, err
+		}
+	}
 
-	var names []string
+	names := make([]string, 0, len(catalog))
 	for name := range catalog {
-		// Skip the built-in "consul" service
-		if name == "consul" {
+		if slices.Contains(w.opts.SkipServices, name) {
 			continue
 		}
 		names = append(names, name)
@@ -102,73 +351,278 @@ func (w *Watcher) ListServices(ctx context.Context, waitIndex uint64) ([]string,
 
 // GetServiceInstances returns healthy instances for a named service.
 func (w *Watcher) GetServiceInstances(ctx context.Context, serviceName string) ([]ServiceInstance, error) {
-	url := fmt.Sprintf("%s/v1/health/service/%s?passing=true", w.addr, serviceName)
+	svc, err := w.getService(ctx, serviceName)
+	if err != nil {
+		return nil, err
+	}
+	return svc.instances, nil
+}
 
-	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+// getService fetches healthy instances for a named service, reusing the
+// cached result when Consul reports the same index as last time. Unhealthy
+// instances are fetched too, for their failing checks, and filtered out here
+// the way ?passing=true would.
+func (w *Watcher) getService(ctx context.Context, serviceName string) (cachedService, error) {
+	return w.getServiceAt(ctx, serviceName, 0)
+}
+
+// getServiceAt is getService as a blocking query, returning once the
+// service's health index moves past waitIndex, or after 5m. A zero
+// waitIndex returns at once.
+func (w *Watcher) getServiceAt(ctx context.Context, serviceName string, waitIndex uint64) (cachedService, error) {
+	reqURL := fmt.Sprintf("%s/v1/health/service/%s", w.addr, url.PathEscape(serviceName))
+	query := url.Values{}
+	if w.dc != "" {
+		query.Set("dc", w.dc)
+	}
+	if w.ns != "" {
+		query.Set("ns", w.ns)
+	}
+	if w.peer != "" {
+		query.Set("peer", w.peer)
+	}
+	w.setConsistency(query)
+	if index, wait := w.healthWait(waitIndex); index != 0 {
+		query.Set("index", strconv.FormatUint(index, 10))
+		query.Set("wait", wait)
+	}
+	if len(query) > 0 {
+		reqURL += "?" + query.Encode()
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, reqURL, nil)
 	if err != nil {
-		return nil, fmt.Errorf("creating request: %w", err)
+		return cachedService{}, fmt.Errorf("creating request: %w", err)
 	}
-	if w.token != "" {
-		req.Header.Set("X-Consul-Token", w.token)
+	if waitIndex == 0 {
+		w.setCacheControl(req)
 	}
 
 	resp, err := w.client.Do(req)
+	w.debug.recordResponse(endpointHealthService, resp, err)
+	observeLastContact(endpointHealthService, resp)
+	w.observeQueryBackend(resp)
 	if err != nil {
-		return nil, fmt.Errorf("querying consul: %w", err)
+		return cachedService{}, fmt.Errorf("querying consul: %w", err)
 	}
 	defer resp.Body.Close()
 
 	if resp.StatusCode != http.StatusOK {
 		body, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
-		return nil, fmt.Errorf("consul returned %d: %s", resp.StatusCode, string(body))
+		return cachedService{}, fmt.Errorf("consul returned %d: %s", resp.StatusCode, string(body))
+	}
+
+	index, _ := strconv.ParseUint(resp.Header.Get("X-Consul-Index"), 10, 64)
+	if index != 0 {
+		w.cacheMu.Lock()
+		cached, ok := w.cache[serviceName]
+		w.cacheMu.Unlock()
+		if ok && cached.index == index {
+			// Drain so the connection can be reused.
+			io.Copy(io.Discard, resp.Body)
+			return cached, nil
+		}
 	}
 
 	var entries []healthServiceEntry
 	if err := json.NewDecoder(resp.Body).Decode(&entries); err != nil {
-		return nil, fmt.Errorf("decoding response: %w", err)
+		return cachedService{}, fmt.Errorf("decoding response: %w", err)
+	}
+	if w.opts.Strict != nil {
+		if err := w.checkHealth(serviceName, entries); err != nil {
+			return w.quarantine(serviceName, index, err)
+		}
 	}
 
-	var instances []ServiceInstance
+	instances := make([]ServiceInstance, 0, len(entries))
+	var failing []Check
+	var skipped int
 	for _, e := range entries {
+		if slices.Contains(w.opts.SkipKinds, e.Service.Kind) {
+			skipped++
+			continue
+		}
 		addr := e.Service.Address
 		if addr == "" {
 			addr = e.Node.Address
 		}
+
+		passing := true
+		for _, c := range e.Checks {
+			if c.Status == "passing" {
+				continue
+			}
+			passing = false
+			failing = append(failing, Check{
+				Datacenter: w.dc,
+				Node:       e.Node.Node,
+				ServiceID:  c.ServiceID,
+				Address:    addr,
+				Name:       c.Name,
+				Status:     c.Status,
+				Output:     c.Output,
+			})
+		}
+		if !passing {
+			continue
+		}
+
 		instances = append(instances, ServiceInstance{
 			ServiceName: e.Service.Service,
+			ID:          e.Service.ID,
+			Node:        e.Node.Node,
 			Address:     addr,
 			Port:        e.Service.Port,
-			Tags:        e.Service.Tags,
+			Tags:        internTags(e.Service.Tags),
+			Meta:        e.Service.Meta,
+			Datacenter:  w.dc,
+			Weight:      e.Service.Weights.Passing,
 		})
 	}
 
-	return instances, nil
+	svc := cachedService{
+		dc:         w.dc,
+		ns:         w.ns,
+		peer:       w.peer,
+		index:      index,
+		instances:  instances,
+		tags:       collectTags(instances),
+		meta:       collectMeta(instances),
+		registered: len(entries) - skipped,
+		failing:    failing,
+		skipKind:   skipped > 0 && skipped == len(entries),
+	}
+	if index != 0 {
+		w.cacheMu.Lock()
+		w.cache[serviceName] = svc
+		w.cacheMu.Unlock()
+	}
+	return svc, nil
 }
 
 // WatchServices starts watching Consul for service changes and sends full
 // state snapshots on the returned channel whenever changes are detected.
-func (w *Watcher) WatchServices(ctx context.Context) (<-chan []ServiceState, error) {
-	ch := make(chan []ServiceState, 1)
+func (w *Watcher) WatchServices(ctx context.Context) (<-chan Snapshot, error) {
+	if len(w.dcs) > 0 {
+		return w.watchDatacenters(ctx)
+	}
+	if w.allNamespaces() {
+		return w.watchNamespaces(ctx)
+	}
+	if w.peering() {
+		return w.watchPeers(ctx)
+	}
+	return w.watchCatalog(ctx), nil
+}
+
+// watchCatalog runs the watch loop of w's own catalog.
+func (w *Watcher) watchCatalog(ctx context.Context) <-chan Snapshot {
+	ch := make(chan Snapshot, 1)
+
+	// In WatchModeService, watchHealth sends the snapshots instead, and is
+	// handed the services listed.
+	var catalog chan catalogChange
+	if w.opts.WatchMode == WatchModeService {
+		catalog = make(chan catalogChange)
+		go w.watchHealth(ctx, catalog, ch)
+	}
 
 	go func() {
-		defer close(ch)
+		if catalog != nil {
+			defer close(catalog)
+		} else {
+			defer close(ch)
+		}
 
 		var waitIndex uint64
+		var lastStates []ServiceState
+		var heldFailures int
 		backoff := time.Second
+		polling := w.opts.WatchMode == WatchModePoll
+		first := true
+		// retryAt is when to try blocking queries again after falling back,
+		// and probing is set while that try is under way.
+		var retryAt time.Time
+		var probing bool
+		fallBack := func() {
+			polling, probing = true, false
+			retryAt = time.Now().Add(blockingRetryInterval)
+			w.debug.update(func(st *WatcherState) {
+				st.Polling, st.HeldFailures = true, heldFailures
+			})
+		}
 
 		for {
+			if polling && !first {
+				select {
+				case <-ctx.Done():
+					return
+				case <-time.After(w.pollInterval()):
+				}
+			}
+			first = false
+			if polling && !retryAt.IsZero() && time.Now().After(retryAt) {
+				slog.Info("trying blocking queries again")
+				polling, probing, heldFailures = false, true, 0
+				lastStates = nil
+			}
+
 			select {
 			case <-ctx.Done():
 				return
 			default:
 			}
 
-			names, newIndex, err := w.ListServices(ctx, waitIndex)
+			index := waitIndex
+			if polling {
+				index = 0 // never block
+			}
+			start := time.Now()
+			names, newIndex, err := w.ListServices(ctx, index)
+			w.debug.update(func(st *WatcherState) {
+				st.LastQuery = &QueryState{
+					Started:  start,
+					Duration: time.Since(start).String(),
+					Index:    index,
+					NewIndex: newIndex,
+				}
+				if err != nil {
+					st.LastQuery.Error = err.Error()
+				}
+			})
 			if err != nil {
 				if ctx.Err() != nil {
 					return
 				}
+				// Consul itself fails fast. A query that was held open and
+				// then failed was most likely cut by a proxy idle timeout,
+				// unless it answered with a response rejected as garbage.
+				if !polling && time.Since(start) >= heldQueryThreshold && !isRejected(err) {
+					heldFailures++
+					if probing {
+						slog.Info("blocking query still fails after being held, polling again",
+							"retry_in", blockingRetryInterval)
+						fallBack()
+						continue
+					}
+					if heldFailures >= heldFailuresBeforePolling {
+						slog.Warn("blocking queries keep failing after being held, falling back to polling",
+							"failures", heldFailures, "poll_interval", w.pollInterval())
+						fallBack()
+						continue
+					}
+				} else {
+					heldFailures = 0
+				}
 				slog.Error("failed to list consul services", "error", err, "backoff", backoff)
+				w.debug.update(func(st *WatcherState) {
+					st.HeldFailures = heldFailures
+					st.Retry = &RetryState{
+						Backoff: backoff.String(),
+						Next:    time.Now().Add(backoff),
+						Error:   err.Error(),
+					}
+				})
 				select {
 				case <-ctx.Done():
 					return
@@ -178,73 +632,265 @@ func (w *Watcher) WatchServices(ctx context.Context) (<-chan []ServiceState, err
 				continue
 			}
 			backoff = time.Second
+			heldFailures = 0
+
+			switch {
+			case !polling && newIndex == 0 && probing:
+				slog.Info("consul response still has no X-Consul-Index, polling again",
+					"retry_in", blockingRetryInterval)
+				fallBack()
+			case !polling && newIndex == 0:
+				slog.Warn("consul response has no X-Consul-Index, falling back to polling",
+					"poll_interval", w.pollInterval())
+				fallBack()
+			case probing && time.Since(start) >= heldQueryThreshold:
+				slog.Info("blocking queries work again")
+				probing, retryAt = false, time.Time{}
+			}
+			w.debug.update(func(st *WatcherState) {
+				st.Polling, st.HeldFailures, st.Retry = polling, 0, nil
+			})
 
-			// Only fetch instances if index changed (or first poll)
-			if newIndex == waitIndex && waitIndex != 0 {
-				continue
+			// states holds the instances when they were fetched to find out
+			// whether anything changed.
+			var states []ServiceState
+			if polling {
+				if newIndex != 0 {
+					// The index moves with every change of the catalog.
+					if newIndex == waitIndex {
+						continue
+					}
+				} else {
+					// Without an index, neither the catalog nor the health
+					// responses tell what changed, so every poll fetches the
+					// instances and compares them with the last ones sent.
+					states = w.fetchStates(ctx, names)
+					if lastStates != nil && reflect.DeepEqual(states, lastStates) {
+						continue
+					}
+					lastStates = states
+				}
+				waitIndex = newIndex
+			} else {
+				// Only fetch instances if index changed (or first poll)
+				if newIndex == waitIndex && waitIndex != 0 {
+					continue
+				}
+				waitIndex = newIndex
 			}
-			waitIndex = newIndex
+			w.debug.update(func(st *WatcherState) {
+				st.WaitIndex = waitIndex
+			})
 
 			slog.Info("consul services changed", "services", names, "index", newIndex)
 
-			var states []ServiceState
-			for _, name := range names {
-				instances, err := w.GetServiceInstances(ctx, name)
-				if err != nil {
-					slog.Error("failed to get service instances", "service", name, "error", err)
-					// Include the service with nil instances so the syncer
-					// still sees it in the desired set and won't orphan-delete it.
-					states = append(states, ServiceState{
-						Name:      name,
-						Instances: nil,
-					})
-					continue
+			snap := Snapshot{DetectedAt: time.Now()}
+			if catalog != nil {
+				// Listed services keep being fetched by their health
+				// watches; only new ones are fetched here.
+				c := catalogChange{names: names, index: newIndex, detectedAt: snap.DetectedAt}
+				if polling {
+					if states == nil {
+						states = w.fetchStates(ctx, names)
+					}
+					c.states = states
+				} else {
+					w.fetchMissing(ctx, names)
 				}
-				states = append(states, ServiceState{
-					Name:      name,
-					Instances: instances,
-					Tags:      collectTags(instances),
-				})
+				select {
+				case catalog <- c:
+				case <-ctx.Done():
+					return
+				}
+				continue
+			}
+			if states == nil {
+				states = w.fetchStates(ctx, names)
 			}
+			snap.Services = states
+			snap.Index = max(newIndex, w.cachedIndex(names))
 
 			select {
-			case ch <- states:
+			case ch <- snap:
 			case <-ctx.Done():
 				return
 			}
 		}
 	}()
 
-	return ch, nil
+	return ch
+}
+
+// namesKey returns an order-independent key for a list of service names.
+func namesKey(names []string) string {
+	sorted := slices.Clone(names)
+	slices.Sort(sorted)
+	return strings.Join(sorted, "\x00")
+}
+
+func (w *Watcher) pollInterval() time.Duration {
+	if w.opts.PollInterval > 0 {
+		return w.opts.PollInterval
+	}
+	return defaultPollInterval
+}
+
+// WatchHealthy reports whether the watch loop is running on blocking queries
+// and its last catalog query succeeded, so changes are seen as they happen.
+// With several datacenters, every namespace or peering, every one of them
+// must be healthy, and so must the namespace or peer list query.
+func (w *Watcher) WatchHealthy() bool {
+	if len(w.dcs) > 0 {
+		for _, dc := range w.dcs {
+			if !dc.WatchHealthy() {
+				return false
+			}
+		}
+		return true
+	}
+	if w.allNamespaces() {
+		return w.nss.healthy() && w.debug.healthy()
+	}
+	if w.peering() && !(w.peers.healthy() && w.peerList.healthy()) {
+		return false
+	}
+	return w.debug.healthy()
+}
+
+// FetchService fetches the current healthy instances of a single service.
+func (w *Watcher) FetchService(ctx context.Context, name string) (ServiceState, error) {
+	if len(w.dcs) > 0 {
+		return w.fetchServiceDatacenters(ctx, name)
+	}
+	if w.allNamespaces() {
+		return w.fetchServiceNamespaces(ctx, name)
+	}
+	if w.peering() {
+		return w.fetchServicePeers(ctx, name)
+	}
+	svc, err := w.getService(ctx, name)
+	if err != nil {
+		return ServiceState{}, err
+	}
+	return svc.state(name), nil
 }
 
 // FetchAllServices does a single non-blocking fetch of all tagged services and their instances.
 func (w *Watcher) FetchAllServices(ctx context.Context) ([]ServiceState, error) {
+	if len(w.dcs) > 0 {
+		return w.fetchAllDatacenters(ctx)
+	}
+	if w.allNamespaces() {
+		return w.fetchAllNamespaces(ctx)
+	}
+	if w.peering() {
+		return w.fetchAllPeers(ctx)
+	}
 	names, _, err := w.ListServices(ctx, 0)
 	if err != nil {
 		return nil, err
 	}
 
-	var states []ServiceState
+	return w.fetchStates(ctx, names), nil
+}
+
+// fetchStates fetches healthy instances for each named service and builds
+// the snapshot handed to the syncer. Services whose health index hasn't moved
+// share their instance slices with the previous snapshot, and cache entries
+// for services no longer listed are dropped.
+func (w *Watcher) fetchStates(ctx context.Context, names []string) []ServiceState {
+	w.cacheMu.Lock()
+	w.gen++
+	gen := w.gen
+	w.cacheMu.Unlock()
+
+	states := make([]ServiceState, 0, len(names))
 	for _, name := range names {
-		instances, err := w.GetServiceInstances(ctx, name)
+		svc, err := w.getService(ctx, name)
 		if err != nil {
-			slog.Error("failed to get service instances during resync", "service", name, "error", err)
-			// Include the service with nil instances so the syncer
-			// still sees it in the desired set and won't orphan-delete it.
-			states = append(states, ServiceState{
-				Name:      name,
-				Instances: nil,
-			})
+			slog.ErrorContext(ctx, "failed to get service instances", "service", name, "error", err)
+			states = append(states, w.unfetchedState(name))
+			w.touchCache(name, gen)
 			continue
 		}
-		states = append(states, ServiceState{
-			Name:      name,
-			Instances: instances,
-			Tags:      collectTags(instances),
-		})
+		w.touchCache(name, gen)
+		if svc.skipKind {
+			slog.DebugContext(ctx, "skipping consul service of a skipped kind", "service", name)
+			continue
+		}
+		states = append(states, svc.state(name))
+	}
+
+	w.cacheMu.Lock()
+	for name, c := range w.cache {
+		if c.gen < gen {
+			delete(w.cache, name)
+		}
+	}
+	w.cacheMu.Unlock()
+
+	return states
+}
+
+// unfetchedState is the state of a service whose instances couldn't be
+// fetched: it has nil instances, so the syncer still sees it in the desired
+// set and won't orphan-delete it.
+func (w *Watcher) unfetchedState(name string) ServiceState {
+	st := ServiceState{
+		Name:      name,
+		Instances: nil,
+		Namespace: w.ns,
+		Peer:      w.peer,
 	}
-	return states, nil
+	if w.dc != "" {
+		st.Datacenters = []string{w.dc}
+	}
+	return st
+}
+
+// touchCache marks a cached service as seen in the given generation so it
+// survives the prune at the end of fetchStates.
+func (w *Watcher) touchCache(name string, gen uint64) {
+	w.cacheMu.Lock()
+	defer w.cacheMu.Unlock()
+	if c, ok := w.cache[name]; ok {
+		c.gen = gen
+		w.cache[name] = c
+	}
+}
+
+func (c cachedService) state(name string) ServiceState {
+	st := ServiceState{
+		Name:          name,
+		Instances:     c.instances,
+		Tags:          c.tags,
+		Meta:          c.meta,
+		Registered:    c.registered,
+		FailingChecks: c.failing,
+		Namespace:     c.ns,
+		Peer:          c.peer,
+	}
+	if c.dc != "" {
+		st.Datacenters = []string{c.dc}
+	}
+	return st
+}
+
+// collectMeta merges service meta across all instances. Keys set on several
+// instances with different values keep the first instance's value.
+func collectMeta(instances []ServiceInstance) map[string]string {
+	var meta map[string]string
+	for _, inst := range instances {
+		for k, v := range inst.Meta {
+			if meta == nil {
+				meta = make(map[string]string)
+			}
+			if _, ok := meta[k]; !ok {
+				meta[k] = v
+			}
+		}
+	}
+	return meta
 }
 
 // collectTags returns a deduplicated union of tags across all instances.
@@ -261,3 +907,13 @@ func collectTags(instances []ServiceInstance) []string {
 	}
 	return tags
 }
+
+// internTags deduplicates tag strings across instances and cycles. Large
+// catalogs repeat the same handful of tags thousands of times, and without
+// interning each decoded copy is retained separately.
+func internTags(tags []string) []string {
+	for i, t := range tags {
+		tags[i] = unique.Make(t).Value()
+	}
+	return tags
+}
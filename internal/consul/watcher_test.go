@@ -0,0 +1,178 @@
+package consul_test
+
+import (
+	"context"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/alexieff-io/consul-sync/consulsynctest"
+	"github.com/alexieff-io/consul-sync/internal/consul"
+)
+
+// snapshotTimeout bounds the wait for a snapshot. It is far below the
+// blocking query wait and the poll interval, so a snapshot arriving in time
+// was woken by the change.
+const snapshotTimeout = 5 * time.Second
+
+func startWatcher(t *testing.T, srv *consulsynctest.Server, tag string, opts consul.Options) <-chan consul.Snapshot {
+	t.Helper()
+	ctx, cancel := context.WithCancel(context.Background())
+	t.Cleanup(cancel)
+	w := consul.NewWatcher(srv.URL(), "", tag, opts)
+	ch, err := w.WatchServices(ctx)
+	if err != nil {
+		t.Fatalf("WatchServices: %v", err)
+	}
+	return ch
+}
+
+// blocking are the options of a watcher on blocking queries, which would not
+// poll within snapshotTimeout.
+var blocking = consul.Options{PollInterval: time.Hour}
+
+func nextSnapshot(t *testing.T, ch <-chan consul.Snapshot) consul.Snapshot {
+	t.Helper()
+	select {
+	case snap, ok := <-ch:
+		if !ok {
+			t.Fatal("watch channel closed")
+		}
+		return snap
+	case <-time.After(snapshotTimeout):
+		t.Fatal("no snapshot within", snapshotTimeout)
+	}
+	return consul.Snapshot{}
+}
+
+func serviceNames(snap consul.Snapshot) []string {
+	var names []string
+	for _, st := range snap.Services {
+		names = append(names, st.Name)
+	}
+	slices.Sort(names)
+	return names
+}
+
+func TestWatcherWakesOnChange(t *testing.T) {
+	srv := consulsynctest.NewServer()
+	defer srv.Close()
+	srv.Register("web", consulsynctest.Instance{ID: "web-1", Address: "10.0.0.1", Port: 80, Tags: []string{"kubernetes"}})
+
+	ch := startWatcher(t, srv, "kubernetes", blocking)
+	snap := nextSnapshot(t, ch)
+	if got := serviceNames(snap); !slices.Equal(got, []string{"web"}) {
+		t.Fatalf("first snapshot services = %v, want [web]", got)
+	}
+
+	// Let the watcher block on the index of the first snapshot.
+	time.Sleep(100 * time.Millisecond)
+	srv.Register("web", consulsynctest.Instance{ID: "web-2", Address: "10.0.0.2", Port: 80, Tags: []string{"kubernetes"}})
+	srv.Register("api", consulsynctest.Instance{ID: "api-1", Address: "10.0.0.3", Port: 8080, Tags: []string{"kubernetes"}})
+
+	want := []string{"api", "web"}
+	for {
+		snap = nextSnapshot(t, ch)
+		if slices.Equal(serviceNames(snap), want) {
+			break
+		}
+	}
+	for _, st := range snap.Services {
+		if st.Name == "web" && len(st.Instances) != 2 {
+			t.Errorf("web instances = %d, want 2", len(st.Instances))
+		}
+	}
+	if snap.Index < srv.Index() {
+		t.Errorf("snapshot index = %d, want at least %d", snap.Index, srv.Index())
+	}
+}
+
+func TestWatcherFiltersByTag(t *testing.T) {
+	srv := consulsynctest.NewServer()
+	defer srv.Close()
+	srv.Register("web", consulsynctest.Instance{ID: "web-1", Address: "10.0.0.1", Port: 80, Tags: []string{"kubernetes"}})
+	srv.Register("db", consulsynctest.Instance{ID: "db-1", Address: "10.0.0.2", Port: 5432, Tags: []string{"internal"}})
+
+	ch := startWatcher(t, srv, "kubernetes", blocking)
+	snap := nextSnapshot(t, ch)
+	if got := serviceNames(snap); !slices.Equal(got, []string{"web"}) {
+		t.Fatalf("services = %v, want [web]", got)
+	}
+
+	// Tagging db brings it in.
+	srv.Register("db", consulsynctest.Instance{ID: "db-1", Address: "10.0.0.2", Port: 5432, Tags: []string{"internal", "kubernetes"}})
+	for !slices.Equal(serviceNames(snap), []string{"db", "web"}) {
+		snap = nextSnapshot(t, ch)
+	}
+}
+
+func TestWatcherRemovesDeregisteredService(t *testing.T) {
+	srv := consulsynctest.NewServer()
+	defer srv.Close()
+	srv.Register("web", consulsynctest.Instance{ID: "web-1", Address: "10.0.0.1", Port: 80, Tags: []string{"kubernetes"}})
+	srv.Register("api", consulsynctest.Instance{ID: "api-1", Address: "10.0.0.2", Port: 8080, Tags: []string{"kubernetes"}})
+
+	ch := startWatcher(t, srv, "kubernetes", blocking)
+	snap := nextSnapshot(t, ch)
+	if got := serviceNames(snap); !slices.Equal(got, []string{"api", "web"}) {
+		t.Fatalf("services = %v, want [api web]", got)
+	}
+
+	srv.Deregister("api", "api-1")
+	for !slices.Equal(serviceNames(snap), []string{"web"}) {
+		snap = nextSnapshot(t, ch)
+	}
+}
+
+func TestWatcherPollsInstanceChanges(t *testing.T) {
+	for _, tt := range []struct {
+		name    string
+		mode    consul.WatchMode
+		noIndex bool
+	}{
+		{"poll with index", consul.WatchModePoll, false},
+		{"poll without index", consul.WatchModePoll, true},
+		{"auto falling back without index", consul.WatchModeAuto, true},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			srv := consulsynctest.NewServer()
+			defer srv.Close()
+			srv.NoIndex = tt.noIndex
+			srv.Register("web", consulsynctest.Instance{ID: "web-1", Address: "10.0.0.1", Port: 80, Tags: []string{"kubernetes"}})
+
+			ch := startWatcher(t, srv, "kubernetes", consul.Options{WatchMode: tt.mode, PollInterval: 20 * time.Millisecond})
+			if got := instanceAddresses(nextSnapshot(t, ch)); !slices.Equal(got, []string{"10.0.0.1"}) {
+				t.Fatalf("first snapshot addresses = %v, want [10.0.0.1]", got)
+			}
+
+			// Unchanged polls send nothing.
+			select {
+			case snap := <-ch:
+				t.Fatalf("snapshot without a change: %v", instanceAddresses(snap))
+			case <-time.After(200 * time.Millisecond):
+			}
+
+			// Neither change alters the list of services.
+			srv.Register("web", consulsynctest.Instance{ID: "web-2", Address: "10.0.0.2", Port: 80, Tags: []string{"kubernetes"}})
+			if got := instanceAddresses(nextSnapshot(t, ch)); !slices.Equal(got, []string{"10.0.0.1", "10.0.0.2"}) {
+				t.Errorf("addresses after registering = %v, want [10.0.0.1 10.0.0.2]", got)
+			}
+			srv.SetStatus("web", "web-1", consulsynctest.StatusCritical)
+			if got := instanceAddresses(nextSnapshot(t, ch)); !slices.Equal(got, []string{"10.0.0.2"}) {
+				t.Errorf("addresses after failing web-1 = %v, want [10.0.0.2]", got)
+			}
+		})
+	}
+}
+
+// instanceAddresses returns the sorted addresses of every instance in snap.
+func instanceAddresses(snap consul.Snapshot) []string {
+	var addrs []string
+	for _, st := range snap.Services {
+		for _, inst := range st.Instances {
+			addrs = append(addrs, inst.Address)
+		}
+	}
+	slices.Sort(addrs)
+	return addrs
+}
@@ -0,0 +1,274 @@
+// Package dns looks up the A and AAAA records of hostnames together with
+// their TTLs, which the Go resolver doesn't report, so addresses looked up
+// for instances registered by hostname can be refreshed when their records
+// expire rather than on a fixed schedule.
+package dns
+
+import (
+	"bufio"
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"math/rand/v2"
+	"net"
+	"net/netip"
+	"os"
+	"strings"
+	"time"
+
+	"golang.org/x/net/dns/dnsmessage"
+)
+
+// resolvConf lists the nameservers used when none are configured.
+const resolvConf = "/etc/resolv.conf"
+
+// queryTimeout bounds each query to a nameserver.
+const queryTimeout = 5 * time.Second
+
+// ErrNotFound is returned for hostnames that don't exist or have no A or
+// AAAA records.
+var ErrNotFound = errors.New("no A or AAAA records")
+
+// Resolver sends recursive queries to a list of nameservers, trying them in
+// order until one answers.
+type Resolver struct {
+	servers []string // host:port
+}
+
+// NewResolver returns a Resolver querying servers, as host or host:port,
+// or those of /etc/resolv.conf if there are none.
+func NewResolver(servers []string) (*Resolver, error) {
+	if len(servers) == 0 {
+		var err error
+		servers, err = readResolvConf(resolvConf)
+		if err != nil {
+			return nil, err
+		}
+	}
+	r := &Resolver{}
+	for _, s := range servers {
+		s = strings.TrimSpace(s)
+		if s == "" {
+			continue
+		}
+		if _, _, err := net.SplitHostPort(s); err != nil {
+			s = net.JoinHostPort(s, "53")
+		}
+		host, _, _ := net.SplitHostPort(s)
+		if _, err := netip.ParseAddr(host); err != nil {
+			return nil, fmt.Errorf("nameserver %q is not an IP address", host)
+		}
+		r.servers = append(r.servers, s)
+	}
+	if len(r.servers) == 0 {
+		return nil, fmt.Errorf("no nameservers")
+	}
+	return r, nil
+}
+
+// readResolvConf returns the nameservers listed in the resolv.conf at path.
+func readResolvConf(path string) ([]string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("reading nameservers: %w", err)
+	}
+	defer f.Close()
+	var servers []string
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) >= 2 && fields[0] == "nameserver" {
+			servers = append(servers, fields[1])
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("reading nameservers: %w", err)
+	}
+	if len(servers) == 0 {
+		return nil, fmt.Errorf("no nameservers in %s", path)
+	}
+	return servers, nil
+}
+
+// Lookup returns the addresses of host, which is taken as fully qualified,
+// and the lowest TTL of the records they were found through, CNAMEs
+// included.
+func (r *Resolver) Lookup(ctx context.Context, host string) ([]netip.Addr, time.Duration, error) {
+	fqdn := host
+	if !strings.HasSuffix(fqdn, ".") {
+		fqdn += "."
+	}
+	name, err := dnsmessage.NewName(fqdn)
+	if err != nil {
+		return nil, 0, fmt.Errorf("hostname %q: %w", host, err)
+	}
+
+	var addrs []netip.Addr
+	var ttl uint32
+	first := true
+	for _, qtype := range []dnsmessage.Type{dnsmessage.TypeA, dnsmessage.TypeAAAA} {
+		found, minTTL, err := r.query(ctx, name, qtype)
+		if errors.Is(err, ErrNotFound) {
+			continue
+		}
+		if err != nil {
+			return nil, 0, fmt.Errorf("looking up %s: %w", host, err)
+		}
+		addrs = append(addrs, found...)
+		if first || minTTL < ttl {
+			ttl, first = minTTL, false
+		}
+	}
+	if len(addrs) == 0 {
+		return nil, 0, fmt.Errorf("looking up %s: %w", host, ErrNotFound)
+	}
+	return addrs, time.Duration(ttl) * time.Second, nil
+}
+
+// query asks the nameservers in turn for the records of name of type qtype,
+// until one answers.
+func (r *Resolver) query(ctx context.Context, name dnsmessage.Name, qtype dnsmessage.Type) ([]netip.Addr, uint32, error) {
+	id := uint16(rand.Uint32())
+	msg := dnsmessage.Message{
+		Header:    dnsmessage.Header{ID: id, RecursionDesired: true},
+		Questions: []dnsmessage.Question{{Name: name, Type: qtype, Class: dnsmessage.ClassINET}},
+	}
+	query, err := msg.Pack()
+	if err != nil {
+		return nil, 0, fmt.Errorf("packing query: %w", err)
+	}
+
+	var lastErr error
+	for _, server := range r.servers {
+		resp, err := exchange(ctx, "udp", server, query)
+		if err == nil && truncated(resp) {
+			resp, err = exchange(ctx, "tcp", server, query)
+		}
+		if err != nil {
+			lastErr = fmt.Errorf("nameserver %s: %w", server, err)
+			continue
+		}
+		addrs, ttl, err := parseAnswer(resp, id, qtype)
+		if err != nil && !errors.Is(err, ErrNotFound) {
+			lastErr = fmt.Errorf("nameserver %s: %w", server, err)
+			continue
+		}
+		return addrs, ttl, err
+	}
+	if err := ctx.Err(); err != nil {
+		return nil, 0, err
+	}
+	return nil, 0, lastErr
+}
+
+// exchange sends query to server over network, udp or tcp, and returns the
+// response.
+func exchange(ctx context.Context, network, server string, query []byte) ([]byte, error) {
+	ctx, cancel := context.WithTimeout(ctx, queryTimeout)
+	defer cancel()
+	var d net.Dialer
+	conn, err := d.DialContext(ctx, network, server)
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+	if deadline, ok := ctx.Deadline(); ok {
+		conn.SetDeadline(deadline)
+	}
+
+	if network == "udp" {
+		if _, err := conn.Write(query); err != nil {
+			return nil, err
+		}
+		buf := make([]byte, 65535)
+		n, err := conn.Read(buf)
+		if err != nil {
+			return nil, err
+		}
+		return buf[:n], nil
+	}
+
+	// Over TCP, messages are prefixed with their length.
+	framed := binary.BigEndian.AppendUint16(nil, uint16(len(query)))
+	if _, err := conn.Write(append(framed, query...)); err != nil {
+		return nil, err
+	}
+	var length [2]byte
+	if _, err := io.ReadFull(conn, length[:]); err != nil {
+		return nil, err
+	}
+	resp := make([]byte, binary.BigEndian.Uint16(length[:]))
+	if _, err := io.ReadFull(conn, resp); err != nil {
+		return nil, err
+	}
+	return resp, nil
+}
+
+// truncated reports whether resp was cut short to fit a UDP datagram.
+func truncated(resp []byte) bool {
+	var p dnsmessage.Parser
+	h, err := p.Start(resp)
+	return err == nil && h.Truncated
+}
+
+// parseAnswer returns the addresses of type qtype in resp, the response to
+// query id, and the lowest TTL of its answers.
+func parseAnswer(resp []byte, id uint16, qtype dnsmessage.Type) ([]netip.Addr, uint32, error) {
+	var p dnsmessage.Parser
+	h, err := p.Start(resp)
+	if err != nil {
+		return nil, 0, fmt.Errorf("parsing response: %w", err)
+	}
+	if h.ID != id || !h.Response {
+		return nil, 0, fmt.Errorf("unexpected response")
+	}
+	switch h.RCode {
+	case dnsmessage.RCodeSuccess:
+	case dnsmessage.RCodeNameError:
+		return nil, 0, ErrNotFound
+	default:
+		return nil, 0, fmt.Errorf("response code %s", h.RCode)
+	}
+	if err := p.SkipAllQuestions(); err != nil {
+		return nil, 0, fmt.Errorf("parsing response: %w", err)
+	}
+
+	var addrs []netip.Addr
+	var ttl uint32
+	for i := 0; ; i++ {
+		rh, err := p.AnswerHeader()
+		if errors.Is(err, dnsmessage.ErrSectionDone) {
+			break
+		}
+		if err != nil {
+			return nil, 0, fmt.Errorf("parsing response: %w", err)
+		}
+		if i == 0 || rh.TTL < ttl {
+			ttl = rh.TTL
+		}
+		switch {
+		case rh.Type == qtype && qtype == dnsmessage.TypeA:
+			res, err := p.AResource()
+			if err != nil {
+				return nil, 0, fmt.Errorf("parsing response: %w", err)
+			}
+			addrs = append(addrs, netip.AddrFrom4(res.A))
+		case rh.Type == qtype && qtype == dnsmessage.TypeAAAA:
+			res, err := p.AAAAResource()
+			if err != nil {
+				return nil, 0, fmt.Errorf("parsing response: %w", err)
+			}
+			addrs = append(addrs, netip.AddrFrom16(res.AAAA))
+		default:
+			if err := p.SkipAnswer(); err != nil {
+				return nil, 0, fmt.Errorf("parsing response: %w", err)
+			}
+		}
+	}
+	if len(addrs) == 0 {
+		return nil, 0, ErrNotFound
+	}
+	return addrs, ttl, nil
+}
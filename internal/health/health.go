@@ -3,48 +3,178 @@ package health
 import (
 	"context"
 	"encoding/json"
+	"errors"
+	"io/fs"
+	"log/slog"
+	"maps"
 	"net/http"
+	"os"
+	"slices"
+	"strings"
+	"sync"
 	"sync/atomic"
 
+	"github.com/prometheus/client_golang/prometheus"
 	"github.com/prometheus/client_golang/prometheus/promhttp"
+	ctrlmetrics "sigs.k8s.io/controller-runtime/pkg/metrics"
+
+	"github.com/alexieff-io/consul-sync/internal/metrics"
+)
+
+// States of the controller, exported as consul_sync_state.
+const (
+	stateStarting = "starting"
+	stateReady    = "ready"
+	stateDegraded = "degraded"
 )
 
+var states = []string{stateStarting, stateReady, stateDegraded}
+
+// Options holds optional Server behavior.
+type Options struct {
+	// ReadyFile, if set, is written once the controller becomes ready and
+	// rewritten after every reconcile, for supervisors that can't probe HTTP.
+	ReadyFile string
+	// HealthAddr, if set, serves /healthz and /readyz on their own listener,
+	// leaving metrics and debug endpoints on the main address, so probes can
+	// be allowed through a NetworkPolicy or firewall that blocks the rest.
+	HealthAddr string
+}
+
 // Server serves health check and metrics endpoints.
 type Server struct {
 	addr    string
 	ready   atomic.Bool
 	server  *http.Server
+	health  *http.Server // with Options.HealthAddr
 	version string
 	commit  string
+	opts    Options
+
+	// extra holds handlers registered with Handle.
+	extra map[string]http.Handler
+
+	// degraded holds the reasons a ready controller is degraded, see
+	// SetDegraded.
+	degradedMu sync.Mutex
+	degraded   map[string]string
 }
 
 // NewServer creates a new health/metrics server.
-func NewServer(addr, version, commit string) *Server {
-	return &Server{addr: addr, version: version, commit: commit}
+func NewServer(addr, version, commit string, opts Options) *Server {
+	s := &Server{addr: addr, version: version, commit: commit, opts: opts}
+	s.exportState()
+	return s
 }
 
-// SetReady marks the server as ready (called after first successful sync).
+// SetReady marks the server as ready (called after every sync). The first
+// call also notifies systemd when running under a Type=notify unit.
 func (s *Server) SetReady() {
-	s.ready.Store(true)
+	if !s.ready.Swap(true) {
+		if err := sdNotify("READY=1"); err != nil {
+			slog.Error("failed to notify systemd", "error", err)
+		}
+	}
+	if s.opts.ReadyFile != "" {
+		if err := writeReadyFile(s.opts.ReadyFile); err != nil {
+			slog.Error("failed to write ready file", "path", s.opts.ReadyFile, "error", err)
+		}
+	}
+	s.exportState()
 }
 
-// ListenAndServe starts the HTTP server for health checks and metrics.
+// SetDegraded replaces the reasons the controller is degraded: running, but
+// with data that can't be trusted, such as a Consul that can't be read or a
+// partial sync. reasons maps each reason to a detail shown by
+// /readyz?verbose; none means healthy. A degraded controller stays ready.
+func (s *Server) SetDegraded(reasons map[string]string) {
+	s.degradedMu.Lock()
+	for reason := range s.degraded {
+		if _, ok := reasons[reason]; !ok {
+			metrics.DegradedReasons.DeleteLabelValues(reason)
+		}
+	}
+	for reason := range reasons {
+		metrics.DegradedReasons.WithLabelValues(reason).Set(1)
+	}
+	s.degraded = maps.Clone(reasons)
+	s.degradedMu.Unlock()
+	s.exportState()
+}
+
+// State returns the state of the controller, starting, ready or degraded,
+// with the reasons it is degraded.
+func (s *Server) State() (string, map[string]string) {
+	if !s.ready.Load() {
+		return stateStarting, nil
+	}
+	s.degradedMu.Lock()
+	defer s.degradedMu.Unlock()
+	if len(s.degraded) > 0 {
+		return stateDegraded, maps.Clone(s.degraded)
+	}
+	return stateReady, nil
+}
+
+// exportState sets consul_sync_state to the current state.
+func (s *Server) exportState() {
+	current, _ := s.State()
+	for _, st := range states {
+		v := 0.0
+		if st == current {
+			v = 1
+		}
+		metrics.State.WithLabelValues(st).Set(v)
+	}
+}
+
+// Handle registers an additional handler, such as a debug endpoint, on the
+// server. It must be called before ListenAndServe.
+func (s *Server) Handle(pattern string, handler http.Handler) {
+	if s.extra == nil {
+		s.extra = make(map[string]http.Handler)
+	}
+	s.extra[pattern] = handler
+}
+
+// ListenAndServe starts the HTTP server for health checks and metrics, and
+// the separate health check server with Options.HealthAddr. It returns when
+// either stops.
 func (s *Server) ListenAndServe() error {
 	mux := http.NewServeMux()
+	probes := mux
+	if s.opts.HealthAddr != "" {
+		probes = http.NewServeMux()
+	}
 
-	mux.HandleFunc("GET /healthz", func(w http.ResponseWriter, _ *http.Request) {
+	probes.HandleFunc("GET /healthz", func(w http.ResponseWriter, _ *http.Request) {
 		w.WriteHeader(http.StatusOK)
 		w.Write([]byte("ok"))
 	})
 
-	mux.HandleFunc("GET /readyz", func(w http.ResponseWriter, _ *http.Request) {
-		if s.ready.Load() {
-			w.WriteHeader(http.StatusOK)
-			w.Write([]byte("ok"))
-		} else {
+	probes.HandleFunc("GET /readyz", func(w http.ResponseWriter, r *http.Request) {
+		state, reasons := s.State()
+		switch state {
+		case stateStarting:
 			w.WriteHeader(http.StatusServiceUnavailable)
 			w.Write([]byte("not ready"))
+		case stateDegraded:
+			w.WriteHeader(http.StatusOK)
+			w.Write([]byte("degraded"))
+		default:
+			w.WriteHeader(http.StatusOK)
+			w.Write([]byte("ok"))
 		}
+		if !r.URL.Query().Has("verbose") {
+			return
+		}
+		// One line per reason, e.g. "[-] consul_stale: ...".
+		var b strings.Builder
+		for _, reason := range slices.Sorted(maps.Keys(reasons)) {
+			b.WriteString("\n[-] " + reason + ": " + reasons[reason])
+		}
+		b.WriteString("\n")
+		w.Write([]byte(b.String()))
 	})
 
 	mux.HandleFunc("GET /version", func(w http.ResponseWriter, _ *http.Request) {
@@ -55,16 +185,46 @@ func (s *Server) ListenAndServe() error {
 		})
 	})
 
-	mux.Handle("GET /metrics", promhttp.Handler())
+	// controller-runtime registers the client-go request, workqueue and
+	// leader election metrics in a registry of its own.
+	gatherers := prometheus.Gatherers{prometheus.DefaultGatherer, ctrlmetrics.Registry}
+	mux.Handle("GET /metrics", promhttp.InstrumentMetricHandler(
+		prometheus.DefaultRegisterer, promhttp.HandlerFor(gatherers, promhttp.HandlerOpts{}),
+	))
+
+	for pattern, handler := range s.extra {
+		mux.Handle(pattern, handler)
+	}
 
 	s.server = &http.Server{Addr: s.addr, Handler: mux}
-	return s.server.ListenAndServe()
+	if s.opts.HealthAddr == "" {
+		return s.server.ListenAndServe()
+	}
+
+	s.health = &http.Server{Addr: s.opts.HealthAddr, Handler: probes}
+	errs := make(chan error, 2)
+	go func() { errs <- s.health.ListenAndServe() }()
+	go func() { errs <- s.server.ListenAndServe() }()
+	return <-errs
 }
 
 // Shutdown gracefully shuts down the HTTP server.
 func (s *Server) Shutdown(ctx context.Context) error {
+	if err := sdNotify("STOPPING=1"); err != nil {
+		slog.Error("failed to notify systemd", "error", err)
+	}
+	if s.opts.ReadyFile != "" {
+		if err := os.Remove(s.opts.ReadyFile); err != nil && !errors.Is(err, fs.ErrNotExist) {
+			slog.Error("failed to remove ready file", "path", s.opts.ReadyFile, "error", err)
+		}
+	}
+
+	var errs []error
+	if s.health != nil {
+		errs = append(errs, s.health.Shutdown(ctx))
+	}
 	if s.server != nil {
-		return s.server.Shutdown(ctx)
+		errs = append(errs, s.server.Shutdown(ctx))
 	}
-	return nil
+	return errors.Join(errs...)
 }
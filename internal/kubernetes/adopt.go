@@ -0,0 +1,82 @@
+package kubernetes
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"slices"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+)
+
+// patchFunc patches the object being adopted and returns the result.
+type patchFunc func(pt types.PatchType, data []byte, opts metav1.PatchOptions) (metav1.Object, error)
+
+// applyOptions returns the options of consul-sync's server-side applies of
+// kind. They force ownership of fields other managers set, such as the
+// managed-by label of a Service created by another tool, in adoption mode or
+// under ConflictForce.
+func (s *Syncer) applyOptions(kind string) metav1.PatchOptions {
+	opts := metav1.PatchOptions{FieldManager: fieldManager}
+	if s.conflictPolicy(kind) == ConflictForce {
+		force := true
+		opts.Force = &force
+	}
+	return opts
+}
+
+// releaseManagers removes the managers in AdoptFieldManagers from obj, just
+// applied, so consul-sync is its only owner. A manager that applied the object
+// applies an empty configuration, which also removes the fields only it set;
+// a manager that updated it is dropped from the managed fields, leaving any
+// fields only it set unowned.
+func (s *Syncer) releaseManagers(ctx context.Context, obj metav1.Object, typeMeta metav1.TypeMeta, patch patchFunc) error {
+	if !s.opts.Adopt || len(s.opts.AdoptFieldManagers) == 0 {
+		return nil
+	}
+
+	for _, entry := range obj.GetManagedFields() {
+		if entry.Operation != metav1.ManagedFieldsOperationApply || !s.adoptsFrom(entry.Manager) {
+			continue
+		}
+		empty := map[string]any{
+			"apiVersion": typeMeta.APIVersion,
+			"kind":       typeMeta.Kind,
+			"metadata":   map[string]any{"name": obj.GetName(), "namespace": obj.GetNamespace()},
+		}
+		data, err := json.Marshal(empty)
+		if err != nil {
+			return fmt.Errorf("marshaling empty %s: %w", typeMeta.Kind, err)
+		}
+		if obj, err = patch(types.ApplyPatchType, data, metav1.PatchOptions{FieldManager: entry.Manager}); err != nil {
+			return fmt.Errorf("releasing fields of %s: %w", entry.Manager, err)
+		}
+		slog.InfoContext(ctx, "adopted object from previous field manager", "kind", typeMeta.Kind, "name", obj.GetName(), "manager", entry.Manager)
+	}
+
+	entries := obj.GetManagedFields()
+	kept := slices.DeleteFunc(slices.Clone(entries), func(e metav1.ManagedFieldsEntry) bool {
+		return s.adoptsFrom(e.Manager)
+	})
+	if len(kept) == len(entries) {
+		return nil
+	}
+	data, err := json.Marshal([]map[string]any{
+		{"op": "replace", "path": "/metadata/managedFields", "value": kept},
+	})
+	if err != nil {
+		return fmt.Errorf("marshaling managed fields: %w", err)
+	}
+	if _, err := patch(types.JSONPatchType, data, metav1.PatchOptions{}); err != nil {
+		return fmt.Errorf("removing previous field managers: %w", err)
+	}
+	slog.InfoContext(ctx, "adopted object from previous field managers", "kind", typeMeta.Kind, "name", obj.GetName(), "managers", len(entries)-len(kept))
+	return nil
+}
+
+// adoptsFrom reports whether consul-sync takes objects over from manager.
+func (s *Syncer) adoptsFrom(manager string) bool {
+	return manager != fieldManager && manager != healthFieldManager && slices.Contains(s.opts.AdoptFieldManagers, manager)
+}
@@ -0,0 +1,74 @@
+package kubernetes
+
+import (
+	"log/slog"
+	"slices"
+
+	"github.com/alexieff-io/consul-sync/internal/consul"
+)
+
+// aliasMetaKey is the Consul service meta key naming the Kubernetes Service a
+// Consul service contributes to, e.g. k8s-alias=payments.
+const aliasMetaKey = "k8s-alias"
+
+// AliasOf returns the name of the Kubernetes Service svc contributes to: its
+// k8s-alias meta if set, otherwise its own name.
+func AliasOf(svc consul.ServiceState) string {
+	if alias := svc.Meta[aliasMetaKey]; alias != "" {
+		return alias
+	}
+	return svc.Name
+}
+
+// MergeAliases combines services sharing an alias into a single state named
+// after the alias, so sharded or per-region registrations back one Service
+// and route. A service whose name equals another's alias joins that group.
+// Instances and failing checks are concatenated, tags unioned and meta merged
+// with the first service winning. Instances on a different port than the
+// group's first instance are dropped, since the Service exposes a single
+// port. Groups keep the position of their first member.
+func MergeAliases(services []consul.ServiceState) []consul.ServiceState {
+	index := make(map[string]int, len(services))
+	merged := make([]consul.ServiceState, 0, len(services))
+
+	for _, svc := range services {
+		alias := AliasOf(svc)
+		i, ok := index[alias]
+		if !ok {
+			index[alias] = len(merged)
+			svc.Name = alias
+			merged = append(merged, svc)
+			continue
+		}
+
+		group := &merged[i]
+		group.Instances = slices.Clone(group.Instances)
+		for _, inst := range svc.Instances {
+			if len(group.Instances) > 0 && inst.Port != group.Instances[0].Port {
+				slog.Warn("dropping aliased instance on a different port",
+					"alias", alias, "service", svc.Name, "address", inst.Address,
+					"port", inst.Port, "expected_port", group.Instances[0].Port)
+				continue
+			}
+			group.Instances = append(group.Instances, inst)
+		}
+		group.Registered += svc.Registered
+		group.FailingChecks = append(slices.Clip(group.FailingChecks), svc.FailingChecks...)
+		for _, t := range svc.Tags {
+			if !slices.Contains(group.Tags, t) {
+				group.Tags = append(slices.Clip(group.Tags), t)
+			}
+		}
+		if len(svc.Meta) > 0 {
+			meta := make(map[string]string, len(group.Meta)+len(svc.Meta))
+			for k, v := range svc.Meta {
+				meta[k] = v
+			}
+			for k, v := range group.Meta {
+				meta[k] = v
+			}
+			group.Meta = meta
+		}
+	}
+	return merged
+}
@@ -0,0 +1,393 @@
+package kubernetes
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"strings"
+
+	corev1 "k8s.io/api/core/v1"
+	discoveryv1 "k8s.io/api/discovery/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+
+	"github.com/alexieff-io/consul-sync/internal/consul"
+	"github.com/alexieff-io/consul-sync/internal/metrics"
+)
+
+// Audit problems.
+const (
+	AuditMissing  = "missing"  // desired but absent from the cluster
+	AuditOrphaned = "orphaned" // managed but no longer desired
+	AuditDrifted  = "drifted"  // present but different from the desired state
+)
+
+// Discrepancy is a difference between the state derived from the catalog and
+// the managed objects in the cluster. Objects outside the target namespace
+// are named namespace/name.
+type Discrepancy struct {
+	Kind    string `json:"kind"`
+	Name    string `json:"name"`
+	Problem string `json:"problem"`
+	Detail  string `json:"detail,omitempty"`
+}
+
+// AuditReport is the result of an Audit.
+type AuditReport struct {
+	Services      int           `json:"services"` // desired Services checked
+	Discrepancies []Discrepancy `json:"discrepancies"`
+}
+
+// Audit compares the given service states with the managed objects in the
+// cluster, the way Sync would reconcile them, without making any changes.
+// Orphans are reported regardless of MaxDeletionsPerSync. In node mode only
+// this node's EndpointSlices are checked, and orphaned Services and HTTPRoutes
+// aren't reported since other nodes may still serve them.
+func (s *Syncer) Audit(ctx context.Context, services []consul.ServiceState) (AuditReport, error) {
+	var report AuditReport
+	placed := s.placeServices(ctx, MergeAliases(services))
+	for _, ns := range s.syncers() {
+		r, err := ns.audit(s.withNamespace(ctx, ns), placed[ns.namespace])
+		if err != nil {
+			return AuditReport{}, err
+		}
+		report.Services += r.Services
+		for _, d := range r.Discrepancies {
+			if ns != s {
+				d.Name = ns.namespace + "/" + d.Name
+			}
+			report.Discrepancies = append(report.Discrepancies, d)
+		}
+	}
+
+	slices.SortFunc(report.Discrepancies, func(a, b Discrepancy) int {
+		if c := strings.Compare(a.Kind, b.Kind); c != 0 {
+			return c
+		}
+		return strings.Compare(a.Name, b.Name)
+	})
+
+	metrics.AuditDiscrepancies.Reset()
+	for _, d := range report.Discrepancies {
+		metrics.AuditDiscrepancies.WithLabelValues(d.Kind, d.Problem).Inc()
+	}
+	return report, nil
+}
+
+// audit is Audit within the syncer's namespace, for services already merged
+// by MergeAliases.
+func (s *Syncer) audit(ctx context.Context, services []consul.ServiceState) (AuditReport, error) {
+	c := s.clientsFor(s.namespace)
+	opts := metav1.ListOptions{LabelSelector: managedByKey + "=" + managedBy}
+	writeSlices := !s.opts.ServiceOnly && s.opts.EndpointsMode.slices()
+	writeEndpoints := !s.opts.ServiceOnly && s.opts.EndpointsMode.endpoints()
+
+	existingSvcs := make(map[string]*corev1.Service)
+	if !s.opts.ExternalServices {
+		list, err := c.Core.CoreV1().Services(s.namespace).List(ctx, opts)
+		if err != nil {
+			return AuditReport{}, fmt.Errorf("listing managed services: %w", err)
+		}
+		for i := range list.Items {
+			existingSvcs[list.Items[i].Name] = &list.Items[i]
+		}
+	}
+
+	existingSlices := make(map[string]*discoveryv1.EndpointSlice)
+	if writeSlices {
+		sliceOpts := opts
+		if s.opts.NodeName != "" {
+			sliceOpts.LabelSelector += "," + nodeLabelKey + "=" + s.opts.NodeName
+		}
+		list, err := c.Core.DiscoveryV1().EndpointSlices(s.namespace).List(ctx, sliceOpts)
+		if err != nil {
+			return AuditReport{}, fmt.Errorf("listing managed endpointslices: %w", err)
+		}
+		for i := range list.Items {
+			existingSlices[list.Items[i].Name] = &list.Items[i]
+		}
+	}
+
+	existingEndpoints := make(map[string]*corev1.Endpoints)
+	if writeEndpoints {
+		list, err := c.Core.CoreV1().Endpoints(s.namespace).List(ctx, opts)
+		if err != nil {
+			return AuditReport{}, fmt.Errorf("listing managed endpoints: %w", err)
+		}
+		for i := range list.Items {
+			existingEndpoints[list.Items[i].Name] = &list.Items[i]
+		}
+	}
+
+	existingRoutes := make(map[string]*unstructured.Unstructured)
+	if s.routeCfg.Enabled {
+		list, err := c.Dynamic.Resource(httpRouteGVR).Namespace(s.namespace).List(ctx, opts)
+		if err != nil {
+			return AuditReport{}, fmt.Errorf("listing managed httproutes: %w", err)
+		}
+		for i := range list.Items {
+			existingRoutes[list.Items[i].GetName()] = &list.Items[i]
+		}
+	}
+
+	var report AuditReport
+	add := func(kind, name, problem, detailFmt string, args ...any) {
+		report.Discrepancies = append(report.Discrepancies, Discrepancy{
+			Kind: kind, Name: name, Problem: problem, Detail: fmt.Sprintf(detailFmt, args...),
+		})
+	}
+
+	desired := make(map[string]bool)
+	desiredRoutes := make(map[string]bool)
+	var members []routeMember
+	for _, svc := range services {
+		name := s.opts.Names.Sanitize(svc.Name)
+		desired[name] = true
+
+		// Sync leaves these services' objects as they are.
+		if len(svc.Instances) == 0 {
+			continue
+		}
+		ports := s.portsFor(ctx, svc, name, false)
+		if !validPorts(ports) {
+			continue
+		}
+		wantPorts := portNumbers(ports)
+		report.Services++
+		want := instanceAddresses(s.endpointInstances(ctx, svc, name, false))
+
+		mode := s.serviceModeFor(ctx, svc)
+		if existing, ok := existingSvcs[name]; !ok && !s.opts.ExternalServices {
+			add("Service", name, AuditMissing, "")
+		} else if ok {
+			if got := servicePorts(existing); !slices.Equal(got, wantPorts) {
+				add("Service", name, AuditDrifted, "ports %v, want %v", got, wantPorts)
+			}
+			if !mode.matches(existing, want) {
+				add("Service", name, AuditDrifted, "not shaped for mode %s", mode)
+			}
+		}
+		// An ExternalName Service has no endpoints.
+		externalName := mode == ServiceModeExternalName && !s.opts.ExternalServices
+
+		if writeSlices && !externalName {
+			wantByFamily := byFamily(want)
+			for _, family := range addressFamilies {
+				sliceName := s.sliceName(name, family)
+				existing, ok := existingSlices[sliceName]
+				if !ok {
+					// The IPv6 and FQDN slices only exist while there
+					// are instances of that address type.
+					if family == discoveryv1.AddressTypeIPv4 || len(wantByFamily[family]) > 0 {
+						add("EndpointSlice", sliceName, AuditMissing, "")
+					}
+					continue
+				}
+				var got []string
+				for _, ep := range existing.Endpoints {
+					// Draining endpoints are deliberately kept past their
+					// removal from Consul.
+					if ep.Conditions.Terminating != nil && *ep.Conditions.Terminating {
+						continue
+					}
+					got = append(got, ep.Addresses...)
+				}
+				if diff := addressDiff(got, wantByFamily[family]); diff != "" {
+					add("EndpointSlice", sliceName, AuditDrifted, "%s", diff)
+				}
+				if got := endpointSlicePorts(existing); !slices.Equal(got, wantPorts) {
+					add("EndpointSlice", sliceName, AuditDrifted, "ports %v, want %v", got, wantPorts)
+				}
+			}
+		}
+
+		if writeEndpoints && !externalName {
+			if existing, ok := existingEndpoints[name]; !ok {
+				add("Endpoints", name, AuditMissing, "")
+			} else {
+				var got []string
+				for _, subset := range existing.Subsets {
+					for _, addr := range subset.Addresses {
+						got = append(got, addr.IP)
+					}
+				}
+				if diff := addressDiff(got, ipAddresses(want)); diff != "" {
+					add("Endpoints", name, AuditDrifted, "%s", diff)
+				}
+			}
+		}
+
+		if s.routeCfg.Enabled {
+			members = append(members, s.routeMembers(ctx, svc, name, routePort(ports), false)...)
+		}
+	}
+
+	plans, _ := s.planRoutes(members)
+	routeCfg := s.routeConfigFor(s.namespace)
+	plans = s.acceptedPlans(ctx, plans, s.readGateways(ctx, routeCfg, plans), false)
+	for _, plan := range plans {
+		desiredRoutes[plan.name] = true
+
+		existing, ok := existingRoutes[plan.name]
+		if !ok {
+			add("HTTPRoute", plan.name, AuditMissing, "")
+			continue
+		}
+		hostnames, _, _ := unstructured.NestedStringSlice(existing.Object, "spec", "hostnames")
+		if !slices.Equal(hostnames, []string{plan.hostname}) {
+			add("HTTPRoute", plan.name, AuditDrifted, "hostnames %v, want [%s]", hostnames, plan.hostname)
+		}
+		if parent := routeParent(existing); parent != plan.gateway {
+			add("HTTPRoute", plan.name, AuditDrifted, "gateway %q, want %q", parent, plan.gateway)
+		}
+		if listeners := routeListeners(existing); !slices.Equal(listeners, plan.listeners) {
+			add("HTTPRoute", plan.name, AuditDrifted, "listeners %s, want %s", describeListeners(listeners), describeListeners(plan.listeners))
+		}
+		if backends, want := routeBackends(existing), plan.backends(); !slices.Equal(backends, want) {
+			add("HTTPRoute", plan.name, AuditDrifted, "backends %v, want %v", backends, want)
+		}
+	}
+
+	nodeMode := s.opts.NodeName != ""
+	for name := range existingSvcs {
+		if !desired[name] && !nodeMode {
+			add("Service", name, AuditOrphaned, "")
+		}
+	}
+	for name, eps := range existingSlices {
+		if !desired[eps.Labels["kubernetes.io/service-name"]] {
+			add("EndpointSlice", name, AuditOrphaned, "")
+		}
+	}
+	for name := range existingEndpoints {
+		if !desired[name] {
+			add("Endpoints", name, AuditOrphaned, "")
+		}
+	}
+	for name := range existingRoutes {
+		if !desiredRoutes[name] && !nodeMode {
+			add("HTTPRoute", name, AuditOrphaned, "")
+		}
+	}
+	return report, nil
+}
+
+// instanceKey identifies inst across applies by its Consul service ID, or
+// by its address for sources without IDs.
+func instanceKey(inst consul.ServiceInstance) string {
+	if inst.ID == "" {
+		return inst.Address
+	}
+	return inst.Datacenter + "/" + inst.Node + "/" + inst.ID
+}
+
+// instanceAddresses returns the addresses of instances sorted, each once, so
+// Consul reordering its response, listing two instances on one address or
+// replacing an instance under a new ID leaves the written endpoints
+// unchanged.
+func instanceAddresses(instances []consul.ServiceInstance) []string {
+	addrs := make([]string, 0, len(instances))
+	for _, inst := range instances {
+		addrs = append(addrs, inst.Address)
+	}
+	slices.Sort(addrs)
+	return slices.Compact(addrs)
+}
+
+func servicePorts(svc *corev1.Service) []int32 {
+	var ports []int32
+	for _, p := range svc.Spec.Ports {
+		ports = append(ports, p.Port)
+	}
+	return ports
+}
+
+func endpointSlicePorts(eps *discoveryv1.EndpointSlice) []int32 {
+	var ports []int32
+	for _, p := range eps.Ports {
+		if p.Port != nil {
+			ports = append(ports, *p.Port)
+		}
+	}
+	return ports
+}
+
+// portNumbers returns the numbers of ports, in order.
+func portNumbers(ports []namedPort) []int32 {
+	numbers := make([]int32, 0, len(ports))
+	for _, p := range ports {
+		numbers = append(numbers, p.port)
+	}
+	return numbers
+}
+
+// addressDiff describes the addresses missing from got and the extra ones in
+// it, or returns "" when both hold the same set.
+func addressDiff(got, want []string) string {
+	var missing, extra []string
+	for _, a := range want {
+		if !slices.Contains(got, a) {
+			missing = append(missing, a)
+		}
+	}
+	for _, a := range got {
+		if !slices.Contains(want, a) {
+			extra = append(extra, a)
+		}
+	}
+	if len(missing) == 0 && len(extra) == 0 {
+		return ""
+	}
+	return fmt.Sprintf("missing addresses %v, extra addresses %v", missing, extra)
+}
+
+// routeBackends returns the Service names of the route's rules, in order.
+func routeBackends(route *unstructured.Unstructured) []string {
+	rules, _, _ := unstructured.NestedSlice(route.Object, "spec", "rules")
+	var backends []string
+	for _, r := range rules {
+		rule, _ := r.(map[string]any)
+		refs, _, _ := unstructured.NestedSlice(rule, "backendRefs")
+		for _, ref := range refs {
+			ref, _ := ref.(map[string]any)
+			name, _, _ := unstructured.NestedString(ref, "name")
+			backends = append(backends, name)
+		}
+	}
+	return backends
+}
+
+// routeListeners returns the sectionNames of the route's parentRefs, or nil
+// if it is attached to every listener.
+func routeListeners(route *unstructured.Unstructured) []string {
+	refs, _, _ := unstructured.NestedSlice(route.Object, "spec", "parentRefs")
+	var listeners []string
+	for _, r := range refs {
+		ref, _ := r.(map[string]any)
+		section, _, _ := unstructured.NestedString(ref, "sectionName")
+		if section == "" {
+			return nil
+		}
+		listeners = append(listeners, section)
+	}
+	return listeners
+}
+
+// describeListeners formats listeners for an audit detail.
+func describeListeners(listeners []string) string {
+	if listeners == nil {
+		return allListeners
+	}
+	return fmt.Sprint(listeners)
+}
+
+// routeParent returns the name of the route's first parent Gateway.
+func routeParent(route *unstructured.Unstructured) string {
+	refs, _, _ := unstructured.NestedSlice(route.Object, "spec", "parentRefs")
+	if len(refs) == 0 {
+		return ""
+	}
+	ref, _ := refs[0].(map[string]any)
+	name, _, _ := unstructured.NestedString(ref, "name")
+	return name
+}
@@ -0,0 +1,107 @@
+package kubernetes
+
+import (
+	"context"
+	"encoding/json"
+	"log/slog"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+
+	"github.com/alexieff-io/consul-sync/internal/consul"
+)
+
+// failureState tracks the consecutive failed syncs of one service.
+type failureState struct {
+	failures int
+	state    string    // stateKey of the service when it last failed
+	until    time.Time // end of the quarantine, zero when not quarantined
+}
+
+// stateKey summarizes the parts of svc its objects are generated from, so a
+// quarantine is lifted as soon as the service changes in the catalog.
+func stateKey(svc consul.ServiceState) string {
+	data, _ := json.Marshal(struct {
+		Instances []consul.ServiceInstance
+		Tags      []string
+		Meta      map[string]string
+	}{svc.Instances, svc.Tags, svc.Meta})
+	return string(data)
+}
+
+// quarantined reports whether the service name is quarantined after
+// repeatedly failing to sync, and should be skipped until its quarantine
+// ends or its state in the catalog changes.
+func (s *Syncer) quarantined(ctx context.Context, name string, svc consul.ServiceState, now time.Time) bool {
+	f, ok := s.failures[name]
+	if !ok || f.until.IsZero() {
+		return false
+	}
+	if f.state != stateKey(svc) {
+		slog.InfoContext(ctx, "releasing quarantined service, it changed in the catalog", "service", name)
+		delete(s.failures, name)
+		return false
+	}
+	if !now.Before(f.until) {
+		slog.InfoContext(ctx, "retrying quarantined service", "service", name, "failures", f.failures)
+		return false
+	}
+	return true
+}
+
+// recordSync records the outcome of syncing the service name, quarantining it
+// once it has failed FailureThreshold times in a row. Each further failure
+// doubles the quarantine, from FailureBackoff up to MaxFailureBackoff.
+func (s *Syncer) recordSync(ctx context.Context, name string, svc consul.ServiceState, err error, now time.Time) {
+	if s.opts.FailureThreshold <= 0 {
+		return
+	}
+	if err == nil {
+		if f, ok := s.failures[name]; ok && !f.until.IsZero() {
+			slog.InfoContext(ctx, "quarantined service synced again", "service", name)
+		}
+		delete(s.failures, name)
+		return
+	}
+
+	f, ok := s.failures[name]
+	if !ok {
+		f = &failureState{}
+		s.failures[name] = f
+	}
+	f.failures++
+	f.state = stateKey(svc)
+	if f.failures < s.opts.FailureThreshold {
+		return
+	}
+
+	backoff := s.opts.FailureBackoff
+	for i := s.opts.FailureThreshold; i < f.failures; i++ {
+		backoff *= 2
+		if limit := s.opts.MaxFailureBackoff; limit > 0 && backoff >= limit {
+			backoff = limit
+			break
+		}
+	}
+	f.until = now.Add(backoff)
+
+	slog.WarnContext(ctx, "quarantining repeatedly failing service", "service", name, "failures", f.failures, "retry_in", backoff, "error", err)
+	s.eventf(s.namespace, name, corev1.EventTypeWarning, "Quarantined",
+		"Failed to sync %d times in a row, retrying in %s unless the service changes: %v", f.failures, backoff, err)
+}
+
+// pruneFailures forgets the failures of services no longer in desired and
+// returns the number of quarantined services.
+func (s *Syncer) pruneFailures(desired map[string]bool, now time.Time) int {
+	quarantined := 0
+	for name, f := range s.failures {
+		if !desired[name] {
+			delete(s.failures, name)
+			continue
+		}
+		if now.Before(f.until) {
+			quarantined++
+		}
+	}
+	return quarantined
+}
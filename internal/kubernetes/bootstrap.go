@@ -0,0 +1,77 @@
+package kubernetes
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"maps"
+	"slices"
+	"strings"
+	"sync"
+
+	corev1 "k8s.io/api/core/v1"
+)
+
+// eventBatch holds back the Events of the first Sync, the catch-up with the
+// catalog after a start, see Options.QuietBootstrap. It is shared by the
+// Syncers of every namespace.
+type eventBatch struct {
+	mu      sync.Mutex
+	started bool
+	active  bool
+	// held counts the Events held back, by reason, and warnings those of
+	// type Warning.
+	held     map[string]int
+	warnings int
+}
+
+// begin starts holding back Events if quiet is set and this is the first
+// Sync, reporting whether it did.
+func (b *eventBatch) begin(quiet bool) bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.started {
+		return false
+	}
+	b.started = true
+	b.active = quiet
+	b.held = make(map[string]int)
+	return quiet
+}
+
+// hold reports whether an Event is held back, counting it if so.
+func (b *eventBatch) hold(eventType, reason string) bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if !b.active {
+		return false
+	}
+	b.held[reason]++
+	if eventType == corev1.EventTypeWarning {
+		b.warnings++
+	}
+	return true
+}
+
+// holding reports whether Events are held back right now.
+func (b *eventBatch) holding() bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.active
+}
+
+// end stops holding back Events and logs a single summary of those held.
+func (b *eventBatch) end(ctx context.Context) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.active = false
+	var total int
+	reasons := make([]string, 0, len(b.held))
+	for _, reason := range slices.Sorted(maps.Keys(b.held)) {
+		total += b.held[reason]
+		reasons = append(reasons, fmt.Sprintf("%s=%d", reason, b.held[reason]))
+	}
+	slog.InfoContext(ctx, "initial sync complete, per-service events held back",
+		"events", total, "warnings", b.warnings, "reasons", strings.Join(reasons, ","))
+	b.held = nil
+}
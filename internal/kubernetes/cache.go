@@ -0,0 +1,118 @@
+package kubernetes
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	discoveryv1 "k8s.io/api/discovery/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"sigs.k8s.io/controller-runtime/pkg/cache"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// cacheSyncTimeout bounds the wait for an informer of the cache to sync on
+// its first read, so a missing list or watch permission fails the sync
+// instead of blocking it.
+const cacheSyncTimeout = 2 * time.Minute
+
+// CacheOptions returns the options of an informer cache holding the
+// Services, EndpointSlices and Endpoints managed by consul-sync in the
+// namespaces the syncer reads with its own identity, for UseCache. It
+// returns false if every namespace is read with tenant clients.
+func (s *Syncer) CacheOptions() (cache.Options, bool) {
+	namespaces := make(map[string]cache.Config)
+	for _, syncer := range s.syncers() {
+		if _, ok := s.opts.TenantClients[syncer.namespace]; !ok {
+			namespaces[syncer.namespace] = cache.Config{}
+		}
+	}
+	if len(namespaces) == 0 {
+		return cache.Options{}, false
+	}
+	return cache.Options{
+		DefaultNamespaces:    namespaces,
+		DefaultLabelSelector: labels.SelectorFromSet(labels.Set{managedByKey: managedBy}),
+	}, true
+}
+
+// UseCache makes the syncer list the Services, EndpointSlices and Endpoints
+// it manages from reader, a cache created with CacheOptions, instead of the
+// API server. Namespaces with tenant clients are still read through those,
+// and every write, as well as audits and HTTPRoutes, still goes to the API
+// server. It must be called before the first Sync.
+func (s *Syncer) UseCache(reader client.Reader) {
+	for _, syncer := range s.syncers() {
+		if _, ok := s.opts.TenantClients[syncer.namespace]; !ok {
+			syncer.cache = reader
+		}
+	}
+}
+
+// listServices lists the Services of the syncer's namespace matching
+// selector, from the cache if there is one. The Services must not be
+// modified.
+func (s *Syncer) listServices(ctx context.Context, selector string) ([]corev1.Service, error) {
+	if s.cache == nil {
+		list, err := s.clientsFor(s.namespace).Core.CoreV1().Services(s.namespace).List(ctx, metav1.ListOptions{LabelSelector: selector})
+		if err != nil {
+			return nil, err
+		}
+		return list.Items, nil
+	}
+	var list corev1.ServiceList
+	if err := s.listCached(ctx, &list, selector); err != nil {
+		return nil, err
+	}
+	return list.Items, nil
+}
+
+// listSlices lists the EndpointSlices of the syncer's namespace matching
+// selector, from the cache if there is one. The slices must not be
+// modified.
+func (s *Syncer) listSlices(ctx context.Context, selector string) ([]discoveryv1.EndpointSlice, error) {
+	if s.cache == nil {
+		list, err := s.clientsFor(s.namespace).Core.DiscoveryV1().EndpointSlices(s.namespace).List(ctx, metav1.ListOptions{LabelSelector: selector})
+		if err != nil {
+			return nil, err
+		}
+		return list.Items, nil
+	}
+	var list discoveryv1.EndpointSliceList
+	if err := s.listCached(ctx, &list, selector); err != nil {
+		return nil, err
+	}
+	return list.Items, nil
+}
+
+// listEndpoints lists the Endpoints of the syncer's namespace matching
+// selector, from the cache if there is one. The Endpoints must not be
+// modified.
+func (s *Syncer) listEndpoints(ctx context.Context, selector string) ([]corev1.Endpoints, error) {
+	if s.cache == nil {
+		list, err := s.clientsFor(s.namespace).Core.CoreV1().Endpoints(s.namespace).List(ctx, metav1.ListOptions{LabelSelector: selector})
+		if err != nil {
+			return nil, err
+		}
+		return list.Items, nil
+	}
+	var list corev1.EndpointsList
+	if err := s.listCached(ctx, &list, selector); err != nil {
+		return nil, err
+	}
+	return list.Items, nil
+}
+
+// listCached lists the objects of the syncer's namespace matching selector
+// from the cache into list.
+func (s *Syncer) listCached(ctx context.Context, list client.ObjectList, selector string) error {
+	sel, err := labels.Parse(selector)
+	if err != nil {
+		return fmt.Errorf("parsing label selector: %w", err)
+	}
+	ctx, cancel := context.WithTimeout(ctx, cacheSyncTimeout)
+	defer cancel()
+	return s.cache.List(ctx, list, client.InNamespace(s.namespace), client.MatchingLabelsSelector{Selector: sel})
+}
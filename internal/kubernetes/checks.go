@@ -0,0 +1,196 @@
+package kubernetes
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"slices"
+	"strings"
+
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+
+	"github.com/alexieff-io/consul-sync/internal/consul"
+)
+
+// Annotations summarizing a service's health in Consul, set on its Service so
+// cluster users can see why instances are missing without the Consul UI.
+const (
+	instancesAnnotation     = "consul-sync.alexieff.io/instances"
+	failingChecksAnnotation = "consul-sync.alexieff.io/failing-checks"
+	datacentersAnnotation   = "consul-sync.alexieff.io/datacenters"
+)
+
+// healthFieldManager owns the health annotations, apart from the rest of the
+// Service, so they can still be updated while the Service is left alone for
+// lack of healthy instances.
+const healthFieldManager = "consul-sync-health"
+
+// Limits keeping the failing checks annotation readable and well below the
+// 256KiB total annotation size limit.
+const (
+	maxAnnotatedChecks = 10
+	maxCheckOutput     = 120
+)
+
+// maxReportedChecks caps the checks listed in an EndpointsUnhealthy Event,
+// keeping it within the 1KiB Event message limit.
+const maxReportedChecks = 5
+
+// healthAnnotations returns the health annotations for svc, or nil when the
+// source doesn't report unhealthy instances.
+func healthAnnotations(svc consul.ServiceState) map[string]string {
+	if svc.Registered == 0 {
+		return nil
+	}
+	annotations := map[string]string{
+		instancesAnnotation: fmt.Sprintf("%d/%d healthy", len(svc.Instances), svc.Registered),
+	}
+	if len(svc.Datacenters) > 0 {
+		annotations[datacentersAnnotation] = strings.Join(svc.Datacenters, ",")
+	}
+	if len(svc.FailingChecks) == 0 {
+		return annotations
+	}
+
+	var b strings.Builder
+	for i, c := range svc.FailingChecks {
+		if i == maxAnnotatedChecks {
+			fmt.Fprintf(&b, "... and %d more\n", len(svc.FailingChecks)-i)
+			break
+		}
+		b.WriteString(describeCheck(c))
+		b.WriteByte('\n')
+	}
+	annotations[failingChecksAnnotation] = strings.TrimSuffix(b.String(), "\n")
+	return annotations
+}
+
+// describeCheck returns a one-line description of a failing check.
+func describeCheck(c consul.Check) string {
+	instance := c.Node
+	if c.Datacenter != "" {
+		instance = c.Datacenter + "/" + instance
+	}
+	if c.ServiceID != "" {
+		instance += "/" + c.ServiceID
+	}
+	desc := fmt.Sprintf("%s: %s is %s", instance, c.Name, c.Status)
+	if output := condenseOutput(c.Output); output != "" {
+		desc += ": " + output
+	}
+	return desc
+}
+
+// condenseOutput returns the first non-empty line of a check output,
+// truncated to maxCheckOutput bytes.
+func condenseOutput(output string) string {
+	for _, line := range strings.Split(output, "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" {
+			continue
+		}
+		if len(line) > maxCheckOutput {
+			line = strings.ToValidUTF8(line[:maxCheckOutput], "") + "..."
+		}
+		return line
+	}
+	return ""
+}
+
+// applyHealthAnnotations applies the health annotations of svc to the Service
+// name. Unless applied is set, the Service may not exist and is only
+// annotated if it does. Nothing is written in node mode, where each node only
+// knows its own instances, or to external Services.
+func (s *Syncer) applyHealthAnnotations(ctx context.Context, name string, svc consul.ServiceState, applied bool) error {
+	if s.opts.NodeName != "" || s.opts.ExternalServices {
+		return nil
+	}
+	annotations := healthAnnotations(svc)
+	key := s.namespace + "/" + name
+	last, ok := s.appliedHealth[key]
+	if want := fmt.Sprint(annotations); ok && last == want || !ok && annotations == nil {
+		return nil
+	}
+
+	c := s.clientsFor(s.namespace)
+	if !applied {
+		if _, err := c.Core.CoreV1().Services(s.namespace).Get(ctx, name, metav1.GetOptions{}); apierrors.IsNotFound(err) {
+			return nil
+		} else if err != nil {
+			return fmt.Errorf("getting service: %w", err)
+		}
+	}
+
+	obj := &corev1.Service{
+		TypeMeta: metav1.TypeMeta{
+			APIVersion: "v1",
+			Kind:       "Service",
+		},
+		ObjectMeta: metav1.ObjectMeta{
+			Name:        name,
+			Namespace:   s.namespace,
+			Annotations: annotations,
+		},
+	}
+	data, err := json.Marshal(obj)
+	if err != nil {
+		return fmt.Errorf("marshaling service annotations: %w", err)
+	}
+	s.pace(ctx)
+	if _, err := c.Core.CoreV1().Services(s.namespace).Patch(
+		ctx, name, types.ApplyPatchType, data,
+		metav1.PatchOptions{FieldManager: healthFieldManager},
+	); err != nil {
+		return err
+	}
+	s.appliedHealth[key] = fmt.Sprint(annotations)
+	return nil
+}
+
+// reportRemovedEndpoints records the ready addresses just applied for the
+// Service name and, when addresses of the previous apply were dropped because
+// of failing checks, records a Warning Event on the Service listing the
+// checks, so it's clear from kubectl alone why the endpoints went away.
+// Addresses of deregistered instances are dropped silently.
+func (s *Syncer) reportRemovedEndpoints(name string, svc consul.ServiceState) {
+	current := instanceAddresses(svc.Instances)
+	previous, known := s.endpointAddrs[name]
+	s.endpointAddrs[name] = current
+	if !known {
+		return
+	}
+
+	var removed, checks []string
+	total := 0
+	for _, addr := range previous {
+		if slices.Contains(current, addr) {
+			continue
+		}
+		failing := false
+		for _, c := range svc.FailingChecks {
+			if c.Address != addr {
+				continue
+			}
+			failing = true
+			total++
+			if len(checks) < maxReportedChecks {
+				checks = append(checks, describeCheck(c))
+			}
+		}
+		if failing {
+			removed = append(removed, addr)
+		}
+	}
+	if len(removed) == 0 {
+		return
+	}
+	if n := total - len(checks); n > 0 {
+		checks = append(checks, fmt.Sprintf("... and %d more", n))
+	}
+	s.eventf(s.namespace, name, corev1.EventTypeWarning, "EndpointsUnhealthy",
+		"Removed %d endpoint(s) failing Consul checks (%s): %s",
+		len(removed), strings.Join(removed, ", "), strings.Join(checks, "; "))
+}
@@ -0,0 +1,80 @@
+package kubernetes
+
+import (
+	"context"
+	"log/slog"
+	"sync"
+
+	corev1 "k8s.io/api/core/v1"
+
+	"github.com/alexieff-io/consul-sync/internal/metrics"
+)
+
+// hostnameClaims records which HTTPRoute routes each hostname on each
+// Gateway, across the namespaces of a Syncer, so a Gateway never gets two
+// routes for the same hostname and picks between them arbitrarily.
+// Within a namespace planRoutes already merges them into one route.
+type hostnameClaims struct {
+	mu sync.Mutex
+	// owners maps gateway namespace/name/hostname to the namespace/name of
+	// the route holding it.
+	owners     map[string]string
+	duplicates int
+}
+
+func newHostnameClaims() *hostnameClaims {
+	return &hostnameClaims{owners: make(map[string]string)}
+}
+
+// reset forgets every claim at the start of a Sync, which claims them again
+// in namespace order.
+func (c *hostnameClaims) reset() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.owners = make(map[string]string)
+	c.duplicates = 0
+}
+
+// claim gives key to owner unless another owner holds it, which is returned.
+func (c *hostnameClaims) claim(key, owner string) (string, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if holder, ok := c.owners[key]; ok && holder != owner {
+		c.duplicates++
+		return holder, false
+	}
+	c.owners[key] = owner
+	return owner, true
+}
+
+// claimedPlans returns the plans whose hostname no route of another
+// namespace already holds on the same Gateway, claiming it for them. The
+// plans left out are logged, counted and recorded as Events on their
+// Services.
+func (s *Syncer) claimedPlans(ctx context.Context, cfg HTTPRouteConfig, plans []routePlan) []routePlan {
+	claimed := make([]routePlan, 0, len(plans))
+	for _, plan := range plans {
+		key := cfg.GatewayNamespace + "/" + plan.gateway + "/" + plan.hostname
+		holder, ok := s.claims.claim(key, s.namespace+"/"+plan.name)
+		if ok {
+			claimed = append(claimed, plan)
+			continue
+		}
+		slog.WarnContext(ctx, "skipping httproute, another route already has its hostname on the gateway",
+			"route", plan.name, "gateway", plan.gateway, "hostname", plan.hostname, "holder", holder)
+		for _, name := range plan.backends() {
+			s.eventf(s.namespace, name, corev1.EventTypeWarning, "DuplicateHostname",
+				"Skipping HTTPRoute %s: HTTPRoute %s already routes %s on gateway %s",
+				plan.name, holder, plan.hostname, plan.gateway)
+		}
+	}
+	return claimed
+}
+
+// report exports the number of routes left out by the last Sync for a
+// hostname already claimed.
+func (c *hostnameClaims) report() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	metrics.DuplicateHostnames.Set(float64(c.duplicates))
+}
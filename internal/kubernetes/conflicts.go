@@ -0,0 +1,106 @@
+package kubernetes
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"strings"
+
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+
+	"github.com/alexieff-io/consul-sync/internal/metrics"
+)
+
+// ConflictPolicy selects what an apply does when another field manager owns
+// a field consul-sync sets, e.g. after someone edited the object by hand.
+type ConflictPolicy string
+
+const (
+	// ConflictFail fails the apply, which is retried on the next reconcile.
+	ConflictFail ConflictPolicy = "fail"
+	// ConflictForce takes the fields over: the controller always wins.
+	ConflictForce ConflictPolicy = "force"
+	// ConflictSkip leaves the object as it is and reports the conflict, so
+	// changes made by people are never clobbered.
+	ConflictSkip ConflictPolicy = "skip"
+)
+
+// Resource kinds a ConflictPolicies entry can apply to.
+const (
+	kindService       = "service"
+	kindEndpointSlice = "endpointslice"
+	kindEndpoints     = "endpoints"
+	kindHTTPRoute     = "httproute"
+)
+
+// ConflictPolicies maps a resource kind (service, endpointslice, endpoints or
+// httproute) to its ConflictPolicy. The empty kind holds the default for the
+// others, itself defaulting to ConflictFail.
+type ConflictPolicies map[string]ConflictPolicy
+
+// ParseConflictPolicies parses a comma-separated list of policies, each
+// either kind=policy or a bare default policy, e.g. "skip,httproute=force".
+func ParseConflictPolicies(s string) (ConflictPolicies, error) {
+	policies := make(ConflictPolicies)
+	for _, entry := range strings.Split(s, ",") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+		kind, raw, ok := strings.Cut(entry, "=")
+		if !ok {
+			kind, raw = "", entry
+		}
+		switch kind {
+		case "", kindService, kindEndpointSlice, kindEndpoints, kindHTTPRoute:
+		default:
+			return nil, fmt.Errorf("unknown kind %q, expected service, endpointslice, endpoints or httproute", kind)
+		}
+		switch p := ConflictPolicy(raw); p {
+		case ConflictFail, ConflictForce, ConflictSkip:
+			policies[kind] = p
+		default:
+			return nil, fmt.Errorf("expected fail, force or skip for %q, got %q", entry, raw)
+		}
+	}
+	return policies, nil
+}
+
+// For returns the policy of kind.
+func (p ConflictPolicies) For(kind string) ConflictPolicy {
+	if policy, ok := p[kind]; ok {
+		return policy
+	}
+	if policy, ok := p[""]; ok {
+		return policy
+	}
+	return ConflictFail
+}
+
+// conflictPolicy returns the policy of kind. Adoption mode forces every
+// apply.
+func (s *Syncer) conflictPolicy(kind string) ConflictPolicy {
+	if s.opts.Adopt {
+		return ConflictForce
+	}
+	return s.opts.ConflictPolicies.For(kind)
+}
+
+// skipConflict reports whether err, returned by the apply of the kind object
+// name belonging to Service service, is a conflict the policy of kind skips.
+// Skipped conflicts are logged, counted and recorded as Events.
+func (s *Syncer) skipConflict(ctx context.Context, kind, name, service string, err error) bool {
+	if !apierrors.IsConflict(err) {
+		return false
+	}
+	policy := s.conflictPolicy(kind)
+	metrics.ApplyConflicts.WithLabelValues(kind, string(policy)).Inc()
+	if policy != ConflictSkip {
+		return false
+	}
+	slog.WarnContext(ctx, "leaving object changed by another field manager", "kind", kind, "name", name, "error", err)
+	s.eventf(s.namespace, service, corev1.EventTypeWarning, "ApplyConflict",
+		"Left %s %s unchanged: %v", kind, name, err)
+	return true
+}
@@ -0,0 +1,79 @@
+package kubernetes
+
+import (
+	"slices"
+	"time"
+
+	"github.com/alexieff-io/consul-sync/internal/consul"
+)
+
+// drainState remembers a Service's endpoint addresses across applies, so
+// addresses that disappear from Consul can be drained instead of dropped.
+type drainState struct {
+	instances map[string]string    // instanceKey → address of the last apply
+	draining  map[string]time.Time // removed address → drain deadline
+}
+
+// drainingAddresses records the current instances of the named Service and
+// returns the addresses that are still draining at now, sorted. It returns
+// nil when draining is disabled.
+//
+// Instances are followed by instanceKey, so an instance whose address
+// changes under the same service ID is an update: its old address is
+// dropped rather than drained, as nothing serves on it anymore. An instance
+// replaced under a new ID on the same address keeps its endpoint ready.
+func (s *Syncer) drainingAddresses(name string, instances []consul.ServiceInstance, now time.Time) []string {
+	if s.opts.DrainPeriod <= 0 {
+		return nil
+	}
+
+	current := instanceAddresses(instances)
+	keys := make(map[string]string, len(instances))
+	for _, inst := range instances {
+		keys[instanceKey(inst)] = inst.Address
+	}
+	st, ok := s.drains[name]
+	if !ok {
+		st = &drainState{draining: make(map[string]time.Time)}
+		s.drains[name] = st
+	}
+
+	for key, addr := range st.instances {
+		if newAddr, ok := keys[key]; ok && newAddr != addr {
+			continue
+		}
+		if _, ok := st.draining[addr]; !ok && !slices.Contains(current, addr) {
+			st.draining[addr] = now.Add(s.opts.DrainPeriod)
+		}
+	}
+	st.instances = keys
+
+	var draining []string
+	for addr, deadline := range st.draining {
+		if slices.Contains(current, addr) || !now.Before(deadline) {
+			delete(st.draining, addr)
+			continue
+		}
+		draining = append(draining, addr)
+	}
+	slices.Sort(draining)
+	return draining
+}
+
+// DrainDeadline returns the earliest future time a draining endpoint is due
+// for removal. Removal happens on the next sync of its Service after that
+// time; deadlines already past are waiting on such a sync and are skipped.
+func (s *Syncer) DrainDeadline() (time.Time, bool) {
+	now := time.Now()
+	var next time.Time
+	for _, ns := range s.syncers() {
+		for _, st := range ns.drains {
+			for _, deadline := range st.draining {
+				if deadline.After(now) && (next.IsZero() || deadline.Before(next)) {
+					next = deadline
+				}
+			}
+		}
+	}
+	return next, !next.IsZero()
+}
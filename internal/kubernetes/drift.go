@@ -0,0 +1,156 @@
+package kubernetes
+
+import (
+	"bytes"
+	"context"
+	"log/slog"
+	"slices"
+
+	discoveryv1 "k8s.io/api/discovery/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/dynamic/dynamicinformer"
+	"k8s.io/client-go/informers"
+	"k8s.io/client-go/tools/cache"
+
+	"github.com/alexieff-io/consul-sync/internal/metrics"
+)
+
+// desiredObjects are the names of the Services and HTTPRoutes the last Sync
+// of a namespace called for.
+type desiredObjects struct {
+	services map[string]bool
+	routes   map[string]bool
+}
+
+// WatchDrift watches the managed objects of every namespace and calls
+// trigger when one the last Sync called for is deleted, or changed by another
+// field manager than consul-sync's, so drift is repaired without waiting for
+// the next scheduled resync. It blocks until the context is cancelled.
+func (s *Syncer) WatchDrift(ctx context.Context, trigger func()) {
+	selector := func(opts *metav1.ListOptions) {
+		opts.LabelSelector = managedByKey + "=" + managedBy
+	}
+	var factories []interface{ Start(<-chan struct{}) }
+	var shutdowns []func()
+	for _, ns := range s.syncers() {
+		c := ns.clientsFor(ns.namespace)
+		factory := informers.NewSharedInformerFactoryWithOptions(c.Core, 0,
+			informers.WithNamespace(ns.namespace), informers.WithTweakListOptions(selector))
+		if !ns.opts.ExternalServices {
+			ns.watchKind(ctx, factory.Core().V1().Services().Informer(), kindService, trigger)
+		}
+		if !ns.opts.ServiceOnly && ns.opts.EndpointsMode.slices() {
+			ns.watchKind(ctx, factory.Discovery().V1().EndpointSlices().Informer(), kindEndpointSlice, trigger)
+		}
+		if !ns.opts.ServiceOnly && ns.opts.EndpointsMode.endpoints() {
+			ns.watchKind(ctx, factory.Core().V1().Endpoints().Informer(), kindEndpoints, trigger)
+		}
+		factories = append(factories, factory)
+		shutdowns = append(shutdowns, factory.Shutdown)
+
+		if ns.routeCfg.Enabled {
+			dynFactory := dynamicinformer.NewFilteredDynamicSharedInformerFactory(c.Dynamic, 0, ns.namespace, selector)
+			ns.watchKind(ctx, dynFactory.ForResource(httpRouteGVR).Informer(), kindHTTPRoute, trigger)
+			factories = append(factories, dynFactory)
+			shutdowns = append(shutdowns, dynFactory.Shutdown)
+		}
+		slog.Info("watching managed objects for drift", "namespace", ns.namespace)
+	}
+
+	for _, f := range factories {
+		f.Start(ctx.Done())
+	}
+	<-ctx.Done()
+	for _, shutdown := range shutdowns {
+		shutdown()
+	}
+}
+
+// watchKind calls trigger on the drift of the objects of kind seen by
+// informer.
+func (s *Syncer) watchKind(ctx context.Context, informer cache.SharedIndexInformer, kind string, trigger func()) {
+	informer.AddEventHandler(cache.ResourceEventHandlerFuncs{
+		UpdateFunc: func(oldObj, obj any) {
+			old, okOld := oldObj.(metav1.Object)
+			cur, ok := obj.(metav1.Object)
+			if !okOld || !ok || !s.wanted(kind, cur) {
+				return
+			}
+			if manager, changed := foreignChange(old, cur); changed {
+				slog.InfoContext(ctx, "managed object changed outside consul-sync, resyncing",
+					"kind", kind, "namespace", cur.GetNamespace(), "name", cur.GetName(), "manager", manager)
+				metrics.DriftDetected.WithLabelValues(kind, "changed").Inc()
+				trigger()
+			}
+		},
+		DeleteFunc: func(obj any) {
+			if tombstone, ok := obj.(cache.DeletedFinalStateUnknown); ok {
+				obj = tombstone.Obj
+			}
+			cur, ok := obj.(metav1.Object)
+			if !ok || !s.wanted(kind, cur) {
+				return
+			}
+			slog.InfoContext(ctx, "managed object deleted outside consul-sync, resyncing",
+				"kind", kind, "namespace", cur.GetNamespace(), "name", cur.GetName())
+			metrics.DriftDetected.WithLabelValues(kind, "deleted").Inc()
+			trigger()
+		},
+	})
+}
+
+// wanted reports whether the last Sync called for obj, of kind. Before the
+// first Sync nothing is, since that Sync repairs any drift anyway.
+func (s *Syncer) wanted(kind string, obj metav1.Object) bool {
+	desired := s.desired.Load()
+	if desired == nil {
+		return false
+	}
+	switch kind {
+	case kindHTTPRoute:
+		return desired.routes[obj.GetName()]
+	case kindEndpointSlice:
+		return desired.services[obj.GetLabels()[discoveryv1.LabelServiceName]]
+	default:
+		return desired.services[obj.GetName()]
+	}
+}
+
+// foreignChange reports whether the change from old to cur was made by
+// another field manager than consul-sync's, and which. A field manager of
+// consul-sync whose fields changed without it writing them lost them to
+// someone else, whose name isn't recorded.
+func foreignChange(old, cur metav1.Object) (string, bool) {
+	previous := old.GetManagedFields()
+	for _, e := range cur.GetManagedFields() {
+		if e.Subresource != "" {
+			continue
+		}
+		i := slices.IndexFunc(previous, func(o metav1.ManagedFieldsEntry) bool {
+			return o.Manager == e.Manager && o.Operation == e.Operation && o.Subresource == e.Subresource
+		})
+		ours := e.Manager == fieldManager || e.Manager == healthFieldManager
+		if i < 0 {
+			if !ours {
+				return e.Manager, true
+			}
+			continue
+		}
+		o := previous[i]
+		sameTime := o.Time.Equal(e.Time)
+		if ours && sameTime && !sameFields(o, e) {
+			return "", true
+		}
+		if !ours && (!sameTime || !sameFields(o, e)) {
+			return e.Manager, true
+		}
+	}
+	return "", false
+}
+
+func sameFields(a, b metav1.ManagedFieldsEntry) bool {
+	if a.FieldsV1 == nil || b.FieldsV1 == nil {
+		return a.FieldsV1 == b.FieldsV1
+	}
+	return bytes.Equal(a.FieldsV1.Raw, b.FieldsV1.Raw)
+}
@@ -0,0 +1,125 @@
+package kubernetes
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+
+	corev1 "k8s.io/api/core/v1"
+	discoveryv1 "k8s.io/api/discovery/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+
+	"github.com/alexieff-io/consul-sync/internal/consul"
+)
+
+// EndpointsMode selects which endpoint objects back each managed Service.
+type EndpointsMode string
+
+const (
+	// EndpointsModeSlices writes only discovery.k8s.io/v1 EndpointSlices.
+	EndpointsModeSlices EndpointsMode = "slices"
+	// EndpointsModeEndpoints writes only legacy core/v1 Endpoints, which the
+	// cluster's EndpointSlice mirroring controller turns into slices.
+	EndpointsModeEndpoints EndpointsMode = "endpoints"
+	// EndpointsModeBoth writes both, with mirroring disabled on the Endpoints
+	// so the Service isn't backed by duplicate slices.
+	EndpointsModeBoth EndpointsMode = "both"
+)
+
+// ParseEndpointsMode validates an EndpointsMode. Empty means slices.
+func ParseEndpointsMode(s string) (EndpointsMode, error) {
+	switch m := EndpointsMode(s); m {
+	case "":
+		return EndpointsModeSlices, nil
+	case EndpointsModeSlices, EndpointsModeEndpoints, EndpointsModeBoth:
+		return m, nil
+	default:
+		return "", fmt.Errorf("expected slices, endpoints or both, got %q", s)
+	}
+}
+
+func (m EndpointsMode) slices() bool {
+	return m != EndpointsModeEndpoints
+}
+
+func (m EndpointsMode) endpoints() bool {
+	return m == EndpointsModeEndpoints || m == EndpointsModeBoth
+}
+
+// applyEndpoints writes a core/v1 Endpoints object named after the Service,
+// for clusters and tooling that predate EndpointSlices. Endpoints only hold
+// IPs, so instances registered by hostname are left out.
+func (s *Syncer) applyEndpoints(ctx context.Context, name string, ports []namedPort, instances []consul.ServiceInstance, draining []string) error {
+	c := s.clientsFor(s.namespace)
+
+	current := ipAddresses(instanceAddresses(instances))
+	addresses := make([]corev1.EndpointAddress, 0, len(current))
+	for _, addr := range current {
+		addresses = append(addresses, corev1.EndpointAddress{IP: addr})
+	}
+	var notReady []corev1.EndpointAddress
+	for _, addr := range ipAddresses(draining) {
+		notReady = append(notReady, corev1.EndpointAddress{IP: addr})
+	}
+
+	labels := map[string]string{
+		managedByKey:             managedBy,
+		"app.kubernetes.io/name": name,
+	}
+	if s.opts.EndpointsMode.slices() {
+		labels[discoveryv1.LabelSkipMirror] = "true"
+	}
+
+	ep := &corev1.Endpoints{
+		TypeMeta: metav1.TypeMeta{
+			APIVersion: "v1",
+			Kind:       "Endpoints",
+		},
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      name,
+			Namespace: s.namespace,
+			Labels:    labels,
+		},
+		Subsets: []corev1.EndpointSubset{
+			{
+				Addresses:         addresses,
+				NotReadyAddresses: notReady,
+				Ports:             endpointsPorts(ports),
+			},
+		},
+	}
+
+	data, err := json.Marshal(ep)
+	if err != nil {
+		return fmt.Errorf("marshaling endpoints: %w", err)
+	}
+
+	s.pace(ctx)
+	applied, err := c.Core.CoreV1().Endpoints(s.namespace).Patch(
+		ctx, name, types.ApplyPatchType, data,
+		s.applyOptions(kindEndpoints),
+	)
+	if s.skipConflict(ctx, kindEndpoints, name, name, err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	return s.releaseManagers(ctx, applied, ep.TypeMeta, func(pt types.PatchType, data []byte, opts metav1.PatchOptions) (metav1.Object, error) {
+		return c.Core.CoreV1().Endpoints(s.namespace).Patch(ctx, name, pt, data, opts)
+	})
+}
+
+// deleteEndpoints removes the Endpoints object of a deleted Service. A missing
+// object is not an error.
+func (s *Syncer) deleteEndpoints(ctx context.Context, name string) error {
+	c := s.clientsFor(s.namespace)
+	s.pace(ctx)
+	err := c.Core.CoreV1().Endpoints(s.namespace).Delete(ctx, name, metav1.DeleteOptions{})
+	if apierrors.IsNotFound(err) {
+		return nil
+	}
+	return err
+}
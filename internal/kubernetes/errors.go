@@ -0,0 +1,42 @@
+package kubernetes
+
+import (
+	"context"
+	"errors"
+	"net"
+
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+
+	"github.com/alexieff-io/consul-sync/internal/metrics"
+)
+
+// ErrorClass classifies an error returned by the Kubernetes API for the error
+// metrics, so RBAC problems can be told apart from API server pressure: one
+// of conflict, forbidden, not-found, timeout, throttled, validation or other.
+func ErrorClass(err error) string {
+	var netErr net.Error
+	switch {
+	case apierrors.IsConflict(err), apierrors.IsAlreadyExists(err):
+		return "conflict"
+	case apierrors.IsForbidden(err), apierrors.IsUnauthorized(err):
+		return "forbidden"
+	case apierrors.IsNotFound(err):
+		return "not-found"
+	case apierrors.IsTooManyRequests(err):
+		return "throttled"
+	case apierrors.IsTimeout(err), apierrors.IsServerTimeout(err),
+		errors.Is(err, context.DeadlineExceeded),
+		errors.As(err, &netErr) && netErr.Timeout():
+		return "timeout"
+	case apierrors.IsInvalid(err), apierrors.IsBadRequest(err):
+		return "validation"
+	default:
+		return "other"
+	}
+}
+
+// countError counts err, returned for an object of kind, in the Kubernetes
+// error metrics.
+func countError(kind string, err error) {
+	metrics.KubernetesErrors.WithLabelValues(ErrorClass(err), kind).Inc()
+}
@@ -0,0 +1,44 @@
+package kubernetes
+
+import (
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/kubernetes/scheme"
+	typedcorev1 "k8s.io/client-go/kubernetes/typed/core/v1"
+	"k8s.io/client-go/tools/record"
+)
+
+// NewEventRecorder returns a recorder that publishes Events as consul-sync,
+// along with a function that flushes and stops the underlying broadcaster.
+func NewEventRecorder(client kubernetes.Interface) (record.EventRecorder, func()) {
+	broadcaster := record.NewBroadcaster()
+	broadcaster.StartRecordingToSink(&typedcorev1.EventSinkImpl{Interface: client.CoreV1().Events("")})
+	recorder := broadcaster.NewRecorder(scheme.Scheme, corev1.EventSource{Component: managedBy})
+	return recorder, broadcaster.Shutdown
+}
+
+// serviceRef returns a reference to a managed Service for attaching Events.
+// The UID from the last apply is included when known, since kubectl describe
+// only matches Events whose involvedObject carries the object's UID.
+func (s *Syncer) serviceRef(namespace, name string) *corev1.ObjectReference {
+	ref := &corev1.ObjectReference{
+		APIVersion: "v1",
+		Kind:       "Service",
+		Namespace:  namespace,
+		Name:       name,
+	}
+	if uid, ok := s.serviceUIDs[namespace+"/"+name]; ok {
+		ref.UID = uid
+	}
+	return ref
+}
+
+// eventf records an Event on the named managed Service. It is a no-op when
+// the Syncer was created without a recorder, or while the Events of the
+// first Sync are held back.
+func (s *Syncer) eventf(namespace, name, eventType, reason, messageFmt string, args ...interface{}) {
+	if s.opts.Recorder == nil || s.events.hold(eventType, reason) {
+		return
+	}
+	s.opts.Recorder.Eventf(s.serviceRef(namespace, name), eventType, reason, messageFmt, args...)
+}
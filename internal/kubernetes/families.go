@@ -0,0 +1,186 @@
+package kubernetes
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"net/netip"
+	"strings"
+
+	corev1 "k8s.io/api/core/v1"
+	discoveryv1 "k8s.io/api/discovery/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/validation"
+
+	"github.com/alexieff-io/consul-sync/internal/consul"
+)
+
+// addressFamilies are the address types of the EndpointSlices written for
+// each Service. An EndpointSlice holds addresses of a single family, so a
+// service with IPv4 and IPv6 instances, or instances registered by
+// hostname, gets one slice of each.
+var addressFamilies = []discoveryv1.AddressType{discoveryv1.AddressTypeIPv4, discoveryv1.AddressTypeIPv6, discoveryv1.AddressTypeFQDN}
+
+// HostnamePolicy selects what becomes of instances registered with a
+// hostname as their address rather than an IP.
+type HostnamePolicy string
+
+const (
+	// HostnamePolicyFQDN writes them to an EndpointSlice of address type
+	// FQDN of their own.
+	HostnamePolicyFQDN HostnamePolicy = "fqdn"
+	// HostnamePolicySkip leaves them out of the endpoints.
+	HostnamePolicySkip HostnamePolicy = "skip"
+	// HostnamePolicyResolve has their hostnames looked up before syncing,
+	// see reconciler.WithResolvedHostnames, and leaves those that don't
+	// resolve out of the endpoints.
+	HostnamePolicyResolve HostnamePolicy = "resolve"
+)
+
+// ParseHostnamePolicy validates a HostnamePolicy. Empty means fqdn.
+func ParseHostnamePolicy(s string) (HostnamePolicy, error) {
+	switch p := HostnamePolicy(s); p {
+	case "":
+		return HostnamePolicyFQDN, nil
+	case HostnamePolicyFQDN, HostnamePolicySkip, HostnamePolicyResolve:
+		return p, nil
+	default:
+		return "", fmt.Errorf("expected fqdn, skip or resolve, got %q", s)
+	}
+}
+
+// addressFamily returns the EndpointSlice address type of addr: FQDN for
+// addresses that don't parse as IPs.
+func addressFamily(addr string) discoveryv1.AddressType {
+	ip, err := netip.ParseAddr(addr)
+	switch {
+	case err != nil:
+		return discoveryv1.AddressTypeFQDN
+	case ip.Is6() && !ip.Is4In6():
+		return discoveryv1.AddressTypeIPv6
+	default:
+		return discoveryv1.AddressTypeIPv4
+	}
+}
+
+// ipAddresses returns the addresses that are IPs, which core/v1 Endpoints
+// and externalIPs are limited to.
+func ipAddresses(addresses []string) []string {
+	var ips []string
+	for _, addr := range addresses {
+		if addressFamily(addr) != discoveryv1.AddressTypeFQDN {
+			ips = append(ips, addr)
+		}
+	}
+	return ips
+}
+
+// endpointInstances returns the instances of svc that its endpoints can
+// hold: those registered by IP, and those registered by hostname with
+// HostnamePolicyFQDN. Addresses that are neither an IP nor a valid
+// hostname are always left out; with warn set, they are logged and recorded
+// as an Event.
+func (s *Syncer) endpointInstances(ctx context.Context, svc consul.ServiceState, name string, warn bool) []consul.ServiceInstance {
+	instances := make([]consul.ServiceInstance, 0, len(svc.Instances))
+	for _, inst := range svc.Instances {
+		if addressFamily(inst.Address) != discoveryv1.AddressTypeFQDN {
+			instances = append(instances, inst)
+			continue
+		}
+		if errs := validation.IsDNS1123Subdomain(inst.Address); len(errs) > 0 {
+			if warn {
+				slog.WarnContext(ctx, "leaving out instance with invalid address", "service", name, "instance", inst.ID, "address", inst.Address)
+				s.eventf(s.namespace, name, corev1.EventTypeWarning, "InvalidAddress",
+					"Instance %s left out of the endpoints: address %q is neither an IP nor a hostname: %s", inst.ID, inst.Address, strings.Join(errs, "; "))
+			}
+			continue
+		}
+		if s.opts.Hostnames == HostnamePolicySkip || s.opts.Hostnames == HostnamePolicyResolve {
+			slog.DebugContext(ctx, "leaving out instance registered by hostname", "service", name, "instance", inst.ID, "address", inst.Address)
+			continue
+		}
+		instances = append(instances, inst)
+	}
+	return instances
+}
+
+// byFamily splits addresses by their address type.
+func byFamily(addresses []string) map[discoveryv1.AddressType][]string {
+	families := make(map[discoveryv1.AddressType][]string, len(addressFamilies))
+	for _, addr := range addresses {
+		family := addressFamily(addr)
+		families[family] = append(families[family], addr)
+	}
+	return families
+}
+
+// hasFamilySlice reports whether the Service name has an EndpointSlice of
+// family written by this instance. The managed slices are listed once, so
+// slices written before a restart are known too.
+func (s *Syncer) hasFamilySlice(ctx context.Context, name string, family discoveryv1.AddressType) (bool, error) {
+	if s.familySlices == nil {
+		selector := managedByKey + "=" + managedBy
+		if s.opts.NodeName != "" {
+			selector += "," + nodeLabelKey + "=" + s.opts.NodeName
+		}
+		list, err := s.listSlices(ctx, selector)
+		if err != nil {
+			return false, fmt.Errorf("listing managed endpointslices: %w", err)
+		}
+		s.familySlices = make(map[discoveryv1.AddressType]map[string]bool)
+		for _, eps := range list {
+			s.markFamilySlice(eps.Labels["kubernetes.io/service-name"], eps.AddressType)
+		}
+	}
+	return s.familySlices[family][name], nil
+}
+
+// markFamilySlice records that the Service name has an EndpointSlice of
+// family, once the managed slices have been listed.
+func (s *Syncer) markFamilySlice(name string, family discoveryv1.AddressType) {
+	if s.familySlices == nil {
+		return
+	}
+	if s.familySlices[family] == nil {
+		s.familySlices[family] = make(map[string]bool)
+	}
+	s.familySlices[family][name] = true
+}
+
+// forgetFamilySlices records that the Service name has no EndpointSlices
+// left.
+func (s *Syncer) forgetFamilySlices(name string) {
+	for _, names := range s.familySlices {
+		delete(names, name)
+	}
+}
+
+// deleteSlices deletes this instance's EndpointSlices for the Service name:
+// the IPv4 one, which is always written, and those of the other families
+// recorded for it. Until the managed slices have been listed, as before the
+// first apply after a restart, every family is deleted. Missing slices are
+// not an error.
+func (s *Syncer) deleteSlices(ctx context.Context, name string) error {
+	families := addressFamilies
+	if s.familySlices != nil {
+		families = []discoveryv1.AddressType{discoveryv1.AddressTypeIPv4}
+		for _, family := range addressFamilies {
+			if family != discoveryv1.AddressTypeIPv4 && s.familySlices[family][name] {
+				families = append(families, family)
+			}
+		}
+	}
+
+	c := s.clientsFor(s.namespace)
+	for _, family := range families {
+		sliceName := s.sliceName(name, family)
+		s.pace(ctx)
+		err := c.Core.DiscoveryV1().EndpointSlices(s.namespace).Delete(ctx, sliceName, metav1.DeleteOptions{})
+		if err != nil && !apierrors.IsNotFound(err) {
+			return fmt.Errorf("deleting endpointslice %s: %w", sliceName, err)
+		}
+	}
+	s.forgetFamilySlices(name)
+	return nil
+}
@@ -0,0 +1,166 @@
+package kubernetes
+
+import (
+	"context"
+	"reflect"
+	"slices"
+	"testing"
+
+	discoveryv1 "k8s.io/api/discovery/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	"github.com/alexieff-io/consul-sync/consulsynctest"
+	"github.com/alexieff-io/consul-sync/internal/consul"
+)
+
+func TestAddressFamily(t *testing.T) {
+	for _, tt := range []struct {
+		addr string
+		want discoveryv1.AddressType
+	}{
+		{"10.0.0.1", discoveryv1.AddressTypeIPv4},
+		{"2001:db8::1", discoveryv1.AddressTypeIPv6},
+		{"::1", discoveryv1.AddressTypeIPv6},
+		// IPv4-mapped addresses are IPv4, as the API server validates them.
+		{"::ffff:10.0.0.1", discoveryv1.AddressTypeIPv4},
+		{"web.example.com", discoveryv1.AddressTypeFQDN},
+		{"10.0.0.256", discoveryv1.AddressTypeFQDN},
+		{"", discoveryv1.AddressTypeFQDN},
+	} {
+		if got := addressFamily(tt.addr); got != tt.want {
+			t.Errorf("addressFamily(%q) = %s, want %s", tt.addr, got, tt.want)
+		}
+	}
+}
+
+func TestByFamily(t *testing.T) {
+	for _, tt := range []struct {
+		name      string
+		addresses []string
+		want      map[discoveryv1.AddressType][]string
+	}{
+		{"none", nil, map[discoveryv1.AddressType][]string{}},
+		{"ipv4 only", []string{"10.0.0.1", "10.0.0.2"}, map[discoveryv1.AddressType][]string{
+			discoveryv1.AddressTypeIPv4: {"10.0.0.1", "10.0.0.2"},
+		}},
+		{"ipv6 only", []string{"2001:db8::1"}, map[discoveryv1.AddressType][]string{
+			discoveryv1.AddressTypeIPv6: {"2001:db8::1"},
+		}},
+		{"mixed", []string{"2001:db8::2", "10.0.0.1", "web.example.com", "2001:db8::1", "::ffff:10.0.0.2"}, map[discoveryv1.AddressType][]string{
+			discoveryv1.AddressTypeIPv4: {"10.0.0.1", "::ffff:10.0.0.2"},
+			discoveryv1.AddressTypeIPv6: {"2001:db8::2", "2001:db8::1"},
+			discoveryv1.AddressTypeFQDN: {"web.example.com"},
+		}},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := byFamily(tt.addresses); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("byFamily(%v) = %v, want %v", tt.addresses, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestIPAddresses(t *testing.T) {
+	got := ipAddresses([]string{"10.0.0.1", "web.example.com", "2001:db8::1"})
+	if want := []string{"10.0.0.1", "2001:db8::1"}; !slices.Equal(got, want) {
+		t.Errorf("ipAddresses = %v, want %v", got, want)
+	}
+}
+
+func TestEndpointInstances(t *testing.T) {
+	svc := consul.ServiceState{Name: "web", Instances: []consul.ServiceInstance{
+		{ID: "v4", Address: "10.0.0.1"},
+		{ID: "v6", Address: "2001:db8::1"},
+		{ID: "host", Address: "web-1.example.com"},
+		{ID: "invalid", Address: "not a host"},
+	}}
+	for _, tt := range []struct {
+		policy HostnamePolicy
+		want   []string
+	}{
+		{HostnamePolicyFQDN, []string{"v4", "v6", "host"}},
+		{HostnamePolicySkip, []string{"v4", "v6"}},
+		{HostnamePolicyResolve, []string{"v4", "v6"}},
+	} {
+		s := &Syncer{opts: Options{Hostnames: tt.policy}}
+		var got []string
+		for _, inst := range s.endpointInstances(context.Background(), svc, "web", false) {
+			got = append(got, inst.ID)
+		}
+		if !slices.Equal(got, tt.want) {
+			t.Errorf("%s: endpointInstances = %v, want %v", tt.policy, got, tt.want)
+		}
+	}
+}
+
+func TestParseHostnamePolicy(t *testing.T) {
+	for _, tt := range []struct {
+		in      string
+		want    HostnamePolicy
+		wantErr bool
+	}{
+		{"", HostnamePolicyFQDN, false},
+		{"fqdn", HostnamePolicyFQDN, false},
+		{"skip", HostnamePolicySkip, false},
+		{"resolve", HostnamePolicyResolve, false},
+		{"drop", "", true},
+	} {
+		got, err := ParseHostnamePolicy(tt.in)
+		if got != tt.want || (err != nil) != tt.wantErr {
+			t.Errorf("ParseHostnamePolicy(%q) = %q, %v, want %q, error %t", tt.in, got, err, tt.want, tt.wantErr)
+		}
+	}
+}
+
+// A service with instances of every family gets a slice of each, and loses
+// those of the families it no longer has.
+func TestSyncPartitionsFamilies(t *testing.T) {
+	client, dynClient := consulsynctest.NewFakeClients()
+	s := NewSyncer(client, dynClient, "network", HTTPRouteConfig{}, Options{})
+	svc := func(addrs ...string) []consul.ServiceState {
+		st := consul.ServiceState{Name: "web", Tags: []string{"kubernetes"}}
+		for _, addr := range addrs {
+			st.Instances = append(st.Instances, consul.ServiceInstance{ServiceName: "web", ID: addr, Address: addr, Port: 80})
+		}
+		return []consul.ServiceState{st}
+	}
+	slicesByFamily := func() map[discoveryv1.AddressType][]string {
+		t.Helper()
+		list, err := client.DiscoveryV1().EndpointSlices("network").List(context.Background(), metav1.ListOptions{})
+		if err != nil {
+			t.Fatalf("listing endpointslices: %v", err)
+		}
+		got := make(map[discoveryv1.AddressType][]string)
+		for _, eps := range list.Items {
+			if eps.Name != s.sliceName("web", eps.AddressType) {
+				t.Errorf("%s slice is named %s", eps.AddressType, eps.Name)
+			}
+			var addrs []string
+			for _, ep := range eps.Endpoints {
+				addrs = append(addrs, ep.Addresses...)
+			}
+			got[eps.AddressType] = addrs
+		}
+		return got
+	}
+
+	if _, err := s.Sync(context.Background(), svc("2001:db8::1", "10.0.0.2", "web-1.example.com", "10.0.0.1")); err != nil {
+		t.Fatalf("Sync: %v", err)
+	}
+	want := map[discoveryv1.AddressType][]string{
+		discoveryv1.AddressTypeIPv4: {"10.0.0.1", "10.0.0.2"},
+		discoveryv1.AddressTypeIPv6: {"2001:db8::1"},
+		discoveryv1.AddressTypeFQDN: {"web-1.example.com"},
+	}
+	if got := slicesByFamily(); !reflect.DeepEqual(got, want) {
+		t.Errorf("slices = %v, want %v", got, want)
+	}
+
+	if _, err := s.Sync(context.Background(), svc("10.0.0.1")); err != nil {
+		t.Fatalf("Sync: %v", err)
+	}
+	want = map[discoveryv1.AddressType][]string{discoveryv1.AddressTypeIPv4: {"10.0.0.1"}}
+	if got := slicesByFamily(); !reflect.DeepEqual(got, want) {
+		t.Errorf("slices after dropping ipv6 and fqdn = %v, want %v", got, want)
+	}
+}
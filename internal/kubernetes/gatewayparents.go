@@ -0,0 +1,85 @@
+package kubernetes
+
+import (
+	"context"
+	"log/slog"
+	"slices"
+
+	corev1 "k8s.io/api/core/v1"
+
+	"github.com/alexieff-io/consul-sync/internal/metrics"
+)
+
+// gatewayParent is a Gateway, as namespace/name, or a listener of one, that
+// generated HTTPRoutes attach to.
+type gatewayParent struct {
+	gateway  string
+	listener string
+}
+
+// reportMissingParents reports the Gateways and listeners the plans of a
+// full sync attach to that don't exist, so their routes would dangle. A
+// parent found missing is logged and recorded as an Event on the Services
+// routed through it once, and counted in metrics.MissingRouteParents until
+// it appears. Listeners are only checked on Gateways that could be read.
+func (s *Syncer) reportMissingParents(ctx context.Context, cfg HTTPRouteConfig, plans []routePlan, gateways gatewaySet) {
+	missing := make(map[gatewayParent][]routePlan)
+	for _, plan := range plans {
+		gateway := cfg.GatewayNamespace + "/" + plan.gateway
+		if gateways.missing[plan.gateway] {
+			parent := gatewayParent{gateway: gateway}
+			missing[parent] = append(missing[parent], plan)
+			continue
+		}
+		listeners, ok := gateways.listeners[plan.gateway]
+		if !ok {
+			continue
+		}
+		for _, name := range plan.listeners {
+			if !slices.ContainsFunc(listeners, func(l gatewayListener) bool { return l.name == name }) {
+				parent := gatewayParent{gateway: gateway, listener: name}
+				missing[parent] = append(missing[parent], plan)
+			}
+		}
+	}
+
+	for parent, plans := range missing {
+		if !s.opts.Secondary {
+			metrics.MissingRouteParents.WithLabelValues(parent.gateway, parent.listener).Set(float64(len(plans)))
+		}
+		if s.missingParents[parent] {
+			continue
+		}
+		what := "gateway " + parent.gateway
+		if parent.listener != "" {
+			what = "listener " + parent.listener + " of gateway " + parent.gateway
+		}
+		slog.WarnContext(ctx, "httproutes attach to a missing gateway parent",
+			"gateway", parent.gateway, "listener", parent.listener, "routes", len(plans))
+		notified := make(map[string]bool)
+		for _, plan := range plans {
+			for _, name := range plan.backends() {
+				if notified[name] {
+					continue
+				}
+				notified[name] = true
+				s.eventf(s.namespace, name, corev1.EventTypeWarning, "MissingGatewayParent",
+					"HTTPRoute %s attaches to %s, which doesn't exist", plan.name, what)
+			}
+		}
+	}
+	for parent := range s.missingParents {
+		if _, ok := missing[parent]; ok {
+			continue
+		}
+		slog.InfoContext(ctx, "gateway parent of httproutes no longer missing", "gateway", parent.gateway, "listener", parent.listener)
+		if !s.opts.Secondary {
+			metrics.MissingRouteParents.DeleteLabelValues(parent.gateway, parent.listener)
+		}
+	}
+
+	s.missingParents = make(map[gatewayParent]bool, len(missing))
+	for parent := range missing {
+		s.missingParents[parent] = true
+	}
+}
@@ -0,0 +1,76 @@
+package kubernetes
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	coordinationv1 "k8s.io/api/coordination/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/kubernetes"
+)
+
+// Heartbeat publishes a coordination.k8s.io Lease whose renewTime advances
+// after every successful reconcile. It is not used for leader election:
+// external monitors treat a Lease not renewed within leaseDurationSeconds as
+// a stalled controller.
+type Heartbeat struct {
+	client    kubernetes.Interface
+	namespace string
+	name      string
+	identity  string
+	duration  time.Duration
+}
+
+// NewHeartbeat creates a Heartbeat for the Lease namespace/name. identity is
+// recorded as the holder, and duration as the period after which a missed
+// renewal means the controller has stalled.
+func NewHeartbeat(client kubernetes.Interface, namespace, name, identity string, duration time.Duration) *Heartbeat {
+	return &Heartbeat{
+		client:    client,
+		namespace: namespace,
+		name:      name,
+		identity:  identity,
+		duration:  duration,
+	}
+}
+
+// Renew creates or updates the Lease with the current time.
+func (h *Heartbeat) Renew(ctx context.Context) error {
+	now := metav1.NewMicroTime(time.Now())
+	seconds := int32(h.duration.Seconds())
+	lease := &coordinationv1.Lease{
+		TypeMeta: metav1.TypeMeta{
+			APIVersion: "coordination.k8s.io/v1",
+			Kind:       "Lease",
+		},
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      h.name,
+			Namespace: h.namespace,
+			Labels: map[string]string{
+				managedByKey: managedBy,
+			},
+		},
+		Spec: coordinationv1.LeaseSpec{
+			HolderIdentity:       &h.identity,
+			LeaseDurationSeconds: &seconds,
+			RenewTime:            &now,
+		},
+	}
+
+	data, err := json.Marshal(lease)
+	if err != nil {
+		return fmt.Errorf("marshaling lease: %w", err)
+	}
+
+	_, err = h.client.CoordinationV1().Leases(h.namespace).Patch(
+		ctx, h.name, types.ApplyPatchType, data,
+		metav1.PatchOptions{FieldManager: fieldManager},
+	)
+	if err != nil {
+		return fmt.Errorf("renewing heartbeat lease %s/%s: %w", h.namespace, h.name, err)
+	}
+	return nil
+}
@@ -0,0 +1,88 @@
+package kubernetes
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"strings"
+
+	"k8s.io/apimachinery/pkg/util/validation"
+)
+
+// Reasons reported on the invalid hostname metric.
+const (
+	hostnameReasonLength   = "length"
+	hostnameReasonIP       = "ip_address"
+	hostnameReasonWildcard = "wildcard"
+	hostnameReasonSyntax   = "syntax"
+)
+
+// HostnameLayout selects how generated hostnames are built from a service
+// name, the tag selecting the gateway and the domain suffix.
+type HostnameLayout string
+
+const (
+	// HostnameFlat generates <name>.<suffix> on every gateway.
+	HostnameFlat HostnameLayout = "flat"
+	// HostnamePrefix generates <tag>-<name>.<suffix>, e.g.
+	// internal-grafana.example.com.
+	HostnamePrefix HostnameLayout = "prefix"
+	// HostnameSubdomain generates <name>.<tag>.<suffix>, e.g.
+	// grafana.internal.example.com.
+	HostnameSubdomain HostnameLayout = "subdomain"
+)
+
+// ParseHostnameLayout validates a HostnameLayout. Empty means flat.
+func ParseHostnameLayout(s string) (HostnameLayout, error) {
+	switch l := HostnameLayout(s); l {
+	case "":
+		return HostnameFlat, nil
+	case HostnameFlat, HostnamePrefix, HostnameSubdomain:
+		return l, nil
+	default:
+		return "", fmt.Errorf("unknown hostname layout %q: expected flat, prefix or subdomain", s)
+	}
+}
+
+// hostname returns the generated hostname of service name on the gateway
+// selected by tag.
+func (l HostnameLayout) hostname(name, tag, suffix string) string {
+	switch l {
+	case HostnamePrefix:
+		return strings.ToLower(tag) + "-" + name + "." + suffix
+	case HostnameSubdomain:
+		return name + "." + strings.ToLower(tag) + "." + suffix
+	default:
+		return name + "." + suffix
+	}
+}
+
+// validateHostname checks a generated hostname against the Gateway API rules:
+// an RFC 1123 subdomain of at most 253 characters, not an IP address, with a
+// wildcard allowed only as the entire leftmost label. On failure it returns a
+// short reason suitable for a metric label.
+func validateHostname(hostname string) (string, error) {
+	if len(hostname) > validation.DNS1123SubdomainMaxLength {
+		return hostnameReasonLength, fmt.Errorf("longer than %d characters", validation.DNS1123SubdomainMaxLength)
+	}
+	if net.ParseIP(hostname) != nil {
+		return hostnameReasonIP, errors.New("IP addresses are not allowed")
+	}
+
+	if strings.Contains(hostname, "*") {
+		if errs := validation.IsWildcardDNS1123Subdomain(hostname); len(errs) > 0 {
+			return hostnameReasonWildcard, errors.New(strings.Join(errs, "; "))
+		}
+		return "", nil
+	}
+
+	for _, label := range strings.Split(hostname, ".") {
+		if len(label) > validation.DNS1123LabelMaxLength {
+			return hostnameReasonLength, fmt.Errorf("label %q longer than %d characters", label, validation.DNS1123LabelMaxLength)
+		}
+	}
+	if errs := validation.IsDNS1123Subdomain(hostname); len(errs) > 0 {
+		return hostnameReasonSyntax, errors.New(strings.Join(errs, "; "))
+	}
+	return "", nil
+}
@@ -0,0 +1,141 @@
+package kubernetes
+
+import (
+	"context"
+	"log/slog"
+	"slices"
+	"strings"
+
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+
+	"github.com/alexieff-io/consul-sync/internal/metrics"
+)
+
+var gatewayGVR = schema.GroupVersionResource{
+	Group:    "gateway.networking.k8s.io",
+	Version:  "v1",
+	Resource: "gateways",
+}
+
+// hostnameReasonListener is the invalid hostname metric reason of a hostname
+// that no listener of its Gateway accepts.
+const hostnameReasonListener = "listener_mismatch"
+
+// gatewayListener is a listener of a Gateway. An empty hostname accepts every
+// hostname.
+type gatewayListener struct {
+	name     string
+	hostname string
+}
+
+// gatewaySet holds the Gateways the plans of a sync attach to, by name.
+type gatewaySet struct {
+	// listeners holds the listeners of each Gateway read.
+	listeners map[string][]gatewayListener
+	// missing holds the Gateways that don't exist.
+	missing map[string]bool
+}
+
+// readGateways reads each Gateway the plans attach to. Gateways that can't be
+// read are left out, so their routes are applied unchecked: reading Gateways
+// may not be allowed at all. Those that don't exist are recorded as missing.
+func (s *Syncer) readGateways(ctx context.Context, cfg HTTPRouteConfig, plans []routePlan) gatewaySet {
+	gateways := gatewaySet{
+		listeners: make(map[string][]gatewayListener),
+		missing:   make(map[string]bool),
+	}
+	tried := make(map[string]bool)
+	for _, plan := range plans {
+		if tried[plan.gateway] {
+			continue
+		}
+		tried[plan.gateway] = true
+
+		gw, err := s.clientsFor(cfg.GatewayNamespace).Dynamic.Resource(gatewayGVR).Namespace(cfg.GatewayNamespace).Get(ctx, plan.gateway, metav1.GetOptions{})
+		if apierrors.IsNotFound(err) {
+			gateways.missing[plan.gateway] = true
+			continue
+		}
+		if err != nil {
+			slog.DebugContext(ctx, "not checking hostnames against gateway listeners", "gateway", plan.gateway, "error", err)
+			continue
+		}
+		specs, _, _ := unstructured.NestedSlice(gw.Object, "spec", "listeners")
+		for _, spec := range specs {
+			l, ok := spec.(map[string]interface{})
+			if !ok {
+				continue
+			}
+			name, _, _ := unstructured.NestedString(l, "name")
+			hostname, _, _ := unstructured.NestedString(l, "hostname")
+			gateways.listeners[plan.gateway] = append(gateways.listeners[plan.gateway], gatewayListener{name: name, hostname: hostname})
+		}
+	}
+	return gateways
+}
+
+// acceptedPlans returns the plans whose hostname is accepted by a listener
+// they attach to, leaving out routes their Gateway would never accept. With
+// warn set, the plans left out are logged, counted and recorded as Events on
+// their Services.
+func (s *Syncer) acceptedPlans(ctx context.Context, plans []routePlan, gateways gatewaySet, warn bool) []routePlan {
+	if len(plans) == 0 {
+		return plans
+	}
+
+	accepted := make([]routePlan, 0, len(plans))
+	for _, plan := range plans {
+		var attached []gatewayListener
+		for _, l := range gateways.listeners[plan.gateway] {
+			if plan.listeners == nil || slices.Contains(plan.listeners, l.name) {
+				attached = append(attached, l)
+			}
+		}
+		// Without any listener to compare to there is nothing to check; a
+		// missing listener is reported by the route status monitor.
+		if len(attached) == 0 || slices.ContainsFunc(attached, func(l gatewayListener) bool {
+			return hostnamesIntersect(l.hostname, plan.hostname)
+		}) {
+			accepted = append(accepted, plan)
+			continue
+		}
+
+		if warn {
+			allowed := make([]string, 0, len(attached))
+			for _, l := range attached {
+				allowed = append(allowed, l.name+"="+l.hostname)
+			}
+			metrics.InvalidHostnames.WithLabelValues(hostnameReasonListener).Inc()
+			slog.WarnContext(ctx, "skipping httproute with hostname no gateway listener accepts",
+				"route", plan.name, "gateway", plan.gateway, "hostname", plan.hostname, "listeners", allowed)
+			for _, name := range plan.backends() {
+				s.eventf(s.namespace, name, corev1.EventTypeWarning, "HostnameNotAllowed",
+					"Skipping HTTPRoute %s: hostname %q matches no listener of gateway %s (%s)",
+					plan.name, plan.hostname, plan.gateway, strings.Join(allowed, ", "))
+			}
+		}
+	}
+	return accepted
+}
+
+// hostnamesIntersect reports whether a listener hostname and a route
+// hostname have requests in common, following the Gateway API: an empty
+// listener hostname matches everything, and a leading "*." label matches any
+// non-empty prefix of labels.
+func hostnamesIntersect(listener, route string) bool {
+	listener, route = strings.ToLower(listener), strings.ToLower(route)
+	if listener == "" || listener == route {
+		return true
+	}
+	if suffix, ok := strings.CutPrefix(listener, "*"); ok && strings.HasSuffix(route, suffix) && len(route) > len(suffix) {
+		return true
+	}
+	if suffix, ok := strings.CutPrefix(route, "*"); ok && strings.HasSuffix(listener, suffix) && len(listener) > len(suffix) {
+		return true
+	}
+	return false
+}
@@ -0,0 +1,80 @@
+package kubernetes
+
+import (
+	"context"
+	"fmt"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// ManagedObjects returns every Service, EndpointSlice, Endpoints and HTTPRoute
+// the Syncer currently manages, as they exist in the cluster. Server-populated
+// fields that are meaningless outside the cluster, such as managedFields, are
+// stripped so the result can be archived and reapplied.
+func (s *Syncer) ManagedObjects(ctx context.Context) ([]any, error) {
+	var objects []any
+	for _, ns := range s.syncers() {
+		placed, err := ns.managedObjects(ctx)
+		if err != nil {
+			return nil, err
+		}
+		objects = append(objects, placed...)
+	}
+	return objects, nil
+}
+
+// managedObjects is ManagedObjects within the syncer's namespace.
+func (s *Syncer) managedObjects(ctx context.Context) ([]any, error) {
+	c := s.clientsFor(s.namespace)
+	opts := metav1.ListOptions{LabelSelector: managedByKey + "=" + managedBy}
+	var objects []any
+
+	svcs, err := c.Core.CoreV1().Services(s.namespace).List(ctx, opts)
+	if err != nil {
+		return nil, fmt.Errorf("listing managed services: %w", err)
+	}
+	for i := range svcs.Items {
+		svc := &svcs.Items[i]
+		svc.APIVersion, svc.Kind = "v1", "Service"
+		svc.ManagedFields = nil
+		objects = append(objects, svc)
+	}
+
+	slices, err := c.Core.DiscoveryV1().EndpointSlices(s.namespace).List(ctx, opts)
+	if err != nil {
+		return nil, fmt.Errorf("listing managed endpointslices: %w", err)
+	}
+	for i := range slices.Items {
+		eps := &slices.Items[i]
+		eps.APIVersion, eps.Kind = "discovery.k8s.io/v1", "EndpointSlice"
+		eps.ManagedFields = nil
+		objects = append(objects, eps)
+	}
+
+	if s.opts.EndpointsMode.endpoints() {
+		eps, err := c.Core.CoreV1().Endpoints(s.namespace).List(ctx, opts)
+		if err != nil {
+			return nil, fmt.Errorf("listing managed endpoints: %w", err)
+		}
+		for i := range eps.Items {
+			ep := &eps.Items[i]
+			ep.APIVersion, ep.Kind = "v1", "Endpoints"
+			ep.ManagedFields = nil
+			objects = append(objects, ep)
+		}
+	}
+
+	if s.routeCfg.Enabled {
+		routes, err := c.Dynamic.Resource(httpRouteGVR).Namespace(s.namespace).List(ctx, opts)
+		if err != nil {
+			return nil, fmt.Errorf("listing managed httproutes: %w", err)
+		}
+		for i := range routes.Items {
+			route := &routes.Items[i]
+			route.SetManagedFields(nil)
+			objects = append(objects, route.Object)
+		}
+	}
+
+	return objects, nil
+}
@@ -0,0 +1,90 @@
+package kubernetes
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"maps"
+	"slices"
+	"strings"
+
+	"k8s.io/apimachinery/pkg/util/validation"
+
+	"github.com/alexieff-io/consul-sync/internal/consul"
+)
+
+// reservedAnnotationDomain holds the annotations consul-sync sets itself,
+// which service meta can't override.
+const reservedAnnotationDomain = "consul-sync.alexieff.io/"
+
+// metaAnnotationKey returns the annotation named by the rest of a meta key
+// after Options.AnnotationPrefix. Consul meta keys may only hold letters,
+// digits, - and _, so __ stands for / and _ for a dot, e.g.
+// external-dns_alpha_kubernetes_io__hostname for
+// external-dns.alpha.kubernetes.io/hostname.
+func metaAnnotationKey(rest string) string {
+	return strings.ReplaceAll(strings.ReplaceAll(rest, "__", "/"), "_", ".")
+}
+
+// metaAnnotations returns the annotations the meta of svc asks for on its
+// objects: one per key starting with Options.AnnotationPrefix. With warn
+// set, keys that don't make a valid annotation name, or name one of
+// consul-sync's own, are logged; they are skipped either way.
+func (s *Syncer) metaAnnotations(ctx context.Context, svc consul.ServiceState, warn bool) map[string]string {
+	prefix := s.opts.AnnotationPrefix
+	if prefix == "" {
+		return nil
+	}
+	var annotations map[string]string
+	for _, key := range slices.Sorted(maps.Keys(svc.Meta)) {
+		rest, ok := strings.CutPrefix(key, prefix)
+		if !ok || rest == "" {
+			continue
+		}
+		name := metaAnnotationKey(rest)
+		var err error
+		if errs := validation.IsQualifiedName(name); len(errs) > 0 {
+			err = fmt.Errorf("annotation %q: %s", name, strings.Join(errs, "; "))
+		} else if strings.HasPrefix(name, reservedAnnotationDomain) {
+			err = fmt.Errorf("annotation %q is set by consul-sync", name)
+		}
+		if err != nil {
+			if warn {
+				slog.WarnContext(ctx, "ignoring invalid annotation meta", "service", svc.Name, "key", key, "error", err)
+			}
+			continue
+		}
+		if annotations == nil {
+			annotations = make(map[string]string)
+		}
+		annotations[name] = svc.Meta[key]
+	}
+	return annotations
+}
+
+// routeAnnotations merges the annotations of the services routed by rules,
+// the first by Service name winning on conflicts.
+func routeAnnotations(rules []routeRule) map[string]string {
+	var all []routeRule
+	for _, r := range rules {
+		all = append(all, r)
+		all = append(all, r.split...)
+	}
+	slices.SortFunc(all, func(a, b routeRule) int {
+		return strings.Compare(a.service, b.service)
+	})
+
+	var annotations map[string]string
+	for _, r := range all {
+		for k, v := range r.annotations {
+			if _, ok := annotations[k]; ok {
+				continue
+			}
+			if annotations == nil {
+				annotations = make(map[string]string)
+			}
+			annotations[k] = v
+		}
+	}
+	return annotations
+}
@@ -0,0 +1,132 @@
+package kubernetes
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"regexp"
+	"strings"
+	"unicode"
+)
+
+// maxNameLength is the limit on Service names, which must be RFC 1035 labels.
+const maxNameLength = 63
+
+// CasePolicy selects how uppercase letters in Consul names are handled.
+type CasePolicy string
+
+const (
+	// CaseLower lowercases the name: MyAPI becomes myapi.
+	CaseLower CasePolicy = "lower"
+	// CaseKebab separates words at case changes before lowercasing:
+	// MyAPI becomes my-api.
+	CaseKebab CasePolicy = "kebab"
+)
+
+// TruncateStrategy selects how names longer than 63 characters are shortened.
+type TruncateStrategy string
+
+const (
+	// TruncateCut keeps the first 63 characters, less any hyphens they end
+	// with.
+	TruncateCut TruncateStrategy = "cut"
+	// TruncateHash keeps a prefix and appends a hash of the full Consul name,
+	// so long names sharing a prefix stay distinct.
+	TruncateHash TruncateStrategy = "hash"
+)
+
+// NameSanitizer converts Consul service names into valid Kubernetes names.
+// The zero value reproduces the historical rules: lowercase, every invalid
+// character (dots included) replaced by a hyphen, and cut at 63 characters.
+type NameSanitizer struct {
+	// Replacement substitutes each invalid character. Defaults to "-".
+	Replacement string
+
+	// DotReplacement substitutes dots, which Service names can't contain.
+	// Set it to a distinct sequence such as "--" to keep dotted names from
+	// colliding with hyphenated ones. Defaults to Replacement.
+	DotReplacement string
+
+	// Case selects the uppercase policy. Defaults to CaseLower.
+	Case CasePolicy
+
+	// Truncate selects how over-long names are shortened. Defaults to
+	// TruncateCut.
+	Truncate TruncateStrategy
+}
+
+var validReplacement = regexp.MustCompile(`^[a-z0-9-]+$`)
+
+// Validate reports whether the sanitizer's settings can only produce valid
+// names.
+func (n NameSanitizer) Validate() error {
+	for _, r := range []string{n.Replacement, n.DotReplacement} {
+		if r != "" && !validReplacement.MatchString(r) {
+			return fmt.Errorf("replacement %q may only contain lowercase letters, digits and hyphens", r)
+		}
+	}
+	switch n.Case {
+	case "", CaseLower, CaseKebab:
+	default:
+		return fmt.Errorf("expected case lower or kebab, got %q", n.Case)
+	}
+	switch n.Truncate {
+	case "", TruncateCut, TruncateHash:
+	default:
+		return fmt.Errorf("expected truncate cut or hash, got %q", n.Truncate)
+	}
+	return nil
+}
+
+// Sanitize converts a Consul service name into a valid Kubernetes name.
+func (n NameSanitizer) Sanitize(name string) string {
+	replacement := n.Replacement
+	if replacement == "" {
+		replacement = "-"
+	}
+	dotReplacement := n.DotReplacement
+	if dotReplacement == "" {
+		dotReplacement = replacement
+	}
+
+	var b strings.Builder
+	runes := []rune(name)
+	for i, r := range runes {
+		switch {
+		case r == '.':
+			b.WriteString(dotReplacement)
+		case 'a' <= r && r <= 'z', '0' <= r && r <= '9', r == '-':
+			b.WriteRune(r)
+		case 'A' <= r && r <= 'Z':
+			if n.Case == CaseKebab && i > 0 && wordBoundary(runes, i) {
+				b.WriteString(replacement)
+			}
+			b.WriteRune(unicode.ToLower(r))
+		default:
+			b.WriteString(replacement)
+		}
+	}
+	out := strings.Trim(b.String(), "-")
+
+	if len(out) > maxNameLength {
+		if n.Truncate == TruncateHash {
+			sum := sha256.Sum256([]byte(name))
+			suffix := "-" + hex.EncodeToString(sum[:4])
+			out = strings.TrimRight(out[:maxNameLength-len(suffix)], "-") + suffix
+		} else {
+			out = strings.TrimRight(out[:maxNameLength], "-")
+		}
+	}
+	return out
+}
+
+// wordBoundary reports whether the uppercase rune at i starts a new word: it
+// follows a lowercase letter or digit, or ends a run of capitals before a
+// lowercase letter (the S in HTTPServer).
+func wordBoundary(runes []rune, i int) bool {
+	prev := runes[i-1]
+	if unicode.IsLower(prev) || unicode.IsDigit(prev) {
+		return true
+	}
+	return unicode.IsUpper(prev) && i+1 < len(runes) && unicode.IsLower(runes[i+1])
+}
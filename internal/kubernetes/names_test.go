@@ -0,0 +1,95 @@
+package kubernetes_test
+
+import (
+	"strings"
+	"testing"
+
+	"k8s.io/apimachinery/pkg/util/validation"
+
+	k8s "github.com/alexieff-io/consul-sync/internal/kubernetes"
+)
+
+func TestSanitize(t *testing.T) {
+	long := strings.Repeat("a", 62)
+	for _, tt := range []struct {
+		name      string
+		sanitizer k8s.NameSanitizer
+		in, want  string
+	}{
+		{"valid", k8s.NameSanitizer{}, "web", "web"},
+		{"lowercased", k8s.NameSanitizer{}, "MyAPI", "myapi"},
+		{"invalid characters", k8s.NameSanitizer{}, "my_service.v2", "my-service-v2"},
+		{"trimmed", k8s.NameSanitizer{}, "_web.", "web"},
+		{"replacement", k8s.NameSanitizer{Replacement: "x"}, "my_service.v2", "myxservicexv2"},
+		{"dot replacement", k8s.NameSanitizer{DotReplacement: "--"}, "api.v1_beta", "api--v1-beta"},
+		{"kebab", k8s.NameSanitizer{Case: k8s.CaseKebab}, "MyAPI", "my-api"},
+		{"kebab run of capitals", k8s.NameSanitizer{Case: k8s.CaseKebab}, "HTTPServer", "http-server"},
+		{"kebab after dot", k8s.NameSanitizer{Case: k8s.CaseKebab}, "my.API", "my-api"},
+		{"kebab after digit", k8s.NameSanitizer{Case: k8s.CaseKebab}, "getV2Status", "get-v2-status"},
+		{"kebab with dot replacement", k8s.NameSanitizer{Case: k8s.CaseKebab, DotReplacement: "--"}, "Billing.HTTPServer", "billing--http-server"},
+		{"63 characters kept", k8s.NameSanitizer{}, long + "b", long + "b"},
+		{"cut at 63", k8s.NameSanitizer{}, long + "bcd", long + "b"},
+		{"cut before a hyphen", k8s.NameSanitizer{}, long + ".b", long},
+		{"cut before hyphens", k8s.NameSanitizer{DotReplacement: "--"}, strings.Repeat("a", 61) + ".b", strings.Repeat("a", 61)},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.sanitizer.Sanitize(tt.in); got != tt.want {
+				t.Errorf("Sanitize(%q) = %q, want %q", tt.in, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSanitizeHashesLongNames(t *testing.T) {
+	hash := k8s.NameSanitizer{Truncate: k8s.TruncateHash}
+	prefix := strings.Repeat("a", 60)
+	for _, tt := range []struct {
+		name string
+		a, b string
+	}{
+		{"shared prefix", prefix + "-first", prefix + "-second"},
+		{"cut at a hyphen", strings.Repeat("a", 54) + ".first", strings.Repeat("a", 54) + ".second"},
+		{"differing case", prefix + "-Service", prefix + "-service"},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			a, b := hash.Sanitize(tt.a), hash.Sanitize(tt.b)
+			for _, name := range []string{a, b} {
+				if errs := validation.IsDNS1035Label(name); len(errs) > 0 {
+					t.Errorf("%q is not a valid Service name: %v", name, errs)
+				}
+			}
+			if a == b {
+				t.Errorf("%q and %q both became %q", tt.a, tt.b, a)
+			}
+			if again := hash.Sanitize(tt.a); again != a {
+				t.Errorf("Sanitize(%q) = %q, then %q", tt.a, a, again)
+			}
+		})
+	}
+
+	// Names that fit aren't hashed.
+	if got := hash.Sanitize("web"); got != "web" {
+		t.Errorf("Sanitize(web) = %q, want web", got)
+	}
+}
+
+func TestNameSanitizerValidate(t *testing.T) {
+	for _, tt := range []struct {
+		name      string
+		sanitizer k8s.NameSanitizer
+		wantErr   bool
+	}{
+		{"zero value", k8s.NameSanitizer{}, false},
+		{"all set", k8s.NameSanitizer{Replacement: "x", DotReplacement: "--", Case: k8s.CaseKebab, Truncate: k8s.TruncateHash}, false},
+		{"uppercase replacement", k8s.NameSanitizer{Replacement: "X"}, true},
+		{"dot as dot replacement", k8s.NameSanitizer{DotReplacement: "."}, true},
+		{"unknown case", k8s.NameSanitizer{Case: "upper"}, true},
+		{"unknown truncate", k8s.NameSanitizer{Truncate: "drop"}, true},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := tt.sanitizer.Validate(); (err != nil) != tt.wantErr {
+				t.Errorf("Validate() = %v, want error %t", err, tt.wantErr)
+			}
+		})
+	}
+}
@@ -0,0 +1,53 @@
+package kubernetes
+
+import (
+	"context"
+	"fmt"
+)
+
+// nodeLabelKey labels the EndpointSlices written by a node-local instance
+// with the name of its node.
+const nodeLabelKey = "consul-sync.alexieff.io/node"
+
+// sliceLabels returns the labels of the EndpointSlice for the Service name.
+func (s *Syncer) sliceLabels(name string) map[string]string {
+	labels := map[string]string{
+		"kubernetes.io/service-name":             name,
+		"endpointslice.kubernetes.io/managed-by": managedBy,
+		managedByKey:                             managedBy,
+	}
+	if s.opts.NodeName != "" {
+		labels[nodeLabelKey] = s.opts.NodeName
+	}
+	return labels
+}
+
+// sliceNodes maps each managed Service name to the nodes that have written an
+// EndpointSlice for it.
+func (s *Syncer) sliceNodes(ctx context.Context) (map[string]map[string]bool, error) {
+	list, err := s.listSlices(ctx, managedByKey+"="+managedBy)
+	if err != nil {
+		return nil, fmt.Errorf("listing managed endpointslices: %w", err)
+	}
+
+	nodes := make(map[string]map[string]bool)
+	for _, eps := range list {
+		name := eps.Labels["kubernetes.io/service-name"]
+		if nodes[name] == nil {
+			nodes[name] = make(map[string]bool)
+		}
+		nodes[name][eps.Labels[nodeLabelKey]] = true
+	}
+	return nodes, nil
+}
+
+// servedElsewhere reports whether nodes other than this one have written an
+// EndpointSlice for a Service.
+func (s *Syncer) servedElsewhere(nodes map[string]bool) bool {
+	for node := range nodes {
+		if node != s.opts.NodeName {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,75 @@
+package kubernetes
+
+import (
+	"context"
+	"slices"
+	"time"
+
+	"k8s.io/client-go/util/flowcontrol"
+
+	"github.com/alexieff-io/consul-sync/internal/consul"
+	"github.com/alexieff-io/consul-sync/internal/metrics"
+)
+
+// DefaultWriteBurst is the default Options.WriteBurst.
+const DefaultWriteBurst = 10
+
+// writePacer spaces out the writes of a Syncer and of the syncers of its
+// placed namespaces to Options.WriteRate, so a full resync of thousands of
+// services leaves API server capacity to other controllers. Reads aren't
+// paced. A nil writePacer lets every write through at once.
+type writePacer struct {
+	limiter flowcontrol.RateLimiter
+}
+
+func newWritePacer(opts Options) *writePacer {
+	if opts.WriteRate <= 0 {
+		return nil
+	}
+	burst := opts.WriteBurst
+	if burst <= 0 {
+		burst = DefaultWriteBurst
+	}
+	return &writePacer{limiter: flowcontrol.NewTokenBucketRateLimiter(float32(opts.WriteRate), burst)}
+}
+
+// pace waits for the next write to be allowed. When ctx ends first it
+// returns, and the write fails with the context's error.
+func (s *Syncer) pace(ctx context.Context) {
+	if s.pacer == nil {
+		return
+	}
+	start := time.Now()
+	s.pacer.limiter.Wait(ctx)
+	metrics.WritePacingWait.Add(time.Since(start).Seconds())
+}
+
+// endpointsFirst returns services with those whose ready addresses changed
+// since their last apply, or were never applied, ahead of the rest, whose
+// writes can at most change labels, annotations or routes. The order is
+// otherwise kept. Without pacing, writes aren't held up, and services is
+// returned as is.
+func (s *Syncer) endpointsFirst(services []consul.ServiceState) []consul.ServiceState {
+	if s.pacer == nil {
+		return services
+	}
+	ordered := slices.Clone(services)
+	slices.SortStableFunc(ordered, func(a, b consul.ServiceState) int {
+		ca, cb := s.endpointsChanged(a), s.endpointsChanged(b)
+		switch {
+		case ca && !cb:
+			return -1
+		case cb && !ca:
+			return 1
+		}
+		return 0
+	})
+	return ordered
+}
+
+// endpointsChanged reports whether the ready addresses of svc differ from
+// those of its last endpoints apply.
+func (s *Syncer) endpointsChanged(svc consul.ServiceState) bool {
+	previous, ok := s.endpointAddrs[s.opts.Names.Sanitize(svc.Name)]
+	return !ok || !slices.Equal(previous, instanceAddresses(svc.Instances))
+}
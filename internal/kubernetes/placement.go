@@ -0,0 +1,67 @@
+package kubernetes
+
+import (
+	"context"
+	"log/slog"
+	"maps"
+	"slices"
+
+	"github.com/alexieff-io/consul-sync/internal/consul"
+	"github.com/alexieff-io/consul-sync/internal/logctx"
+)
+
+// namespaceMetaKey is the service meta key placing a service's objects in
+// another namespace than the target namespace.
+const namespaceMetaKey = "k8s-namespace"
+
+// namespaceFor returns the namespace of svc: its k8s-namespace meta if it
+// names an allowed namespace, otherwise the target namespace.
+func (s *Syncer) namespaceFor(ctx context.Context, svc consul.ServiceState) string {
+	ns, ok := svc.Meta[namespaceMetaKey]
+	if !ok || ns == s.namespace {
+		return s.namespace
+	}
+	if _, allowed := s.placed[ns]; !allowed {
+		slog.WarnContext(ctx, "ignoring namespace not in the allowed namespaces", "service", svc.Name, "namespace", ns)
+		return s.namespace
+	}
+	return ns
+}
+
+// in returns the syncer of namespace, which must be the target namespace or
+// one of Options.Namespaces.
+func (s *Syncer) in(namespace string) *Syncer {
+	if placed, ok := s.placed[namespace]; ok {
+		return placed
+	}
+	return s
+}
+
+// syncers returns this syncer followed by those of Options.Namespaces, in
+// namespace order.
+func (s *Syncer) syncers() []*Syncer {
+	all := []*Syncer{s}
+	for _, ns := range slices.Sorted(maps.Keys(s.placed)) {
+		all = append(all, s.placed[ns])
+	}
+	return all
+}
+
+// withNamespace returns ctx, with the namespace of ns attached to its log
+// records when ns is not this syncer, the target namespace's.
+func (s *Syncer) withNamespace(ctx context.Context, ns *Syncer) context.Context {
+	if ns == s {
+		return ctx
+	}
+	return logctx.With(ctx, "namespace", ns.namespace)
+}
+
+// placeServices groups services by the namespace they are placed in.
+func (s *Syncer) placeServices(ctx context.Context, services []consul.ServiceState) map[string][]consul.ServiceState {
+	placed := make(map[string][]consul.ServiceState, len(s.placed)+1)
+	for _, svc := range services {
+		ns := s.namespaceFor(ctx, svc)
+		placed[ns] = append(placed[ns], svc)
+	}
+	return placed
+}
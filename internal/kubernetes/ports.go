@@ -0,0 +1,228 @@
+package kubernetes
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"maps"
+	"slices"
+	"strconv"
+	"strings"
+
+	corev1 "k8s.io/api/core/v1"
+	discoveryv1 "k8s.io/api/discovery/v1"
+	"k8s.io/apimachinery/pkg/util/validation"
+
+	"github.com/alexieff-io/consul-sync/internal/consul"
+)
+
+// portsMetaKey is the Consul service meta key listing the named ports of a
+// service, as comma-separated name[:port] entries, e.g. http,grpc:9090. An
+// entry without a port is on the instances' own port.
+const portsMetaKey = "k8s-ports"
+
+// protocolMetaKey is the Consul service meta key naming the protocol of a
+// service's ports, one of protocolAppProtocols. A service without it takes
+// the first of its tags that names one.
+const protocolMetaKey = "k8s-protocol"
+
+// defaultPortName names the single port of a service without k8s-ports meta
+// or another protocol, and is the port HTTPRoutes send requests to when a
+// service has several.
+const defaultPortName = "http"
+
+// protocolAppProtocols maps the protocols a port can be detected as to its
+// appProtocol, which gateways and meshes pick the upstream protocol by.
+// gRPC is HTTP/2 without TLS to a gateway. UDP ports have none.
+var protocolAppProtocols = map[string]string{
+	"http":  "http",
+	"http2": "kubernetes.io/h2c",
+	"grpc":  "kubernetes.io/h2c",
+	"tcp":   "tcp",
+	"udp":   "",
+}
+
+// namedPort is a port of a managed Service and its endpoints.
+type namedPort struct {
+	name        string
+	port        int32
+	protocol    corev1.Protocol
+	appProtocol string
+}
+
+// newPort returns the port name on port speaking protocol.
+func newPort(name string, port int32, protocol string) namedPort {
+	transport := corev1.ProtocolTCP
+	if protocol == "udp" {
+		transport = corev1.ProtocolUDP
+	}
+	return namedPort{name: name, port: port, protocol: transport, appProtocol: protocolAppProtocols[protocol]}
+}
+
+// portProtocol returns the protocol a port name says, as <protocol> or
+// <protocol>-<suffix>, e.g. grpc-api, or fallback.
+func portProtocol(name, fallback string) string {
+	protocol, _, _ := strings.Cut(name, "-")
+	if _, ok := protocolAppProtocols[protocol]; ok {
+		return protocol
+	}
+	return fallback
+}
+
+// parsePorts parses k8s-ports meta. Entries without a port get instancePort,
+// and those whose name doesn't say a protocol get protocol.
+func parsePorts(raw string, instancePort int32, protocol string) ([]namedPort, error) {
+	var ports []namedPort
+	for _, entry := range strings.Split(raw, ",") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+		name, num, hasPort := strings.Cut(entry, ":")
+		name = strings.TrimSpace(name)
+		if errs := validation.IsValidPortName(name); len(errs) > 0 {
+			return nil, fmt.Errorf("port name %q: %s", name, strings.Join(errs, "; "))
+		}
+		if slices.ContainsFunc(ports, func(p namedPort) bool { return p.name == name }) {
+			return nil, fmt.Errorf("port name %q is listed twice", name)
+		}
+		port := instancePort
+		if hasPort {
+			n, err := strconv.Atoi(strings.TrimSpace(num))
+			if err != nil || n < 1 || n > 65535 {
+				return nil, fmt.Errorf("port %q of %s must be from 1 to 65535", num, name)
+			}
+			port = int32(n)
+		}
+		np := newPort(name, port, portProtocol(name, protocol))
+		// A Service can't have two ports on the same number and protocol.
+		if i := slices.IndexFunc(ports, func(p namedPort) bool { return p.port == np.port && p.protocol == np.protocol }); i >= 0 {
+			return nil, fmt.Errorf("ports %s and %s are both %d/%s", ports[i].name, name, port, np.protocol)
+		}
+		ports = append(ports, np)
+	}
+	if len(ports) == 0 {
+		return nil, fmt.Errorf("%s %q lists no port", portsMetaKey, raw)
+	}
+	return ports, nil
+}
+
+// portsFor returns the ports of svc: those of its k8s-ports meta, or a single
+// port on the first instance's port, named after the service's protocol.
+// Invalid k8s-ports meta falls back to the single port; with warn set, it is
+// logged and recorded as an Event, and so is invalid k8s-protocol meta. svc
+// must have instances.
+func (s *Syncer) portsFor(ctx context.Context, svc consul.ServiceState, name string, warn bool) []namedPort {
+	instancePort := int32(svc.Instances[0].Port)
+	protocol := s.protocolFor(ctx, svc, name, warn)
+	if raw, ok := svc.Meta[portsMetaKey]; ok {
+		ports, err := parsePorts(raw, instancePort, protocol)
+		if err == nil {
+			return ports
+		}
+		if warn {
+			slog.WarnContext(ctx, "ignoring invalid ports meta", "service", name, "value", raw, "error", err)
+			s.eventf(s.namespace, name, corev1.EventTypeWarning, "InvalidPorts",
+				"Ignoring %s meta, using the single %s port: %v", portsMetaKey, protocol, err)
+		}
+	}
+	return []namedPort{newPort(protocol, instancePort, protocol)}
+}
+
+// protocolFor returns the protocol of the ports of svc: its k8s-protocol
+// meta, or else the first of its tags naming a protocol, or else http.
+func (s *Syncer) protocolFor(ctx context.Context, svc consul.ServiceState, name string, warn bool) string {
+	if raw, ok := svc.Meta[protocolMetaKey]; ok {
+		protocol := strings.ToLower(strings.TrimSpace(raw))
+		if _, known := protocolAppProtocols[protocol]; known {
+			return protocol
+		}
+		if warn {
+			protocols := strings.Join(slices.Sorted(maps.Keys(protocolAppProtocols)), ", ")
+			slog.WarnContext(ctx, "ignoring invalid protocol meta", "service", name, "value", raw, "protocols", protocols)
+			s.eventf(s.namespace, name, corev1.EventTypeWarning, "InvalidProtocol",
+				"Ignoring %s meta %q, not one of %s", protocolMetaKey, raw, protocols)
+		}
+	}
+	for _, tag := range svc.Tags {
+		if _, known := protocolAppProtocols[tag]; known {
+			return tag
+		}
+	}
+	return defaultPortName
+}
+
+// validPorts reports whether every port is in range, which the instances'
+// own port may not be.
+func validPorts(ports []namedPort) bool {
+	return !slices.ContainsFunc(ports, func(p namedPort) bool { return p.port < 1 || p.port > 65535 })
+}
+
+// routePort returns the port HTTPRoutes send requests to: the http one, or
+// else the first TCP one. It returns 0 for services with only UDP ports,
+// which aren't routed.
+func routePort(ports []namedPort) int32 {
+	for _, p := range ports {
+		if p.name == defaultPortName {
+			return p.port
+		}
+	}
+	for _, p := range ports {
+		if p.protocol == corev1.ProtocolTCP {
+			return p.port
+		}
+	}
+	return 0
+}
+
+// serviceSpecPorts renders ports for a Service spec.
+func serviceSpecPorts(ports []namedPort) []corev1.ServicePort {
+	out := make([]corev1.ServicePort, 0, len(ports))
+	for _, p := range ports {
+		out = append(out, corev1.ServicePort{
+			Name:        p.name,
+			Port:        p.port,
+			Protocol:    p.protocol,
+			AppProtocol: appProtocol(p),
+		})
+	}
+	return out
+}
+
+// slicePorts renders ports for an EndpointSlice.
+func slicePorts(ports []namedPort) []discoveryv1.EndpointPort {
+	out := make([]discoveryv1.EndpointPort, 0, len(ports))
+	for _, p := range ports {
+		name, port, protocol := p.name, p.port, p.protocol
+		out = append(out, discoveryv1.EndpointPort{
+			Name:        &name,
+			Port:        &port,
+			Protocol:    &protocol,
+			AppProtocol: appProtocol(p),
+		})
+	}
+	return out
+}
+
+// endpointsPorts renders ports for an Endpoints subset.
+func endpointsPorts(ports []namedPort) []corev1.EndpointPort {
+	out := make([]corev1.EndpointPort, 0, len(ports))
+	for _, p := range ports {
+		out = append(out, corev1.EndpointPort{
+			Name:        p.name,
+			Port:        p.port,
+			Protocol:    p.protocol,
+			AppProtocol: appProtocol(p),
+		})
+	}
+	return out
+}
+
+// appProtocol returns the appProtocol of p, or nil if it has none.
+func appProtocol(p namedPort) *string {
+	if p.appProtocol == "" {
+		return nil
+	}
+	appProtocol := p.appProtocol
+	return &appProtocol
+}
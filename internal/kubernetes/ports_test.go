@@ -0,0 +1,117 @@
+package kubernetes
+
+import (
+	"context"
+	"slices"
+	"testing"
+
+	corev1 "k8s.io/api/core/v1"
+
+	"github.com/alexieff-io/consul-sync/internal/consul"
+)
+
+func TestParsePorts(t *testing.T) {
+	http := newPort("http", 8080, "http")
+	for _, tt := range []struct {
+		name     string
+		raw      string
+		protocol string
+		want     []namedPort
+		wantErr  bool
+	}{
+		{"named ports", "http:8080,grpc:9090", "http", []namedPort{http, newPort("grpc", 9090, "grpc")}, false},
+		{"instance port", "http,metrics:9102", "http", []namedPort{http, newPort("metrics", 9102, "http")}, false},
+		{"spaces and empty entries", " http , grpc : 9090 ,", "http", []namedPort{http, newPort("grpc", 9090, "grpc")}, false},
+		{"protocol from the name", "grpc-api:9090,udp-dns:53,tcp-dns:53", "http", []namedPort{newPort("grpc-api", 9090, "grpc"), newPort("udp-dns", 53, "udp"), newPort("tcp-dns", 53, "tcp")}, false},
+		{"protocol of the service", "admin:9000", "tcp", []namedPort{newPort("admin", 9000, "tcp")}, false},
+		{"empty", "", "http", nil, true},
+		{"only commas", ",,", "http", nil, true},
+		{"missing name", ":8080", "http", nil, true},
+		{"invalid name", "HTTP:8080", "http", nil, true},
+		{"name too long", "a-very-long-port-name:8080", "http", nil, true},
+		{"port not a number", "http:web", "http", nil, true},
+		{"empty port", "http:", "http", nil, true},
+		{"port zero", "http:0", "http", nil, true},
+		{"port out of range", "http:65536", "http", nil, true},
+		{"negative port", "http:-1", "http", nil, true},
+		{"duplicate name", "http:8080,http:9090", "http", nil, true},
+		{"duplicate port", "http:8080,grpc:8080", "http", nil, true},
+		{"two on the instance port", "http,metrics", "http", nil, true},
+		{"duplicate of the instance port", "http,admin:8080", "http", nil, true},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := parsePorts(tt.raw, 8080, tt.protocol)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("parsePorts(%q) error = %v, want error %t", tt.raw, err, tt.wantErr)
+			}
+			if !slices.Equal(got, tt.want) {
+				t.Errorf("parsePorts(%q) = %+v, want %+v", tt.raw, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestPortsFor(t *testing.T) {
+	for _, tt := range []struct {
+		name string
+		meta map[string]string
+		tags []string
+		want []namedPort
+	}{
+		{"single http port", nil, nil, []namedPort{newPort("http", 8080, "http")}},
+		{"single port of the protocol tag", nil, []string{"kubernetes", "grpc"}, []namedPort{newPort("grpc", 8080, "grpc")}},
+		{"single port of the protocol meta", map[string]string{"k8s-protocol": " UDP "}, []string{"grpc"}, []namedPort{newPort("udp", 8080, "udp")}},
+		{"unknown protocol meta", map[string]string{"k8s-protocol": "quic"}, []string{"tcp"}, []namedPort{newPort("tcp", 8080, "tcp")}},
+		{"ports meta", map[string]string{"k8s-ports": "http,grpc:9090"}, nil, []namedPort{newPort("http", 8080, "http"), newPort("grpc", 9090, "grpc")}},
+		{"invalid ports meta", map[string]string{"k8s-ports": "http:99999"}, nil, []namedPort{newPort("http", 8080, "http")}},
+		{"invalid ports meta of a tcp service", map[string]string{"k8s-ports": "a,b"}, []string{"tcp"}, []namedPort{newPort("tcp", 8080, "tcp")}},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			svc := consul.ServiceState{
+				Name:      "web",
+				Tags:      tt.tags,
+				Meta:      tt.meta,
+				Instances: []consul.ServiceInstance{{ServiceName: "web", Address: "10.0.0.1", Port: 8080}},
+			}
+			got := (&Syncer{}).portsFor(context.Background(), svc, "web", false)
+			if !slices.Equal(got, tt.want) {
+				t.Errorf("portsFor = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRoutePort(t *testing.T) {
+	for _, tt := range []struct {
+		name  string
+		ports []namedPort
+		want  int32
+	}{
+		{"http", []namedPort{newPort("grpc", 9090, "grpc"), newPort("http", 8080, "http")}, 8080},
+		{"first tcp", []namedPort{newPort("udp-dns", 53, "udp"), newPort("grpc", 9090, "grpc"), newPort("admin", 9000, "tcp")}, 9090},
+		{"only udp", []namedPort{newPort("udp", 514, "udp")}, 0},
+	} {
+		if got := routePort(tt.ports); got != tt.want {
+			t.Errorf("%s: routePort = %d, want %d", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestNewPortProtocols(t *testing.T) {
+	for _, tt := range []struct {
+		protocol    string
+		transport   corev1.Protocol
+		appProtocol string
+	}{
+		{"http", corev1.ProtocolTCP, "http"},
+		{"http2", corev1.ProtocolTCP, "kubernetes.io/h2c"},
+		{"grpc", corev1.ProtocolTCP, "kubernetes.io/h2c"},
+		{"tcp", corev1.ProtocolTCP, "tcp"},
+		{"udp", corev1.ProtocolUDP, ""},
+	} {
+		p := newPort("p", 80, tt.protocol)
+		if p.protocol != tt.transport || p.appProtocol != tt.appProtocol {
+			t.Errorf("newPort(%s) = %s with appProtocol %q, want %s with %q", tt.protocol, p.protocol, p.appProtocol, tt.transport, tt.appProtocol)
+		}
+	}
+}
@@ -0,0 +1,41 @@
+package kubernetes
+
+import (
+	"context"
+	"log/slog"
+	"strconv"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// keepAnnotation set to true on a managed Service, EndpointSlice, Endpoints
+// or HTTPRoute keeps it through orphan cleanup, e.g. while the traffic of a
+// service leaving Consul is migrated. A kept Service keeps its endpoints too.
+const keepAnnotation = "consul-sync.alexieff.io/keep"
+
+// kept reports whether obj is annotated to survive orphan cleanup.
+func kept(obj metav1.Object) bool {
+	keep, _ := strconv.ParseBool(obj.GetAnnotations()[keepAnnotation])
+	return keep
+}
+
+// retain leaves the orphaned object kind/name in place. The first sync it is
+// retained, it is logged and recorded as an Event on service.
+func (s *Syncer) retain(ctx context.Context, kind, name, service string) {
+	key := kind + "/" + name
+	s.retaining[key] = true
+	if s.retained[key] {
+		return
+	}
+	slog.InfoContext(ctx, "retaining orphaned object annotated to be kept", "kind", kind, "name", name, "service", service)
+	s.eventf(s.namespace, service, corev1.EventTypeNormal, "Retained",
+		"Orphaned %s %s retained: annotated %s=true", kind, name, keepAnnotation)
+}
+
+// endRetained ends the orphan cleanup of a sync, returning the number of
+// objects it retained.
+func (s *Syncer) endRetained() int {
+	s.retained, s.retaining = s.retaining, make(map[string]bool)
+	return len(s.retained)
+}
@@ -0,0 +1,69 @@
+package kubernetes
+
+import (
+	"context"
+	"log/slog"
+
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	"github.com/alexieff-io/consul-sync/internal/metrics"
+)
+
+// serviceExists reports whether the Service name existed before this sync
+// applied it. Services applied earlier by this process are known to exist;
+// others are looked up once.
+func (s *Syncer) serviceExists(ctx context.Context, name string) bool {
+	if _, ok := s.serviceUIDs[s.namespace+"/"+name]; ok {
+		return true
+	}
+	c := s.clientsFor(s.namespace)
+	_, err := c.Core.CoreV1().Services(s.namespace).Get(ctx, name, metav1.GetOptions{})
+	// On any other error, assume it exists rather than risk deleting it.
+	return !apierrors.IsNotFound(err)
+}
+
+// abortService handles a service whose sync failed at step after its Service
+// was applied, so it isn't left half-created. A Service created by this sync
+// is rolled back, along with any endpoints applied for it. A Service that
+// existed before keeps its previous endpoints and routes, which still match
+// its old state, and is only reported.
+func (s *Syncer) abortService(ctx context.Context, name string, created bool, step string, cause error) {
+	if !created {
+		metrics.PartialSyncs.WithLabelValues("left_partial").Inc()
+		s.eventf(s.namespace, name, corev1.EventTypeWarning, "PartialSync",
+			"Applying the %s failed, previous objects left in place: %v", step, cause)
+		return
+	}
+
+	slog.WarnContext(ctx, "rolling back new service after failed apply", "service", name, "step", step)
+	metrics.PartialSyncs.WithLabelValues("rolled_back").Inc()
+	c := s.clientsFor(s.namespace)
+	if !s.opts.ServiceOnly && s.opts.EndpointsMode.slices() {
+		if err := s.deleteSlices(ctx, name); err != nil {
+			slog.ErrorContext(ctx, "failed to roll back endpointslices", "service", name, "error", err)
+		}
+	}
+	if !s.opts.ServiceOnly && s.opts.EndpointsMode.endpoints() {
+		if err := s.deleteEndpoints(ctx, name); err != nil {
+			slog.ErrorContext(ctx, "failed to roll back endpoints", "name", name, "error", err)
+		}
+	}
+	delete(s.drains, name)
+	delete(s.endpointAddrs, name)
+
+	// In node mode other nodes may have started serving the Service since;
+	// an unused one is removed by cleanup.
+	if s.opts.NodeName != "" {
+		return
+	}
+	s.pace(ctx)
+	err := c.Core.CoreV1().Services(s.namespace).Delete(ctx, name, metav1.DeleteOptions{})
+	if err != nil && !apierrors.IsNotFound(err) {
+		slog.ErrorContext(ctx, "failed to roll back service", "name", name, "error", err)
+		return
+	}
+	delete(s.serviceUIDs, s.namespace+"/"+name)
+	delete(s.appliedHealth, s.namespace+"/"+name)
+}
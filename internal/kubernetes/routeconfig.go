@@ -0,0 +1,157 @@
+package kubernetes
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"strings"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/watch"
+	"k8s.io/client-go/kubernetes"
+	"sigs.k8s.io/yaml"
+)
+
+// HTTPRouteOverride replaces parts of the global HTTPRouteConfig for routes
+// created in one namespace. Empty fields inherit the global value.
+type HTTPRouteOverride struct {
+	DomainSuffix     string `json:"domainSuffix,omitempty"`
+	InternalGateway  string `json:"internalGateway,omitempty"`
+	ExternalGateway  string `json:"externalGateway,omitempty"`
+	GatewayNamespace string `json:"gatewayNamespace,omitempty"`
+	// GatewayListener is a comma-separated list of listeners, or * for all.
+	GatewayListener string `json:"gatewayListener,omitempty"`
+	// HostnameLayout is flat, prefix or subdomain.
+	HostnameLayout HostnameLayout `json:"hostnameLayout,omitempty"`
+}
+
+// RouteConfigSource references the ConfigMap holding per-namespace
+// HTTPRouteOverrides. Each data key is a namespace and each value a YAML or
+// JSON document for that namespace's override.
+type RouteConfigSource struct {
+	Namespace string
+	Name      string
+}
+
+// ParseRouteConfigSource parses a reference of the form [namespace/]name.
+func ParseRouteConfigSource(ref, defaultNamespace string) (RouteConfigSource, error) {
+	ns, name, ok := strings.Cut(ref, "/")
+	if !ok {
+		ns, name = defaultNamespace, ref
+	}
+	if name == "" {
+		return RouteConfigSource{}, fmt.Errorf("missing name in %q", ref)
+	}
+	return RouteConfigSource{Namespace: ns, Name: name}, nil
+}
+
+func (src RouteConfigSource) String() string {
+	return "configmap:" + src.Namespace + "/" + src.Name
+}
+
+// LoadRouteOverrides reads the referenced ConfigMap and returns its overrides
+// along with the resourceVersion to start watching from.
+func LoadRouteOverrides(ctx context.Context, client kubernetes.Interface, src RouteConfigSource) (map[string]HTTPRouteOverride, string, error) {
+	cm, err := client.CoreV1().ConfigMaps(src.Namespace).Get(ctx, src.Name, metav1.GetOptions{})
+	if err != nil {
+		return nil, "", fmt.Errorf("getting %s: %w", src, err)
+	}
+	overrides, err := parseRouteOverrides(cm)
+	if err != nil {
+		return nil, "", fmt.Errorf("parsing %s: %w", src, err)
+	}
+	return overrides, cm.ResourceVersion, nil
+}
+
+// WatchRouteOverrides watches the referenced ConfigMap from resourceVersion
+// onwards and calls apply with the new overrides each time it changes. When
+// the ConfigMap is deleted, apply receives no overrides. Invalid updates are
+// logged and the previous overrides stay in effect. It blocks until the
+// context is cancelled.
+func WatchRouteOverrides(ctx context.Context, client kubernetes.Interface, src RouteConfigSource, resourceVersion string, apply func(map[string]HTTPRouteOverride)) {
+	list := func(ctx context.Context, opts metav1.ListOptions) (runtime.Object, error) {
+		return client.CoreV1().ConfigMaps(src.Namespace).List(ctx, opts)
+	}
+	watchNamed(ctx, src.String(), src.Name, resourceVersion, list, client.CoreV1().ConfigMaps(src.Namespace).Watch, func(event watch.Event) {
+		switch event.Type {
+		case watch.Deleted:
+			slog.Warn("route config source deleted, using global route settings", "source", src.String())
+			apply(nil)
+		case watch.Added, watch.Modified:
+			cm, ok := event.Object.(*corev1.ConfigMap)
+			if !ok {
+				return
+			}
+			overrides, err := parseRouteOverrides(cm)
+			if err != nil {
+				slog.Error("failed to reload route overrides", "source", src.String(), "error", err)
+				return
+			}
+			apply(overrides)
+			slog.Info("reloaded route overrides", "source", src.String(), "namespaces", len(overrides))
+		}
+	})
+}
+
+func parseRouteOverrides(cm *corev1.ConfigMap) (map[string]HTTPRouteOverride, error) {
+	overrides := make(map[string]HTTPRouteOverride, len(cm.Data))
+	for namespace, doc := range cm.Data {
+		var o HTTPRouteOverride
+		if err := yaml.UnmarshalStrict([]byte(doc), &o); err != nil {
+			return nil, fmt.Errorf("namespace %s: %w", namespace, err)
+		}
+		if o.HostnameLayout != "" {
+			if _, err := ParseHostnameLayout(string(o.HostnameLayout)); err != nil {
+				return nil, fmt.Errorf("namespace %s: %w", namespace, err)
+			}
+		}
+		overrides[namespace] = o
+	}
+	return overrides, nil
+}
+
+// SetRouteOverrides replaces the per-namespace HTTPRoute overrides. Routes
+// pick up the new settings on the next sync, and routes left behind on a
+// previous gateway are cleaned up as orphans. It is safe to call concurrently
+// with Sync.
+func (s *Syncer) SetRouteOverrides(overrides map[string]HTTPRouteOverride) {
+	for _, ns := range s.syncers() {
+		ns.routeOverrides.Store(&overrides)
+	}
+}
+
+// routeConfigFor returns the HTTPRoute settings for routes in namespace,
+// with any override for that namespace applied over the global config.
+func (s *Syncer) routeConfigFor(namespace string) HTTPRouteConfig {
+	cfg := s.routeCfg
+	overrides := s.routeOverrides.Load()
+	if overrides == nil {
+		return cfg
+	}
+	o, ok := (*overrides)[namespace]
+	if !ok {
+		return cfg
+	}
+
+	if o.DomainSuffix != "" {
+		cfg.DomainSuffix = o.DomainSuffix
+	}
+	if o.InternalGateway != "" {
+		cfg.InternalGateway = o.InternalGateway
+	}
+	if o.ExternalGateway != "" {
+		cfg.ExternalGateway = o.ExternalGateway
+	}
+	if o.GatewayNamespace != "" {
+		cfg.GatewayNamespace = o.GatewayNamespace
+	}
+	if o.GatewayListener != "" {
+		cfg.GatewayListeners = ParseListeners(o.GatewayListener)
+	}
+	if o.HostnameLayout != "" {
+		cfg.HostnameLayout = o.HostnameLayout
+	}
+	return cfg
+}
@@ -0,0 +1,128 @@
+package kubernetes
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"maps"
+	"slices"
+	"strings"
+
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+)
+
+// maxRouteChanges caps the changes listed when an HTTPRoute changes, so a
+// reshuffle of many rules doesn't flood the log and Events.
+const maxRouteChanges = 10
+
+// routeTopLists are the lists of an HTTPRoute spec whose removed entries are
+// reported against the live spec.
+var routeTopLists = []string{"parentRefs", "hostnames", "rules"}
+
+// previousRouteSpec returns the spec the HTTPRoute routeName had before this
+// apply, and whether it is the spec consul-sync last applied. Until a route
+// is applied once by this process it is read from the cluster, with the
+// defaults filled in by the API server, or nil when it doesn't exist yet.
+func (s *Syncer) previousRouteSpec(ctx context.Context, routeName string) (map[string]interface{}, bool) {
+	if spec, ok := s.appliedRoutes[routeName]; ok {
+		return spec, true
+	}
+	c := s.clientsFor(s.namespace)
+	live, err := c.Dynamic.Resource(httpRouteGVR).Namespace(s.namespace).Get(ctx, routeName, metav1.GetOptions{})
+	if err != nil {
+		if !apierrors.IsNotFound(err) {
+			slog.DebugContext(ctx, "failed to read httproute before apply, not diffing it", "route", routeName, "error", err)
+		}
+		return nil, false
+	}
+	spec, _, _ := unstructured.NestedMap(live.Object, "spec")
+	return spec, false
+}
+
+// reportRouteChanges logs and records an Event on the Service of plan with
+// the fields of the HTTPRoute spec that changed from old, and remembers spec
+// as the last applied.
+func (s *Syncer) reportRouteChanges(ctx context.Context, routeName string, plan routePlan, old, spec map[string]interface{}, applied bool) {
+	s.appliedRoutes[routeName] = spec
+	if old == nil {
+		return
+	}
+	changes := routeChanges(old, spec, applied)
+	if len(changes) == 0 {
+		return
+	}
+	if len(changes) > maxRouteChanges {
+		changes = append(changes[:maxRouteChanges], fmt.Sprintf("and %d more", len(changes)-maxRouteChanges))
+	}
+	diff := strings.Join(changes, "; ")
+	slog.InfoContext(ctx, "httproute changed", "route", routeName, "service", plan.service, "changes", diff)
+	s.eventf(s.namespace, plan.service, corev1.EventTypeNormal, "HTTPRouteChanged", "HTTPRoute %s changed: %s", routeName, diff)
+}
+
+// routeChanges lists the fields that differ between the HTTPRoute specs old
+// and spec, as path: old -> new. When old is the spec last applied, every
+// field is compared; when it is the live spec, fields only it sets are
+// server defaults, so only the fields spec sets and the entries removed from
+// its top-level lists are.
+func routeChanges(old, spec map[string]interface{}, applied bool) []string {
+	before := make(map[string]string)
+	after := make(map[string]string)
+	flattenFields("", old, before)
+	flattenFields("", spec, after)
+
+	var changes []string
+	for _, path := range slices.Sorted(maps.Keys(after)) {
+		prev, ok := before[path]
+		switch {
+		case !ok:
+			changes = append(changes, fmt.Sprintf("%s: %s (added)", path, after[path]))
+		case prev != after[path]:
+			changes = append(changes, fmt.Sprintf("%s: %s -> %s", path, prev, after[path]))
+		}
+	}
+	if applied {
+		for _, path := range slices.Sorted(maps.Keys(before)) {
+			if _, ok := after[path]; !ok {
+				changes = append(changes, fmt.Sprintf("%s: %s (removed)", path, before[path]))
+			}
+		}
+		return changes
+	}
+	for _, list := range routeTopLists {
+		prev, _, _ := unstructured.NestedSlice(old, list)
+		next, _, _ := unstructured.NestedSlice(spec, list)
+		for i := len(next); i < len(prev); i++ {
+			changes = append(changes, fmt.Sprintf("%s[%d] removed", list, i))
+		}
+	}
+	return changes
+}
+
+// flattenFields adds the leaves of v to out, keyed by their path, e.g.
+// rules[0].backendRefs[0].port, with JSON-encoded values.
+func flattenFields(path string, v interface{}, out map[string]string) {
+	switch v := v.(type) {
+	case map[string]interface{}:
+		for k, child := range v {
+			if path == "" {
+				flattenFields(k, child, out)
+			} else {
+				flattenFields(path+"."+k, child, out)
+			}
+		}
+	case []interface{}:
+		for i, child := range v {
+			flattenFields(fmt.Sprintf("%s[%d]", path, i), child, out)
+		}
+	default:
+		data, err := json.Marshal(v)
+		if err != nil {
+			data = []byte(fmt.Sprint(v))
+		}
+		out[path] = string(data)
+	}
+}
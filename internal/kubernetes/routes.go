@@ -0,0 +1,400 @@
+package kubernetes
+
+import (
+	"cmp"
+	"context"
+	"fmt"
+	"log/slog"
+	"slices"
+	"strings"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/util/validation"
+
+	"github.com/alexieff-io/consul-sync/internal/consul"
+	"github.com/alexieff-io/consul-sync/internal/metrics"
+)
+
+// Consul service meta keys shaping a service's HTTPRoutes.
+const (
+	hostnameMetaKey = "k8s-hostname" // full hostname instead of <name>.<DOMAIN_SUFFIX>
+	pathMetaKey     = "k8s-path"     // path prefix the service's rule matches
+	headerMetaKey   = "k8s-header"   // Name=value header the service's rule matches
+	methodMetaKey   = "k8s-method"   // HTTP method the service's rule matches
+	queryMetaKey    = "k8s-query"    // name=value query parameter the service's rule matches
+
+	listenersMetaKey = "k8s-listeners" // listeners the service's routes attach to
+)
+
+// allListeners selects every listener of a Gateway in GATEWAY_LISTENER and
+// k8s-listeners meta.
+const allListeners = "*"
+
+// ParseListeners parses a comma-separated list of Gateway listener names.
+// "*" selects every listener and returns nil.
+func ParseListeners(s string) []string {
+	var listeners []string
+	for _, l := range strings.Split(s, ",") {
+		l = strings.TrimSpace(l)
+		if l == allListeners {
+			return nil
+		}
+		if l != "" && !slices.Contains(listeners, l) {
+			listeners = append(listeners, l)
+		}
+	}
+	return listeners
+}
+
+// routeRule sends the requests matching path, method, header and query to
+// one Service, or splits them by weight with the Services in split.
+type routeRule struct {
+	service string
+	port    int32
+	path    string // path prefix; empty matches any path
+	method  string // upper case; empty matches any method
+	header  string // Name=value, matched exactly; empty matches any request
+	query   string // name=value, matched exactly; empty matches any request
+
+	// weight is the service's share of the requests when they are split,
+	// and weighted whether it was set, see routeWeight.
+	weight   int32
+	weighted bool
+	split    []routeRule
+
+	// annotations are the service's meta annotations, see metaAnnotations,
+	// and labels its route tag labels, see routeTagLabels.
+	annotations map[string]string
+	labels      map[string]string
+}
+
+// httpMethods are the methods an HTTPRoute match accepts.
+var httpMethods = []string{"GET", "HEAD", "POST", "PUT", "DELETE", "CONNECT", "OPTIONS", "TRACE", "PATCH"}
+
+// routeMember is a service asking for a route on one gateway.
+type routeMember struct {
+	gateway   string
+	hostname  string
+	listeners []string // nil for every listener
+	rule      routeRule
+}
+
+// routePlan is one HTTPRoute to apply: a hostname on a gateway, with one rule
+// per service sharing the hostname.
+type routePlan struct {
+	name      string
+	service   string // the Service the route is labeled with: its first rule's
+	gateway   string
+	hostname  string
+	listeners []string // union of the members' listeners, nil for every listener
+	rules     []routeRule
+
+	// annotations and labels merge the meta annotations and route tag
+	// labels of the routed services.
+	annotations map[string]string
+	labels      map[string]string
+}
+
+// routeConflict is a service left out of a shared route because another
+// service already matches the same requests.
+type routeConflict struct {
+	service  string
+	winner   string
+	gateway  string
+	hostname string
+}
+
+// routeMembers returns the routes svc asks for, named name in Kubernetes and
+// served on port, none without one. With warn set, invalid hostnames and matches are logged,
+// counted and recorded as Events.
+func (s *Syncer) routeMembers(ctx context.Context, svc consul.ServiceState, name string, port int32, warn bool) []routeMember {
+	cfg := s.routeConfigFor(s.namespace)
+	gateways := routeGateways(cfg, svc.Tags)
+	if len(gateways) == 0 || port == 0 {
+		return nil
+	}
+
+	rule := routeRule{
+		service: name,
+		port:    port,
+		path:    svc.Meta[pathMetaKey],
+		method:  strings.ToUpper(svc.Meta[methodMetaKey]),
+		header:  svc.Meta[headerMetaKey],
+		query:   svc.Meta[queryMetaKey],
+	}
+	if err := rule.validate(); err != nil {
+		if warn {
+			slog.WarnContext(ctx, "skipping httproutes with invalid match", "service", name, "error", err)
+			s.eventf(s.namespace, name, corev1.EventTypeWarning, "InvalidRouteMatch",
+				"Skipping HTTPRoutes: %v", err)
+		}
+		return nil
+	}
+	rule.annotations = s.metaAnnotations(ctx, svc, false)
+	rule.labels = s.routeTagLabels(name, svc.Tags)
+	var err error
+	if rule.weight, rule.weighted, err = routeWeight(svc); err != nil && warn {
+		slog.WarnContext(ctx, "ignoring invalid route weight", "service", name, "value", svc.Meta[weightMetaKey], "error", err)
+	}
+
+	listeners := cfg.GatewayListeners
+	if raw, ok := svc.Meta[listenersMetaKey]; ok {
+		if err := validateListeners(raw); err != nil {
+			if warn {
+				slog.WarnContext(ctx, "ignoring invalid listeners", "service", name, "value", raw, "error", err)
+			}
+		} else {
+			listeners = ParseListeners(raw)
+		}
+	}
+
+	members := make([]routeMember, 0, len(gateways))
+	for _, gw := range gateways {
+		hostname := cfg.HostnameLayout.hostname(name, gw.tag, cfg.DomainSuffix)
+		if h := svc.Meta[hostnameMetaKey]; h != "" {
+			hostname = strings.ToLower(h)
+		}
+		if reason, err := validateHostname(hostname); err != nil {
+			if warn {
+				routeName := name + "-" + gw.name
+				metrics.InvalidHostnames.WithLabelValues(reason).Inc()
+				slog.WarnContext(ctx, "skipping httproute with invalid hostname", "service", name, "gateway", gw.name, "hostname", hostname, "error", err)
+				s.eventf(s.namespace, name, corev1.EventTypeWarning, "InvalidHostname",
+					"Skipping HTTPRoute %s: hostname %q is invalid: %v", routeName, hostname, err)
+			}
+			continue
+		}
+		members = append(members, routeMember{gateway: gw.name, hostname: hostname, listeners: listeners, rule: rule})
+	}
+	return members
+}
+
+// validateListeners checks k8s-listeners meta.
+func validateListeners(raw string) error {
+	if strings.TrimSpace(raw) == allListeners {
+		return nil
+	}
+	listeners := ParseListeners(raw)
+	if len(listeners) == 0 {
+		return fmt.Errorf("%s %q names no listener", listenersMetaKey, raw)
+	}
+	for _, l := range listeners {
+		if errs := validation.IsDNS1123Subdomain(l); len(errs) > 0 {
+			return fmt.Errorf("%s %q: %s", listenersMetaKey, l, strings.Join(errs, "; "))
+		}
+	}
+	return nil
+}
+
+// parentRefs returns the parentRefs of the plan's route: one per listener,
+// or a single one without sectionName attaching it to every listener.
+func parentRefs(cfg HTTPRouteConfig, plan routePlan) []interface{} {
+	if plan.listeners == nil {
+		return []interface{}{
+			map[string]interface{}{
+				"name":      plan.gateway,
+				"namespace": cfg.GatewayNamespace,
+			},
+		}
+	}
+	refs := make([]interface{}, 0, len(plan.listeners))
+	for _, listener := range plan.listeners {
+		refs = append(refs, map[string]interface{}{
+			"name":        plan.gateway,
+			"namespace":   cfg.GatewayNamespace,
+			"sectionName": listener,
+		})
+	}
+	return refs
+}
+
+// validate checks the k8s-path, k8s-method, k8s-header and k8s-query meta of
+// a rule.
+func (r routeRule) validate() error {
+	if r.path != "" && !strings.HasPrefix(r.path, "/") {
+		return fmt.Errorf("%s %q must start with /", pathMetaKey, r.path)
+	}
+	if r.method != "" && !slices.Contains(httpMethods, r.method) {
+		return fmt.Errorf("%s %q is not one of %s", methodMetaKey, r.method, strings.Join(httpMethods, ", "))
+	}
+	if r.header != "" {
+		name, value, ok := strings.Cut(r.header, "=")
+		if !ok || value == "" {
+			return fmt.Errorf("%s %q must be Name=value", headerMetaKey, r.header)
+		}
+		if errs := validation.IsHTTPHeaderName(name); len(errs) > 0 {
+			return fmt.Errorf("%s %q: %s", headerMetaKey, r.header, strings.Join(errs, "; "))
+		}
+	}
+	if r.query != "" {
+		name, value, ok := strings.Cut(r.query, "=")
+		if !ok || name == "" || value == "" {
+			return fmt.Errorf("%s %q must be name=value", queryMetaKey, r.query)
+		}
+		if len(name) > 256 {
+			return fmt.Errorf("%s %q: name longer than 256 characters", queryMetaKey, r.query)
+		}
+	}
+	return nil
+}
+
+// matchKey identifies the requests a rule matches.
+func (r routeRule) matchKey() string {
+	return r.path + "\x00" + r.method + "\x00" + r.header + "\x00" + r.query
+}
+
+// planRoutes merges the members sharing a hostname on a gateway into a single
+// route, instead of one conflicting route per service. A hostname used by a
+// single service keeps its <service>-<gateway> route; a shared one is named
+// after the hostname. Rules are ordered most specific first, then by
+// Service name, so plans are deterministic. When several services match the
+// same requests, they are split by weight if any of them is weighted;
+// otherwise the first by name keeps them and the rest are returned as
+// conflicts.
+func (s *Syncer) planRoutes(members []routeMember) ([]routePlan, []routeConflict) {
+	type key struct{ gateway, hostname string }
+	groups := make(map[key][]routeRule)
+	listeners := make(map[key][]string)
+	var keys []key
+	for _, m := range members {
+		k := key{m.gateway, m.hostname}
+		if _, ok := groups[k]; !ok {
+			keys = append(keys, k)
+			listeners[k] = m.listeners
+		} else if listeners[k] != nil {
+			listeners[k] = unionListeners(listeners[k], m.listeners)
+		}
+		groups[k] = append(groups[k], m.rule)
+	}
+
+	var plans []routePlan
+	var conflicts []routeConflict
+	for _, k := range keys {
+		rules := groups[k]
+		slices.SortFunc(rules, func(a, b routeRule) int {
+			return strings.Compare(a.service, b.service)
+		})
+
+		kept, lost := splitRules(rules)
+		for _, c := range lost {
+			c.gateway, c.hostname = k.gateway, k.hostname
+			conflicts = append(conflicts, c)
+		}
+		// The same precedence the Gateway API gives matches: longest path,
+		// then method, header and query parameter matches.
+		slices.SortStableFunc(kept, func(a, b routeRule) int {
+			if c := cmp.Compare(len(b.path), len(a.path)); c != 0 {
+				return c
+			}
+			if c := cmp.Compare(len(b.method), len(a.method)); c != 0 {
+				return c
+			}
+			if c := cmp.Compare(len(b.header), len(a.header)); c != 0 {
+				return c
+			}
+			return cmp.Compare(len(b.query), len(a.query))
+		})
+
+		plan := routePlan{
+			name:      kept[0].service + "-" + k.gateway,
+			service:   kept[0].service,
+			gateway:   k.gateway,
+			hostname:  k.hostname,
+			listeners: listeners[k],
+			rules:     kept,
+
+			annotations: routeAnnotations(kept),
+			labels:      routeLabels(kept),
+		}
+		if len(rules) > 1 {
+			plan.name = s.opts.Names.Sanitize(k.hostname) + "-" + k.gateway
+		}
+		plans = append(plans, plan)
+	}
+	return plans, conflicts
+}
+
+// unionListeners returns the listeners in a or b, sorted, or nil for every
+// listener if either is nil.
+func unionListeners(a, b []string) []string {
+	if a == nil || b == nil {
+		return nil
+	}
+	union := slices.Clone(a)
+	for _, l := range b {
+		if !slices.Contains(union, l) {
+			union = append(union, l)
+		}
+	}
+	slices.Sort(union)
+	return union
+}
+
+// backends returns the Service names of the plan's rules, in order, each
+// followed by the Services splitting its requests.
+func (p routePlan) backends() []string {
+	names := make([]string, 0, len(p.rules))
+	for _, r := range p.rules {
+		names = append(names, r.service)
+		for _, b := range r.split {
+			names = append(names, b.service)
+		}
+	}
+	return names
+}
+
+// reportConflicts logs and records an Event for each service left out of a
+// shared route, and exports the number of conflicts.
+func (s *Syncer) reportConflicts(ctx context.Context, conflicts []routeConflict) {
+	for _, c := range conflicts {
+		slog.WarnContext(ctx, "service left out of shared httproute, another service matches the same requests",
+			"service", c.service, "winner", c.winner, "gateway", c.gateway, "hostname", c.hostname)
+		s.eventf(s.namespace, c.service, corev1.EventTypeWarning, "HostnameConflict",
+			"Left out of the HTTPRoute for %s on %s: %s already matches the same requests", c.hostname, c.gateway, c.winner)
+	}
+	if !s.opts.Secondary {
+		metrics.HostnameConflicts.Set(float64(len(conflicts)))
+	}
+}
+
+// object renders the rule as an HTTPRoute rule.
+func (r routeRule) object() map[string]interface{} {
+	rule := map[string]interface{}{
+		"backendRefs": r.backendRefs(),
+	}
+
+	match := map[string]interface{}{}
+	if r.path != "" {
+		match["path"] = map[string]interface{}{
+			"type":  "PathPrefix",
+			"value": r.path,
+		}
+	}
+	if r.method != "" {
+		match["method"] = r.method
+	}
+	if r.header != "" {
+		name, value, _ := strings.Cut(r.header, "=")
+		match["headers"] = []interface{}{
+			map[string]interface{}{
+				"type":  "Exact",
+				"name":  name,
+				"value": value,
+			},
+		}
+	}
+	if r.query != "" {
+		name, value, _ := strings.Cut(r.query, "=")
+		match["queryParams"] = []interface{}{
+			map[string]interface{}{
+				"type":  "Exact",
+				"name":  name,
+				"value": value,
+			},
+		}
+	}
+	if len(match) > 0 {
+		rule["matches"] = []interface{}{match}
+	}
+	return rule
+}
@@ -0,0 +1,344 @@
+package kubernetes
+
+import (
+	"context"
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/client-go/dynamic"
+	"k8s.io/client-go/dynamic/dynamicinformer"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/tools/cache"
+	"k8s.io/client-go/tools/record"
+
+	"github.com/alexieff-io/consul-sync/internal/metrics"
+)
+
+// monitoredRouteConditions are the HTTPRoute parent conditions that must be
+// True for a route to carry traffic.
+var monitoredRouteConditions = []string{"Accepted", "ResolvedRefs"}
+
+// RouteProblem is an HTTPRoute parent condition that isn't True.
+type RouteProblem struct {
+	Namespace string    `json:"namespace"`
+	Route     string    `json:"route"`
+	Service   string    `json:"service"`
+	Gateway   string    `json:"gateway"`
+	Condition string    `json:"condition"`
+	Status    string    `json:"status"`
+	Reason    string    `json:"reason"`
+	Message   string    `json:"message"`
+	Since     time.Time `json:"since"`
+}
+
+// RouteStatusMonitor watches the status of managed HTTPRoutes and surfaces
+// routes the gateway hasn't accepted or whose backends it can't resolve.
+type RouteStatusMonitor struct {
+	client    kubernetes.Interface
+	dynClient dynamic.Interface
+	namespace string
+	recorder  record.EventRecorder
+
+	mu       sync.Mutex
+	problems map[string][]RouteProblem // keyed by namespace/name
+	// gateways holds, per route keyed by namespace/name, the Gateways it
+	// attaches to and whether each accepted it, for the per-gateway
+	// metrics. counted holds the Gateways those were last reported for.
+	gateways map[string]map[string]bool
+	counted  map[string]bool
+}
+
+// NewRouteStatusMonitor creates a monitor for the HTTPRoutes managed in
+// namespace. Events are dropped if recorder is nil.
+func NewRouteStatusMonitor(client kubernetes.Interface, dynClient dynamic.Interface, namespace string, recorder record.EventRecorder) *RouteStatusMonitor {
+	return &RouteStatusMonitor{
+		client:    client,
+		dynClient: dynClient,
+		namespace: namespace,
+		recorder:  recorder,
+		problems:  make(map[string][]RouteProblem),
+		gateways:  make(map[string]map[string]bool),
+		counted:   make(map[string]bool),
+	}
+}
+
+// Run watches managed HTTPRoutes until the context is cancelled.
+func (m *RouteStatusMonitor) Run(ctx context.Context) {
+	factory := dynamicinformer.NewFilteredDynamicSharedInformerFactory(m.dynClient, 0, m.namespace,
+		func(opts *metav1.ListOptions) {
+			opts.LabelSelector = managedByKey + "=" + managedBy
+		})
+	informer := factory.ForResource(httpRouteGVR).Informer()
+	informer.AddEventHandler(cache.ResourceEventHandlerFuncs{
+		AddFunc:    func(obj any) { m.update(ctx, obj) },
+		UpdateFunc: func(_, obj any) { m.update(ctx, obj) },
+		DeleteFunc: func(obj any) { m.remove(obj) },
+	})
+
+	slog.Info("watching httproute status", "namespace", m.namespace)
+	factory.Start(ctx.Done())
+	<-ctx.Done()
+	factory.Shutdown()
+}
+
+// Problems returns the current route problems, ordered by route.
+func (m *RouteStatusMonitor) Problems() []RouteProblem {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	var out []RouteProblem
+	for _, p := range m.problems {
+		out = append(out, p...)
+	}
+	slices.SortFunc(out, func(a, b RouteProblem) int {
+		if c := strings.Compare(a.Route, b.Route); c != 0 {
+			return c
+		}
+		if c := strings.Compare(a.Gateway, b.Gateway); c != 0 {
+			return c
+		}
+		return strings.Compare(a.Condition, b.Condition)
+	})
+	return out
+}
+
+// ServeHTTP lists the current route problems as JSON.
+func (m *RouteStatusMonitor) ServeHTTP(w http.ResponseWriter, _ *http.Request) {
+	problems := m.Problems()
+	if problems == nil {
+		problems = []RouteProblem{}
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(problems)
+}
+
+func (m *RouteStatusMonitor) update(ctx context.Context, obj any) {
+	route, ok := obj.(*unstructured.Unstructured)
+	if !ok {
+		return
+	}
+	key := route.GetNamespace() + "/" + route.GetName()
+	problems := routeProblems(route)
+
+	m.mu.Lock()
+	previous := m.problems[key]
+	if len(problems) == 0 {
+		delete(m.problems, key)
+	} else {
+		m.problems[key] = problems
+	}
+	m.gateways[key] = attachedGateways(route)
+	m.updateMetrics()
+	m.mu.Unlock()
+
+	service := route.GetLabels()["app.kubernetes.io/name"]
+	for _, p := range problems {
+		if !containsProblem(previous, p) {
+			slog.WarnContext(ctx, "httproute condition not true", "route", p.Route, "gateway", p.Gateway,
+				"condition", p.Condition, "reason", p.Reason, "message", p.Message)
+			m.eventf(ctx, route.GetNamespace(), service, corev1.EventTypeWarning, "HTTPRouteNot"+p.Condition,
+				"HTTPRoute %s on gateway %s: %s is %s: %s: %s", p.Route, p.Gateway, p.Condition, p.Status, p.Reason, p.Message)
+		}
+	}
+	if len(previous) > 0 && len(problems) == 0 {
+		slog.InfoContext(ctx, "httproute conditions recovered", "route", route.GetName())
+		m.eventf(ctx, route.GetNamespace(), service, corev1.EventTypeNormal, "HTTPRouteRecovered",
+			"HTTPRoute %s is accepted and its references are resolved", route.GetName())
+	}
+}
+
+func (m *RouteStatusMonitor) remove(obj any) {
+	if tombstone, ok := obj.(cache.DeletedFinalStateUnknown); ok {
+		obj = tombstone.Obj
+	}
+	route, ok := obj.(*unstructured.Unstructured)
+	if !ok {
+		return
+	}
+	m.mu.Lock()
+	delete(m.problems, route.GetNamespace()+"/"+route.GetName())
+	delete(m.gateways, route.GetNamespace()+"/"+route.GetName())
+	m.updateMetrics()
+	m.mu.Unlock()
+}
+
+// updateMetrics recounts problems per condition, and routes attached to and
+// accepted by each Gateway. The caller holds m.mu.
+func (m *RouteStatusMonitor) updateMetrics() {
+	counts := make(map[string]int)
+	for _, problems := range m.problems {
+		for _, p := range problems {
+			counts[p.Condition]++
+		}
+	}
+	for _, cond := range monitoredRouteConditions {
+		metrics.HTTPRouteProblems.WithLabelValues(cond).Set(float64(counts[cond]))
+	}
+
+	attached := make(map[string]int)
+	accepted := make(map[string]int)
+	for _, gateways := range m.gateways {
+		for gateway, ok := range gateways {
+			attached[gateway]++
+			if ok {
+				accepted[gateway]++
+			}
+		}
+	}
+	for gateway := range m.counted {
+		if _, ok := attached[gateway]; !ok {
+			metrics.GatewayHTTPRoutes.DeleteLabelValues(gateway)
+			metrics.GatewayAcceptedHTTPRoutes.DeleteLabelValues(gateway)
+			delete(m.counted, gateway)
+		}
+	}
+	for gateway, n := range attached {
+		metrics.GatewayHTTPRoutes.WithLabelValues(gateway).Set(float64(n))
+		metrics.GatewayAcceptedHTTPRoutes.WithLabelValues(gateway).Set(float64(accepted[gateway]))
+		m.counted[gateway] = true
+	}
+}
+
+// attachedGateways returns the Gateways route attaches to, as namespace/name,
+// and whether each has accepted it: every status parent of that Gateway
+// reports Accepted True for the route's current generation, and there is at
+// least one.
+func attachedGateways(route *unstructured.Unstructured) map[string]bool {
+	gateways := make(map[string]bool)
+	refs, _, _ := unstructured.NestedSlice(route.Object, "spec", "parentRefs")
+	for _, r := range refs {
+		if ref, ok := r.(map[string]any); ok {
+			gateways[parentGateway(route, ref)] = false
+		}
+	}
+
+	reported := make(map[string]bool)
+	parents, _, _ := unstructured.NestedSlice(route.Object, "status", "parents")
+	for _, p := range parents {
+		parent, ok := p.(map[string]any)
+		if !ok {
+			continue
+		}
+		ref, _, _ := unstructured.NestedMap(parent, "parentRef")
+		gateway := parentGateway(route, ref)
+		if _, ok := gateways[gateway]; !ok {
+			continue
+		}
+		accepted := parentAccepted(route, parent)
+		if reported[gateway] {
+			accepted = accepted && gateways[gateway]
+		}
+		reported[gateway] = true
+		gateways[gateway] = accepted
+	}
+	return gateways
+}
+
+// parentGateway returns the namespace/name of a parentRef, which defaults to
+// the route's namespace.
+func parentGateway(route *unstructured.Unstructured, ref map[string]any) string {
+	name, _, _ := unstructured.NestedString(ref, "name")
+	namespace, _, _ := unstructured.NestedString(ref, "namespace")
+	if namespace == "" {
+		namespace = route.GetNamespace()
+	}
+	return namespace + "/" + name
+}
+
+// parentAccepted reports whether a status parent has Accepted True for the
+// route's current generation.
+func parentAccepted(route *unstructured.Unstructured, parent map[string]any) bool {
+	conditions, _, _ := unstructured.NestedSlice(parent, "conditions")
+	for _, c := range conditions {
+		cond, ok := c.(map[string]any)
+		if !ok {
+			continue
+		}
+		if condType, _, _ := unstructured.NestedString(cond, "type"); condType != "Accepted" {
+			continue
+		}
+		if gen, ok, _ := unstructured.NestedInt64(cond, "observedGeneration"); ok && gen < route.GetGeneration() {
+			return false
+		}
+		status, _, _ := unstructured.NestedString(cond, "status")
+		return status == string(metav1.ConditionTrue)
+	}
+	return false
+}
+
+// eventf records an Event on the route's Service. The Service is looked up
+// for its UID so the Event shows up in kubectl describe.
+func (m *RouteStatusMonitor) eventf(ctx context.Context, namespace, service, eventType, reason, messageFmt string, args ...any) {
+	if m.recorder == nil || service == "" {
+		return
+	}
+	ref := &corev1.ObjectReference{APIVersion: "v1", Kind: "Service", Namespace: namespace, Name: service}
+	if svc, err := m.client.CoreV1().Services(namespace).Get(ctx, service, metav1.GetOptions{}); err == nil {
+		ref.UID = svc.UID
+	}
+	m.recorder.Eventf(ref, eventType, reason, messageFmt, args...)
+}
+
+// routeProblems returns the monitored conditions that aren't True on any
+// parent of route. Parents that haven't reported status for the current
+// generation yet are skipped rather than treated as broken.
+func routeProblems(route *unstructured.Unstructured) []RouteProblem {
+	parents, _, _ := unstructured.NestedSlice(route.Object, "status", "parents")
+	var problems []RouteProblem
+	for _, p := range parents {
+		parent, ok := p.(map[string]any)
+		if !ok {
+			continue
+		}
+		gateway, _, _ := unstructured.NestedString(parent, "parentRef", "name")
+		conditions, _, _ := unstructured.NestedSlice(parent, "conditions")
+		for _, c := range conditions {
+			cond, ok := c.(map[string]any)
+			if !ok {
+				continue
+			}
+			condType, _, _ := unstructured.NestedString(cond, "type")
+			if !slices.Contains(monitoredRouteConditions, condType) {
+				continue
+			}
+			if gen, ok, _ := unstructured.NestedInt64(cond, "observedGeneration"); ok && gen < route.GetGeneration() {
+				continue
+			}
+			status, _, _ := unstructured.NestedString(cond, "status")
+			if status == string(metav1.ConditionTrue) {
+				continue
+			}
+			reason, _, _ := unstructured.NestedString(cond, "reason")
+			message, _, _ := unstructured.NestedString(cond, "message")
+			since, _, _ := unstructured.NestedString(cond, "lastTransitionTime")
+			t, _ := time.Parse(time.RFC3339, since)
+			problems = append(problems, RouteProblem{
+				Namespace: route.GetNamespace(),
+				Route:     route.GetName(),
+				Service:   route.GetLabels()["app.kubernetes.io/name"],
+				Gateway:   gateway,
+				Condition: condType,
+				Status:    status,
+				Reason:    reason,
+				Message:   message,
+				Since:     t,
+			})
+		}
+	}
+	return problems
+}
+
+// containsProblem reports whether problems holds the same condition on the
+// same gateway with the same reason.
+func containsProblem(problems []RouteProblem, p RouteProblem) bool {
+	return slices.ContainsFunc(problems, func(q RouteProblem) bool {
+		return q.Gateway == p.Gateway && q.Condition == p.Condition && q.Reason == p.Reason
+	})
+}
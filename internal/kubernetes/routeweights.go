@@ -0,0 +1,95 @@
+package kubernetes
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/alexieff-io/consul-sync/internal/consul"
+)
+
+// weightMetaKey is the Consul service meta key declaring the service's share
+// of the requests it matches together with other services.
+const weightMetaKey = "k8s-weight"
+
+// maxBackendWeight is the largest backendRef weight the Gateway API accepts.
+const maxBackendWeight = 1000000
+
+// routeWeight returns the weight of svc when it splits the requests of a
+// route rule with other services: its k8s-weight meta, or else the sum of the
+// Consul weights of its instances, so each instance keeps the share Consul's
+// DNS gives it. weighted reports whether either was set to anything but the
+// default, which makes the service split requests instead of conflicting
+// over them. An invalid k8s-weight is returned as an error, with the Consul
+// weights.
+func routeWeight(svc consul.ServiceState) (weight int32, weighted bool, err error) {
+	if raw, ok := svc.Meta[weightMetaKey]; ok {
+		w, perr := strconv.Atoi(strings.TrimSpace(raw))
+		if perr == nil && w >= 0 && w <= maxBackendWeight {
+			return int32(w), true, nil
+		}
+		err = fmt.Errorf("%s %q must be an integer from 0 to %d", weightMetaKey, raw, maxBackendWeight)
+	}
+
+	var sum int
+	for _, inst := range svc.Instances {
+		if inst.Weight <= 0 {
+			// Not reported by the source: Consul's default.
+			sum++
+			continue
+		}
+		sum += inst.Weight
+		if inst.Weight != 1 {
+			weighted = true
+		}
+	}
+	return int32(min(sum, maxBackendWeight)), weighted, err
+}
+
+// splitRules merges the rules matching the same requests into the first of
+// them as weighted backends, when any of them is weighted. rules are sorted
+// by Service name. The other services matching the same requests are
+// returned as conflicts, without gateway and hostname, the first by name
+// keeping the requests.
+func splitRules(rules []routeRule) ([]routeRule, []routeConflict) {
+	weighted := make(map[string]bool)
+	for _, r := range rules {
+		if r.weighted {
+			weighted[r.matchKey()] = true
+		}
+	}
+
+	claimed := make(map[string]int, len(rules))
+	var kept []routeRule
+	var conflicts []routeConflict
+	for _, r := range rules {
+		i, ok := claimed[r.matchKey()]
+		switch {
+		case !ok:
+			claimed[r.matchKey()] = len(kept)
+			kept = append(kept, r)
+		case weighted[r.matchKey()]:
+			kept[i].split = append(kept[i].split, r)
+		default:
+			conflicts = append(conflicts, routeConflict{service: r.service, winner: kept[i].service})
+		}
+	}
+	return kept, conflicts
+}
+
+// backendRefs renders the backendRefs of the rule: its own Service, then the
+// Services splitting its requests, each with its weight.
+func (r routeRule) backendRefs() []interface{} {
+	refs := make([]interface{}, 0, 1+len(r.split))
+	for _, b := range append([]routeRule{r}, r.split...) {
+		ref := map[string]interface{}{
+			"name": b.service,
+			"port": int64(b.port),
+		}
+		if len(r.split) > 0 {
+			ref["weight"] = int64(b.weight)
+		}
+		refs = append(refs, ref)
+	}
+	return refs
+}
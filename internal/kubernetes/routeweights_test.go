@@ -0,0 +1,121 @@
+package kubernetes
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/alexieff-io/consul-sync/internal/consul"
+)
+
+func TestRouteWeight(t *testing.T) {
+	instances := func(weights ...int) []consul.ServiceInstance {
+		var out []consul.ServiceInstance
+		for _, w := range weights {
+			out = append(out, consul.ServiceInstance{Weight: w})
+		}
+		return out
+	}
+	for _, tt := range []struct {
+		name         string
+		meta         map[string]string
+		instances    []consul.ServiceInstance
+		want         int32
+		wantWeighted bool
+		wantErr      bool
+	}{
+		{"default weights", nil, instances(1, 1, 1), 3, false, false},
+		{"unreported weights", nil, instances(0, 0), 2, false, false},
+		{"instance weights", nil, instances(1, 3), 4, true, false},
+		{"no instances", nil, nil, 0, false, false},
+		{"capped", nil, instances(maxBackendWeight, 5), maxBackendWeight, true, false},
+		{"meta", map[string]string{"k8s-weight": "90"}, instances(1, 3), 90, true, false},
+		{"meta with spaces", map[string]string{"k8s-weight": " 10 "}, instances(1), 10, true, false},
+		{"meta zero", map[string]string{"k8s-weight": "0"}, instances(1), 0, true, false},
+		{"meta at the limit", map[string]string{"k8s-weight": "1000000"}, instances(1), maxBackendWeight, true, false},
+		{"meta above the limit", map[string]string{"k8s-weight": "1000001"}, instances(1, 1), 2, false, true},
+		{"negative meta", map[string]string{"k8s-weight": "-1"}, instances(2), 2, true, true},
+		{"meta not a number", map[string]string{"k8s-weight": "half"}, instances(1), 1, false, true},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			got, weighted, err := routeWeight(consul.ServiceState{Name: "web", Meta: tt.meta, Instances: tt.instances})
+			if got != tt.want || weighted != tt.wantWeighted || (err != nil) != tt.wantErr {
+				t.Errorf("routeWeight = %d, %t, %v, want %d, %t, error %t", got, weighted, err, tt.want, tt.wantWeighted, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestSplitRules(t *testing.T) {
+	rule := func(service, path string, weight int32, weighted bool) routeRule {
+		return routeRule{service: service, port: 80, path: path, weight: weight, weighted: weighted}
+	}
+	// kept describes a kept rule by its service and those splitting it.
+	type kept struct {
+		service string
+		split   []string
+	}
+	for _, tt := range []struct {
+		name          string
+		rules         []routeRule
+		wantKept      []kept
+		wantConflicts []routeConflict
+	}{
+		{
+			name:     "distinct matches",
+			rules:    []routeRule{rule("api", "/api", 1, false), rule("web", "", 1, false)},
+			wantKept: []kept{{"api", nil}, {"web", nil}},
+		},
+		{
+			name:          "unweighted conflict",
+			rules:         []routeRule{rule("a", "/", 1, false), rule("b", "/", 1, false)},
+			wantKept:      []kept{{"a", nil}},
+			wantConflicts: []routeConflict{{service: "b", winner: "a"}},
+		},
+		{
+			name:     "split by the weighted one",
+			rules:    []routeRule{rule("a", "/", 1, false), rule("b", "/", 9, true), rule("c", "/", 1, false)},
+			wantKept: []kept{{"a", []string{"b", "c"}}},
+		},
+		{
+			name:          "split only where weighted",
+			rules:         []routeRule{rule("a", "/", 1, false), rule("b", "/", 1, false), rule("c", "/v2", 3, true), rule("d", "/v2", 1, false)},
+			wantKept:      []kept{{"a", nil}, {"c", []string{"d"}}},
+			wantConflicts: []routeConflict{{service: "b", winner: "a"}},
+		},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			rules, conflicts := splitRules(tt.rules)
+			var got []kept
+			for _, r := range rules {
+				k := kept{service: r.service}
+				for _, s := range r.split {
+					k.split = append(k.split, s.service)
+				}
+				got = append(got, k)
+			}
+			if !reflect.DeepEqual(got, tt.wantKept) {
+				t.Errorf("kept %+v, want %+v", got, tt.wantKept)
+			}
+			if !reflect.DeepEqual(conflicts, tt.wantConflicts) {
+				t.Errorf("conflicts %+v, want %+v", conflicts, tt.wantConflicts)
+			}
+		})
+	}
+}
+
+func TestBackendRefs(t *testing.T) {
+	single := routeRule{service: "web", port: 8080, weight: 3}
+	want := []interface{}{map[string]interface{}{"name": "web", "port": int64(8080)}}
+	if got := single.backendRefs(); !reflect.DeepEqual(got, want) {
+		t.Errorf("backendRefs of a single service = %v, want %v", got, want)
+	}
+
+	split := routeRule{service: "web", port: 8080, weight: 90, split: []routeRule{{service: "web-canary", port: 9090, weight: 10}}}
+	want = []interface{}{
+		map[string]interface{}{"name": "web", "port": int64(8080), "weight": int64(90)},
+		map[string]interface{}{"name": "web-canary", "port": int64(9090), "weight": int64(10)},
+	}
+	if got := split.backendRefs(); !reflect.DeepEqual(got, want) {
+		t.Errorf("backendRefs of a split = %v, want %v", got, want)
+	}
+}
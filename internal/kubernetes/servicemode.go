@@ -0,0 +1,207 @@
+package kubernetes
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"net"
+	"strings"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/util/validation"
+
+	"github.com/alexieff-io/consul-sync/internal/consul"
+)
+
+// serviceModeMetaKey is the Consul service meta key selecting the Service
+// mode for a single service, e.g. k8s-service-mode=clusterip.
+const serviceModeMetaKey = "k8s-service-mode"
+
+// loadBalancerClassMetaKey is the Consul service meta key overriding the
+// loadBalancerClass of a single LoadBalancer Service.
+const loadBalancerClassMetaKey = "k8s-lb-class"
+
+// ServiceMode selects how the Kubernetes Service for a Consul service is
+// shaped.
+type ServiceMode string
+
+const (
+	// ServiceModeHeadless creates a headless Service (clusterIP: None), so
+	// cluster DNS resolves the name straight to the instance addresses.
+	ServiceModeHeadless ServiceMode = "headless"
+	// ServiceModeClusterIP allocates a cluster IP that kube-proxy forwards to
+	// the instance addresses, for clusters whose DNS can't serve headless
+	// records pointing outside the cluster.
+	ServiceModeClusterIP ServiceMode = "clusterip"
+	// ServiceModeExternalIPs is ServiceModeClusterIP with the instance
+	// addresses also set as spec.externalIPs.
+	ServiceModeExternalIPs ServiceMode = "externalips"
+	// ServiceModeLoadBalancer creates a LoadBalancer Service, so a load
+	// balancer implementation such as MetalLB or kube-vip exposes the
+	// instances on L4 without going through a gateway.
+	ServiceModeLoadBalancer ServiceMode = "loadbalancer"
+	// ServiceModeExternalName creates an ExternalName Service, a DNS alias
+	// of the DNS name the service's instances register as their address,
+	// without endpoints. It is only selected per service, by meta.
+	ServiceModeExternalName ServiceMode = "externalname"
+)
+
+// LoadBalancerConfig shapes the Services of ServiceModeLoadBalancer.
+type LoadBalancerConfig struct {
+	// Class is the loadBalancerClass of the Services, overridable per
+	// service with k8s-lb-class meta. Empty leaves them to the cluster's
+	// default load balancer implementation.
+	Class string
+
+	// Annotations are set on the Services, e.g. to select a MetalLB
+	// address pool.
+	Annotations map[string]string
+}
+
+// ParseServiceMode validates a ServiceMode. Empty means headless.
+func ParseServiceMode(s string) (ServiceMode, error) {
+	switch m := ServiceMode(s); m {
+	case "":
+		return ServiceModeHeadless, nil
+	case ServiceModeHeadless, ServiceModeClusterIP, ServiceModeExternalIPs, ServiceModeLoadBalancer, ServiceModeExternalName:
+		return m, nil
+	default:
+		return "", fmt.Errorf("expected headless, clusterip, externalips, loadbalancer or externalname, got %q", s)
+	}
+}
+
+// serviceModeFor returns the mode of svc: its k8s-service-mode meta if valid,
+// otherwise the configured default.
+func (s *Syncer) serviceModeFor(ctx context.Context, svc consul.ServiceState) ServiceMode {
+	def := s.opts.ServiceMode
+	if def == "" {
+		def = ServiceModeHeadless
+	}
+	raw, ok := svc.Meta[serviceModeMetaKey]
+	if !ok {
+		return def
+	}
+	mode, err := ParseServiceMode(raw)
+	if err != nil {
+		slog.WarnContext(ctx, "ignoring invalid service mode", "service", svc.Name, "value", raw, "error", err)
+		return def
+	}
+	if mode == ServiceModeExternalIPs && s.opts.NodeName != "" {
+		// Each node only knows its own addresses, so nodes would keep
+		// overwriting each other's externalIPs.
+		slog.WarnContext(ctx, "externalips service mode is not supported in node mode, using clusterip", "service", svc.Name)
+		return ServiceModeClusterIP
+	}
+	if mode == ServiceModeExternalName {
+		if err := validateExternalName(svc.Instances); err != nil {
+			slog.WarnContext(ctx, "ignoring externalname service mode", "service", svc.Name, "error", err)
+			return def
+		}
+	}
+	return mode
+}
+
+// ExternalNameService reports whether the k8s-service-mode meta of svc asks
+// for an ExternalName Service, which needs the DNS name its instances
+// register as their address.
+func ExternalNameService(svc consul.ServiceState) bool {
+	mode, err := ParseServiceMode(svc.Meta[serviceModeMetaKey])
+	return err == nil && mode == ServiceModeExternalName
+}
+
+// validateExternalName checks that instances, which may be none, register
+// one DNS name as their address, for ServiceModeExternalName.
+func validateExternalName(instances []consul.ServiceInstance) error {
+	for _, inst := range instances {
+		if net.ParseIP(inst.Address) != nil {
+			return fmt.Errorf("address %s is an IP address, not a DNS name", inst.Address)
+		}
+		if errs := validation.IsDNS1123Subdomain(inst.Address); len(errs) > 0 {
+			return fmt.Errorf("address %q: %s", inst.Address, strings.Join(errs, "; "))
+		}
+		if inst.Address != instances[0].Address {
+			return fmt.Errorf("instances register several addresses, %s and %s", instances[0].Address, inst.Address)
+		}
+	}
+	return nil
+}
+
+// loadBalancerClassFor returns the loadBalancerClass of svc: its k8s-lb-class
+// meta if set, otherwise the configured class.
+func (s *Syncer) loadBalancerClassFor(svc consul.ServiceState) string {
+	if class := svc.Meta[loadBalancerClassMetaKey]; class != "" {
+		return class
+	}
+	return s.opts.LoadBalancer.Class
+}
+
+// applyTo sets the mode-specific fields of svc. addresses are the instance
+// addresses, whose IPs ServiceModeExternalIPs uses, and lb the load
+// balancer settings of ServiceModeLoadBalancer, with lbClass the class to
+// use.
+func (m ServiceMode) applyTo(svc *corev1.Service, addresses []string, lb LoadBalancerConfig, lbClass string) {
+	spec := &svc.Spec
+	spec.Type = corev1.ServiceTypeClusterIP
+	switch m {
+	case ServiceModeClusterIP:
+		// Leave clusterIP unset so one is allocated.
+	case ServiceModeExternalIPs:
+		spec.ExternalIPs = ipAddresses(addresses)
+	case ServiceModeLoadBalancer:
+		spec.Type = corev1.ServiceTypeLoadBalancer
+		if lbClass != "" {
+			spec.LoadBalancerClass = &lbClass
+		}
+		svc.Annotations = lb.Annotations
+	case ServiceModeExternalName:
+		spec.Type = corev1.ServiceTypeExternalName
+		if len(addresses) > 0 {
+			spec.ExternalName = addresses[0]
+		}
+	default:
+		spec.ClusterIP = corev1.ClusterIPNone
+	}
+}
+
+// matches reports whether an existing Service has the shape of mode.
+func (m ServiceMode) matches(svc *corev1.Service, addresses []string) bool {
+	headless := svc.Spec.ClusterIP == corev1.ClusterIPNone
+	if headless != (m == ServiceModeHeadless) {
+		return false
+	}
+	if (svc.Spec.Type == corev1.ServiceTypeLoadBalancer) != (m == ServiceModeLoadBalancer) {
+		return false
+	}
+	if (svc.Spec.Type == corev1.ServiceTypeExternalName) != (m == ServiceModeExternalName) {
+		return false
+	}
+	if m == ServiceModeExternalName {
+		return len(addresses) > 0 && svc.Spec.ExternalName == addresses[0]
+	}
+	if m == ServiceModeExternalIPs {
+		return addressDiff(svc.Spec.ExternalIPs, ipAddresses(addresses)) == ""
+	}
+	return len(svc.Spec.ExternalIPs) == 0
+}
+
+// dropEndpoints deletes the endpoints of the ExternalName Service name, left
+// from another mode, once.
+func (s *Syncer) dropEndpoints(ctx context.Context, name string) error {
+	if s.opts.ServiceOnly || s.externalNames[name] {
+		return nil
+	}
+	if s.opts.EndpointsMode.slices() {
+		if err := s.deleteSlices(ctx, name); err != nil {
+			return err
+		}
+	}
+	if s.opts.EndpointsMode.endpoints() {
+		if err := s.deleteEndpoints(ctx, name); err != nil {
+			return fmt.Errorf("deleting endpoints %s: %w", name, err)
+		}
+	}
+	delete(s.drains, name)
+	delete(s.endpointAddrs, name)
+	s.externalNames[name] = true
+	return nil
+}
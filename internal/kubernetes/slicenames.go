@@ -0,0 +1,82 @@
+package kubernetes
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"strings"
+
+	discoveryv1 "k8s.io/api/discovery/v1"
+	"k8s.io/apimachinery/pkg/util/validation"
+)
+
+// DefaultSliceSuffix is appended to the Service name to name its
+// EndpointSlices, see Options.SliceSuffix.
+const DefaultSliceSuffix = "consul"
+
+// SliceNaming selects how EndpointSlice names are built from the Service
+// name.
+type SliceNaming string
+
+const (
+	// SliceNamingSuffix names slices <name>-<suffix>[-v6|-fqdn][-<node>],
+	// hashed as with SliceNamingHash only when that is longer than 63
+	// characters.
+	SliceNamingSuffix SliceNaming = "suffix"
+	// SliceNamingHash always names slices after the suffixed name,
+	// truncated to fit, followed by a hash of it, e.g. web-consul-1a2b3c4d.
+	SliceNamingHash SliceNaming = "hash"
+)
+
+// ParseSliceNaming validates a SliceNaming. Empty means suffix.
+func ParseSliceNaming(s string) (SliceNaming, error) {
+	switch n := SliceNaming(s); n {
+	case "":
+		return SliceNamingSuffix, nil
+	case SliceNamingSuffix, SliceNamingHash:
+		return n, nil
+	default:
+		return "", fmt.Errorf("unknown slice naming %q: expected suffix or hash", s)
+	}
+}
+
+// ValidateSliceSuffix checks that suffix can follow a Service name in a
+// DNS label.
+func ValidateSliceSuffix(suffix string) error {
+	if errs := validation.IsDNS1123Label(suffix); len(errs) > 0 {
+		return fmt.Errorf("slice suffix %q: %s", suffix, strings.Join(errs, ", "))
+	}
+	return nil
+}
+
+// sliceName returns the name of the EndpointSlice written for the Service
+// name holding addresses of family. Node-local instances each write slices of
+// their own, holding only the instances on their node.
+func (s *Syncer) sliceName(name string, family discoveryv1.AddressType) string {
+	suffix := s.opts.SliceSuffix
+	if suffix == "" {
+		suffix = DefaultSliceSuffix
+	}
+	full := name + "-" + suffix
+	switch family {
+	case discoveryv1.AddressTypeIPv6:
+		full += "-v6"
+	case discoveryv1.AddressTypeFQDN:
+		full += "-fqdn"
+	}
+	if s.opts.NodeName != "" {
+		full += "-" + s.opts.NodeName
+	}
+	if s.opts.SliceNaming != SliceNamingHash && len(full) <= maxNameLength {
+		return full
+	}
+
+	// Keep the slices of Services whose names share a long prefix, and the
+	// slices of one Service on different nodes, apart.
+	sum := sha256.Sum256([]byte(full))
+	hash := "-" + hex.EncodeToString(sum[:4])
+	if len(full) > maxNameLength-len(hash) {
+		full = strings.TrimRight(full[:maxNameLength-len(hash)], "-.")
+	}
+	return full + hash
+}
@@ -0,0 +1,126 @@
+package kubernetes
+
+import (
+	"strings"
+	"testing"
+
+	discoveryv1 "k8s.io/api/discovery/v1"
+	"k8s.io/apimachinery/pkg/util/validation"
+)
+
+func TestSliceName(t *testing.T) {
+	name56 := strings.Repeat("a", 56)
+	name63 := strings.Repeat("a", 63)
+	for _, tt := range []struct {
+		name    string
+		opts    Options
+		service string
+		family  discoveryv1.AddressType
+		want    string
+	}{
+		{"suffix", Options{}, "web", discoveryv1.AddressTypeIPv4, "web-consul"},
+		{"custom suffix", Options{SliceSuffix: "eps"}, "web", discoveryv1.AddressTypeIPv4, "web-eps"},
+		{"ipv6", Options{}, "web", discoveryv1.AddressTypeIPv6, "web-consul-v6"},
+		{"fqdn", Options{}, "web", discoveryv1.AddressTypeFQDN, "web-consul-fqdn"},
+		{"node", Options{NodeName: "node-1"}, "web", discoveryv1.AddressTypeIPv4, "web-consul-node-1"},
+		{"ipv6 on a node", Options{NodeName: "node-1"}, "web", discoveryv1.AddressTypeIPv6, "web-consul-v6-node-1"},
+		{"hash", Options{SliceNaming: SliceNamingHash}, "web", discoveryv1.AddressTypeIPv4, "web-consul-ead05125"},
+		{"hash ipv6", Options{SliceNaming: SliceNamingHash}, "web", discoveryv1.AddressTypeIPv6, "web-consul-v6-70ae8b4b"},
+		{"63 characters kept", Options{}, name56, discoveryv1.AddressTypeIPv4, name56 + "-consul"},
+		{"longer hashed", Options{}, name63, discoveryv1.AddressTypeIPv4, strings.Repeat("a", 54) + "-ce08c383"},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			s := &Syncer{opts: tt.opts}
+			got := s.sliceName(tt.service, tt.family)
+			if got != tt.want {
+				t.Errorf("sliceName(%q, %s) = %q, want %q", tt.service, tt.family, got, tt.want)
+			}
+			if len(got) > maxNameLength {
+				t.Errorf("sliceName(%q, %s) is %d characters long", tt.service, tt.family, len(got))
+			}
+		})
+	}
+}
+
+// Services with long names sharing a prefix, in every family and on nodes
+// with long names too, get distinct and valid slice names.
+func TestSliceNamesOfLongServicesDiffer(t *testing.T) {
+	prefix := strings.Repeat("a", 62)
+	services := []string{prefix + "b", prefix + "c", strings.Repeat("a", 53) + "-" + strings.Repeat("b", 9)}
+	families := []discoveryv1.AddressType{discoveryv1.AddressTypeIPv4, discoveryv1.AddressTypeIPv6, discoveryv1.AddressTypeFQDN}
+
+	for _, tt := range []struct {
+		name string
+		opts Options
+	}{
+		{"suffix", Options{}},
+		{"hash", Options{SliceNaming: SliceNamingHash}},
+		{"suffix on a node", Options{NodeName: "ip-10-0-0-1.ec2.internal"}},
+		{"hash on a node", Options{SliceNaming: SliceNamingHash, NodeName: "ip-10-0-0-1.ec2.internal"}},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			s := &Syncer{opts: tt.opts}
+			seen := make(map[string]string)
+			for _, service := range services {
+				for _, family := range families {
+					got := s.sliceName(service, family)
+					if len(got) > maxNameLength {
+						t.Errorf("slice of %s %s is %d characters long", service, family, len(got))
+					}
+					if errs := validation.IsDNS1123Subdomain(got); len(errs) > 0 {
+						t.Errorf("slice of %s %s: %q is invalid: %v", service, family, got, errs)
+					}
+					what := service + " " + string(family)
+					if other, ok := seen[got]; ok {
+						t.Errorf("%s and %s share the slice name %q", other, what, got)
+					}
+					seen[got] = what
+				}
+			}
+		})
+	}
+
+	// Nodes share the Service but not the slice.
+	a := (&Syncer{opts: Options{NodeName: "node-a"}}).sliceName(prefix+"b", discoveryv1.AddressTypeIPv4)
+	b := (&Syncer{opts: Options{NodeName: "node-b"}}).sliceName(prefix+"b", discoveryv1.AddressTypeIPv4)
+	if a == b {
+		t.Errorf("nodes share the slice name %q", a)
+	}
+}
+
+func TestParseSliceNaming(t *testing.T) {
+	for _, tt := range []struct {
+		in      string
+		want    SliceNaming
+		wantErr bool
+	}{
+		{"", SliceNamingSuffix, false},
+		{"suffix", SliceNamingSuffix, false},
+		{"hash", SliceNamingHash, false},
+		{"Hash", "", true},
+		{"random", "", true},
+	} {
+		got, err := ParseSliceNaming(tt.in)
+		if got != tt.want || (err != nil) != tt.wantErr {
+			t.Errorf("ParseSliceNaming(%q) = %q, %v, want %q, error %t", tt.in, got, err, tt.want, tt.wantErr)
+		}
+	}
+}
+
+func TestValidateSliceSuffix(t *testing.T) {
+	for _, tt := range []struct {
+		suffix  string
+		wantErr bool
+	}{
+		{"consul", false},
+		{"eps-1", false},
+		{"", true},
+		{"Consul", true},
+		{"-eps", true},
+		{"eps.v1", true},
+	} {
+		if err := ValidateSliceSuffix(tt.suffix); (err != nil) != tt.wantErr {
+			t.Errorf("ValidateSliceSuffix(%q) = %v, want error %t", tt.suffix, err, tt.wantErr)
+		}
+	}
+}
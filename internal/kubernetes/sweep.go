@@ -0,0 +1,79 @@
+package kubernetes
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// sweepSlices deletes this instance's managed EndpointSlices whose Service
+// is neither desired nor among the managed Services in services. cleanup
+// only finds slices through their Service, so slices left behind when a
+// Service was deleted without them, e.g. after a partial failure or by hand,
+// would otherwise never be removed.
+//
+// Slices named otherwise than SliceSuffix and SliceNaming call for, as after
+// either was changed, are deleted too once the slice under the current name
+// has been written, so a Service never goes without endpoints in between.
+// With ExternalServices, services already come from the slices, so only
+// those are deleted.
+func (s *Syncer) sweepSlices(ctx context.Context, desired map[string]bool, services []string, budget *deleteBudget) error {
+	c := s.clientsFor(s.namespace)
+	selector := managedByKey + "=" + managedBy
+	if s.opts.NodeName != "" {
+		selector += "," + nodeLabelKey + "=" + s.opts.NodeName
+	}
+	list, err := s.listSlices(ctx, selector)
+	if err != nil {
+		return fmt.Errorf("listing managed endpointslices: %w", err)
+	}
+
+	known := make(map[string]bool, len(services))
+	for _, name := range services {
+		known[name] = true
+	}
+	existing := make(map[string]bool, len(list))
+	for _, eps := range list {
+		existing[eps.Name] = true
+	}
+	for _, eps := range list {
+		name := eps.Labels["kubernetes.io/service-name"]
+		if s.opts.NodeName == "" && eps.Labels[nodeLabelKey] != "" {
+			continue
+		}
+		current := s.sliceName(name, eps.AddressType)
+		orphan := !desired[name] && !known[name] && !s.opts.ExternalServices
+		if !orphan && (eps.Name == current || !existing[current]) {
+			continue
+		}
+		if !budget.take() {
+			continue
+		}
+
+		if orphan {
+			slog.InfoContext(ctx, "deleting endpointslice without a service", "endpointslice", eps.Name, "service", name)
+		} else {
+			slog.InfoContext(ctx, "deleting endpointslice under an outdated name", "endpointslice", eps.Name, "service", name, "name", current)
+		}
+		s.pace(ctx)
+		err := c.Core.DiscoveryV1().EndpointSlices(s.namespace).Delete(ctx, eps.Name, metav1.DeleteOptions{})
+		if apierrors.IsNotFound(err) {
+			err = nil
+		}
+		countOrphanDeletion(kindEndpointSlice, err)
+		if err != nil {
+			slog.ErrorContext(ctx, "failed to delete endpointslice", "endpointslice", eps.Name, "error", err)
+			continue
+		}
+		if !orphan {
+			continue
+		}
+		s.forgetFamilySlices(name)
+		delete(s.drains, name)
+		delete(s.endpointAddrs, name)
+	}
+	return nil
+}
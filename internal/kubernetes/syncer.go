@@ -6,17 +6,22 @@ import (
 	"errors"
 	"fmt"
 	"log/slog"
-	"regexp"
-	"strings"
+	"maps"
+	"slices"
+	"sync/atomic"
+	"time"
 
 	corev1 "k8s.io/api/core/v1"
 	discoveryv1 "k8s.io/api/discovery/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
 	"k8s.io/apimachinery/pkg/runtime/schema"
 	"k8s.io/apimachinery/pkg/types"
 	"k8s.io/client-go/dynamic"
 	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/tools/record"
+	"sigs.k8s.io/controller-runtime/pkg/client"
 
 	"github.com/alexieff-io/consul-sync/internal/consul"
 	"github.com/alexieff-io/consul-sync/internal/metrics"
@@ -41,9 +46,136 @@ type HTTPRouteConfig struct {
 	InternalGateway  string
 	ExternalGateway  string
 	GatewayNamespace string
-	GatewayListener  string
+	// GatewayListeners are the listener sectionNames routes attach to.
+	// Empty attaches them to every listener of the Gateway.
+	GatewayListeners []string
 	InternalTag      string
 	ExternalTag      string
+	// HostnameLayout places the gateway's tag in generated hostnames.
+	// Empty means HostnameFlat.
+	HostnameLayout HostnameLayout
+}
+
+// Options holds optional Syncer behavior.
+type Options struct {
+	// TenantClients maps a namespace to clients impersonating that tenant's
+	// ServiceAccount. Namespaces without an entry use the controller identity.
+	TenantClients map[string]Clients
+
+	// Recorder publishes Events on managed Services. Events are dropped if nil.
+	Recorder record.EventRecorder
+
+	// Hostnames selects what becomes of instances registered by hostname
+	// rather than IP. Empty means HostnamePolicyFQDN.
+	Hostnames HostnamePolicy
+
+	// Secondary marks the syncer of a secondary cluster mirroring the
+	// managed one, which leaves the gauges describing the managed cluster,
+	// such as consul_sync_synced_services, to its syncer.
+	Secondary bool
+
+	// QuietBootstrap holds back the Events of the first Sync, which catches
+	// up with the whole catalog after a start, and logs a summary of them
+	// instead. Services synced by it are logged at debug level.
+	QuietBootstrap bool
+
+	// ServiceOnly manages only the selector-less Services and leaves their
+	// EndpointSlices to another controller. Slices are neither applied nor
+	// deleted.
+	ServiceOnly bool
+
+	// ExternalServices leaves the Services to be managed outside
+	// consul-sync: they are neither applied, annotated nor deleted, and the
+	// endpoints and HTTPRoutes written refer to the existing Service of the
+	// same name. Not supported in node mode.
+	ExternalServices bool
+
+	// EndpointsMode selects EndpointSlices, legacy Endpoints, or both.
+	// Empty means EndpointSlices only.
+	EndpointsMode EndpointsMode
+
+	// SliceSuffix follows the Service name in the names of its
+	// EndpointSlices. Empty means DefaultSliceSuffix.
+	SliceSuffix string
+
+	// SliceNaming selects plain suffixed or hashed EndpointSlice names.
+	// Empty means suffixed, hashed only where too long.
+	SliceNaming SliceNaming
+
+	// MaxDeletionsPerSync caps how many orphaned Services (with their
+	// EndpointSlices) and HTTPRoutes are deleted per Sync. Zero means no limit.
+	MaxDeletionsPerSync int
+
+	// Names converts Consul service names into Kubernetes names.
+	Names NameSanitizer
+
+	// ServiceMode is the default Service shape, overridable per service with
+	// k8s-service-mode meta. Empty means headless.
+	ServiceMode ServiceMode
+
+	// LoadBalancer configures the Services of ServiceModeLoadBalancer.
+	LoadBalancer LoadBalancerConfig
+
+	// AnnotationPrefix selects the service meta copied as annotations
+	// onto the Service, EndpointSlices and HTTPRoutes of a service, see
+	// metaAnnotations. Empty copies none.
+	AnnotationPrefix string
+
+	// TagLabelPrefix, when set, labels each Service with its Consul tags,
+	// sanitized and prefixed, see tagLabels.
+	TagLabelPrefix string
+
+	// RouteLabelTags lists the Consul tags labeled on the HTTPRoutes of the
+	// services carrying them, so Gateway API policies can select the
+	// routes, see routeTagLabels.
+	RouteLabelTags []string
+
+	// DrainPeriod keeps instances that disappear from Consul in the endpoint
+	// objects as terminating for this long before removing them. Zero
+	// removes them immediately.
+	DrainPeriod time.Duration
+
+	// Adopt force-applies every object, taking over fields set by other
+	// managers, so objects created by another tool under the same names are
+	// taken over instead of failing with conflicts.
+	Adopt bool
+
+	// AdoptFieldManagers lists previous field managers removed from the
+	// objects in adoption mode, e.g. an older consul-sync field manager name.
+	AdoptFieldManagers []string
+
+	// ConflictPolicies selects, per resource kind, whether applies fail,
+	// force or skip when another field manager owns a field they set.
+	// Adopt forces every apply regardless.
+	ConflictPolicies ConflictPolicies
+
+	// FailureThreshold quarantines a service after this many consecutive
+	// failed syncs: it is skipped, keeping its existing objects, until
+	// its quarantine ends or it changes in the catalog. Zero disables
+	// quarantines.
+	FailureThreshold int
+
+	// FailureBackoff is the first quarantine of a service, doubled on each
+	// further failure up to MaxFailureBackoff.
+	FailureBackoff    time.Duration
+	MaxFailureBackoff time.Duration
+
+	// NodeName runs the syncer for a single node's services, alongside one
+	// instance per node. Each writes its own EndpointSlice per Service, and
+	// a Service and its HTTPRoutes are only deleted once no node serves it.
+	// Empty syncs the whole catalog.
+	NodeName string
+
+	// WriteRate paces the writes of each Sync and SyncService to this many
+	// per second, in bursts of up to WriteBurst, and applies the services
+	// whose endpoints changed first. Zero doesn't pace writes.
+	WriteRate  float64
+	WriteBurst int
+
+	// Namespaces lists the namespaces, besides the target namespace, that
+	// services may be placed in with k8s-namespace meta. Each is
+	// reconciled on every Sync, so services moved out of it are cleaned up.
+	Namespaces []string
 }
 
 // Syncer creates and manages Kubernetes Services and EndpointSlices.
@@ -52,106 +184,447 @@ type Syncer struct {
 	dynClient dynamic.Interface
 	namespace string
 	routeCfg  HTTPRouteConfig
+	opts      Options
+
+	// routeOverrides holds per-namespace HTTPRoute settings layered over
+	// routeCfg. It is replaced wholesale by SetRouteOverrides.
+	routeOverrides atomic.Pointer[map[string]HTTPRouteOverride]
+
+	// desired holds the Services and HTTPRoutes the last Sync called for,
+	// read by WatchDrift to tell drift from the removal of orphans.
+	desired atomic.Pointer[desiredObjects]
+
+	// serviceUIDs caches the UID of each applied Service, keyed by
+	// namespace/name, so Events can reference it.
+	serviceUIDs map[string]types.UID
+
+	// appliedHealth caches the health annotations last applied to each
+	// Service, keyed by namespace/name, so unchanged ones aren't reapplied.
+	appliedHealth map[string]string
+
+	// appliedRoutes caches the spec last applied to each HTTPRoute, keyed by
+	// name, to report the fields that change.
+	appliedRoutes map[string]map[string]interface{}
+
+	// sharedHostnames holds the gateway/hostname pairs merged from several
+	// services by the last Sync. SyncService leaves their routes alone.
+	sharedHostnames map[string]bool
+
+	// claims holds the hostnames each generated HTTPRoute routes on its
+	// Gateway, shared with the syncers of placed, so only the first route
+	// of a hostname on a Gateway is applied.
+	claims *hostnameClaims
+
+	// pacer paces writes to Options.WriteRate, shared with the syncers of
+	// placed. Nil when writes aren't paced.
+	pacer *writePacer
+
+	// events holds back the Events of the first Sync, shared with the
+	// syncers of placed.
+	events *eventBatch
+
+	// failures tracks the services failing to sync, by managed Service
+	// name, for quarantines.
+	failures map[string]*failureState
+
+	// familySlices holds, by address type, the Services with an IPv6 or
+	// FQDN EndpointSlice, so it is deleted once they have no addresses of
+	// that type left. It is loaded on first use by hasFamilySlice.
+	familySlices map[discoveryv1.AddressType]map[string]bool
+
+	// drains tracks, per managed Service name, the addresses of the last
+	// apply and the drain deadline of addresses removed since.
+	drains map[string]*drainState
+
+	// endpointAddrs holds, per managed Service name, the ready addresses of
+	// its last endpoints apply, to report those removed by failing checks.
+	endpointAddrs map[string][]string
+
+	// retained holds the orphans, by kind/name, the last sync left in place
+	// for their keepAnnotation, and retaining those of the sync in progress.
+	retained  map[string]bool
+	retaining map[string]bool
+
+	// missingParents holds the route parents found missing by the last
+	// full sync, see reportMissingParents.
+	missingParents map[gatewayParent]bool
+
+	// externalNames holds the Services of ServiceModeExternalName whose
+	// endpoints from another mode were deleted.
+	externalNames map[string]bool
+
+	// cache, if set, serves the lists of managed Services, EndpointSlices
+	// and Endpoints, see UseCache.
+	cache client.Reader
+
+	// placed holds a Syncer for each namespace of Options.Namespaces,
+	// sharing this one's clients and options, for the services placed
+	// there.
+	placed map[string]*Syncer
 }
 
 // NewSyncer creates a new Kubernetes syncer.
-func NewSyncer(client kubernetes.Interface, dynClient dynamic.Interface, namespace string, routeCfg HTTPRouteConfig) *Syncer {
+func NewSyncer(client kubernetes.Interface, dynClient dynamic.Interface, namespace string, routeCfg HTTPRouteConfig, opts Options) *Syncer {
+	s := newSyncer(client, dynClient, namespace, routeCfg, opts, newHostnameClaims(), newWritePacer(opts), &eventBatch{})
+	for _, ns := range opts.Namespaces {
+		if ns == namespace {
+			continue
+		}
+		if s.placed == nil {
+			s.placed = make(map[string]*Syncer)
+		}
+		s.placed[ns] = newSyncer(client, dynClient, ns, routeCfg, opts, s.claims, s.pacer, s.events)
+	}
+	return s
+}
+
+func newSyncer(client kubernetes.Interface, dynClient dynamic.Interface, namespace string, routeCfg HTTPRouteConfig, opts Options, claims *hostnameClaims, pacer *writePacer, events *eventBatch) *Syncer {
 	return &Syncer{
 		client:    client,
 		dynClient: dynClient,
 		namespace: namespace,
 		routeCfg:  routeCfg,
+		opts:      opts,
+		claims:    claims,
+		pacer:     pacer,
+		events:    events,
+
+		serviceUIDs:   make(map[string]types.UID),
+		appliedHealth: make(map[string]string),
+		appliedRoutes: make(map[string]map[string]interface{}),
+		drains:        make(map[string]*drainState),
+		endpointAddrs: make(map[string][]string),
+		externalNames: make(map[string]bool),
+		failures:      make(map[string]*failureState),
+		retaining:     make(map[string]bool),
 	}
 }
 
+// SyncResult summarizes the changes made by a Sync.
+type SyncResult struct {
+	Services  int // Services applied, with their endpoints unless ServiceOnly
+	Endpoints int
+	Routes    int // HTTPRoutes applied
+	Deleted   int // orphaned Services and HTTPRoutes deleted
+	Deferred  int // orphans left for a later Sync by MaxDeletionsPerSync
+	Retained  int // orphans left in place for their keep annotation
+	Skipped   int // quarantined services not synced
+	Errors    int
+}
+
+// add adds the counts of r to the result.
+func (r *SyncResult) add(o SyncResult) {
+	r.Services += o.Services
+	r.Endpoints += o.Endpoints
+	r.Routes += o.Routes
+	r.Retained += o.Retained
+	r.Skipped += o.Skipped
+	r.Errors += o.Errors
+}
+
 // Sync reconciles Kubernetes resources to match the given Consul service states.
-func (s *Syncer) Sync(ctx context.Context, services []consul.ServiceState) error {
-	desired := make(map[string]bool)
-	desiredRoutes := make(map[string]bool)
-	var totalEndpoints int
-	var routeCount int
+func (s *Syncer) Sync(ctx context.Context, services []consul.ServiceState) (SyncResult, error) {
+	var result SyncResult
+	var desired, quarantined int
 	var syncErrors []error
+	budget := &deleteBudget{remaining: s.opts.MaxDeletionsPerSync}
+	if s.events.begin(s.opts.QuietBootstrap) {
+		defer s.events.end(ctx)
+	}
+	placed := s.placeServices(ctx, MergeAliases(services))
+	s.claims.reset()
+	for _, ns := range s.syncers() {
+		res, err := ns.syncNamespace(s.withNamespace(ctx, ns), placed[ns.namespace], budget)
+		result.add(res.SyncResult)
+		desired += res.desired
+		quarantined += res.quarantined
+		if err != nil {
+			syncErrors = append(syncErrors, err)
+		}
+	}
 
-	for _, svc := range services {
-		name := sanitizeName(svc.Name)
+	if budget.deferred > 0 {
+		slog.WarnContext(ctx, "deletion limit reached, deferring remaining orphans to later syncs",
+			"limit", s.opts.MaxDeletionsPerSync, "deferred", budget.deferred)
+	}
+	if !s.opts.Secondary {
+		metrics.DeferredDeletions.Set(float64(budget.deferred))
+		metrics.RetainedOrphans.Set(float64(result.Retained))
+		if s.routeCfg.Enabled {
+			metrics.SyncedHTTPRoutes.Set(float64(result.Routes))
+			s.claims.report()
+		}
+		metrics.SyncedServices.Set(float64(desired))
+		metrics.SyncedEndpoints.Set(float64(result.Endpoints))
+		metrics.QuarantinedServices.Set(float64(quarantined))
+	}
+
+	result.Deleted = budget.used
+	result.Deferred = budget.deferred
+	return result, errors.Join(syncErrors...)
+}
+
+// namespaceResult is the part of a Sync done in one namespace.
+type namespaceResult struct {
+	SyncResult
+	desired     int // Services the catalog calls for
+	quarantined int // services in quarantine after the sync
+}
+
+// syncNamespace reconciles the Services in the syncer's namespace to match
+// services, already merged by MergeAliases, and cleans up its orphans.
+func (s *Syncer) syncNamespace(ctx context.Context, services []consul.ServiceState, budget *deleteBudget) (namespaceResult, error) {
+	var result namespaceResult
+	desired := make(map[string]bool, len(services))
+	desiredRoutes := make(map[string]bool, len(services))
+	var members []routeMember
+	created := make(map[string]bool)
+	// failed holds the first error of each synced service, nil for those
+	// that succeeded, and skipped the quarantined ones.
+	failed := make(map[string]error, len(services))
+	skipped := make(map[string]bool)
+	now := time.Now()
+	var syncErrors []error
+
+	for _, svc := range s.endpointsFirst(services) {
+		name := s.opts.Names.Sanitize(svc.Name)
 		desired[name] = true
 
-		if len(svc.Instances) == 0 {
-			slog.Warn("skipping service with no healthy instances", "service", svc.Name)
+		if s.quarantined(ctx, name, svc, now) {
+			// Its existing objects and routes are kept as they are.
+			skipped[name] = true
+			result.Skipped++
+			if s.routeCfg.Enabled && len(svc.Instances) > 0 {
+				members = append(members, s.routeMembers(ctx, svc, name, routePort(s.portsFor(ctx, svc, name, false)), false)...)
+			}
 			continue
 		}
 
-		port := int32(svc.Instances[0].Port)
-		if port < 1 || port > 65535 {
-			slog.Warn("skipping service with invalid port", "service", svc.Name, "port", port)
-			continue
+		res, err := s.syncService(ctx, svc)
+		if res.applied {
+			result.Services++
+			metrics.ServiceEndpoints.Observe(float64(res.endpoints))
 		}
-		totalEndpoints += len(svc.Instances)
-
-		if err := s.applyService(ctx, name, port); err != nil {
-			metrics.KubernetesErrors.Inc()
-			slog.Error("failed to apply service, skipping", "service", name, "error", err)
-			syncErrors = append(syncErrors, fmt.Errorf("applying service %s: %w", name, err))
-			continue
+		members = append(members, res.routes...)
+		if res.created {
+			created[name] = true
 		}
-
-		if err := s.applyEndpointSlice(ctx, name, port, svc.Instances); err != nil {
-			metrics.KubernetesErrors.Inc()
-			slog.Error("failed to apply endpointslice, skipping", "service", name, "error", err)
-			syncErrors = append(syncErrors, fmt.Errorf("applying endpointslice %s: %w", name, err))
-			continue
+		result.Endpoints += res.endpoints
+		failed[name] = err
+		if err != nil {
+			syncErrors = append(syncErrors, err)
 		}
+	}
 
-		// Create HTTPRoutes based on service tags
-		if s.routeCfg.Enabled {
-			if hasTag(svc.Tags, s.routeCfg.InternalTag) {
-				routeName := name + "-" + s.routeCfg.InternalGateway
-				desiredRoutes[routeName] = true
-				if err := s.applyHTTPRoute(ctx, name, port, s.routeCfg.InternalGateway); err != nil {
-					metrics.KubernetesErrors.Inc()
-					slog.Error("failed to apply httproute, skipping", "service", name, "gateway", s.routeCfg.InternalGateway, "error", err)
-					syncErrors = append(syncErrors, fmt.Errorf("applying httproute %s: %w", routeName, err))
-				} else {
-					routeCount++
-				}
+	// Create HTTPRoutes, merging services that share a hostname
+	if s.routeCfg.Enabled {
+		plans, conflicts := s.planRoutes(members)
+		s.reportConflicts(ctx, conflicts)
+		shared := make(map[string]bool)
+		routeCfg := s.routeConfigFor(s.namespace)
+		gateways := s.readGateways(ctx, routeCfg, plans)
+		s.reportMissingParents(ctx, routeCfg, plans, gateways)
+		plans = s.acceptedPlans(ctx, plans, gateways, true)
+		plans = s.claimedPlans(ctx, routeCfg, plans)
+		for _, plan := range plans {
+			desiredRoutes[plan.name] = true
+			if len(plan.backends()) > 1 {
+				shared[plan.gateway+"/"+plan.hostname] = true
+			}
+			if !slices.ContainsFunc(plan.backends(), func(name string) bool { return !skipped[name] }) {
+				continue
 			}
-			if hasTag(svc.Tags, s.routeCfg.ExternalTag) {
-				routeName := name + "-" + s.routeCfg.ExternalGateway
-				desiredRoutes[routeName] = true
-				if err := s.applyHTTPRoute(ctx, name, port, s.routeCfg.ExternalGateway); err != nil {
-					metrics.KubernetesErrors.Inc()
-					slog.Error("failed to apply httproute, skipping", "service", name, "gateway", s.routeCfg.ExternalGateway, "error", err)
-					syncErrors = append(syncErrors, fmt.Errorf("applying httproute %s: %w", routeName, err))
-				} else {
-					routeCount++
+			if err := s.applyHTTPRoute(ctx, routeCfg, plan); err != nil {
+				countError(kindHTTPRoute, err)
+				slog.ErrorContext(ctx, "failed to apply httproute, skipping", "service", plan.service, "gateway", plan.gateway, "error", err)
+				syncErrors = append(syncErrors, err)
+				for _, name := range plan.backends() {
+					s.abortService(ctx, name, created[name], "httproute "+plan.name, err)
+					if failed[name] == nil && !skipped[name] {
+						failed[name] = err
+					}
 				}
+			} else {
+				result.Routes++
 			}
 		}
+		s.sharedHostnames = shared
+	}
 
-		slog.Info("synced service", "service", name, "endpoints", len(svc.Instances))
+	for _, svc := range services {
+		name := s.opts.Names.Sanitize(svc.Name)
+		if !skipped[name] {
+			s.recordSync(ctx, name, svc, failed[name], now)
+		}
 	}
+	result.quarantined = s.pruneFailures(desired, now)
+	s.desired.Store(&desiredObjects{services: desired, routes: desiredRoutes})
 
 	// Cleanup orphaned resources
-	if err := s.cleanup(ctx, desired); err != nil {
-		metrics.KubernetesErrors.Inc()
+	served, err := s.cleanup(ctx, desired, budget)
+	if err != nil {
+		countError(kindService, err)
 		syncErrors = append(syncErrors, fmt.Errorf("cleaning up orphans: %w", err))
 	}
 
 	if s.routeCfg.Enabled {
-		if err := s.cleanupHTTPRoutes(ctx, desiredRoutes); err != nil {
-			metrics.KubernetesErrors.Inc()
+		if err := s.cleanupHTTPRoutes(ctx, desiredRoutes, served, budget); err != nil {
+			countError(kindHTTPRoute, err)
 			syncErrors = append(syncErrors, fmt.Errorf("cleaning up orphan httproutes: %w", err))
 		}
-		metrics.SyncedHTTPRoutes.Set(float64(routeCount))
 	}
+	result.Retained = s.endRetained()
 
-	metrics.SyncedServices.Set(float64(len(desired)))
-	metrics.SyncedEndpoints.Set(float64(totalEndpoints))
+	result.desired = len(desired)
+	result.Errors = len(syncErrors)
+	return result, errors.Join(syncErrors...)
+}
 
-	return errors.Join(syncErrors...)
+// SyncService applies the resources for a single Consul service without
+// touching anything else, so one service can be refreshed between full syncs.
+// Orphans are only cleaned up by Sync, including the objects of a service
+// moved to another namespace. An aliased service must be passed already
+// merged with the rest of its alias group by MergeAliases.
+func (s *Syncer) SyncService(ctx context.Context, svc consul.ServiceState) error {
+	ns := s.in(s.namespaceFor(ctx, svc))
+	return ns.syncOne(s.withNamespace(ctx, ns), svc)
 }
 
-func (s *Syncer) applyService(ctx context.Context, name string, port int32) error {
+// syncOne is SyncService within the syncer's namespace.
+func (s *Syncer) syncOne(ctx context.Context, svc consul.ServiceState) error {
+	name := s.opts.Names.Sanitize(svc.Name)
+	now := time.Now()
+	if s.quarantined(ctx, name, svc, now) {
+		slog.DebugContext(ctx, "skipping quarantined service", "service", name)
+		return nil
+	}
+	res, err := s.syncService(ctx, svc)
+	if err != nil || !s.routeCfg.Enabled {
+		s.recordSync(ctx, name, svc, err, now)
+		return err
+	}
+
+	var routeErrors []error
+	routeCfg := s.routeConfigFor(s.namespace)
+	plans, _ := s.planRoutes(res.routes)
+	plans = s.acceptedPlans(ctx, plans, s.readGateways(ctx, routeCfg, plans), true)
+	plans = s.claimedPlans(ctx, routeCfg, plans)
+	for _, plan := range plans {
+		// Routes shared with other services are left to Sync, which knows
+		// all of them.
+		if s.sharedHostnames[plan.gateway+"/"+plan.hostname] {
+			continue
+		}
+		if err := s.applyHTTPRoute(ctx, routeCfg, plan); err != nil {
+			countError(kindHTTPRoute, err)
+			slog.ErrorContext(ctx, "failed to apply httproute, skipping", "service", plan.service, "gateway", plan.gateway, "error", err)
+			routeErrors = append(routeErrors, err)
+			s.abortService(ctx, plan.service, res.created, "httproute "+plan.name, err)
+		}
+	}
+	err = errors.Join(routeErrors...)
+	s.recordSync(ctx, name, svc, err, now)
+	return err
+}
+
+// serviceResult records what syncService applied for one service.
+type serviceResult struct {
+	routes    []routeMember // HTTPRoutes the service asks for
+	applied   bool          // the Service and its endpoints were applied
+	created   bool          // the Service didn't exist before
+	endpoints int
+}
+
+func (s *Syncer) syncService(ctx context.Context, svc consul.ServiceState) (serviceResult, error) {
+	var res serviceResult
+	name := s.opts.Names.Sanitize(svc.Name)
+
+	if len(svc.Instances) == 0 {
+		slog.WarnContext(ctx, "skipping service with no healthy instances", "service", svc.Name)
+		if err := s.applyHealthAnnotations(ctx, name, svc, false); err != nil {
+			countError(kindService, err)
+			slog.ErrorContext(ctx, "failed to annotate service health", "service", name, "error", err)
+		}
+		return res, nil
+	}
+
+	ports := s.portsFor(ctx, svc, name, true)
+	if !validPorts(ports) {
+		slog.WarnContext(ctx, "skipping service with invalid port", "service", svc.Name, "port", svc.Instances[0].Port)
+		return res, nil
+	}
+	var mode ServiceMode
+	if !s.opts.ExternalServices {
+		mode = s.serviceModeFor(ctx, svc)
+	}
+	// An ExternalName Service has no endpoints.
+	withEndpoints := !s.opts.ServiceOnly && mode != ServiceModeExternalName
+	var instances []consul.ServiceInstance
+	var draining []string
+	if withEndpoints {
+		instances = s.endpointInstances(ctx, svc, name, true)
+		res.endpoints = len(instances)
+		draining = s.drainingAddresses(name, instances, time.Now())
+	}
+
+	annotations := s.metaAnnotations(ctx, svc, true)
+
+	if !s.opts.ExternalServices {
+		res.created = !s.serviceExists(ctx, name)
+		labels := s.tagLabels(name, svc.Tags)
+		if err := s.applyService(ctx, name, ports, mode, s.loadBalancerClassFor(svc), instanceAddresses(svc.Instances), annotations, labels); err != nil {
+			countError(kindService, err)
+			slog.ErrorContext(ctx, "failed to apply service, skipping", "service", name, "error", err)
+			return res, fmt.Errorf("applying service %s: %w", name, err)
+		}
+		if err := s.applyHealthAnnotations(ctx, name, svc, true); err != nil {
+			countError(kindService, err)
+			slog.ErrorContext(ctx, "failed to annotate service health", "service", name, "error", err)
+		}
+	}
+	if mode == ServiceModeExternalName {
+		if err := s.dropEndpoints(ctx, name); err != nil {
+			countError(kindEndpointSlice, err)
+			slog.ErrorContext(ctx, "failed to delete endpoints of externalname service", "service", name, "error", err)
+		}
+	} else {
+		delete(s.externalNames, name)
+	}
+
+	if withEndpoints && s.opts.EndpointsMode.slices() {
+		if err := s.applyEndpointSlice(ctx, name, ports, instances, draining, annotations); err != nil {
+			countError(kindEndpointSlice, err)
+			slog.ErrorContext(ctx, "failed to apply endpointslice, skipping", "service", name, "error", err)
+			s.abortService(ctx, name, res.created, "endpointslice", err)
+			return res, fmt.Errorf("applying endpointslice %s: %w", name, err)
+		}
+	}
+	if withEndpoints && s.opts.EndpointsMode.endpoints() {
+		if err := s.applyEndpoints(ctx, name, ports, instances, draining); err != nil {
+			countError(kindEndpoints, err)
+			slog.ErrorContext(ctx, "failed to apply endpoints, skipping", "service", name, "error", err)
+			s.abortService(ctx, name, res.created, "endpoints", err)
+			return res, fmt.Errorf("applying endpoints %s: %w", name, err)
+		}
+	}
+	if withEndpoints {
+		s.reportRemovedEndpoints(name, svc)
+	}
+	res.applied = true
+	if s.routeCfg.Enabled {
+		res.routes = s.routeMembers(ctx, svc, name, routePort(ports), true)
+	}
+
+	level := slog.LevelInfo
+	if s.events.holding() {
+		level = slog.LevelDebug
+	}
+	slog.Log(ctx, level, "synced service", "service", name, "endpoints", len(svc.Instances), "draining", len(draining))
+	return res, nil
+}
+
+func (s *Syncer) applyService(ctx context.Context, name string, ports []namedPort, mode ServiceMode, lbClass string, addresses []string, annotations, labels map[string]string) error {
+	c := s.clientsFor(s.namespace)
 	svc := &corev1.Service{
 		TypeMeta: metav1.TypeMeta{
 			APIVersion: "v1",
@@ -166,45 +639,128 @@ func (s *Syncer) applyService(ctx context.Context, name string, port int32) erro
 			},
 		},
 		Spec: corev1.ServiceSpec{
-			Type:      corev1.ServiceTypeClusterIP,
-			ClusterIP: "None",
-			Ports: []corev1.ServicePort{
-				{
-					Name:     "http",
-					Port:     port,
-					Protocol: corev1.ProtocolTCP,
-				},
-			},
+			Ports: serviceSpecPorts(ports),
 		},
 	}
 
+	mode.applyTo(svc, addresses, s.opts.LoadBalancer, lbClass)
+	for k, v := range labels {
+		// Tags never replace the labels consul-sync selects its objects by.
+		if _, ok := svc.Labels[k]; !ok {
+			svc.Labels[k] = v
+		}
+	}
+	if len(annotations) > 0 {
+		// The service's own annotations win over LOADBALANCER_ANNOTATIONS.
+		merged := make(map[string]string, len(svc.Annotations)+len(annotations))
+		maps.Copy(merged, svc.Annotations)
+		maps.Copy(merged, annotations)
+		svc.Annotations = merged
+	}
+
 	data, err := json.Marshal(svc)
 	if err != nil {
 		return fmt.Errorf("marshaling service: %w", err)
 	}
 
-	_, err = s.client.CoreV1().Services(s.namespace).Patch(
+	s.pace(ctx)
+	applied, err := c.Core.CoreV1().Services(s.namespace).Patch(
 		ctx, name, types.ApplyPatchType, data,
-		metav1.PatchOptions{FieldManager: fieldManager},
+		s.applyOptions(kindService),
 	)
-	return err
+	if apierrors.IsInvalid(err) {
+		// clusterIP and loadBalancerClass are immutable, so switching to
+		// or from headless, or to another class, needs the Service
+		// recreated.
+		slog.InfoContext(ctx, "recreating service to change its mode", "service", name, "mode", mode, "error", err)
+		s.pace(ctx)
+		if err := c.Core.CoreV1().Services(s.namespace).Delete(ctx, name, metav1.DeleteOptions{}); err != nil && !apierrors.IsNotFound(err) {
+			return fmt.Errorf("deleting service to change its mode: %w", err)
+		}
+		delete(s.appliedHealth, s.namespace+"/"+name)
+		s.pace(ctx)
+		applied, err = c.Core.CoreV1().Services(s.namespace).Patch(
+			ctx, name, types.ApplyPatchType, data,
+			s.applyOptions(kindService),
+		)
+	}
+	if s.skipConflict(ctx, kindService, name, name, err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	s.serviceUIDs[s.namespace+"/"+name] = applied.UID
+	return s.releaseManagers(ctx, applied, svc.TypeMeta, func(pt types.PatchType, data []byte, opts metav1.PatchOptions) (metav1.Object, error) {
+		return c.Core.CoreV1().Services(s.namespace).Patch(ctx, name, pt, data, opts)
+	})
 }
 
-func (s *Syncer) applyEndpointSlice(ctx context.Context, name string, port int32, instances []consul.ServiceInstance) error {
-	sliceName := name + "-consul"
-	protocol := corev1.ProtocolTCP
-	portName := "http"
+// applyEndpointSlice writes the EndpointSlices of the Service name: an IPv4
+// slice, and an IPv6 or FQDN one while the service has addresses of that
+// type.
+func (s *Syncer) applyEndpointSlice(ctx context.Context, name string, ports []namedPort, instances []consul.ServiceInstance, draining []string, annotations map[string]string) error {
+	ready := byFamily(instanceAddresses(instances))
+	terminating := byFamily(draining)
+
+	for _, family := range addressFamilies {
+		if family != discoveryv1.AddressTypeIPv4 && len(ready[family])+len(terminating[family]) == 0 {
+			// The IPv4 slice is always written, even empty, but the
+			// others only exist while needed.
+			exists, err := s.hasFamilySlice(ctx, name, family)
+			if err != nil {
+				return err
+			}
+			if exists {
+				c := s.clientsFor(s.namespace)
+				sliceName := s.sliceName(name, family)
+				s.pace(ctx)
+				err := c.Core.DiscoveryV1().EndpointSlices(s.namespace).Delete(ctx, sliceName, metav1.DeleteOptions{})
+				if err != nil && !apierrors.IsNotFound(err) {
+					return fmt.Errorf("deleting endpointslice %s: %w", sliceName, err)
+				}
+				delete(s.familySlices[family], name)
+			}
+			continue
+		}
+		if err := s.applyFamilySlice(ctx, name, ports, family, ready[family], terminating[family], annotations); err != nil {
+			return err
+		}
+		if family != discoveryv1.AddressTypeIPv4 {
+			s.markFamilySlice(name, family)
+		}
+	}
+	return nil
+}
+
+// applyFamilySlice writes the EndpointSlice of the Service name for family,
+// with the given ready and draining addresses.
+func (s *Syncer) applyFamilySlice(ctx context.Context, name string, ports []namedPort, family discoveryv1.AddressType, addresses, draining []string, annotations map[string]string) error {
+	c := s.clientsFor(s.namespace)
+	sliceName := s.sliceName(name, family)
 	ready := true
 
-	var endpoints []discoveryv1.Endpoint
-	for _, inst := range instances {
+	notReady, terminating := false, true
+
+	endpoints := make([]discoveryv1.Endpoint, 0, len(addresses)+len(draining))
+	for _, addr := range addresses {
 		endpoints = append(endpoints, discoveryv1.Endpoint{
-			Addresses: []string{inst.Address},
+			Addresses: []string{addr},
 			Conditions: discoveryv1.EndpointConditions{
 				Ready: &ready,
 			},
 		})
 	}
+	for _, addr := range draining {
+		endpoints = append(endpoints, discoveryv1.Endpoint{
+			Addresses: []string{addr},
+			Conditions: discoveryv1.EndpointConditions{
+				Ready:       &notReady,
+				Serving:     &notReady,
+				Terminating: &terminating,
+			},
+		})
+	}
 
 	eps := &discoveryv1.EndpointSlice{
 		TypeMeta: metav1.TypeMeta{
@@ -212,23 +768,14 @@ func (s *Syncer) applyEndpointSlice(ctx context.Context, name string, port int32
 			Kind:       "EndpointSlice",
 		},
 		ObjectMeta: metav1.ObjectMeta{
-			Name:      sliceName,
-			Namespace: s.namespace,
-			Labels: map[string]string{
-				"kubernetes.io/service-name":             name,
-				"endpointslice.kubernetes.io/managed-by": managedBy,
-				managedByKey:                             managedBy,
-			},
+			Name:        sliceName,
+			Namespace:   s.namespace,
+			Labels:      s.sliceLabels(name),
+			Annotations: annotations,
 		},
-		AddressType: discoveryv1.AddressTypeIPv4,
+		AddressType: family,
 		Endpoints:   endpoints,
-		Ports: []discoveryv1.EndpointPort{
-			{
-				Name:     &portName,
-				Port:     &port,
-				Protocol: &protocol,
-			},
-		},
+		Ports:       slicePorts(ports),
 	}
 
 	data, err := json.Marshal(eps)
@@ -236,16 +783,29 @@ func (s *Syncer) applyEndpointSlice(ctx context.Context, name string, port int32
 		return fmt.Errorf("marshaling endpointslice: %w", err)
 	}
 
-	_, err = s.client.DiscoveryV1().EndpointSlices(s.namespace).Patch(
+	s.pace(ctx)
+	applied, err := c.Core.DiscoveryV1().EndpointSlices(s.namespace).Patch(
 		ctx, sliceName, types.ApplyPatchType, data,
-		metav1.PatchOptions{FieldManager: fieldManager},
+		s.applyOptions(kindEndpointSlice),
 	)
-	return err
+	if s.skipConflict(ctx, kindEndpointSlice, sliceName, name, err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	return s.releaseManagers(ctx, applied, eps.TypeMeta, func(pt types.PatchType, data []byte, opts metav1.PatchOptions) (metav1.Object, error) {
+		return c.Core.DiscoveryV1().EndpointSlices(s.namespace).Patch(ctx, sliceName, pt, data, opts)
+	})
 }
 
-func (s *Syncer) applyHTTPRoute(ctx context.Context, serviceName string, port int32, gatewayName string) error {
-	routeName := serviceName + "-" + gatewayName
-	hostname := serviceName + "." + s.routeCfg.DomainSuffix
+func (s *Syncer) applyHTTPRoute(ctx context.Context, routeCfg HTTPRouteConfig, plan routePlan) error {
+	c := s.clientsFor(s.namespace)
+	routeName := plan.name
+	rules := make([]interface{}, 0, len(plan.rules))
+	for _, rule := range plan.rules {
+		rules = append(rules, rule.object())
+	}
 
 	route := &unstructured.Unstructured{
 		Object: map[string]interface{}{
@@ -254,55 +814,87 @@ func (s *Syncer) applyHTTPRoute(ctx context.Context, serviceName string, port in
 			"metadata": map[string]interface{}{
 				"name":      routeName,
 				"namespace": s.namespace,
-				"labels": map[string]interface{}{
-					managedByKey:             managedBy,
-					"app.kubernetes.io/name": serviceName,
-				},
+				"labels":    routeObjectLabels(plan),
 			},
 			"spec": map[string]interface{}{
-				"parentRefs": []interface{}{
-					map[string]interface{}{
-						"name":        gatewayName,
-						"namespace":   s.routeCfg.GatewayNamespace,
-						"sectionName": s.routeCfg.GatewayListener,
-					},
-				},
+				"parentRefs": parentRefs(routeCfg, plan),
 				"hostnames": []interface{}{
-					hostname,
-				},
-				"rules": []interface{}{
-					map[string]interface{}{
-						"backendRefs": []interface{}{
-							map[string]interface{}{
-								"name": serviceName,
-								"port": int64(port),
-							},
-						},
-					},
+					plan.hostname,
 				},
+				"rules": rules,
 			},
 		},
 	}
 
+	if len(plan.annotations) > 0 {
+		route.SetAnnotations(plan.annotations)
+	}
+
 	data, err := json.Marshal(route)
 	if err != nil {
 		return fmt.Errorf("marshaling httproute: %w", err)
 	}
 
-	_, err = s.dynClient.Resource(httpRouteGVR).Namespace(s.namespace).Patch(
+	old, wasApplied := s.previousRouteSpec(ctx, routeName)
+	s.pace(ctx)
+	applied, err := c.Dynamic.Resource(httpRouteGVR).Namespace(s.namespace).Patch(
 		ctx, routeName, types.ApplyPatchType, data,
-		metav1.PatchOptions{FieldManager: fieldManager},
+		s.applyOptions(kindHTTPRoute),
 	)
+	if s.skipConflict(ctx, kindHTTPRoute, routeName, plan.service, err) {
+		return nil
+	}
 	if err != nil {
 		return fmt.Errorf("applying httproute %s: %w", routeName, err)
 	}
+	typeMeta := metav1.TypeMeta{APIVersion: route.GetAPIVersion(), Kind: route.GetKind()}
+	if err := s.releaseManagers(ctx, applied, typeMeta, func(pt types.PatchType, data []byte, opts metav1.PatchOptions) (metav1.Object, error) {
+		return c.Dynamic.Resource(httpRouteGVR).Namespace(s.namespace).Patch(ctx, routeName, pt, data, opts)
+	}); err != nil {
+		return fmt.Errorf("adopting httproute %s: %w", routeName, err)
+	}
 
-	slog.Info("applied httproute", "route", routeName, "gateway", gatewayName, "hostname", hostname)
+	slog.InfoContext(ctx, "applied httproute", "route", routeName, "gateway", plan.gateway, "hostname", plan.hostname, "rules", len(plan.rules))
+	s.reportRouteChanges(ctx, routeName, plan, old, route.Object["spec"].(map[string]interface{}), wasApplied)
 	return nil
 }
 
-func (s *Syncer) cleanupHTTPRoutes(ctx context.Context, desiredRoutes map[string]bool) error {
-	routes, err := s.dynClient.Resource(httpRouteGVR).Namespace(s.namespace).List(ctx, metav1.ListOptions{
+// deleteBudget caps the number of orphans removed within a single Sync, so a
+// mass deregistration in Consul is spread over several reconciles instead of
+// hitting the API server and gateways all at once.
+type deleteBudget struct {
+	remaining int // non-positive means unlimited
+	used      int
+	deferred  int
+}
+
+// take reports whether another deletion may proceed, counting it if so.
+func (b *deleteBudget) take() bool {
+	if b.remaining > 0 && b.used >= b.remaining {
+		b.deferred++
+		return false
+	}
+	b.used++
+	return true
+}
+
+// countOrphanDeletion counts the deletion of an orphaned object of kind,
+// failed if err is set.
+func countOrphanDeletion(kind string, err error) {
+	outcome := "deleted"
+	if err != nil {
+		outcome = "failed"
+	}
+	metrics.OrphanDeletions.WithLabelValues(kind, outcome).Inc()
+}
+
+// cleanupHTTPRoutes deletes the managed HTTPRoutes not in desiredRoutes,
+// except those annotated to be kept. In node mode, routes of the Services in
+// served are kept, since this node can't tell whether the nodes serving them
+// still want their routes.
+func (s *Syncer) cleanupHTTPRoutes(ctx context.Context, desiredRoutes, served map[string]bool, budget *deleteBudget) error {
+	c := s.clientsFor(s.namespace)
+	routes, err := c.Dynamic.Resource(httpRouteGVR).Namespace(s.namespace).List(ctx, metav1.ListOptions{
 		LabelSelector: managedByKey + "=" + managedBy,
 	})
 	if err != nil {
@@ -310,49 +902,204 @@ func (s *Syncer) cleanupHTTPRoutes(ctx context.Context, desiredRoutes map[string
 	}
 
 	for _, route := range routes.Items {
-		if desiredRoutes[route.GetName()] {
+		if desiredRoutes[route.GetName()] || served[route.GetLabels()["app.kubernetes.io/name"]] {
+			continue
+		}
+		if kept(&route) {
+			s.retain(ctx, kindHTTPRoute, route.GetName(), route.GetLabels()["app.kubernetes.io/name"])
+			continue
+		}
+		if !budget.take() {
 			continue
 		}
 
-		slog.Info("deleting orphaned httproute", "route", route.GetName())
-		if err := s.dynClient.Resource(httpRouteGVR).Namespace(s.namespace).Delete(ctx, route.GetName(), metav1.DeleteOptions{}); err != nil {
-			slog.Error("failed to delete httproute", "name", route.GetName(), "error", err)
+		slog.InfoContext(ctx, "deleting orphaned httproute", "route", route.GetName())
+		s.pace(ctx)
+		err := c.Dynamic.Resource(httpRouteGVR).Namespace(s.namespace).Delete(ctx, route.GetName(), metav1.DeleteOptions{})
+		delete(s.appliedRoutes, route.GetName())
+		countOrphanDeletion(kindHTTPRoute, err)
+		if err != nil {
+			slog.ErrorContext(ctx, "failed to delete httproute", "name", route.GetName(), "error", err)
 		}
 	}
 
 	return nil
 }
 
-func (s *Syncer) cleanup(ctx context.Context, desired map[string]bool) error {
-	svcs, err := s.client.CoreV1().Services(s.namespace).List(ctx, metav1.ListOptions{
-		LabelSelector: managedByKey + "=" + managedBy,
-	})
+// cleanup deletes the managed Services not in desired, along with their
+// endpoints, except those annotated to be kept. In node mode it returns the
+// Services still served by this or another node, whose HTTPRoutes must be
+// kept.
+func (s *Syncer) cleanup(ctx context.Context, desired map[string]bool, budget *deleteBudget) (map[string]bool, error) {
+	c := s.clientsFor(s.namespace)
+	names, keep, err := s.managedNames(ctx)
 	if err != nil {
-		return fmt.Errorf("listing managed services: %w", err)
+		return nil, err
 	}
 
-	for _, svc := range svcs.Items {
-		if desired[svc.Name] {
+	var served map[string]bool
+	var nodes map[string]map[string]bool
+	if s.opts.NodeName != "" {
+		served = make(map[string]bool, len(desired))
+		for name := range desired {
+			served[name] = true
+		}
+		if nodes, err = s.sliceNodes(ctx); err != nil {
+			return nil, err
+		}
+	}
+
+	for _, name := range names {
+		if desired[name] {
+			continue
+		}
+		if keep[name] != "" {
+			s.retain(ctx, keep[name], name, name)
+			continue
+		}
+		if s.opts.NodeName != "" {
+			s.cleanupNodeSlice(ctx, name, nodes[name], budget)
+			if s.servedElsewhere(nodes[name]) {
+				served[name] = true
+				continue
+			}
+		}
+		if !budget.take() {
 			continue
 		}
 
-		slog.Info("deleting orphaned service", "service", svc.Name)
+		slog.InfoContext(ctx, "deleting orphaned service", "service", name)
 
-		// Delete the EndpointSlice first
-		sliceName := svc.Name + "-consul"
-		err := s.client.DiscoveryV1().EndpointSlices(s.namespace).Delete(ctx, sliceName, metav1.DeleteOptions{})
+		// Delete the EndpointSlice and Endpoints first
+		if !s.opts.ServiceOnly && s.opts.EndpointsMode.slices() && s.opts.NodeName == "" {
+			err := s.deleteSlices(ctx, name)
+			countOrphanDeletion(kindEndpointSlice, err)
+			if err != nil {
+				slog.ErrorContext(ctx, "failed to delete endpointslices", "service", name, "error", err)
+			}
+		}
+		if !s.opts.ServiceOnly && s.opts.EndpointsMode.endpoints() {
+			err := s.deleteEndpoints(ctx, name)
+			countOrphanDeletion(kindEndpoints, err)
+			if err != nil {
+				slog.ErrorContext(ctx, "failed to delete endpoints", "name", name, "error", err)
+			}
+		}
+		delete(s.drains, name)
+		delete(s.endpointAddrs, name)
+		if s.opts.ExternalServices {
+			continue
+		}
+
+		// Delete the Service. In node mode another node may have deleted it
+		// first.
+		s.pace(ctx)
+		err := c.Core.CoreV1().Services(s.namespace).Delete(ctx, name, metav1.DeleteOptions{})
+		if apierrors.IsNotFound(err) {
+			err = nil
+		}
+		countOrphanDeletion(kindService, err)
 		if err != nil {
-			slog.Error("failed to delete endpointslice", "name", sliceName, "error", err)
+			return nil, fmt.Errorf("deleting service %s: %w", name, err)
 		}
+		delete(s.serviceUIDs, s.namespace+"/"+name)
+		delete(s.appliedHealth, s.namespace+"/"+name)
+	}
 
-		// Delete the Service
-		err = s.client.CoreV1().Services(s.namespace).Delete(ctx, svc.Name, metav1.DeleteOptions{})
+	if !s.opts.ServiceOnly && s.opts.EndpointsMode.slices() {
+		if err := s.sweepSlices(ctx, desired, names, budget); err != nil {
+			return nil, err
+		}
+	}
+
+	return served, nil
+}
+
+// managedNames returns the names of the managed Services, or with
+// ExternalServices, of the Services that have managed endpoints. keep maps
+// the names annotated to be kept to the kind of the annotated object.
+func (s *Syncer) managedNames(ctx context.Context) (names []string, keep map[string]string, err error) {
+	selector := managedByKey + "=" + managedBy
+	keep = make(map[string]string)
+	if !s.opts.ExternalServices {
+		svcs, err := s.listServices(ctx, selector)
 		if err != nil {
-			return fmt.Errorf("deleting service %s: %w", svc.Name, err)
+			return nil, nil, fmt.Errorf("listing managed services: %w", err)
+		}
+		for _, svc := range svcs {
+			names = append(names, svc.Name)
+			if kept(&svc) {
+				keep[svc.Name] = kindService
+			}
 		}
+		return names, keep, nil
 	}
 
-	return nil
+	if !s.opts.ServiceOnly && s.opts.EndpointsMode.slices() {
+		list, err := s.listSlices(ctx, selector)
+		if err != nil {
+			return nil, nil, fmt.Errorf("listing managed endpointslices: %w", err)
+		}
+		for _, eps := range list {
+			name := eps.Labels["kubernetes.io/service-name"]
+			names = append(names, name)
+			if kept(&eps) {
+				keep[name] = kindEndpointSlice
+			}
+		}
+	}
+	if !s.opts.ServiceOnly && s.opts.EndpointsMode.endpoints() {
+		list, err := s.listEndpoints(ctx, selector)
+		if err != nil {
+			return nil, nil, fmt.Errorf("listing managed endpoints: %w", err)
+		}
+		for _, ep := range list {
+			names = append(names, ep.Name)
+			if kept(&ep) && keep[ep.Name] == "" {
+				keep[ep.Name] = kindEndpoints
+			}
+		}
+	}
+	slices.Sort(names)
+	return slices.Compact(names), keep, nil
+}
+
+// cleanupNodeSlice deletes this node's EndpointSlice for a Service it no
+// longer serves. nodes are the nodes with a slice for the Service.
+func (s *Syncer) cleanupNodeSlice(ctx context.Context, name string, nodes map[string]bool, budget *deleteBudget) {
+	if s.opts.ServiceOnly || !nodes[s.opts.NodeName] || !budget.take() {
+		return
+	}
+
+	slog.InfoContext(ctx, "deleting endpointslices of service no longer on this node", "service", name, "node", s.opts.NodeName)
+	err := s.deleteSlices(ctx, name)
+	countOrphanDeletion(kindEndpointSlice, err)
+	if err != nil {
+		slog.ErrorContext(ctx, "failed to delete endpointslices", "service", name, "error", err)
+	}
+	delete(nodes, s.opts.NodeName)
+	delete(s.drains, name)
+	delete(s.endpointAddrs, name)
+}
+
+// routeGateways returns the gateways a service should get an HTTPRoute on,
+// based on its Consul tags.
+func routeGateways(cfg HTTPRouteConfig, tags []string) []routeGateway {
+	var gateways []routeGateway
+	if hasTag(tags, cfg.InternalTag) {
+		gateways = append(gateways, routeGateway{name: cfg.InternalGateway, tag: cfg.InternalTag})
+	}
+	if hasTag(tags, cfg.ExternalTag) {
+		gateways = append(gateways, routeGateway{name: cfg.ExternalGateway, tag: cfg.ExternalTag})
+	}
+	return gateways
+}
+
+// routeGateway is a gateway a service is routed through, with the tag that
+// selected it.
+type routeGateway struct {
+	name string
+	tag  string
 }
 
 func hasTag(tags []string, target string) bool {
@@ -363,17 +1110,3 @@ func hasTag(tags []string, target string) bool {
 	}
 	return false
 }
-
-var invalidChars = regexp.MustCompile(`[^a-z0-9-]`)
-
-// sanitizeName converts a Consul service name into a valid Kubernetes name.
-func sanitizeName(name string) string {
-	name = strings.ToLower(name)
-	name = strings.ReplaceAll(name, "_", "-")
-	name = invalidChars.ReplaceAllString(name, "-")
-	name = strings.Trim(name, "-")
-	if len(name) > 63 {
-		name = name[:63]
-	}
-	return name
-}
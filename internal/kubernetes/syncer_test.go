@@ -0,0 +1,188 @@
+package kubernetes_test
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"testing"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	discoveryv1 "k8s.io/api/discovery/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/kubernetes/fake"
+	k8stesting "k8s.io/client-go/testing"
+
+	"github.com/alexieff-io/consul-sync/consulsynctest"
+	"github.com/alexieff-io/consul-sync/internal/consul"
+	k8s "github.com/alexieff-io/consul-sync/internal/kubernetes"
+)
+
+const namespace = "network"
+
+var managedLabels = map[string]string{"app.kubernetes.io/managed-by": "consul-sync"}
+
+func service(name string, addrs ...string) consul.ServiceState {
+	st := consul.ServiceState{Name: name, Tags: []string{"kubernetes"}}
+	for _, addr := range addrs {
+		st.Instances = append(st.Instances, consul.ServiceInstance{ServiceName: name, Address: addr, Port: 80, ID: name + "-" + addr})
+	}
+	return st
+}
+
+// orphan returns a managed Service and EndpointSlice of a service no longer
+// in Consul.
+func orphan(name string) []runtime.Object {
+	return []runtime.Object{
+		&corev1.Service{ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: namespace, Labels: managedLabels}},
+		&discoveryv1.EndpointSlice{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      name + "-" + k8s.DefaultSliceSuffix,
+				Namespace: namespace,
+				Labels: map[string]string{
+					"app.kubernetes.io/managed-by": "consul-sync",
+					"kubernetes.io/service-name":   name,
+				},
+			},
+			AddressType: discoveryv1.AddressTypeIPv4,
+		},
+	}
+}
+
+func newSyncer(t *testing.T, opts k8s.Options, objects ...runtime.Object) (*k8s.Syncer, *fake.Clientset) {
+	t.Helper()
+	client, dynClient := consulsynctest.NewFakeClients(objects...)
+	return k8s.NewSyncer(client, dynClient, namespace, k8s.HTTPRouteConfig{}, opts), client
+}
+
+func serviceNames(t *testing.T, client *fake.Clientset) []string {
+	t.Helper()
+	list, err := client.CoreV1().Services(namespace).List(context.Background(), metav1.ListOptions{})
+	if err != nil {
+		t.Fatalf("listing services: %v", err)
+	}
+	var names []string
+	for _, svc := range list.Items {
+		names = append(names, svc.Name)
+	}
+	slices.Sort(names)
+	return names
+}
+
+func sliceNames(t *testing.T, client *fake.Clientset) []string {
+	t.Helper()
+	list, err := client.DiscoveryV1().EndpointSlices(namespace).List(context.Background(), metav1.ListOptions{})
+	if err != nil {
+		t.Fatalf("listing endpointslices: %v", err)
+	}
+	var names []string
+	for _, eps := range list.Items {
+		names = append(names, eps.Labels["kubernetes.io/service-name"])
+	}
+	slices.Sort(names)
+	return names
+}
+
+func TestSyncDeletesOrphans(t *testing.T) {
+	s, client := newSyncer(t, k8s.Options{}, orphan("old")...)
+
+	result, err := s.Sync(context.Background(), []consul.ServiceState{service("web", "10.0.0.1")})
+	if err != nil {
+		t.Fatalf("Sync: %v", err)
+	}
+	if got := serviceNames(t, client); !slices.Equal(got, []string{"web"}) {
+		t.Errorf("services = %v, want [web]", got)
+	}
+	if got := sliceNames(t, client); !slices.Equal(got, []string{"web"}) {
+		t.Errorf("endpointslices of services = %v, want [web]", got)
+	}
+	if result.Deleted != 1 {
+		t.Errorf("deleted = %d, want 1", result.Deleted)
+	}
+	// old had no IPv6 or FQDN slice, so none was asked to be deleted.
+	deletes := 0
+	for _, action := range client.Actions() {
+		if action.Matches("delete", "endpointslices") {
+			deletes++
+		}
+	}
+	if deletes != 1 {
+		t.Errorf("endpointslice deletes = %d, want 1", deletes)
+	}
+}
+
+func TestSyncCapsDeletions(t *testing.T) {
+	var objects []runtime.Object
+	for _, name := range []string{"old-a", "old-b", "old-c"} {
+		objects = append(objects, orphan(name)...)
+	}
+	s, client := newSyncer(t, k8s.Options{MaxDeletionsPerSync: 1}, objects...)
+	states := []consul.ServiceState{service("web", "10.0.0.1")}
+
+	for i, want := range []struct{ deleted, deferred, left int }{
+		{1, 2, 2},
+		{1, 1, 1},
+		{1, 0, 0},
+		{0, 0, 0},
+	} {
+		result, err := s.Sync(context.Background(), states)
+		if err != nil {
+			t.Fatalf("Sync %d: %v", i+1, err)
+		}
+		if result.Deleted != want.deleted || result.Deferred != want.deferred {
+			t.Errorf("Sync %d deleted %d and deferred %d, want %d and %d", i+1, result.Deleted, result.Deferred, want.deleted, want.deferred)
+		}
+		if left := len(serviceNames(t, client)) - 1; left != want.left {
+			t.Errorf("Sync %d left %d orphans, want %d", i+1, left, want.left)
+		}
+	}
+}
+
+func TestSyncQuarantinesFailingService(t *testing.T) {
+	s, client := newSyncer(t, k8s.Options{FailureThreshold: 2, FailureBackoff: time.Hour})
+	applies := 0
+	client.PrependReactor("patch", "services", func(action k8stesting.Action) (bool, runtime.Object, error) {
+		if action.(k8stesting.PatchAction).GetName() != "bad" {
+			return false, nil, nil
+		}
+		applies++
+		return true, nil, apierrors.NewInternalError(errors.New("apply rejected"))
+	})
+
+	states := []consul.ServiceState{service("web", "10.0.0.1"), service("bad", "10.0.0.2")}
+	for i := range 2 {
+		result, err := s.Sync(context.Background(), states)
+		if err == nil {
+			t.Fatalf("Sync %d succeeded, want the error of bad", i+1)
+		}
+		if result.Skipped != 0 {
+			t.Errorf("Sync %d skipped %d services, want 0", i+1, result.Skipped)
+		}
+	}
+	if applies != 2 {
+		t.Fatalf("bad applied %d times, want 2", applies)
+	}
+
+	// Quarantined after the second failure: skipped without an error.
+	result, err := s.Sync(context.Background(), states)
+	if err != nil {
+		t.Fatalf("Sync in quarantine: %v", err)
+	}
+	if result.Skipped != 1 || applies != 2 {
+		t.Errorf("Sync in quarantine skipped %d and applied bad %d times, want 1 and 2", result.Skipped, applies)
+	}
+	if got := serviceNames(t, client); !slices.Equal(got, []string{"web"}) {
+		t.Errorf("services = %v, want [web]", got)
+	}
+
+	// A change in the catalog lifts the quarantine.
+	states[1] = service("bad", "10.0.0.2", "10.0.0.3")
+	if _, err := s.Sync(context.Background(), states); err == nil {
+		t.Error("Sync after a change succeeded, want the error of bad")
+	}
+	if applies != 3 {
+		t.Errorf("bad applied %d times after a change, want 3", applies)
+	}
+}
@@ -0,0 +1,113 @@
+package kubernetes
+
+import (
+	"fmt"
+	"log/slog"
+	"maps"
+	"slices"
+	"strings"
+
+	"k8s.io/apimachinery/pkg/util/validation"
+)
+
+// defaultTagLabelPrefix prefixes the tag labels of HTTPRoutes when
+// Options.TagLabelPrefix is empty.
+const defaultTagLabelPrefix = "consul.tag/"
+
+// ValidateTagLabelPrefix checks TAG_LABEL_PREFIX: followed by a tag, it must
+// make a valid label key, e.g. consul.tag/.
+func ValidateTagLabelPrefix(prefix string) error {
+	if errs := validation.IsQualifiedName(prefix + "tag"); len(errs) > 0 {
+		return fmt.Errorf("%q followed by a tag is not a valid label key: %s", prefix, strings.Join(errs, "; "))
+	}
+	return nil
+}
+
+// tagLabelName sanitizes a Consul tag for a label key: characters a label
+// name can't hold become -, and leading and trailing ones that aren't
+// letters or digits are dropped.
+func tagLabelName(tag string) string {
+	name := strings.Map(func(r rune) rune {
+		switch {
+		case r >= 'a' && r <= 'z', r >= 'A' && r <= 'Z', r >= '0' && r <= '9', r == '-', r == '_', r == '.':
+			return r
+		}
+		return '-'
+	}, tag)
+	return strings.Trim(name, "-_.")
+}
+
+// tagLabels returns the labels Options.TagLabelPrefix asks for on the Service
+// of a service with tags: "true" under the prefix followed by each sanitized
+// tag. Tags that still don't make a valid label key, e.g. for being too
+// long, are left out.
+func (s *Syncer) tagLabels(service string, tags []string) map[string]string {
+	if s.opts.TagLabelPrefix == "" {
+		return nil
+	}
+	return tagLabelSet(s.opts.TagLabelPrefix, service, tags)
+}
+
+// routeTagLabels returns the labels of the HTTPRoutes of a service with
+// tags: like tagLabels, for the tags in Options.RouteLabelTags only, under
+// Options.TagLabelPrefix or else defaultTagLabelPrefix.
+func (s *Syncer) routeTagLabels(service string, tags []string) map[string]string {
+	var selected []string
+	for _, tag := range tags {
+		if slices.Contains(s.opts.RouteLabelTags, tag) {
+			selected = append(selected, tag)
+		}
+	}
+	prefix := s.opts.TagLabelPrefix
+	if prefix == "" {
+		prefix = defaultTagLabelPrefix
+	}
+	return tagLabelSet(prefix, service, selected)
+}
+
+// tagLabelSet returns "true" under prefix followed by each sanitized tag.
+func tagLabelSet(prefix, service string, tags []string) map[string]string {
+	if len(tags) == 0 {
+		return nil
+	}
+	labels := make(map[string]string, len(tags))
+	for _, tag := range tags {
+		key := prefix + tagLabelName(tag)
+		if errs := validation.IsQualifiedName(key); len(errs) > 0 {
+			slog.Debug("leaving out tag without a valid label key", "service", service, "tag", tag, "key", key)
+			continue
+		}
+		labels[key] = "true"
+	}
+	return labels
+}
+
+// routeLabels merges the route tag labels of the services routed by rules:
+// a route is labeled with a tag if any of them carries it.
+func routeLabels(rules []routeRule) map[string]string {
+	var labels map[string]string
+	for _, r := range rules {
+		for _, b := range append([]routeRule{r}, r.split...) {
+			if len(b.labels) == 0 {
+				continue
+			}
+			if labels == nil {
+				labels = make(map[string]string)
+			}
+			maps.Copy(labels, b.labels)
+		}
+	}
+	return labels
+}
+
+// routeObjectLabels returns the labels of the HTTPRoute of plan. Tag labels
+// never replace the ones consul-sync selects its routes by.
+func routeObjectLabels(plan routePlan) map[string]interface{} {
+	labels := make(map[string]interface{}, 2+len(plan.labels))
+	for k, v := range plan.labels {
+		labels[k] = v
+	}
+	labels[managedByKey] = managedBy
+	labels["app.kubernetes.io/name"] = plan.service
+	return labels
+}
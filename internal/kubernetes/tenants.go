@@ -0,0 +1,49 @@
+package kubernetes
+
+import (
+	"fmt"
+
+	"k8s.io/client-go/dynamic"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/rest"
+)
+
+// Clients bundles the typed and dynamic clients used to write into a namespace.
+type Clients struct {
+	Core    kubernetes.Interface
+	Dynamic dynamic.Interface
+}
+
+// NewTenantClients builds a set of clients per tenant namespace that
+// impersonate the given ServiceAccount in that namespace. The controller's own
+// identity then only needs impersonate rights, and each tenant's RBAC bounds
+// what consul-sync can do in their namespace.
+func NewTenantClients(base *rest.Config, serviceAccounts map[string]string) (map[string]Clients, error) {
+	tenants := make(map[string]Clients, len(serviceAccounts))
+	for ns, sa := range serviceAccounts {
+		cfg := rest.CopyConfig(base)
+		cfg.Impersonate = rest.ImpersonationConfig{
+			UserName: fmt.Sprintf("system:serviceaccount:%s:%s", ns, sa),
+		}
+
+		core, err := kubernetes.NewForConfig(cfg)
+		if err != nil {
+			return nil, fmt.Errorf("creating kubernetes client for tenant %s: %w", ns, err)
+		}
+		dyn, err := dynamic.NewForConfig(cfg)
+		if err != nil {
+			return nil, fmt.Errorf("creating dynamic client for tenant %s: %w", ns, err)
+		}
+		tenants[ns] = Clients{Core: core, Dynamic: dyn}
+	}
+	return tenants, nil
+}
+
+// clientsFor returns the clients to use for the given namespace, falling back
+// to the controller's own identity for namespaces without a tenant mapping.
+func (s *Syncer) clientsFor(namespace string) Clients {
+	if c, ok := s.opts.TenantClients[namespace]; ok {
+		return c
+	}
+	return Clients{Core: s.client, Dynamic: s.dynClient}
+}
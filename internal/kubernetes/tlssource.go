@@ -0,0 +1,133 @@
+package kubernetes
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"strings"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/watch"
+	"k8s.io/client-go/kubernetes"
+
+	"github.com/alexieff-io/consul-sync/internal/consul"
+)
+
+// TLSSource references a ConfigMap or Secret holding Consul TLS material.
+// ConfigMaps can only carry the CA bundle; Secrets may also carry a client
+// certificate and key under the standard kubernetes.io/tls keys.
+type TLSSource struct {
+	Kind      string // "configmap" or "secret"
+	Namespace string
+	Name      string
+	CAKey     string
+}
+
+// ParseTLSSource parses a reference of the form kind:[namespace/]name.
+func ParseTLSSource(ref, defaultNamespace, caKey string) (TLSSource, error) {
+	kind, rest, ok := strings.Cut(ref, ":")
+	kind = strings.ToLower(kind)
+	if !ok || (kind != "configmap" && kind != "secret") {
+		return TLSSource{}, fmt.Errorf("expected configmap:<name> or secret:<name>, got %q", ref)
+	}
+
+	ns, name, ok := strings.Cut(rest, "/")
+	if !ok {
+		ns, name = defaultNamespace, rest
+	}
+	if name == "" {
+		return TLSSource{}, fmt.Errorf("missing name in %q", ref)
+	}
+
+	return TLSSource{Kind: kind, Namespace: ns, Name: name, CAKey: caKey}, nil
+}
+
+func (src TLSSource) String() string {
+	return src.Kind + ":" + src.Namespace + "/" + src.Name
+}
+
+// LoadTLSSource reads the referenced object and returns its TLS material along
+// with the resourceVersion to start watching from.
+func LoadTLSSource(ctx context.Context, client kubernetes.Interface, src TLSSource) (consul.TLSConfig, string, error) {
+	if src.Kind == "configmap" {
+		cm, err := client.CoreV1().ConfigMaps(src.Namespace).Get(ctx, src.Name, metav1.GetOptions{})
+		if err != nil {
+			return consul.TLSConfig{}, "", fmt.Errorf("getting %s: %w", src, err)
+		}
+		cfg, err := src.fromConfigMap(cm)
+		return cfg, cm.ResourceVersion, err
+	}
+
+	secret, err := client.CoreV1().Secrets(src.Namespace).Get(ctx, src.Name, metav1.GetOptions{})
+	if err != nil {
+		return consul.TLSConfig{}, "", fmt.Errorf("getting %s: %w", src, err)
+	}
+	cfg, err := src.fromSecret(secret)
+	return cfg, secret.ResourceVersion, err
+}
+
+// WatchTLSSource watches the referenced object from resourceVersion onwards and
+// calls apply with the new TLS material each time it changes. It blocks until
+// the context is cancelled.
+func WatchTLSSource(ctx context.Context, client kubernetes.Interface, src TLSSource, resourceVersion string, apply func(consul.TLSConfig) error) {
+	fn := watchFunc(client.CoreV1().Secrets(src.Namespace).Watch)
+	list := func(ctx context.Context, opts metav1.ListOptions) (runtime.Object, error) {
+		return client.CoreV1().Secrets(src.Namespace).List(ctx, opts)
+	}
+	if src.Kind == "configmap" {
+		fn = client.CoreV1().ConfigMaps(src.Namespace).Watch
+		list = func(ctx context.Context, opts metav1.ListOptions) (runtime.Object, error) {
+			return client.CoreV1().ConfigMaps(src.Namespace).List(ctx, opts)
+		}
+	}
+
+	watchNamed(ctx, src.String(), src.Name, resourceVersion, list, fn, func(event watch.Event) {
+		if event.Type != watch.Added && event.Type != watch.Modified {
+			return
+		}
+		cfg, err := src.fromObject(event.Object)
+		if err == nil {
+			err = apply(cfg)
+		}
+		if err != nil {
+			slog.Error("failed to reload consul tls material", "source", src.String(), "error", err)
+			return
+		}
+		slog.Info("reloaded consul tls material", "source", src.String())
+	})
+}
+
+func (src TLSSource) fromObject(obj runtime.Object) (consul.TLSConfig, error) {
+	switch o := obj.(type) {
+	case *corev1.ConfigMap:
+		return src.fromConfigMap(o)
+	case *corev1.Secret:
+		return src.fromSecret(o)
+	default:
+		return consul.TLSConfig{}, fmt.Errorf("unexpected object %T", obj)
+	}
+}
+
+func (src TLSSource) fromConfigMap(cm *corev1.ConfigMap) (consul.TLSConfig, error) {
+	ca, ok := cm.Data[src.CAKey]
+	if !ok {
+		return consul.TLSConfig{}, fmt.Errorf("%s has no key %q", src, src.CAKey)
+	}
+	return consul.TLSConfig{CACert: []byte(ca)}, nil
+}
+
+func (src TLSSource) fromSecret(secret *corev1.Secret) (consul.TLSConfig, error) {
+	cfg := consul.TLSConfig{CACert: secret.Data[src.CAKey]}
+	// A CA stored under tls.crt (as the Consul Helm chart does) sits next to
+	// the CA's own private key, which must never be presented as a client cert.
+	if src.CAKey != corev1.TLSCertKey {
+		cfg.ClientCert = secret.Data[corev1.TLSCertKey]
+		cfg.ClientKey = secret.Data[corev1.TLSPrivateKeyKey]
+	}
+	if len(cfg.CACert) == 0 && len(cfg.ClientCert) == 0 {
+		return consul.TLSConfig{}, fmt.Errorf("%s has neither %q nor %q", src, src.CAKey, corev1.TLSCertKey)
+	}
+	return cfg, nil
+}
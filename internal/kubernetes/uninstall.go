@@ -0,0 +1,46 @@
+package kubernetes
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+)
+
+// Uninstall deletes every object this instance manages: Services with their
+// endpoints, and HTTPRoutes if enabled. MaxDeletionsPerSync does not apply.
+// In node mode only this node's EndpointSlices are deleted, with the Services
+// and routes no other node serves. Objects annotated
+// consul-sync.alexieff.io/keep=true are left in place, as by orphan cleanup.
+// It returns the number of Services and HTTPRoutes deleted.
+func (s *Syncer) Uninstall(ctx context.Context) (int, error) {
+	slog.InfoContext(ctx, "deleting all managed resources")
+	deleted := 0
+	for _, ns := range s.syncers() {
+		n, err := ns.uninstall(s.withNamespace(ctx, ns))
+		deleted += n
+		if err != nil {
+			return deleted, err
+		}
+	}
+	return deleted, nil
+}
+
+// uninstall is Uninstall within the syncer's namespace.
+func (s *Syncer) uninstall(ctx context.Context) (int, error) {
+	budget := &deleteBudget{}
+	// Routes are only deleted once it's known which Services other nodes
+	// still serve.
+	served, err := s.cleanup(ctx, nil, budget)
+	if err != nil {
+		return budget.used, fmt.Errorf("deleting services: %w", err)
+	}
+	if s.routeCfg.Enabled {
+		if err := s.cleanupHTTPRoutes(ctx, nil, served, budget); err != nil {
+			return budget.used, fmt.Errorf("deleting httproutes: %w", err)
+		}
+	}
+	s.endRetained()
+	clear(s.failures)
+	s.sharedHostnames = nil
+	return budget.used, nil
+}
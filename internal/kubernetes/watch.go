@@ -0,0 +1,125 @@
+package kubernetes
+
+import (
+	"context"
+	"log/slog"
+	"time"
+
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/fields"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/watch"
+	"k8s.io/client-go/tools/cache"
+	watchtools "k8s.io/client-go/tools/watch"
+)
+
+// Bounds of the wait before listing a watched object again once its watch
+// can no longer be resumed, doubling while the list fails.
+const (
+	minRelistBackoff = time.Second
+	maxRelistBackoff = time.Minute
+)
+
+// watchFunc starts a watch on a single namespaced resource type.
+type watchFunc func(ctx context.Context, opts metav1.ListOptions) (watch.Interface, error)
+
+// listFunc lists a single namespaced resource type.
+type listFunc func(ctx context.Context, opts metav1.ListOptions) (runtime.Object, error)
+
+// watchNamed watches the object called name from resourceVersion onwards and
+// passes every event to handle, resuming across dropped connections. When
+// the watch can no longer be resumed, e.g. once resourceVersion is too old,
+// the object is listed again, with backoff, and passed to handle as
+// Modified, or as a Deleted event without an object if it no longer exists,
+// before it is watched from the list's resourceVersion. It blocks until the
+// context is cancelled. what describes the object in log lines.
+func watchNamed(ctx context.Context, what, name, resourceVersion string, list listFunc, fn watchFunc, handle func(watch.Event)) {
+	selector := fields.OneTermEqualSelector("metadata.name", name).String()
+	lw := &cache.ListWatch{
+		ListFunc: func(opts metav1.ListOptions) (runtime.Object, error) {
+			opts.FieldSelector = selector
+			return list(ctx, opts)
+		},
+		WatchFunc: func(opts metav1.ListOptions) (watch.Interface, error) {
+			opts.FieldSelector = selector
+			return fn(ctx, opts)
+		},
+	}
+
+	for {
+		watchFrom(ctx, what, resourceVersion, lw, handle)
+		if ctx.Err() != nil {
+			return
+		}
+		resourceVersion = relist(ctx, what, lw, handle)
+		if resourceVersion == "" {
+			return
+		}
+	}
+}
+
+// watchFrom passes the events of lw from resourceVersion onwards to handle
+// until the context is cancelled or the watch can no longer be resumed.
+func watchFrom(ctx context.Context, what, resourceVersion string, lw *cache.ListWatch, handle func(watch.Event)) {
+	w, err := watchtools.NewRetryWatcher(resourceVersion, lw)
+	if err != nil {
+		slog.Error("failed to start watch", "object", what, "error", err)
+		return
+	}
+	defer w.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-w.Done():
+			slog.Warn("watch ended, listing the object again", "object", what)
+			return
+		case event := <-w.ResultChan():
+			handle(event)
+		}
+	}
+}
+
+// relist lists the object of lw until it succeeds, waiting longer after each
+// failure, passes it to handle and returns the resourceVersion to watch from,
+// or "" once the context is cancelled.
+func relist(ctx context.Context, what string, lw *cache.ListWatch, handle func(watch.Event)) string {
+	delay := minRelistBackoff
+	for {
+		select {
+		case <-ctx.Done():
+			return ""
+		case <-time.After(delay):
+		}
+
+		obj, err := lw.List(metav1.ListOptions{})
+		var items []runtime.Object
+		var resourceVersion string
+		if err == nil {
+			items, err = meta.ExtractList(obj)
+		}
+		if err == nil {
+			var accessor metav1.ListInterface
+			accessor, err = meta.ListAccessor(obj)
+			if err == nil {
+				resourceVersion = accessor.GetResourceVersion()
+			}
+		}
+		if err != nil {
+			slog.Error("failed to list watched object", "object", what, "retry_in", delay, "error", err)
+			delay = min(delay*2, maxRelistBackoff)
+			continue
+		}
+
+		if len(items) == 0 {
+			handle(watch.Event{Type: watch.Deleted})
+		}
+		for _, item := range items {
+			handle(watch.Event{Type: watch.Modified, Object: item})
+		}
+		slog.Info("resumed watch", "object", what, "resource_version", resourceVersion)
+		return resourceVersion
+	}
+}
@@ -0,0 +1,68 @@
+// Package logctx carries log attributes in a context, so every record logged
+// with that context includes them without threading a logger through calls.
+package logctx
+
+import (
+	"context"
+	"log/slog"
+	"time"
+)
+
+type ctxKey struct{}
+
+// With returns a context whose log records carry the given attributes, in
+// addition to any already attached to ctx. Arguments are interpreted as by
+// slog.Logger.With.
+func With(ctx context.Context, args ...any) context.Context {
+	prev, _ := ctx.Value(ctxKey{}).([]slog.Attr)
+	r := slog.NewRecord(time.Time{}, 0, "", 0)
+	r.Add(args...)
+	attrs := make([]slog.Attr, 0, len(prev)+r.NumAttrs())
+	attrs = append(attrs, prev...)
+	r.Attrs(func(a slog.Attr) bool {
+		attrs = append(attrs, a)
+		return true
+	})
+	return context.WithValue(ctx, ctxKey{}, attrs)
+}
+
+// Handler adds the attributes attached by With to every record it handles.
+type Handler struct {
+	next slog.Handler
+}
+
+// NewHandler wraps next so records logged with a context carry its attributes.
+func NewHandler(next slog.Handler) *Handler {
+	return &Handler{next: next}
+}
+
+func (h *Handler) Enabled(ctx context.Context, level slog.Level) bool {
+	return h.next.Enabled(ctx, level)
+}
+
+func (h *Handler) Handle(ctx context.Context, r slog.Record) error {
+	if attrs, ok := ctx.Value(ctxKey{}).([]slog.Attr); ok {
+		r.AddAttrs(attrs...)
+	}
+	return h.next.Handle(ctx, r)
+}
+
+func (h *Handler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	return &Handler{next: h.next.WithAttrs(attrs)}
+}
+
+func (h *Handler) WithGroup(name string) slog.Handler {
+	return &Handler{next: h.next.WithGroup(name)}
+}
+
+// Value returns the value of the attribute with the given key attached by
+// With. The most recently attached value wins; it reports false if none was.
+func Value(ctx context.Context, key string) (slog.Value, bool) {
+	attrs, _ := ctx.Value(ctxKey{}).([]slog.Attr)
+	for i := len(attrs) - 1; i >= 0; i-- {
+		if attrs[i].Key == key {
+			return attrs[i].Value, true
+		}
+	}
+	return slog.Value{}, false
+}
@@ -21,18 +21,211 @@ var (
 		Help: "Total reconciliations performed",
 	}, []string{"status"})
 
+	State = promauto.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "consul_sync_state",
+		Help: "State of the controller: 1 for the current one of starting, ready or degraded",
+	}, []string{"state"})
+
+	DegradedReasons = promauto.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "consul_sync_degraded",
+		Help: "Reasons the controller is degraded, 1 while each applies",
+	}, []string{"reason"})
+
 	ConsulErrors = promauto.NewCounter(prometheus.CounterOpts{
 		Name: "consul_sync_consul_errors_total",
 		Help: "Total errors communicating with Consul",
 	})
 
-	KubernetesErrors = promauto.NewCounter(prometheus.CounterOpts{
+	KubernetesErrors = promauto.NewCounterVec(prometheus.CounterOpts{
 		Name: "consul_sync_kubernetes_errors_total",
-		Help: "Total errors communicating with the Kubernetes API",
-	})
+		Help: "Total errors communicating with the Kubernetes API, by error class and resource kind",
+	}, []string{"class", "kind"})
 
 	SyncedHTTPRoutes = promauto.NewGauge(prometheus.GaugeOpts{
 		Name: "consul_sync_httproutes_total",
 		Help: "Number of currently synced HTTPRoute resources",
 	})
+
+	InvalidHostnames = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "consul_sync_invalid_hostnames_total",
+		Help: "Generated HTTPRoute hostnames skipped because they failed validation",
+	}, []string{"reason"})
+
+	HostnameConflicts = promauto.NewGauge(prometheus.GaugeOpts{
+		Name: "consul_sync_hostname_conflicts",
+		Help: "Services left out of a shared HTTPRoute by the last sync because another service matches the same requests",
+	})
+
+	DuplicateHostnames = promauto.NewGauge(prometheus.GaugeOpts{
+		Name: "consul_sync_duplicate_hostnames",
+		Help: "HTTPRoutes not applied by the last sync because a route in another namespace already has their hostname on the same gateway",
+	})
+
+	HTTPRouteProblems = promauto.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "consul_sync_httproute_problems",
+		Help: "Managed HTTPRoute parents whose condition is not True",
+	}, []string{"condition"})
+
+	GatewayHTTPRoutes = promauto.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "consul_sync_gateway_httproutes",
+		Help: "Managed HTTPRoutes attached to each Gateway",
+	}, []string{"gateway"})
+
+	MissingRouteParents = promauto.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "consul_sync_missing_route_parents",
+		Help: "HTTPRoutes planned by the last sync that attach to a Gateway, or a listener of one, that doesn't exist",
+	}, []string{"gateway", "listener"})
+
+	GatewayAcceptedHTTPRoutes = promauto.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "consul_sync_gateway_httproutes_accepted",
+		Help: "Managed HTTPRoutes each Gateway reports as Accepted for their current generation",
+	}, []string{"gateway"})
+
+	AuditDiscrepancies = promauto.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "consul_sync_audit_discrepancies",
+		Help: "Differences between the catalog and cluster state found by the last audit",
+	}, []string{"kind", "problem"})
+
+	CoalescedSnapshots = promauto.NewCounter(prometheus.CounterOpts{
+		Name: "consul_sync_coalesced_snapshots_total",
+		Help: "Watch snapshots dropped because a newer one arrived before they were reconciled",
+	})
+
+	SyncLag = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "consul_sync_sync_lag_seconds",
+		Help:    "Time from detecting a catalog change to completing the corresponding Kubernetes applies",
+		Buckets: []float64{0.1, 0.25, 0.5, 1, 2.5, 5, 10, 30, 60, 120},
+	}, []string{"trigger"})
+
+	ServiceEndpoints = promauto.NewHistogram(prometheus.HistogramOpts{
+		Name:    "consul_sync_service_endpoints",
+		Help:    "Ready endpoints per synced service, observed for each service on every full sync",
+		Buckets: []float64{1, 2, 5, 10, 25, 50, 100, 250, 500, 1000},
+	})
+
+	ProbeTotal = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "consul_sync_probe_total",
+		Help: "Synthetic HTTP probes of generated hostnames through their gateway",
+	}, []string{"service", "gateway", "result"})
+
+	ProbeSuccess = promauto.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "consul_sync_probe_success",
+		Help: "Whether the last synthetic probe of a generated hostname succeeded (1) or failed (0)",
+	}, []string{"service", "gateway"})
+
+	ProbeDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "consul_sync_probe_duration_seconds",
+		Help:    "Latency of synthetic probes of generated hostnames, including failures",
+		Buckets: []float64{0.005, 0.01, 0.025, 0.05, 0.1, 0.25, 0.5, 1, 2.5, 5, 10},
+	}, []string{"service", "gateway"})
+
+	DeferredDeletions = promauto.NewGauge(prometheus.GaugeOpts{
+		Name: "consul_sync_deferred_deletions",
+		Help: "Orphaned resources left in place by the last sync because the per-sync deletion limit was reached",
+	})
+
+	RetainedOrphans = promauto.NewGauge(prometheus.GaugeOpts{
+		Name: "consul_sync_retained_orphans",
+		Help: "Orphaned resources left in place by the last sync because they are annotated consul-sync.alexieff.io/keep=true",
+	})
+
+	WritePacingWait = promauto.NewCounter(prometheus.CounterOpts{
+		Name: "consul_sync_write_pacing_wait_seconds_total",
+		Help: "Time Kubernetes writes waited to stay under KUBE_WRITE_RATE",
+	})
+
+	ApplyConflicts = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "consul_sync_apply_conflicts_total",
+		Help: "Applies that conflicted with fields owned by another field manager",
+	}, []string{"kind", "policy"})
+
+	OrphanDeletions = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "consul_sync_orphan_deletions_total",
+		Help: "Deletions of orphaned managed resources, by resource kind and whether they succeeded",
+	}, []string{"kind", "outcome"})
+
+	QuarantinedServices = promauto.NewGauge(prometheus.GaugeOpts{
+		Name: "consul_sync_quarantined_services",
+		Help: "Services skipped by syncs after repeatedly failing to apply",
+	})
+
+	PartialSyncs = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "consul_sync_partial_syncs_total",
+		Help: "Services whose Service applied but whose endpoints or HTTPRoute failed, by how they were handled",
+	}, []string{"outcome"})
+
+	RejectedConsulResponses = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "consul_sync_consul_rejected_responses_total",
+		Help: "Consul responses rejected by CONSUL_STRICT, by endpoint and reason",
+	}, []string{"endpoint", "reason"})
+
+	ConsulLastContact = promauto.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "consul_sync_consul_last_contact_seconds",
+		Help: "Time since the Consul server answering the last query heard from the leader, from X-Consul-LastContact, by endpoint",
+	}, []string{"endpoint"})
+
+	ConsulQueryBackend = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "consul_sync_consul_query_backend_total",
+		Help: "Consul health responses by the backend that answered them, from X-Consul-Query-Backend",
+	}, []string{"backend"})
+
+	SinkSyncs = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "consul_sync_sink_syncs_total",
+		Help: "Full and single-service syncs handed to additional sinks, by sink and outcome",
+	}, []string{"sink", "outcome"})
+
+	InjectedFaults = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "consul_sync_injected_faults_total",
+		Help: "Faults injected into Consul responses by FAULT_INJECTION",
+	}, []string{"kind"})
+
+	BackupTotal = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "consul_sync_backup_total",
+		Help: "State backups uploaded to object storage",
+	}, []string{"status"})
+
+	LastBackupTimestamp = promauto.NewGauge(prometheus.GaugeOpts{
+		Name: "consul_sync_last_backup_timestamp_seconds",
+		Help: "Unix time of the last successful state backup",
+	})
+
+	DriftDetected = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "consul_sync_drift_detected_total",
+		Help: "Managed resources deleted or changed outside consul-sync, by resource kind and change",
+	}, []string{"kind", "change"})
+
+	SkippedResyncs = promauto.NewCounter(prometheus.CounterOpts{
+		Name: "consul_sync_skipped_resyncs_total",
+		Help: "Scheduled full resyncs skipped because the Consul watch was healthy",
+	})
+
+	PendingSnapshots = promauto.NewGauge(prometheus.GaugeOpts{
+		Name: "consul_sync_pending_snapshots",
+		Help: "Watch snapshots waiting for the reconciler, at most one since newer ones replace it",
+	})
+
+	StaleSnapshots = promauto.NewCounter(prometheus.CounterOpts{
+		Name: "consul_sync_stale_snapshots_total",
+		Help: "Watch snapshots dropped because their Consul index is older than a snapshot already received, each resetting the index compared against",
+	})
+
+	AppliedIndex = promauto.NewGauge(prometheus.GaugeOpts{
+		Name: "consul_sync_applied_index",
+		Help: "Consul index of the last watch snapshot reconciled",
+	})
+
+	ReconcileQueueDepth = promauto.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "consul_sync_reconcile_queue_depth",
+		Help: "Reconciles waiting to run, by kind: requested full resyncs, per-service resyncs past due and failed service syncs due for a retry",
+	}, []string{"kind"})
+
+	InFlightApplies = promauto.NewGauge(prometheus.GaugeOpts{
+		Name: "consul_sync_inflight_applies",
+		Help: "Full or per-service syncs currently applying changes to Kubernetes",
+	})
+
+	HostnameLookupFailures = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "consul_sync_hostname_lookup_failures_total",
+		Help: "Failed DNS lookups of hostnames registered as instance addresses, by service",
+	}, []string{"service"})
 )
@@ -0,0 +1,32 @@
+package metrics
+
+import (
+	"runtime"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+// BuildInfo is always 1, with the build's version in its labels, so it can
+// be joined onto other series or used to track rollouts.
+var BuildInfo = promauto.NewGaugeVec(prometheus.GaugeOpts{
+	Name: "consul_sync_build_info",
+	Help: "Build information of the running controller, always 1",
+}, []string{"version", "commit", "goversion"})
+
+func init() {
+	// The default registry comes with the process collector and a Go
+	// collector limited to the classic go_memstats metrics. Replace the
+	// latter with one also exporting the runtime's GC, memory and
+	// scheduler metrics, e.g. go_sched_latencies_seconds.
+	prometheus.Unregister(collectors.NewGoCollector())
+	prometheus.MustRegister(collectors.NewGoCollector(
+		collectors.WithGoCollectorRuntimeMetrics(collectors.MetricsGC, collectors.MetricsMemory, collectors.MetricsScheduler),
+	))
+}
+
+// SetBuildInfo exports the version and commit of the running binary.
+func SetBuildInfo(version, commit string) {
+	BuildInfo.WithLabelValues(version, commit, runtime.Version()).Set(1)
+}
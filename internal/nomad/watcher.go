@@ -0,0 +1,240 @@
+// Package nomad reads services registered in Nomad's native service catalog,
+// for clusters that run workloads on Nomad without Consul. Services are
+// reported as consul.ServiceState so they flow through the same pipeline.
+package nomad
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"slices"
+	"strconv"
+	"time"
+
+	"github.com/alexieff-io/consul-sync/internal/consul"
+)
+
+// Options holds optional Watcher behavior.
+type Options struct {
+	// Namespace is the Nomad namespace to read services from. Defaults to
+	// "default".
+	Namespace string
+
+	// SkipServices lists service names never handed to the syncer.
+	SkipServices []string
+}
+
+// Watcher watches Nomad's service catalog using blocking queries.
+type Watcher struct {
+	addr   string
+	token  string
+	tag    string
+	opts   Options
+	client *http.Client
+}
+
+// NewWatcher creates a new Nomad watcher. An empty tag selects every service
+// in the namespace except those in opts.SkipServices.
+func NewWatcher(addr, token, tag string, opts Options) *Watcher {
+	if opts.Namespace == "" {
+		opts.Namespace = "default"
+	}
+	return &Watcher{
+		addr:  addr,
+		token: token,
+		tag:   tag,
+		opts:  opts,
+		client: &http.Client{
+			Timeout: 6 * time.Minute, // longer than the 5m blocking wait
+		},
+	}
+}
+
+// servicesResponse is the JSON response from /v1/services.
+type servicesResponse []struct {
+	Namespace string `json:"Namespace"`
+	Services  []struct {
+		ServiceName string   `json:"ServiceName"`
+		Tags        []string `json:"Tags"`
+	} `json:"Services"`
+}
+
+// serviceRegistration is a single entry from /v1/service/<name>.
+type serviceRegistration struct {
+	ID          string   `json:"ID"`
+	NodeID      string   `json:"NodeID"`
+	ServiceName string   `json:"ServiceName"`
+	Address     string   `json:"Address"`
+	Port        int      `json:"Port"`
+	Tags        []string `json:"Tags"`
+}
+
+// ListServices returns the names of services carrying the configured tag,
+// along with the Nomad index for blocking queries.
+func (w *Watcher) ListServices(ctx context.Context, waitIndex uint64) ([]string, uint64, error) {
+	query := url.Values{}
+	query.Set("namespace", w.opts.Namespace)
+	query.Set("index", strconv.FormatUint(waitIndex, 10))
+	query.Set("wait", "5m")
+
+	var resp servicesResponse
+	index, err := w.get(ctx, "/v1/services?"+query.Encode(), &resp)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	var names []string
+	for _, ns := range resp {
+		for _, svc := range ns.Services {
+			if slices.Contains(w.opts.SkipServices, svc.ServiceName) {
+				continue
+			}
+			if w.tag != "" && !slices.Contains(svc.Tags, w.tag) {
+				continue
+			}
+			names = append(names, svc.ServiceName)
+		}
+	}
+	return names, index, nil
+}
+
+// FetchService returns the current registrations of a single service. Nomad
+// only lists registrations of running allocations; it has no equivalent of
+// Consul's passing-only health filter.
+func (w *Watcher) FetchService(ctx context.Context, name string) (consul.ServiceState, error) {
+	query := url.Values{}
+	query.Set("namespace", w.opts.Namespace)
+
+	var regs []serviceRegistration
+	if _, err := w.get(ctx, "/v1/service/"+url.PathEscape(name)+"?"+query.Encode(), &regs); err != nil {
+		return consul.ServiceState{}, err
+	}
+
+	st := consul.ServiceState{Name: name}
+	seen := make(map[string]bool)
+	for _, r := range regs {
+		st.Instances = append(st.Instances, consul.ServiceInstance{
+			ServiceName: r.ServiceName,
+			ID:          r.ID,
+			Node:        r.NodeID,
+			Address:     r.Address,
+			Port:        r.Port,
+			Tags:        r.Tags,
+		})
+		for _, t := range r.Tags {
+			if !seen[t] {
+				seen[t] = true
+				st.Tags = append(st.Tags, t)
+			}
+		}
+	}
+	return st, nil
+}
+
+// FetchAllServices does a single non-blocking fetch of all tagged services
+// and their registrations.
+func (w *Watcher) FetchAllServices(ctx context.Context) ([]consul.ServiceState, error) {
+	names, _, err := w.ListServices(ctx, 0)
+	if err != nil {
+		return nil, err
+	}
+	return w.fetchStates(ctx, names), nil
+}
+
+// WatchServices starts watching Nomad for service changes and sends full
+// state snapshots on the returned channel whenever changes are detected.
+func (w *Watcher) WatchServices(ctx context.Context) (<-chan consul.Snapshot, error) {
+	ch := make(chan consul.Snapshot, 1)
+
+	go func() {
+		defer close(ch)
+
+		var waitIndex uint64
+		backoff := time.Second
+
+		for {
+			names, newIndex, err := w.ListServices(ctx, waitIndex)
+			if err != nil {
+				if ctx.Err() != nil {
+					return
+				}
+				slog.Error("failed to list nomad services", "error", err, "backoff", backoff)
+				select {
+				case <-ctx.Done():
+					return
+				case <-time.After(backoff):
+				}
+				backoff = min(backoff*2, 30*time.Second)
+				continue
+			}
+			backoff = time.Second
+
+			if newIndex == waitIndex && waitIndex != 0 {
+				continue
+			}
+			// Nomad indexes start at 1; 0 would never block.
+			waitIndex = max(newIndex, 1)
+
+			slog.Info("nomad services changed", "services", names, "index", newIndex)
+
+			snap := consul.Snapshot{DetectedAt: time.Now()}
+			snap.Services = w.fetchStates(ctx, names)
+
+			select {
+			case ch <- snap:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+
+	return ch, nil
+}
+
+// fetchStates fetches the registrations of each named service. Services that
+// fail are included with nil instances so they aren't orphan-deleted.
+func (w *Watcher) fetchStates(ctx context.Context, names []string) []consul.ServiceState {
+	states := make([]consul.ServiceState, 0, len(names))
+	for _, name := range names {
+		st, err := w.FetchService(ctx, name)
+		if err != nil {
+			slog.ErrorContext(ctx, "failed to get nomad service registrations", "service", name, "error", err)
+			st = consul.ServiceState{Name: name}
+		}
+		states = append(states, st)
+	}
+	return states
+}
+
+// get performs a GET against the Nomad API, decodes the JSON body into out,
+// and returns the X-Nomad-Index header.
+func (w *Watcher) get(ctx context.Context, path string, out any) (uint64, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, w.addr+path, nil)
+	if err != nil {
+		return 0, fmt.Errorf("creating request: %w", err)
+	}
+	if w.token != "" {
+		req.Header.Set("X-Nomad-Token", w.token)
+	}
+
+	resp, err := w.client.Do(req)
+	if err != nil {
+		return 0, fmt.Errorf("querying nomad: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+		return 0, fmt.Errorf("nomad returned %d: %s", resp.StatusCode, string(body))
+	}
+
+	index, _ := strconv.ParseUint(resp.Header.Get("X-Nomad-Index"), 10, 64)
+	if err := json.NewDecoder(resp.Body).Decode(out); err != nil {
+		return 0, fmt.Errorf("decoding response: %w", err)
+	}
+	return index, nil
+}
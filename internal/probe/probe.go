@@ -0,0 +1,288 @@
+// Package probe periodically sends HTTP requests for each generated HTTPRoute
+// hostname through the gateway the route is attached to, checking that the
+// whole Consul → Service → HTTPRoute → gateway chain actually serves traffic,
+// not just that every object was applied.
+package probe
+
+import (
+	"context"
+	"crypto/tls"
+	"fmt"
+	"io"
+	"log/slog"
+	"net"
+	"net/http"
+	"sync"
+	"time"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/dynamic"
+
+	"github.com/alexieff-io/consul-sync/internal/metrics"
+)
+
+// concurrency bounds the probes in flight at once.
+const concurrency = 8
+
+var (
+	httpRouteGVR = schema.GroupVersionResource{Group: "gateway.networking.k8s.io", Version: "v1", Resource: "httproutes"}
+	gatewayGVR   = schema.GroupVersionResource{Group: "gateway.networking.k8s.io", Version: "v1", Resource: "gateways"}
+)
+
+// Config holds the probe schedule and request settings.
+type Config struct {
+	Interval time.Duration
+	Timeout  time.Duration
+	Path     string // request path, e.g. /healthz
+	Scheme   string // http or https
+
+	// InsecureSkipVerify disables certificate verification of the gateways.
+	InsecureSkipVerify bool
+
+	// GatewayAddrs maps a gateway name to the host[:port] probed for it.
+	// Gateways without an entry use the first address in their status.
+	GatewayAddrs map[string]string
+}
+
+// Prober probes the managed HTTPRoutes on an interval.
+type Prober struct {
+	cfg       Config
+	dynClient dynamic.Interface
+	namespace string
+
+	clientsMu sync.Mutex
+	clients   map[string]*http.Client // by gateway address
+
+	// last holds the result of the previous probe of each target, to log
+	// only changes and drop metrics of routes that are gone.
+	last map[target]bool
+}
+
+// target is one route hostname probed through one gateway.
+type target struct {
+	service  string
+	gateway  string
+	hostname string
+}
+
+// New creates a Prober for the managed HTTPRoutes in namespace.
+func New(cfg Config, dynClient dynamic.Interface, namespace string) *Prober {
+	if cfg.Path == "" {
+		cfg.Path = "/"
+	}
+	if cfg.Scheme == "" {
+		cfg.Scheme = "https"
+	}
+	return &Prober{
+		cfg:       cfg,
+		dynClient: dynClient,
+		namespace: namespace,
+		clients:   make(map[string]*http.Client),
+		last:      make(map[target]bool),
+	}
+}
+
+// Run probes every route each interval until the context is cancelled.
+func (p *Prober) Run(ctx context.Context) {
+	ticker := time.NewTicker(p.cfg.Interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if err := p.probeAll(ctx); err != nil && ctx.Err() == nil {
+				slog.Error("route probing failed", "error", err)
+			}
+		}
+	}
+}
+
+// probeAll probes every managed route once.
+func (p *Prober) probeAll(ctx context.Context) error {
+	routes, err := p.dynClient.Resource(httpRouteGVR).Namespace(p.namespace).List(ctx, metav1.ListOptions{
+		LabelSelector: "app.kubernetes.io/managed-by=consul-sync",
+	})
+	if err != nil {
+		return fmt.Errorf("listing managed httproutes: %w", err)
+	}
+
+	addrs := make(map[string]string)
+	type job struct {
+		target
+		addr string
+	}
+	var jobs []job
+	for i := range routes.Items {
+		route := &routes.Items[i]
+		hostnames, _, _ := unstructured.NestedStringSlice(route.Object, "spec", "hostnames")
+		gateway, gatewayNS := routeParent(route)
+		if len(hostnames) == 0 || gateway == "" {
+			continue
+		}
+		if gatewayNS == "" {
+			gatewayNS = p.namespace
+		}
+
+		key := gatewayNS + "/" + gateway
+		addr, ok := addrs[key]
+		if !ok {
+			addr, err = p.gatewayAddress(ctx, gatewayNS, gateway)
+			if err != nil {
+				slog.WarnContext(ctx, "skipping probes through gateway without an address", "gateway", key, "error", err)
+			}
+			addrs[key] = addr
+		}
+		if addr == "" {
+			continue
+		}
+		jobs = append(jobs, job{
+			target: target{service: route.GetLabels()["app.kubernetes.io/name"], gateway: gateway, hostname: hostnames[0]},
+			addr:   addr,
+		})
+	}
+
+	results := make([]bool, len(jobs))
+	sem := make(chan struct{}, concurrency)
+	var wg sync.WaitGroup
+	for i, j := range jobs {
+		wg.Add(1)
+		sem <- struct{}{}
+		go func() {
+			defer wg.Done()
+			defer func() { <-sem }()
+			results[i] = p.probe(ctx, j.target, j.addr)
+		}()
+	}
+	wg.Wait()
+
+	seen := make(map[target]bool, len(jobs))
+	for i, j := range jobs {
+		seen[j.target] = true
+		ok := results[i]
+		if prev, probed := p.last[j.target]; probed && prev != ok {
+			if ok {
+				slog.InfoContext(ctx, "route probe recovered", "service", j.service, "gateway", j.gateway, "hostname", j.hostname)
+			} else {
+				slog.WarnContext(ctx, "route probe failing", "service", j.service, "gateway", j.gateway, "hostname", j.hostname)
+			}
+		}
+		p.last[j.target] = ok
+	}
+	for t := range p.last {
+		if !seen[t] {
+			delete(p.last, t)
+			metrics.ProbeSuccess.DeleteLabelValues(t.service, t.gateway)
+			metrics.ProbeDuration.DeleteLabelValues(t.service, t.gateway)
+			metrics.ProbeTotal.DeletePartialMatch(map[string]string{"service": t.service, "gateway": t.gateway})
+		}
+	}
+	return nil
+}
+
+// probe sends one request for t to the gateway at addr and records the
+// result. Any response below 500 counts as success: the gateway routed the
+// request to a backend, whatever the backend made of it.
+func (p *Prober) probe(ctx context.Context, t target, addr string) bool {
+	ctx, cancel := context.WithTimeout(ctx, p.cfg.Timeout)
+	defer cancel()
+
+	start := time.Now()
+	ok := false
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, p.cfg.Scheme+"://"+t.hostname+p.cfg.Path, nil)
+	if err == nil {
+		var resp *http.Response
+		resp, err = p.clientFor(addr).Do(req)
+		if err == nil {
+			io.Copy(io.Discard, io.LimitReader(resp.Body, 64<<10))
+			resp.Body.Close()
+			ok = resp.StatusCode < 500
+			if !ok {
+				err = fmt.Errorf("gateway returned %d", resp.StatusCode)
+			}
+		}
+	}
+	metrics.ProbeDuration.WithLabelValues(t.service, t.gateway).Observe(time.Since(start).Seconds())
+
+	result := "success"
+	if !ok {
+		result = "failure"
+		slog.DebugContext(ctx, "route probe failed", "service", t.service, "gateway", t.gateway, "hostname", t.hostname, "error", err)
+	}
+	metrics.ProbeTotal.WithLabelValues(t.service, t.gateway, result).Inc()
+	if ok {
+		metrics.ProbeSuccess.WithLabelValues(t.service, t.gateway).Set(1)
+	} else {
+		metrics.ProbeSuccess.WithLabelValues(t.service, t.gateway).Set(0)
+	}
+	return ok
+}
+
+// clientFor returns a client that connects to addr for every request, while
+// keeping the request's hostname for the Host header and TLS server name.
+func (p *Prober) clientFor(addr string) *http.Client {
+	p.clientsMu.Lock()
+	defer p.clientsMu.Unlock()
+	if c, ok := p.clients[addr]; ok {
+		return c
+	}
+
+	dialer := &net.Dialer{Timeout: p.cfg.Timeout}
+	t := http.DefaultTransport.(*http.Transport).Clone()
+	t.Proxy = nil
+	t.DialContext = func(ctx context.Context, network, _ string) (net.Conn, error) {
+		return dialer.DialContext(ctx, network, addr)
+	}
+	t.TLSClientConfig = &tls.Config{InsecureSkipVerify: p.cfg.InsecureSkipVerify}
+	c := &http.Client{
+		Transport: t,
+		// Report the gateway's own answer rather than following it.
+		CheckRedirect: func(*http.Request, []*http.Request) error { return http.ErrUseLastResponse },
+	}
+	p.clients[addr] = c
+	return c
+}
+
+// gatewayAddress returns the host:port to probe the named gateway at.
+func (p *Prober) gatewayAddress(ctx context.Context, namespace, name string) (string, error) {
+	host, ok := p.cfg.GatewayAddrs[name]
+	if !ok {
+		gw, err := p.dynClient.Resource(gatewayGVR).Namespace(namespace).Get(ctx, name, metav1.GetOptions{})
+		if err != nil {
+			return "", fmt.Errorf("getting gateway: %w", err)
+		}
+		addrs, _, _ := unstructured.NestedSlice(gw.Object, "status", "addresses")
+		if len(addrs) == 0 {
+			return "", fmt.Errorf("gateway has no status addresses")
+		}
+		addr, _ := addrs[0].(map[string]any)
+		host, _, _ = unstructured.NestedString(addr, "value")
+		if host == "" {
+			return "", fmt.Errorf("gateway has no status addresses")
+		}
+	}
+
+	if _, _, err := net.SplitHostPort(host); err == nil {
+		return host, nil
+	}
+	port := "443"
+	if p.cfg.Scheme == "http" {
+		port = "80"
+	}
+	return net.JoinHostPort(host, port), nil
+}
+
+// routeParent returns the name and namespace of the route's first parent.
+func routeParent(route *unstructured.Unstructured) (string, string) {
+	refs, _, _ := unstructured.NestedSlice(route.Object, "spec", "parentRefs")
+	if len(refs) == 0 {
+		return "", ""
+	}
+	ref, _ := refs[0].(map[string]any)
+	name, _, _ := unstructured.NestedString(ref, "name")
+	namespace, _, _ := unstructured.NestedString(ref, "namespace")
+	return name, namespace
+}
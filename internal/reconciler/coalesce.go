@@ -0,0 +1,88 @@
+package reconciler
+
+import (
+	"context"
+	"log/slog"
+	"slices"
+
+	"github.com/alexieff-io/consul-sync/internal/consul"
+	"github.com/alexieff-io/consul-sync/internal/metrics"
+)
+
+// coalesce relays the snapshots of in, keeping only the latest while the
+// receiver is busy. Since every snapshot is a full state, the intermediate
+// ones received during a long Sync can be dropped, so a burst of catalog
+// changes costs one reconcile rather than a backlog of stale ones. A
+// coalesced snapshot keeps the DetectedAt of the oldest snapshot it replaced,
+// so sync lag still runs from the first change it covers, and lists the
+// services changed in all of them, or none if any may have changed.
+//
+// A snapshot with an older Consul index than the last one pending or
+// delivered, as answered by a lagging server, is dropped instead. Consul's
+// indexes can also go backwards for good, e.g. once a snapshot of its state
+// is restored, so the index is then reset to the dropped snapshot's, as
+// Consul's docs recommend, and the snapshots following it are accepted.
+func coalesce(ctx context.Context, in <-chan consul.Snapshot) <-chan consul.Snapshot {
+	out := make(chan consul.Snapshot)
+	go func() {
+		defer close(out)
+		var pending consul.Snapshot
+		have := false
+		// last is the highest index pending or delivered since the last
+		// reset.
+		var last uint64
+		for {
+			// Sending is only enabled while a snapshot is pending.
+			var send chan<- consul.Snapshot
+			if have {
+				send = out
+			}
+			select {
+			case <-ctx.Done():
+				return
+			case snap, ok := <-in:
+				if !ok {
+					if have {
+						select {
+						case out <- pending:
+						case <-ctx.Done():
+						}
+					}
+					return
+				}
+				if snap.Index != 0 && snap.Index < last {
+					metrics.StaleSnapshots.Inc()
+					slog.WarnContext(ctx, "dropping stale snapshot, resetting index", "index", snap.Index, "last_index", last)
+					last = snap.Index
+					continue
+				}
+				last = max(last, snap.Index)
+				if have {
+					metrics.CoalescedSnapshots.Inc()
+					slog.DebugContext(ctx, "dropping superseded snapshot", "services", len(pending.Services))
+					if pending.DetectedAt.Before(snap.DetectedAt) {
+						snap.DetectedAt = pending.DetectedAt
+					}
+					if pending.Changed == nil || snap.Changed == nil {
+						snap.Changed = nil
+					} else {
+						// The sender may still hold snap.Changed.
+						changed := slices.Clone(snap.Changed)
+						for _, name := range pending.Changed {
+							if !slices.Contains(changed, name) {
+								changed = append(changed, name)
+							}
+						}
+						snap.Changed = changed
+					}
+				}
+				pending, have = snap, true
+				metrics.PendingSnapshots.Set(1)
+			case send <- pending:
+				pending, have = consul.Snapshot{}, false
+				metrics.PendingSnapshots.Set(0)
+			}
+		}
+	}()
+	return out
+}
@@ -0,0 +1,169 @@
+package reconciler
+
+import (
+	"context"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/alexieff-io/consul-sync/internal/consul"
+)
+
+// receiveTimeout bounds the wait for a snapshot expected from coalesce.
+const receiveTimeout = 5 * time.Second
+
+func receive(t *testing.T, out <-chan consul.Snapshot) consul.Snapshot {
+	t.Helper()
+	select {
+	case snap, ok := <-out:
+		if !ok {
+			t.Fatal("coalesce closed its channel")
+		}
+		return snap
+	case <-time.After(receiveTimeout):
+		t.Fatal("no snapshot within", receiveTimeout)
+	}
+	return consul.Snapshot{}
+}
+
+// expectNothing fails if out delivers a snapshot soon.
+func expectNothing(t *testing.T, out <-chan consul.Snapshot) {
+	t.Helper()
+	select {
+	case snap := <-out:
+		t.Fatalf("unexpected snapshot at index %d", snap.Index)
+	case <-time.After(50 * time.Millisecond):
+	}
+}
+
+func TestCoalesce(t *testing.T) {
+	t0 := time.Now()
+	snap := func(index uint64, detected int, changed ...string) consul.Snapshot {
+		return consul.Snapshot{Index: index, DetectedAt: t0.Add(time.Duration(detected) * time.Second), Changed: changed}
+	}
+	all := func(index uint64, detected int) consul.Snapshot {
+		s := snap(index, detected)
+		s.Changed = nil
+		return s
+	}
+	// Each batch is sent while the receiver is busy, then received.
+	for _, tt := range []struct {
+		name    string
+		batches [][]consul.Snapshot
+		want    []consul.Snapshot
+	}{
+		{
+			name:    "one at a time",
+			batches: [][]consul.Snapshot{{snap(1, 0, "a")}, {snap(2, 1, "b")}},
+			want:    []consul.Snapshot{snap(1, 0, "a"), snap(2, 1, "b")},
+		},
+		{
+			name:    "burst merges changes",
+			batches: [][]consul.Snapshot{{snap(1, 0, "a"), snap(2, 1, "b"), snap(3, 2, "a", "c")}},
+			want:    []consul.Snapshot{snap(3, 0, "a", "c", "b")},
+		},
+		{
+			name:    "any change in a burst",
+			batches: [][]consul.Snapshot{{snap(1, 0, "a"), all(2, 1), snap(3, 2, "c")}},
+			want:    []consul.Snapshot{all(3, 0)},
+		},
+		{
+			name:    "unversioned",
+			batches: [][]consul.Snapshot{{snap(5, 0, "a")}, {snap(0, 1, "b")}, {snap(5, 2, "c")}},
+			want:    []consul.Snapshot{snap(5, 0, "a"), snap(0, 1, "b"), snap(5, 2, "c")},
+		},
+		{
+			name:    "older than pending",
+			batches: [][]consul.Snapshot{{snap(5, 0, "a"), snap(4, 1, "b")}},
+			want:    []consul.Snapshot{snap(5, 0, "a")},
+		},
+		{
+			name:    "older than delivered",
+			batches: [][]consul.Snapshot{{snap(5, 0, "a")}, {snap(4, 1, "b")}, {snap(6, 2, "c")}},
+			want:    []consul.Snapshot{snap(5, 0, "a"), snap(6, 2, "c")},
+		},
+		{
+			name:    "reset after going backwards",
+			batches: [][]consul.Snapshot{{snap(100, 0, "a")}, {snap(3, 1, "b")}, {snap(4, 2, "c")}, {snap(2, 3, "d")}, {snap(5, 4, "e")}},
+			want:    []consul.Snapshot{snap(100, 0, "a"), snap(4, 2, "c"), snap(5, 4, "e")},
+		},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx, cancel := context.WithCancel(context.Background())
+			defer cancel()
+			in := make(chan consul.Snapshot)
+			out := coalesce(ctx, in)
+
+			var got []consul.Snapshot
+			for _, batch := range tt.batches {
+				// in is unbuffered, so each snapshot is taken in before the
+				// next is sent.
+				for _, s := range batch {
+					in <- s
+				}
+				select {
+				case s := <-out:
+					got = append(got, s)
+				case <-time.After(50 * time.Millisecond):
+				}
+			}
+			close(in)
+			for s := range out {
+				got = append(got, s)
+			}
+
+			if len(got) != len(tt.want) {
+				t.Fatalf("got %d snapshots %+v, want %d %+v", len(got), got, len(tt.want), tt.want)
+			}
+			for i := range got {
+				g, w := got[i], tt.want[i]
+				if g.Index != w.Index || !g.DetectedAt.Equal(w.DetectedAt) || !slices.Equal(g.Changed, w.Changed) || (g.Changed == nil) != (w.Changed == nil) {
+					t.Errorf("snapshot %d = index %d detected %s changed %v, want index %d detected %s changed %v",
+						i, g.Index, g.DetectedAt.Sub(t0), g.Changed, w.Index, w.DetectedAt.Sub(t0), w.Changed)
+				}
+			}
+		})
+	}
+}
+
+// Merging the changes of a burst leaves the senders' slices alone.
+func TestCoalesceCopiesChanged(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	in := make(chan consul.Snapshot)
+	out := coalesce(ctx, in)
+
+	changed := make([]string, 1, 4)
+	changed[0] = "b"
+	in <- consul.Snapshot{Index: 1, Changed: []string{"a"}}
+	in <- consul.Snapshot{Index: 2, Changed: changed}
+
+	if got := receive(t, out).Changed; !slices.Equal(got, []string{"b", "a"}) {
+		t.Errorf("changed = %v, want [b a]", got)
+	}
+	if spare := changed[:cap(changed)]; spare[1] != "" {
+		t.Errorf("sender's slice was appended to: %v", spare)
+	}
+	expectNothing(t, out)
+}
+
+func TestCoalesceStopsWithContext(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	in := make(chan consul.Snapshot)
+	out := coalesce(ctx, in)
+	in <- consul.Snapshot{Index: 1}
+	cancel()
+
+	select {
+	case _, ok := <-out:
+		if ok {
+			// The pending snapshot may have won the race with the
+			// cancellation; the channel must close next.
+			if _, ok := <-out; ok {
+				t.Error("coalesce kept sending after its context was done")
+			}
+		}
+	case <-time.After(receiveTimeout):
+		t.Fatal("coalesce didn't stop with its context")
+	}
+}
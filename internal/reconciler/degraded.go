@@ -0,0 +1,50 @@
+package reconciler
+
+import (
+	"maps"
+	"strings"
+)
+
+// Reasons the controller is degraded, see health.Server.SetDegraded.
+const (
+	// degradedConsulStale: the last full fetch from the source failed, so
+	// the cluster may lag behind the catalog.
+	degradedConsulStale = "consul_stale"
+	// degradedPartialSync: the last reconcile failed for some objects.
+	degradedPartialSync = "partial_sync"
+	// degradedDeletionsFrozen: the last full sync deferred orphans to later
+	// ones, see k8s.Options.MaxDeletionsPerSync.
+	degradedDeletionsFrozen = "deletions_frozen"
+	// degradedPaused: changes aren't applied until Resume.
+	degradedPaused = "paused"
+)
+
+// setDegraded records detail as the reason the controller is degraded, or
+// clears the reason when detail is empty, and publishes the reasons on the
+// health server.
+func (r *Reconciler) setDegraded(reason, detail string) {
+	r.mu.Lock()
+	if r.degraded[reason] == detail {
+		r.mu.Unlock()
+		return
+	}
+	if detail == "" {
+		delete(r.degraded, reason)
+	} else {
+		if r.degraded == nil {
+			r.degraded = make(map[string]string)
+		}
+		r.degraded[reason] = detail
+	}
+	reasons := maps.Clone(r.degraded)
+	r.mu.Unlock()
+	r.healthServer.SetDegraded(reasons)
+}
+
+// errorDetail renders err on a single line, as a degraded reason detail.
+func errorDetail(err error) string {
+	if err == nil {
+		return ""
+	}
+	return strings.ReplaceAll(err.Error(), "\n", "; ")
+}
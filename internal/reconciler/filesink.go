@@ -0,0 +1,84 @@
+package reconciler
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"sync"
+
+	"sigs.k8s.io/yaml"
+
+	"github.com/alexieff-io/consul-sync/internal/consul"
+)
+
+// FileSink writes the services to a YAML file, rewritten whole on every
+// sync, for tools that read the inventory from disk.
+type FileSink struct {
+	path string
+
+	mu     sync.Mutex
+	states []consul.ServiceState
+}
+
+// NewFileSink returns a Sink writing to path.
+func NewFileSink(path string) *FileSink {
+	return &FileSink{path: path}
+}
+
+type servicesFile struct {
+	Services []consul.ServiceState `json:"services"`
+}
+
+func (s *FileSink) Name() string { return "file" }
+
+func (s *FileSink) Sync(ctx context.Context, states []consul.ServiceState) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.states = slices.Clone(states)
+	return s.write()
+}
+
+// SyncService replaces the service in the last services written, or adds
+// it, and rewrites the file.
+func (s *FileSink) SyncService(ctx context.Context, st consul.ServiceState) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	i := slices.IndexFunc(s.states, func(other consul.ServiceState) bool { return other.Name == st.Name })
+	if i < 0 {
+		s.states = append(s.states, st)
+	} else {
+		s.states[i] = st
+	}
+	return s.write()
+}
+
+// write replaces the file through a temporary file in the same directory,
+// so readers never see it half written.
+func (s *FileSink) write() error {
+	data, err := yaml.Marshal(servicesFile{Services: s.states})
+	if err != nil {
+		return fmt.Errorf("encoding services: %w", err)
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(s.path), "."+filepath.Base(s.path)+".*")
+	if err != nil {
+		return fmt.Errorf("writing %s: %w", s.path, err)
+	}
+	defer os.Remove(tmp.Name())
+	if err := tmp.Chmod(0o644); err != nil {
+		tmp.Close()
+		return fmt.Errorf("writing %s: %w", s.path, err)
+	}
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return fmt.Errorf("writing %s: %w", s.path, err)
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("writing %s: %w", s.path, err)
+	}
+	if err := os.Rename(tmp.Name(), s.path); err != nil {
+		return fmt.Errorf("writing %s: %w", s.path, err)
+	}
+	return nil
+}
@@ -0,0 +1,320 @@
+package reconciler
+
+import (
+	"context"
+	"log/slog"
+	"maps"
+	"net/netip"
+	"slices"
+	"sync"
+	"time"
+
+	"k8s.io/apimachinery/pkg/util/validation"
+
+	"github.com/alexieff-io/consul-sync/internal/consul"
+	k8s "github.com/alexieff-io/consul-sync/internal/kubernetes"
+	"github.com/alexieff-io/consul-sync/internal/metrics"
+)
+
+// minHostnameTTL is the shortest time the addresses of a hostname are kept
+// before it is looked up again, so records with a TTL of zero aren't looked
+// up in a loop.
+const minHostnameTTL = 5 * time.Second
+
+// hostnameRetry is how long a failed lookup waits to be retried, at most
+// the lookup interval.
+const hostnameRetry = 10 * time.Second
+
+// hostnameLookups bounds the lookups in flight at once.
+const hostnameLookups = 16
+
+// LookupFunc returns the addresses a hostname resolves to and how long they
+// may be kept.
+type LookupFunc func(ctx context.Context, host string) ([]netip.Addr, time.Duration, error)
+
+// WithResolvedHostnames wraps src so instances registered with a hostname as
+// their address are replaced by one instance per address it resolves to,
+// each with the address appended to its ID. A hostname is looked up again
+// once its records expire, and at least every interval; when its addresses
+// change, the last snapshot is sent again with the new ones, listing the
+// services using it as changed. A failed lookup
+// keeps the previous addresses, and instances whose hostname never resolved
+// are left out. Services asking for an ExternalName Service keep their
+// hostnames.
+func WithResolvedHostnames(src Source, lookup LookupFunc, interval time.Duration) Source {
+	return &hostnameSource{
+		Source:   src,
+		lookup:   lookup,
+		interval: interval,
+		records:  make(map[string]*hostnameRecord),
+	}
+}
+
+type hostnameSource struct {
+	Source
+	lookup   LookupFunc
+	interval time.Duration
+
+	mu      sync.Mutex
+	records map[string]*hostnameRecord // by hostname
+}
+
+// hostnameRecord holds what a hostname resolved to.
+type hostnameRecord struct {
+	addrs   []string // sorted, nil until it first resolves
+	refresh time.Time
+	failing bool
+
+	// services registering instances with the hostname, whose lookup
+	// failures are counted.
+	services map[string]bool
+}
+
+func (s *hostnameSource) WatchServices(ctx context.Context) (<-chan consul.Snapshot, error) {
+	in, err := s.Source.WatchServices(ctx)
+	if err != nil {
+		return nil, err
+	}
+	out := make(chan consul.Snapshot, 1)
+	go func() {
+		defer close(out)
+		var last consul.Snapshot
+		var received bool
+		timer := time.NewTimer(s.interval)
+		defer timer.Stop()
+		for {
+			var send bool
+			snap := last
+			select {
+			case next, ok := <-in:
+				if !ok {
+					return
+				}
+				last, received, send = next, true, true
+				snap = next
+			case <-timer.C:
+				// Only the services of the hostnames whose addresses
+				// changed need syncing, not those of the catalog change
+				// last sent.
+				snap.DetectedAt = time.Now()
+				snap.Changed = s.refresh(ctx)
+				send = received && len(snap.Changed) > 0
+			case <-ctx.Done():
+				return
+			}
+			if send {
+				snap.Services = s.resolve(ctx, snap.Services, true)
+				select {
+				case out <- snap:
+				case <-ctx.Done():
+					return
+				}
+			}
+			timer.Reset(time.Until(s.nextRefresh()))
+		}
+	}()
+	return out, nil
+}
+
+func (s *hostnameSource) FetchAllServices(ctx context.Context) ([]consul.ServiceState, error) {
+	states, err := s.Source.FetchAllServices(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return s.resolve(ctx, states, true), nil
+}
+
+func (s *hostnameSource) FetchService(ctx context.Context, name string) (consul.ServiceState, error) {
+	st, err := s.Source.FetchService(ctx, name)
+	if err != nil {
+		return st, err
+	}
+	return s.resolve(ctx, []consul.ServiceState{st}, false)[0], nil
+}
+
+func (s *hostnameSource) WatchHealthy() bool {
+	return watchHealthy(s.Source)
+}
+
+// hostnameAddress reports whether inst registers a hostname rather than an
+// IP. Addresses that are neither are left to the syncer to reject.
+func hostnameAddress(inst consul.ServiceInstance) bool {
+	if _, err := netip.ParseAddr(inst.Address); err == nil {
+		return false
+	}
+	return len(validation.IsDNS1123Subdomain(inst.Address)) == 0
+}
+
+// resolve returns states with the instances registered by hostname replaced
+// by those of its addresses, looking up hostnames seen for the first time.
+// full states are every service, so the hostnames of none of them are
+// forgotten.
+func (s *hostnameSource) resolve(ctx context.Context, states []consul.ServiceState, full bool) []consul.ServiceState {
+	used := make(map[string]map[string]bool)
+	for _, st := range states {
+		if k8s.ExternalNameService(st) {
+			continue
+		}
+		for _, inst := range st.Instances {
+			if !hostnameAddress(inst) {
+				continue
+			}
+			if used[inst.Address] == nil {
+				used[inst.Address] = make(map[string]bool)
+			}
+			used[inst.Address][st.Name] = true
+		}
+	}
+
+	s.mu.Lock()
+	var missing []string
+	for host, services := range used {
+		rec, ok := s.records[host]
+		switch {
+		case !ok:
+			s.records[host] = &hostnameRecord{services: services}
+			missing = append(missing, host)
+		case full:
+			rec.services = services
+		default:
+			maps.Copy(rec.services, services)
+		}
+	}
+	if full {
+		for host := range s.records {
+			if used[host] == nil {
+				delete(s.records, host)
+			}
+		}
+	}
+	s.mu.Unlock()
+	s.lookupAll(ctx, missing)
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	resolved := make([]consul.ServiceState, len(states))
+	for i, st := range states {
+		resolved[i] = st
+		if k8s.ExternalNameService(st) || !slices.ContainsFunc(st.Instances, hostnameAddress) {
+			continue
+		}
+		instances := make([]consul.ServiceInstance, 0, len(st.Instances))
+		for _, inst := range st.Instances {
+			if !hostnameAddress(inst) {
+				instances = append(instances, inst)
+				continue
+			}
+			rec := s.records[inst.Address]
+			if rec == nil {
+				// Forgotten by a concurrent full snapshot.
+				continue
+			}
+			for _, addr := range rec.addrs {
+				r := inst
+				r.Address = addr
+				if inst.ID != "" {
+					r.ID = inst.ID + "/" + addr
+				}
+				instances = append(instances, r)
+			}
+		}
+		resolved[i].Instances = instances
+	}
+	return resolved
+}
+
+// refresh looks up the hostnames whose records have expired, returning the
+// services of those whose addresses changed.
+func (s *hostnameSource) refresh(ctx context.Context) []string {
+	now := time.Now()
+	s.mu.Lock()
+	var due []string
+	for host, rec := range s.records {
+		if !rec.refresh.After(now) {
+			due = append(due, host)
+		}
+	}
+	s.mu.Unlock()
+	return s.lookupAll(ctx, due)
+}
+
+// nextRefresh returns when the first hostname is due to be looked up again.
+func (s *hostnameSource) nextRefresh() time.Time {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	next := time.Now().Add(s.interval)
+	for _, rec := range s.records {
+		if rec.refresh.Before(next) {
+			next = rec.refresh
+		}
+	}
+	return next
+}
+
+// lookupAll looks up hosts, returning the services of those whose addresses
+// changed, sorted.
+func (s *hostnameSource) lookupAll(ctx context.Context, hosts []string) []string {
+	var wg sync.WaitGroup
+	var mu sync.Mutex
+	changed := make(map[string]bool)
+	sem := make(chan struct{}, hostnameLookups)
+	for _, host := range hosts {
+		wg.Add(1)
+		sem <- struct{}{}
+		go func() {
+			defer func() { <-sem; wg.Done() }()
+			addrs, ttl, err := s.lookup(ctx, host)
+			services := s.update(ctx, host, addrs, ttl, err)
+			mu.Lock()
+			for _, name := range services {
+				changed[name] = true
+			}
+			mu.Unlock()
+		}()
+	}
+	wg.Wait()
+	return slices.Sorted(maps.Keys(changed))
+}
+
+// update records the result of a lookup of host, returning the services
+// using it if its addresses changed.
+func (s *hostnameSource) update(ctx context.Context, host string, addrs []netip.Addr, ttl time.Duration, err error) []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	rec, ok := s.records[host]
+	if !ok {
+		// No longer registered by any instance.
+		return nil
+	}
+	now := time.Now()
+	services := slices.Sorted(maps.Keys(rec.services))
+	if err != nil {
+		for _, name := range services {
+			metrics.HostnameLookupFailures.WithLabelValues(name).Inc()
+		}
+		if !rec.failing {
+			slog.WarnContext(ctx, "hostname lookup failed, keeping previous addresses", "hostname", host, "services", services, "addresses", rec.addrs, "error", err)
+		}
+		rec.failing = true
+		rec.refresh = now.Add(min(hostnameRetry, s.interval))
+		return nil
+	}
+	if rec.failing {
+		slog.InfoContext(ctx, "hostname lookup recovered", "hostname", host, "services", services)
+	}
+	rec.failing = false
+	rec.refresh = now.Add(min(max(ttl, minHostnameTTL), s.interval))
+
+	resolved := make([]string, 0, len(addrs))
+	for _, addr := range addrs {
+		resolved = append(resolved, addr.Unmap().String())
+	}
+	slices.Sort(resolved)
+	resolved = slices.Compact(resolved)
+	if slices.Equal(resolved, rec.addrs) {
+		return nil
+	}
+	slog.DebugContext(ctx, "hostname resolved", "hostname", host, "addresses", resolved, "ttl", ttl)
+	rec.addrs = resolved
+	return services
+}
@@ -0,0 +1,249 @@
+package reconciler
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"os"
+	"slices"
+
+	"k8s.io/apimachinery/pkg/util/validation"
+	"sigs.k8s.io/yaml"
+
+	"github.com/alexieff-io/consul-sync/internal/consul"
+	k8s "github.com/alexieff-io/consul-sync/internal/kubernetes"
+)
+
+// namespaceMetaKey is the service meta key the syncer places services by,
+// see k8s.Options.Namespaces.
+const namespaceMetaKey = "k8s-namespace"
+
+// WatchProfile selects the services registered with a tag and syncs them into
+// their own namespace, with their own HTTPRoute settings.
+type WatchProfile struct {
+	Name      string `json:"name"`
+	Tag       string `json:"tag"`
+	Namespace string `json:"namespace"`
+	// Routes overrides the global HTTPRoute settings for the profile's
+	// namespace.
+	Routes *k8s.HTTPRouteOverride `json:"routes,omitempty"`
+}
+
+type watchProfilesFile struct {
+	Profiles []WatchProfile `json:"profiles"`
+}
+
+// LoadWatchProfiles reads a YAML or JSON file of watch profiles. Profile
+// names and namespaces must be unique, and no profile may use
+// targetNamespace, which belongs to the main tag.
+func LoadWatchProfiles(path, targetNamespace string) ([]WatchProfile, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("reading watch profiles: %w", err)
+	}
+	var file watchProfilesFile
+	if err := yaml.UnmarshalStrict(data, &file); err != nil {
+		return nil, fmt.Errorf("parsing watch profiles: %w", err)
+	}
+
+	names := make(map[string]bool)
+	namespaces := map[string]bool{targetNamespace: true}
+	for _, p := range file.Profiles {
+		if p.Name == "" {
+			return nil, fmt.Errorf("watch profile without a name")
+		}
+		if names[p.Name] {
+			return nil, fmt.Errorf("watch profile %q declared twice", p.Name)
+		}
+		names[p.Name] = true
+		if p.Tag == "" {
+			return nil, fmt.Errorf("watch profile %q has no tag", p.Name)
+		}
+		if errs := validation.IsDNS1123Label(p.Namespace); len(errs) > 0 {
+			return nil, fmt.Errorf("watch profile %q has invalid namespace %q: %v", p.Name, p.Namespace, errs)
+		}
+		if namespaces[p.Namespace] {
+			return nil, fmt.Errorf("watch profile %q: namespace %s is already in use", p.Name, p.Namespace)
+		}
+		namespaces[p.Namespace] = true
+		if p.Routes != nil && p.Routes.HostnameLayout != "" {
+			if _, err := k8s.ParseHostnameLayout(string(p.Routes.HostnameLayout)); err != nil {
+				return nil, fmt.Errorf("watch profile %q: %w", p.Name, err)
+			}
+		}
+	}
+	return file.Profiles, nil
+}
+
+// Profile is a source whose services are synced into one namespace. An empty
+// Namespace leaves the services where their own meta places them.
+type Profile struct {
+	Name      string
+	Namespace string
+	Source    Source
+}
+
+// WithProfiles combines the sources of several profiles into one. Each
+// profile's source keeps its own watch loop, and every snapshot holds the
+// latest services of all profiles, placed in their profile's namespace. A
+// service registered under several profiles' tags is only synced by the first
+// of them.
+func WithProfiles(profiles []Profile) Source {
+	if len(profiles) == 1 && profiles[0].Namespace == "" {
+		return profiles[0].Source
+	}
+	return &profileSource{profiles: profiles}
+}
+
+type profileSource struct {
+	profiles []Profile
+}
+
+type profileSnapshot struct {
+	index int
+	snap  consul.Snapshot
+}
+
+func (s *profileSource) WatchServices(ctx context.Context) (<-chan consul.Snapshot, error) {
+	in := make(chan profileSnapshot)
+	for i, p := range s.profiles {
+		ch, err := p.Source.WatchServices(ctx)
+		if err != nil {
+			return nil, fmt.Errorf("watching profile %s: %w", p.Name, err)
+		}
+		go func() {
+			for snap := range ch {
+				select {
+				case in <- profileSnapshot{index: i, snap: snap}:
+				case <-ctx.Done():
+					return
+				}
+			}
+		}()
+	}
+
+	out := make(chan consul.Snapshot)
+	go func() {
+		defer close(out)
+		// Nothing is sent until every profile has reported once, so a fast
+		// profile can't orphan the services of a slower one.
+		latest := make([][]consul.ServiceState, len(s.profiles))
+		indexes := make([]uint64, len(s.profiles))
+		seen := make([]bool, len(s.profiles))
+		pending := len(s.profiles)
+		for {
+			var ps profileSnapshot
+			select {
+			case ps = <-in:
+			case <-ctx.Done():
+				return
+			}
+			latest[ps.index] = ps.snap.Services
+			indexes[ps.index] = ps.snap.Index
+			if !seen[ps.index] {
+				seen[ps.index] = true
+				pending--
+			}
+			if pending > 0 {
+				continue
+			}
+
+			// Every profile watches the same Consul, so the indexes compare.
+			snap := consul.Snapshot{Services: s.merge(latest), DetectedAt: ps.snap.DetectedAt, Index: slices.Max(indexes)}
+			select {
+			case out <- snap:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+	return out, nil
+}
+
+func (s *profileSource) FetchAllServices(ctx context.Context) ([]consul.ServiceState, error) {
+	all := make([][]consul.ServiceState, len(s.profiles))
+	for i, p := range s.profiles {
+		states, err := p.Source.FetchAllServices(ctx)
+		if err != nil {
+			return nil, fmt.Errorf("fetching profile %s: %w", p.Name, err)
+		}
+		all[i] = states
+	}
+	return s.merge(all), nil
+}
+
+// WatchHealthy reports whether the watch of every profile is healthy.
+func (s *profileSource) WatchHealthy() bool {
+	for _, p := range s.profiles {
+		if !watchHealthy(p.Source) {
+			return false
+		}
+	}
+	return true
+}
+
+// FetchService returns the service from the first profile it is registered
+// under.
+func (s *profileSource) FetchService(ctx context.Context, name string) (consul.ServiceState, error) {
+	var (
+		st  consul.ServiceState
+		err error
+	)
+	for _, p := range s.profiles {
+		st, err = p.Source.FetchService(ctx, name)
+		if err == nil && len(st.Instances) > 0 {
+			return place(st, p.Namespace), nil
+		}
+	}
+	return st, err
+}
+
+// merge concatenates the services of every profile, in profile order, placing
+// each in its profile's namespace.
+func (s *profileSource) merge(all [][]consul.ServiceState) []consul.ServiceState {
+	owner := make(map[string]string)
+	var merged []consul.ServiceState
+	for i, states := range all {
+		p := s.profiles[i]
+		for _, st := range states {
+			if first, ok := owner[st.Name]; ok {
+				slog.Warn("service matched by several watch profiles, keeping the first",
+					"service", st.Name, "profile", p.Name, "kept_profile", first)
+				continue
+			}
+			owner[st.Name] = p.Name
+			merged = append(merged, place(st, p.Namespace))
+		}
+	}
+	return merged
+}
+
+// place returns st with its namespace meta set to namespace, if any.
+func place(st consul.ServiceState, namespace string) consul.ServiceState {
+	if namespace == "" {
+		return st
+	}
+	meta := make(map[string]string, len(st.Meta)+1)
+	for k, v := range st.Meta {
+		meta[k] = v
+	}
+	meta[namespaceMetaKey] = namespace
+	st.Meta = meta
+	return st
+}
+
+// RouteOverrides returns the HTTPRoute overrides of the profiles, keyed by
+// namespace, merged under overrides: a namespace present in overrides keeps
+// that override.
+func RouteOverrides(profiles []WatchProfile, overrides map[string]k8s.HTTPRouteOverride) map[string]k8s.HTTPRouteOverride {
+	merged := make(map[string]k8s.HTTPRouteOverride, len(profiles)+len(overrides))
+	for _, p := range profiles {
+		if p.Routes != nil {
+			merged[p.Namespace] = *p.Routes
+		}
+	}
+	for ns, o := range overrides {
+		merged[ns] = o
+	}
+	return merged
+}
@@ -2,85 +2,776 @@ package reconciler
 
 import (
 	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
 	"log/slog"
+	"slices"
+	"sync"
 	"time"
 
+	"k8s.io/client-go/util/workqueue"
+
 	"github.com/alexieff-io/consul-sync/internal/consul"
 	"github.com/alexieff-io/consul-sync/internal/health"
 	k8s "github.com/alexieff-io/consul-sync/internal/kubernetes"
+	"github.com/alexieff-io/consul-sync/internal/logctx"
 	"github.com/alexieff-io/consul-sync/internal/metrics"
 )
 
-// Reconciler orchestrates the Consul watcher and Kubernetes syncer.
+const (
+	// resyncMetaKey is the Consul service meta key overriding the resync
+	// interval for a single service, e.g. k8s-resync=30s.
+	resyncMetaKey = "k8s-resync"
+
+	// minServiceResync bounds how often a single service may be refreshed.
+	minServiceResync = 5 * time.Second
+
+	// Kinds of the reconcile queue depth metric.
+	queueResync  = "resync"
+	queueService = "service"
+	queueRetry   = "retry"
+
+	// retryQueueName names the workqueue of failed service syncs in the
+	// workqueue_* metrics.
+	retryQueueName = "service_retries"
+)
+
+// Source supplies service snapshots to the Reconciler. The Consul watcher is
+// the default implementation.
+type Source interface {
+	// WatchServices sends a full snapshot whenever the set of services changes.
+	WatchServices(ctx context.Context) (<-chan consul.Snapshot, error)
+	// FetchAllServices returns a full snapshot without waiting for changes.
+	FetchAllServices(ctx context.Context) ([]consul.ServiceState, error)
+	// FetchService returns the current state of a single service.
+	FetchService(ctx context.Context, name string) (consul.ServiceState, error)
+}
+
+// HealthReporter is implemented by sources that can tell whether their watch
+// sees changes as they happen, which lets scheduled resyncs be skipped.
+type HealthReporter interface {
+	WatchHealthy() bool
+}
+
+// watchHealthy reports whether src is known to see changes as they happen.
+// Sources that can't tell are assumed not to.
+func watchHealthy(src Source) bool {
+	h, ok := src.(HealthReporter)
+	return ok && h.WatchHealthy()
+}
+
+// Reconciler orchestrates the service source and Kubernetes syncer.
 type Reconciler struct {
-	watcher        *consul.Watcher
-	syncer         *k8s.Syncer
+	source Source
+	// cluster syncs the cluster the controller manages, before the other
+	// sinks. Its Syncer also audits, drains and uninstalls.
+	cluster        *KubernetesSink
 	healthServer   *health.Server
 	resyncInterval time.Duration
+
+	// heartbeat, if set, is renewed after every successful reconcile.
+	heartbeat *k8s.Heartbeat
+
+	// sinks receive every sync after the cluster, see AddSink.
+	sinks []Sink
+
+	// auditOnly compares Consul with the cluster instead of syncing.
+	auditOnly bool
+
+	// healthyResync, if set, replaces resyncInterval while the source's
+	// watch is healthy; an interval of zero skips scheduled resyncs then.
+	healthyResync *time.Duration
+	// lastResync is when the last full resync ran. It is only touched from
+	// the Run goroutine.
+	lastResync time.Time
+
+	// triggerCh requests an out-of-band full resync.
+	triggerCh chan struct{}
+
+	// uninstallCh requests the deletion of every managed object, answered
+	// on the given channel once done.
+	uninstallCh chan chan error
+
+	// serviceResyncs holds services with a k8s-resync override. It is only
+	// touched from the Run goroutine.
+	serviceResyncs map[string]serviceResync
+
+	// retries holds the alias groups whose single-service sync failed, to
+	// be synced again with a per-service exponential backoff. It is created
+	// by Run, and its items are only processed by the Run goroutine.
+	retries workqueue.TypedRateLimitingInterface[string]
+
+	mu     sync.Mutex
+	paused bool
+	status Status
+	states []consul.ServiceState
+
+	// degraded holds the reasons the controller is degraded, with their
+	// details, see setDegraded.
+	degraded map[string]string
+
+	// outcomes holds whether each of the last statusWindow reconciles
+	// failed, and lastSuccess when one last succeeded, for /status.
+	// heartbeatError is the error of the last heartbeat renewal.
+	outcomes       []bool
+	lastSuccess    time.Time
+	heartbeatError string
+}
+
+type serviceResync struct {
+	interval time.Duration
+	next     time.Time
 }
 
-// New creates a new Reconciler.
-func New(watcher *consul.Watcher, syncer *k8s.Syncer, healthServer *health.Server, resyncInterval time.Duration) *Reconciler {
+// Status summarizes the reconciler's recent activity.
+type Status struct {
+	Paused          bool
+	Reconciles      uint64
+	LastReconcile   time.Time
+	LastReconcileID string
+	LastTrigger     string
+	LastError       string
+	Services        int
+	// AppliedIndex is the Consul index of the last watch snapshot
+	// reconciled, zero if none carried one.
+	AppliedIndex uint64
+}
+
+// New creates a new Reconciler syncing the managed cluster through cluster.
+func New(source Source, cluster *KubernetesSink, healthServer *health.Server, resyncInterval time.Duration) *Reconciler {
 	return &Reconciler{
-		watcher:        watcher,
-		syncer:         syncer,
+		source:         source,
+		cluster:        cluster,
 		healthServer:   healthServer,
 		resyncInterval: resyncInterval,
+		triggerCh:      make(chan struct{}, 1),
+		uninstallCh:    make(chan chan error),
+		serviceResyncs: make(map[string]serviceResync),
+	}
+}
+
+// SetHeartbeat makes the Reconciler renew the given heartbeat Lease after
+// every successful reconcile. It must be called before Run.
+func (r *Reconciler) SetHeartbeat(h *k8s.Heartbeat) {
+	r.heartbeat = h
+}
+
+// SetAuditOnly makes the Reconciler audit the cluster against every snapshot
+// instead of syncing it, so a passive instance can check the active one. It
+// must be called before Run.
+func (r *Reconciler) SetAuditOnly(auditOnly bool) {
+	r.auditOnly = auditOnly
+}
+
+// SetHealthyResync stretches the interval between scheduled full resyncs to
+// d while the source reports a healthy watch, or skips them altogether when d
+// is zero. Drift in the cluster should then be caught otherwise, see
+// k8s.Syncer.WatchDrift. It must be called before Run.
+func (r *Reconciler) SetHealthyResync(d time.Duration) {
+	r.healthyResync = &d
+}
+
+// Status returns a snapshot of the reconciler's state.
+func (r *Reconciler) Status() Status {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	st := r.status
+	st.Paused = r.paused
+	return st
+}
+
+// Service returns the last observed Consul state for a service, matched by
+// its Consul name.
+func (r *Reconciler) Service(name string) (consul.ServiceState, bool) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for _, st := range r.states {
+		if st.Name == name {
+			return st, true
+		}
+	}
+	return consul.ServiceState{}, false
+}
+
+// Services returns the last observed Consul state of every service.
+func (r *Reconciler) Services() []consul.ServiceState {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return slices.Clone(r.states)
+}
+
+// TriggerSync requests an immediate full resync. Requests made while one is
+// already pending are coalesced.
+func (r *Reconciler) TriggerSync() {
+	select {
+	case r.triggerCh <- struct{}{}:
+		metrics.ReconcileQueueDepth.WithLabelValues(queueResync).Set(1)
+	default:
+	}
+}
+
+// Uninstall pauses the reconciler and deletes every managed object, so
+// nothing is recreated until Resume. It waits for any reconcile in progress
+// and must be called while Run is running.
+func (r *Reconciler) Uninstall(ctx context.Context) error {
+	if r.auditOnly {
+		return fmt.Errorf("nothing to uninstall in audit-only mode")
+	}
+	done := make(chan error, 1)
+	select {
+	case r.uninstallCh <- done:
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+	select {
+	case err := <-done:
+		return err
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// Pause stops applying changes to Kubernetes until Resume is called. Consul
+// is still watched, but snapshots received while paused are discarded.
+func (r *Reconciler) Pause() {
+	r.mu.Lock()
+	if !r.paused {
+		slog.Info("reconciler paused")
+	}
+	r.paused = true
+	r.mu.Unlock()
+	r.setDegraded(degradedPaused, "changes are not applied until resumed")
+}
+
+// Resume re-enables reconciliation and triggers a full resync to catch up on
+// anything missed while paused.
+func (r *Reconciler) Resume() {
+	r.mu.Lock()
+	wasPaused := r.paused
+	r.paused = false
+	r.mu.Unlock()
+
+	if wasPaused {
+		slog.Info("reconciler resumed")
+		r.setDegraded(degradedPaused, "")
+		r.TriggerSync()
 	}
 }
 
 // Run starts the reconciliation loop. It blocks until the context is cancelled.
 func (r *Reconciler) Run(ctx context.Context) error {
-	watchCh, err := r.watcher.WatchServices(ctx)
+	snapshots, err := r.source.WatchServices(ctx)
 	if err != nil {
 		return err
 	}
+	watchCh := coalesce(ctx, snapshots)
+	// The first snapshot of the watch is a full fetch too.
+	r.lastResync = time.Now()
 
 	resyncTicker := time.NewTicker(r.resyncInterval)
 	defer resyncTicker.Stop()
 
+	serviceTimer := time.NewTimer(r.resyncInterval)
+	serviceTimer.Stop()
+	defer serviceTimer.Stop()
+
+	// drainTimer fires when draining endpoints are due for removal.
+	drainTimer := time.NewTimer(r.resyncInterval)
+	drainTimer.Stop()
+	defer drainTimer.Stop()
+
+	r.retries = workqueue.NewTypedRateLimitingQueueWithConfig(
+		workqueue.DefaultTypedControllerRateLimiter[string](),
+		workqueue.TypedRateLimitingQueueConfig[string]{Name: retryQueueName},
+	)
+	defer r.retries.ShutDown()
+	// The Syncer isn't safe for concurrent use, so retries are handed over
+	// to this goroutine rather than synced by workers of their own.
+	retryCh := make(chan string)
+	go func() {
+		for {
+			name, shutdown := r.retries.Get()
+			if shutdown {
+				return
+			}
+			select {
+			case retryCh <- name:
+			case <-ctx.Done():
+				r.retries.Done(name)
+				return
+			}
+		}
+	}()
+
 	slog.Info("reconciler started", "resync_interval", r.resyncInterval)
 
 	for {
+		if next, ok := r.nextServiceResync(); ok {
+			serviceTimer.Reset(time.Until(next))
+		} else {
+			serviceTimer.Stop()
+		}
+		if deadline, ok := r.cluster.syncer.DrainDeadline(); ok {
+			drainTimer.Reset(time.Until(deadline))
+		} else {
+			drainTimer.Stop()
+		}
+		metrics.ReconcileQueueDepth.WithLabelValues(queueService).Set(float64(r.dueServiceResyncs()))
+		metrics.ReconcileQueueDepth.WithLabelValues(queueRetry).Set(float64(r.retries.Len()))
+
 		select {
 		case <-ctx.Done():
 			slog.Info("reconciler shutting down")
 			return ctx.Err()
 
-		case states, ok := <-watchCh:
+		case snap, ok := <-watchCh:
 			if !ok {
 				slog.Info("watch channel closed")
 				return nil
 			}
-			r.reconcile(ctx, states, "watch")
+			rctx := withReconcileID(ctx)
+			if snap.Changed != nil {
+				r.reconcileChanged(rctx, snap.Services, snap.Changed, snap.DetectedAt)
+			} else {
+				r.reconcile(rctx, snap.Services, "watch", snap.DetectedAt)
+			}
+			r.setAppliedIndex(snap.Index)
 
 		case <-resyncTicker.C:
-			slog.Info("performing scheduled resync")
-			states, err := r.watcher.FetchAllServices(ctx)
-			if err != nil {
-				slog.Error("resync fetch failed", "error", err)
-				metrics.ConsulErrors.Inc()
-				metrics.ReconcileTotal.WithLabelValues("error").Inc()
+			rctx := withReconcileID(ctx)
+			if r.skipResync() {
+				slog.DebugContext(rctx, "skipping scheduled resync, consul watch is healthy", "last_resync", r.lastResync)
+				metrics.SkippedResyncs.Inc()
+				if !r.Status().Paused {
+					r.renewHeartbeat(rctx)
+				}
 				continue
 			}
-			r.reconcile(ctx, states, "resync")
+			slog.InfoContext(rctx, "performing scheduled resync")
+			r.resync(rctx, "resync")
+
+		case <-r.triggerCh:
+			metrics.ReconcileQueueDepth.WithLabelValues(queueResync).Set(0)
+			rctx := withReconcileID(ctx)
+			slog.InfoContext(rctx, "performing requested resync")
+			r.resync(rctx, "manual")
+
+		case <-serviceTimer.C:
+			r.resyncDueServices(ctx)
+
+		case name := <-retryCh:
+			r.retryService(withReconcileID(ctx), name)
+
+		case done := <-r.uninstallCh:
+			r.Pause()
+			rctx := withReconcileID(ctx)
+			deleted, err := r.cluster.syncer.Uninstall(rctx)
+			slog.InfoContext(rctx, "uninstall complete", "deleted", deleted, "error", err)
+			done <- err
+
+		case <-drainTimer.C:
+			rctx := withReconcileID(ctx)
+			slog.InfoContext(rctx, "performing resync to remove drained endpoints")
+			r.resync(rctx, "drain")
 		}
 	}
 }
 
-func (r *Reconciler) reconcile(ctx context.Context, states []consul.ServiceState, trigger string) {
-	slog.Info("reconciling", "trigger", trigger, "services", len(states))
+// reconcileIDKey is the log attribute carrying the reconcile correlation ID.
+const reconcileIDKey = "reconcile_id"
+
+// withReconcileID returns a context whose log records carry a new reconcile
+// correlation ID.
+func withReconcileID(ctx context.Context) context.Context {
+	b := make([]byte, 8)
+	rand.Read(b)
+	return logctx.With(ctx, reconcileIDKey, hex.EncodeToString(b))
+}
+
+// reconcileID returns the correlation ID attached by withReconcileID.
+func reconcileID(ctx context.Context) string {
+	if v, ok := logctx.Value(ctx, reconcileIDKey); ok {
+		return v.String()
+	}
+	return ""
+}
+
+// skipResync reports whether a scheduled resync is unneeded because the
+// source's watch is healthy and the healthy resync interval hasn't passed.
+func (r *Reconciler) skipResync() bool {
+	if r.healthyResync == nil || !watchHealthy(r.source) {
+		return false
+	}
+	return *r.healthyResync == 0 || time.Since(r.lastResync) < *r.healthyResync
+}
 
-	if err := r.syncer.Sync(ctx, states); err != nil {
-		slog.Error("sync completed with errors", "trigger", trigger, "error", err)
+// resync fetches the full Consul state and reconciles it.
+func (r *Reconciler) resync(ctx context.Context, trigger string) {
+	start := time.Now()
+	r.lastResync = start
+	states, err := r.source.FetchAllServices(ctx)
+	if err != nil {
+		slog.ErrorContext(ctx, "resync fetch failed", "trigger", trigger, "error", err)
+		metrics.ConsulErrors.Inc()
+		r.setDegraded(degradedConsulStale, errorDetail(err))
+		r.recordOutcome(err)
 		metrics.ReconcileTotal.WithLabelValues("error").Inc()
+		slog.InfoContext(ctx, "reconciliation complete",
+			"trigger", trigger,
+			"outcome", "error",
+			"duration_ms", time.Since(start).Milliseconds(),
+			"errors", 1,
+		)
+		return
+	}
+	r.reconcile(ctx, states, trigger, start)
+}
+
+// reconcile syncs the given states and emits one summary record covering the
+// whole reconcile, from start to the last write. start is when the change was
+// detected, before the states were fetched, so the time to the last write is
+// the end-to-end sync lag.
+func (r *Reconciler) reconcile(ctx context.Context, states []consul.ServiceState, trigger string, start time.Time) {
+	r.mu.Lock()
+	paused := r.paused
+	r.states = states
+	r.mu.Unlock()
+	r.setDegraded(degradedConsulStale, "")
+	if paused {
+		slog.InfoContext(ctx, "reconciler paused, skipping", "trigger", trigger, "services", len(states))
+		return
+	}
+
+	if r.auditOnly {
+		r.audit(ctx, states, trigger, start)
+		return
+	}
+
+	slog.InfoContext(ctx, "reconciling", "trigger", trigger, "services", len(states))
+
+	metrics.InFlightApplies.Inc()
+	result, err := r.cluster.Apply(ctx, states)
+	r.syncSinks(ctx, states)
+	metrics.InFlightApplies.Dec()
+	outcome := "success"
+	if err != nil {
+		outcome = "error"
+		slog.ErrorContext(ctx, "sync completed with errors", "trigger", trigger, "error", err)
+	}
+	metrics.SyncLag.WithLabelValues(trigger).Observe(time.Since(start).Seconds())
+	if result.Deferred > 0 {
+		r.setDegraded(degradedDeletionsFrozen, fmt.Sprintf("%d orphans deferred by the deletion limit", result.Deferred))
 	} else {
-		metrics.ReconcileTotal.WithLabelValues("success").Inc()
+		r.setDegraded(degradedDeletionsFrozen, "")
+	}
+	r.scheduleServiceResyncs(states)
+	r.finish(ctx, states, trigger, err)
+
+	slog.InfoContext(ctx, "reconciliation complete",
+		"trigger", trigger,
+		"outcome", outcome,
+		"duration_ms", time.Since(start).Milliseconds(),
+		"services", len(states),
+		"applied_services", result.Services,
+		"endpoints", result.Endpoints,
+		"applied_routes", result.Routes,
+		"deleted", result.Deleted,
+		"deferred", result.Deferred,
+		"retained", result.Retained,
+		"skipped", result.Skipped,
+		"errors", result.Errors,
+	)
+}
+
+// reconcileChanged syncs only the changed services of states, the only ones
+// that differ from the last reconcile, each with the rest of its alias group.
+// Audits still cover every service.
+func (r *Reconciler) reconcileChanged(ctx context.Context, states []consul.ServiceState, changed []string, start time.Time) {
+	if r.auditOnly {
+		r.reconcile(ctx, states, "watch", start)
+		return
+	}
+	r.mu.Lock()
+	paused := r.paused
+	r.states = states
+	r.mu.Unlock()
+	r.setDegraded(degradedConsulStale, "")
+	if paused {
+		slog.InfoContext(ctx, "reconciler paused, skipping", "trigger", "watch", "services", len(changed))
+		return
+	}
+
+	aliases := make(map[string]bool, len(changed))
+	for _, st := range states {
+		if slices.Contains(changed, st.Name) {
+			aliases[k8s.AliasOf(st)] = true
+		}
+	}
+	slog.InfoContext(ctx, "reconciling changed services", "trigger", "watch", "services", changed)
+
+	var errs []error
+	for _, st := range k8s.MergeAliases(states) {
+		if !aliases[st.Name] {
+			continue
+		}
+		metrics.InFlightApplies.Inc()
+		err := r.cluster.SyncService(ctx, st)
+		r.syncServiceSinks(ctx, st)
+		metrics.InFlightApplies.Dec()
+		if err != nil {
+			slog.ErrorContext(ctx, "service sync failed", "service", st.Name, "error", err)
+			errs = append(errs, err)
+		}
+		r.requeue(st.Name, err)
+	}
+	err := errors.Join(errs...)
+	outcome := "success"
+	if err != nil {
+		outcome = "error"
+	}
+	metrics.SyncLag.WithLabelValues("watch").Observe(time.Since(start).Seconds())
+	r.finish(ctx, states, "watch", err)
+
+	slog.InfoContext(ctx, "reconciliation complete",
+		"trigger", "watch",
+		"outcome", outcome,
+		"duration_ms", time.Since(start).Milliseconds(),
+		"services", len(states),
+		"synced_services", len(aliases),
+		"errors", len(errs),
+	)
+}
+
+// audit compares the given states with the cluster without changing it, and
+// logs every discrepancy found.
+func (r *Reconciler) audit(ctx context.Context, states []consul.ServiceState, trigger string, start time.Time) {
+	slog.InfoContext(ctx, "auditing", "trigger", trigger, "services", len(states))
+
+	report, err := r.cluster.syncer.Audit(ctx, states)
+	outcome := "success"
+	if err != nil {
+		outcome = "error"
+		metrics.KubernetesErrors.WithLabelValues(k8s.ErrorClass(err), "other").Inc()
+		slog.ErrorContext(ctx, "audit failed", "trigger", trigger, "error", err)
+	}
+	for _, d := range report.Discrepancies {
+		slog.WarnContext(ctx, "audit discrepancy", "kind", d.Kind, "name", d.Name, "problem", d.Problem, "detail", d.Detail)
 	}
+	r.finish(ctx, states, trigger, err)
+
+	slog.InfoContext(ctx, "audit complete",
+		"trigger", trigger,
+		"outcome", outcome,
+		"duration_ms", time.Since(start).Milliseconds(),
+		"services", report.Services,
+		"discrepancies", len(report.Discrepancies),
+	)
+}
+
+// finish records the outcome of a reconcile or audit in the status and
+// metrics, marks the controller ready and renews the heartbeat.
+func (r *Reconciler) finish(ctx context.Context, states []consul.ServiceState, trigger string, err error) {
+	outcome := "success"
+	if err != nil {
+		outcome = "error"
+	}
+	metrics.ReconcileTotal.WithLabelValues(outcome).Inc()
+
+	r.mu.Lock()
+	r.status.Reconciles++
+	r.status.LastReconcile = time.Now()
+	r.status.LastReconcileID = reconcileID(ctx)
+	r.status.LastTrigger = trigger
+	r.status.Services = len(states)
+	r.status.LastError = ""
+	if err != nil {
+		r.status.LastError = err.Error()
+	}
+	r.mu.Unlock()
 
 	// Mark ready after the first sync completes, even with partial errors.
 	// Partial failures (e.g. one bad service) shouldn't block readiness
-	// for the entire controller.
+	// for the entire controller, they only degrade it.
+	r.setDegraded(degradedPartialSync, errorDetail(err))
+	r.recordOutcome(err)
 	r.healthServer.SetReady()
-	slog.Info("reconciliation complete", "trigger", trigger, "services", len(states))
+	if err == nil {
+		r.renewHeartbeat(ctx)
+	}
+}
+
+// renewHeartbeat renews the heartbeat Lease, if any.
+func (r *Reconciler) renewHeartbeat(ctx context.Context) {
+	if r.heartbeat == nil {
+		return
+	}
+	err := r.heartbeat.Renew(ctx)
+	if err != nil {
+		slog.ErrorContext(ctx, "failed to renew heartbeat lease", "error", err)
+		metrics.KubernetesErrors.WithLabelValues(k8s.ErrorClass(err), "lease").Inc()
+	}
+	r.mu.Lock()
+	r.heartbeatError = errorDetail(err)
+	r.mu.Unlock()
+}
+
+// scheduleServiceResyncs updates the per-service resync schedule from the
+// k8s-resync meta of the given states. Services keep their next due time
+// across reconciles unless their interval changes.
+func (r *Reconciler) scheduleServiceResyncs(states []consul.ServiceState) {
+	seen := make(map[string]bool, len(r.serviceResyncs))
+	for _, st := range states {
+		raw, ok := st.Meta[resyncMetaKey]
+		if !ok {
+			continue
+		}
+		interval, err := time.ParseDuration(raw)
+		if err != nil || interval < minServiceResync {
+			slog.Warn("ignoring invalid per-service resync interval",
+				"service", st.Name, "value", raw, "minimum", minServiceResync)
+			continue
+		}
+		if interval >= r.resyncInterval {
+			continue
+		}
+
+		seen[st.Name] = true
+		if cur, ok := r.serviceResyncs[st.Name]; ok && cur.interval == interval {
+			continue
+		}
+		r.serviceResyncs[st.Name] = serviceResync{interval: interval, next: time.Now().Add(interval)}
+	}
+
+	for name := range r.serviceResyncs {
+		if !seen[name] {
+			delete(r.serviceResyncs, name)
+		}
+	}
+}
+
+// nextServiceResync returns the earliest due time of any per-service resync.
+func (r *Reconciler) nextServiceResync() (time.Time, bool) {
+	var next time.Time
+	for _, sr := range r.serviceResyncs {
+		if next.IsZero() || sr.next.Before(next) {
+			next = sr.next
+		}
+	}
+	return next, !next.IsZero()
+}
+
+// dueServiceResyncs counts the per-service resyncs past their due time.
+func (r *Reconciler) dueServiceResyncs() int {
+	now := time.Now()
+	due := 0
+	for _, sr := range r.serviceResyncs {
+		if !sr.next.After(now) {
+			due++
+		}
+	}
+	return due
+}
+
+// resyncDueServices refreshes every service whose per-service interval has
+// elapsed, applying only that service's resources.
+func (r *Reconciler) resyncDueServices(ctx context.Context) {
+	now := time.Now()
+	for name, sr := range r.serviceResyncs {
+		if sr.next.After(now) {
+			continue
+		}
+		sr.next = now.Add(sr.interval)
+		r.serviceResyncs[name] = sr
+
+		r.resyncService(withReconcileID(ctx), name)
+	}
+}
+
+func (r *Reconciler) resyncService(ctx context.Context, name string) {
+	r.mu.Lock()
+	paused := r.paused
+	r.mu.Unlock()
+	if paused {
+		return
+	}
+
+	start := time.Now()
+	st, err := r.source.FetchService(ctx, name)
+	if err != nil {
+		slog.ErrorContext(ctx, "per-service resync fetch failed", "service", name, "error", err)
+		metrics.ConsulErrors.Inc()
+		return
+	}
+
+	// An aliased service shares its Kubernetes Service with the rest of its
+	// alias group, so the whole group is re-applied.
+	alias := k8s.AliasOf(st)
+	r.mu.Lock()
+	for i := range r.states {
+		if r.states[i].Name == name {
+			r.states[i] = st
+		}
+	}
+	for _, merged := range k8s.MergeAliases(r.states) {
+		if merged.Name == alias {
+			st = merged
+		}
+	}
+	r.mu.Unlock()
+
+	slog.DebugContext(ctx, "performing per-service resync", "service", name, "alias", alias)
+	metrics.InFlightApplies.Inc()
+	err = r.cluster.SyncService(ctx, st)
+	r.syncServiceSinks(ctx, st)
+	metrics.InFlightApplies.Dec()
+	if err != nil {
+		slog.ErrorContext(ctx, "per-service resync failed", "service", name, "error", err)
+	}
+	r.requeue(st.Name, err)
+	metrics.SyncLag.WithLabelValues("service").Observe(time.Since(start).Seconds())
+}
+
+// requeue adds the alias group name back to the retries, after a backoff
+// growing with each consecutive failure, if its sync failed with err, and
+// resets its backoff otherwise.
+func (r *Reconciler) requeue(name string, err error) {
+	if err != nil {
+		r.retries.AddRateLimited(name)
+		return
+	}
+	r.retries.Forget(name)
+}
+
+// retryService syncs the alias group name again, from the latest states,
+// after its last single-service sync failed. Only the cluster is synced;
+// the sinks got the service already. Groups no longer in Consul are
+// dropped, full reconciles delete their objects.
+func (r *Reconciler) retryService(ctx context.Context, name string) {
+	defer r.retries.Done(name)
+	r.mu.Lock()
+	paused := r.paused
+	var st consul.ServiceState
+	found := false
+	for _, merged := range k8s.MergeAliases(r.states) {
+		if merged.Name == name {
+			st, found = merged, true
+		}
+	}
+	r.mu.Unlock()
+	if paused || !found {
+		// Resuming resyncs every service anyway.
+		r.retries.Forget(name)
+		return
+	}
+
+	slog.InfoContext(ctx, "retrying service sync", "service", name, "failures", r.retries.NumRequeues(name))
+	metrics.InFlightApplies.Inc()
+	err := r.cluster.SyncService(ctx, st)
+	metrics.InFlightApplies.Dec()
+	if err != nil {
+		slog.ErrorContext(ctx, "service sync retry failed", "service", name, "error", err)
+	}
+	r.requeue(name, err)
 }
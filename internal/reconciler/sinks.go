@@ -0,0 +1,89 @@
+package reconciler
+
+import (
+	"context"
+	"log/slog"
+
+	"github.com/alexieff-io/consul-sync/internal/consul"
+	k8s "github.com/alexieff-io/consul-sync/internal/kubernetes"
+	"github.com/alexieff-io/consul-sync/internal/metrics"
+)
+
+// Sink receives the services applied by the Reconciler. The Kubernetes
+// cluster it manages is synced through a KubernetesSink first; sinks added
+// with AddSink are then handed every full snapshot and every single-service
+// update, with aliased services merged by k8s.MergeAliases, so they can
+// mirror the services elsewhere.
+type Sink interface {
+	// Name identifies the sink in logs and metrics.
+	Name() string
+	// Sync applies the full set of services, dropping any others.
+	Sync(ctx context.Context, states []consul.ServiceState) error
+	// SyncService applies a single service, already merged with the rest
+	// of its alias group.
+	SyncService(ctx context.Context, st consul.ServiceState) error
+}
+
+// KubernetesSink applies the services to a cluster through a k8s.Syncer:
+// the cluster the Reconciler manages, or a secondary one mirroring it.
+// Orphans are cleaned up by its full syncs.
+type KubernetesSink struct {
+	name   string
+	syncer *k8s.Syncer
+}
+
+// NewKubernetesSink returns a Sink called name syncing through syncer.
+func NewKubernetesSink(name string, syncer *k8s.Syncer) *KubernetesSink {
+	return &KubernetesSink{name: name, syncer: syncer}
+}
+
+func (s *KubernetesSink) Name() string { return s.name }
+
+func (s *KubernetesSink) Sync(ctx context.Context, states []consul.ServiceState) error {
+	_, err := s.Apply(ctx, states)
+	return err
+}
+
+// Apply is Sync, also returning what the sync did.
+func (s *KubernetesSink) Apply(ctx context.Context, states []consul.ServiceState) (k8s.SyncResult, error) {
+	return s.syncer.Sync(ctx, states)
+}
+
+func (s *KubernetesSink) SyncService(ctx context.Context, st consul.ServiceState) error {
+	return s.syncer.SyncService(ctx, st)
+}
+
+// AddSink registers a sink to receive every sync after the managed cluster.
+// It must be called before Run.
+func (r *Reconciler) AddSink(s Sink) {
+	r.sinks = append(r.sinks, s)
+}
+
+// syncSinks hands a full snapshot to every sink. A failing sink is logged
+// and counted, but neither holds up the others nor fails the reconcile,
+// which is about the cluster.
+func (r *Reconciler) syncSinks(ctx context.Context, states []consul.ServiceState) {
+	if len(r.sinks) == 0 {
+		return
+	}
+	merged := k8s.MergeAliases(states)
+	for _, s := range r.sinks {
+		countSinkSync(ctx, s, s.Sync(ctx, merged))
+	}
+}
+
+// syncServiceSinks hands a single service to every sink, like syncSinks.
+func (r *Reconciler) syncServiceSinks(ctx context.Context, st consul.ServiceState) {
+	for _, s := range r.sinks {
+		countSinkSync(ctx, s, s.SyncService(ctx, st))
+	}
+}
+
+func countSinkSync(ctx context.Context, s Sink, err error) {
+	outcome := "success"
+	if err != nil {
+		outcome = "error"
+		slog.ErrorContext(ctx, "sink sync failed", "sink", s.Name(), "error", err)
+	}
+	metrics.SinkSyncs.WithLabelValues(s.Name(), outcome).Inc()
+}
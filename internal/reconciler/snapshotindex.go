@@ -0,0 +1,18 @@
+package reconciler
+
+import (
+	"github.com/alexieff-io/consul-sync/internal/metrics"
+)
+
+// setAppliedIndex records index as that of the last watch snapshot
+// reconciled, unless it is zero. Snapshots older than the last one are
+// dropped before they get here, see coalesce.
+func (r *Reconciler) setAppliedIndex(index uint64) {
+	if index == 0 {
+		return
+	}
+	r.mu.Lock()
+	r.status.AppliedIndex = index
+	r.mu.Unlock()
+	metrics.AppliedIndex.Set(float64(index))
+}
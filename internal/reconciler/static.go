@@ -0,0 +1,167 @@
+package reconciler
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"net/netip"
+	"os"
+	"sync"
+
+	"sigs.k8s.io/yaml"
+
+	"github.com/alexieff-io/consul-sync/internal/consul"
+)
+
+// StaticService is an always-synced service declared in a file rather than
+// registered in the service catalog, for appliances that can't register
+// themselves.
+type StaticService struct {
+	Name      string            `json:"name"`
+	Addresses []string          `json:"addresses"`
+	Port      int               `json:"port"`
+	Tags      []string          `json:"tags,omitempty"`
+	Meta      map[string]string `json:"meta,omitempty"`
+}
+
+type staticServicesFile struct {
+	Services []StaticService `json:"services"`
+}
+
+// LoadStaticServices reads a YAML or JSON file of static services and returns
+// them as service states. Static services bypass the tag filter.
+func LoadStaticServices(path string) ([]consul.ServiceState, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("reading static services: %w", err)
+	}
+	var file staticServicesFile
+	if err := yaml.UnmarshalStrict(data, &file); err != nil {
+		return nil, fmt.Errorf("parsing static services: %w", err)
+	}
+
+	states := make([]consul.ServiceState, 0, len(file.Services))
+	seen := make(map[string]bool)
+	for _, svc := range file.Services {
+		if svc.Name == "" {
+			return nil, fmt.Errorf("static service without a name")
+		}
+		if seen[svc.Name] {
+			return nil, fmt.Errorf("static service %q declared twice", svc.Name)
+		}
+		seen[svc.Name] = true
+		if len(svc.Addresses) == 0 {
+			return nil, fmt.Errorf("static service %q has no addresses", svc.Name)
+		}
+		if svc.Port < 1 || svc.Port > 65535 {
+			return nil, fmt.Errorf("static service %q has invalid port %d", svc.Name, svc.Port)
+		}
+
+		st := consul.ServiceState{Name: svc.Name, Tags: svc.Tags, Meta: svc.Meta}
+		for _, addr := range svc.Addresses {
+			if _, err := netip.ParseAddr(addr); err != nil {
+				return nil, fmt.Errorf("static service %q: invalid address %q", svc.Name, addr)
+			}
+			st.Instances = append(st.Instances, consul.ServiceInstance{
+				ServiceName: svc.Name,
+				Address:     addr,
+				Port:        svc.Port,
+				Tags:        svc.Tags,
+				Meta:        svc.Meta,
+			})
+		}
+		states = append(states, st)
+	}
+	return states, nil
+}
+
+// WithStaticServices wraps src so every snapshot also contains the given
+// static services. A service of the same name in src takes precedence.
+func WithStaticServices(src Source, static []consul.ServiceState) Source {
+	if len(static) == 0 {
+		return src
+	}
+	return &staticSource{Source: src, static: static, shadowed: make(map[string]bool)}
+}
+
+type staticSource struct {
+	Source
+	static []consul.ServiceState
+
+	// shadowed holds the static services hidden by a registered service of
+	// the same name, so that is only logged when it starts and ends.
+	mu       sync.Mutex
+	shadowed map[string]bool
+}
+
+func (s *staticSource) WatchServices(ctx context.Context) (<-chan consul.Snapshot, error) {
+	in, err := s.Source.WatchServices(ctx)
+	if err != nil {
+		return nil, err
+	}
+	out := make(chan consul.Snapshot, 1)
+	go func() {
+		defer close(out)
+		for snap := range in {
+			snap.Services = s.merge(snap.Services)
+			select {
+			case out <- snap:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+	return out, nil
+}
+
+func (s *staticSource) FetchAllServices(ctx context.Context) ([]consul.ServiceState, error) {
+	states, err := s.Source.FetchAllServices(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return s.merge(states), nil
+}
+
+func (s *staticSource) FetchService(ctx context.Context, name string) (consul.ServiceState, error) {
+	st, err := s.Source.FetchService(ctx, name)
+	if err == nil && len(st.Instances) > 0 {
+		return st, nil
+	}
+	for _, static := range s.static {
+		if static.Name == name {
+			return static, nil
+		}
+	}
+	return st, err
+}
+
+func (s *staticSource) WatchHealthy() bool {
+	return watchHealthy(s.Source)
+}
+
+// merge appends the static services missing from states.
+func (s *staticSource) merge(states []consul.ServiceState) []consul.ServiceState {
+	names := make(map[string]bool, len(states))
+	for _, st := range states {
+		names[st.Name] = true
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	merged := make([]consul.ServiceState, 0, len(states)+len(s.static))
+	merged = append(merged, states...)
+	for _, st := range s.static {
+		if names[st.Name] {
+			if !s.shadowed[st.Name] {
+				slog.Warn("static service shadowed by a registered service of the same name", "service", st.Name)
+				s.shadowed[st.Name] = true
+			}
+			continue
+		}
+		if s.shadowed[st.Name] {
+			slog.Info("static service no longer shadowed", "service", st.Name)
+			delete(s.shadowed, st.Name)
+		}
+		merged = append(merged, st)
+	}
+	return merged
+}
@@ -0,0 +1,126 @@
+package reconciler
+
+import (
+	"encoding/json"
+	"net/http"
+	"time"
+)
+
+// statusWindow is the number of recent reconciles rolled up by /status.
+const statusWindow = 20
+
+// Component statuses of a StatusReport.
+const (
+	componentOK       = "ok"
+	componentFailing  = "failing"
+	componentPaused   = "paused"
+	componentDisabled = "disabled"
+)
+
+// StatusReport is the rollup served on /status for external uptime systems,
+// instead of scraping several metrics.
+type StatusReport struct {
+	// State is starting, ready or degraded, with the reasons it is
+	// degraded, as for /readyz.
+	State      string                     `json:"state"`
+	Degraded   map[string]string          `json:"degraded,omitempty"`
+	Reconciles ReconcileRollup            `json:"reconciles"`
+	Components map[string]ComponentStatus `json:"components"`
+}
+
+// ReconcileRollup sums up the outcome of the recent reconciles.
+type ReconcileRollup struct {
+	// Window is the number of recent reconciles rolled up, up to 20, of
+	// which Errors failed at least partly.
+	Window    int     `json:"window"`
+	Errors    int     `json:"errors"`
+	ErrorRate float64 `json:"errorRate"`
+	// Total counts the reconciles completed since the start.
+	Total       uint64     `json:"total"`
+	LastSuccess *time.Time `json:"lastSuccess,omitempty"`
+	LastError   string     `json:"lastError,omitempty"`
+}
+
+// ComponentStatus is the health of one part of the controller: ok, failing,
+// paused or disabled, with the reason.
+type ComponentStatus struct {
+	Status string `json:"status"`
+	Detail string `json:"detail,omitempty"`
+}
+
+// recordOutcome adds the outcome of a reconcile to the window rolled up by
+// /status.
+func (r *Reconciler) recordOutcome(err error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.outcomes = append(r.outcomes, err != nil)
+	if len(r.outcomes) > statusWindow {
+		r.outcomes = r.outcomes[1:]
+	}
+	if err == nil {
+		r.lastSuccess = time.Now()
+	}
+}
+
+// StatusReport returns the rollup served on /status.
+func (r *Reconciler) StatusReport() StatusReport {
+	state, degraded := r.healthServer.State()
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	rollup := ReconcileRollup{
+		Window:    len(r.outcomes),
+		Total:     r.status.Reconciles,
+		LastError: r.status.LastError,
+	}
+	for _, failed := range r.outcomes {
+		if failed {
+			rollup.Errors++
+		}
+	}
+	if rollup.Window > 0 {
+		rollup.ErrorRate = float64(rollup.Errors) / float64(rollup.Window)
+	}
+	if !r.lastSuccess.IsZero() {
+		last := r.lastSuccess
+		rollup.LastSuccess = &last
+	}
+
+	components := map[string]ComponentStatus{
+		"consul":     componentFor(r.degraded[degradedConsulStale]),
+		"kubernetes": componentFor(r.degraded[degradedPartialSync]),
+		"reconciler": {Status: componentOK},
+		"heartbeat":  {Status: componentDisabled},
+	}
+	if r.paused {
+		components["reconciler"] = ComponentStatus{Status: componentPaused, Detail: r.degraded[degradedPaused]}
+	}
+	if r.heartbeat != nil {
+		components["heartbeat"] = componentFor(r.heartbeatError)
+	}
+	return StatusReport{
+		State:      state,
+		Degraded:   degraded,
+		Reconciles: rollup,
+		Components: components,
+	}
+}
+
+// componentFor returns a component failing for detail, or ok without one.
+func componentFor(detail string) ComponentStatus {
+	if detail == "" {
+		return ComponentStatus{Status: componentOK}
+	}
+	return ComponentStatus{Status: componentFailing, Detail: detail}
+}
+
+// ServeHTTP writes the StatusReport as JSON, with 503 until the controller
+// is ready, like /readyz.
+func (r *Reconciler) ServeHTTP(w http.ResponseWriter, _ *http.Request) {
+	report := r.StatusReport()
+	w.Header().Set("Content-Type", "application/json")
+	if report.State == "starting" {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}
+	json.NewEncoder(w).Encode(report)
+}
@@ -0,0 +1,91 @@
+package reconciler
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/alexieff-io/consul-sync/internal/consul"
+)
+
+// WebhookConfig configures a WebhookSink.
+type WebhookConfig struct {
+	URL string
+	// TokenFile, when set, holds a bearer token sent with every request. It
+	// is read on every request, so it can be rotated in place.
+	TokenFile string
+	// Timeout bounds each request. Defaults to 10s.
+	Timeout time.Duration
+}
+
+// WebhookSink posts the services as JSON to a URL: the full set as
+// {"event":"sync","services":[...]} and single services as
+// {"event":"service","service":{...}}. Any status but 2xx is a failure.
+type WebhookSink struct {
+	cfg    WebhookConfig
+	client *http.Client
+}
+
+// NewWebhookSink returns a Sink posting to cfg.URL.
+func NewWebhookSink(cfg WebhookConfig) *WebhookSink {
+	if cfg.Timeout <= 0 {
+		cfg.Timeout = 10 * time.Second
+	}
+	return &WebhookSink{cfg: cfg, client: &http.Client{Timeout: cfg.Timeout}}
+}
+
+type webhookEvent struct {
+	Event    string                `json:"event"`
+	Services []consul.ServiceState `json:"services,omitempty"`
+	Service  *consul.ServiceState  `json:"service,omitempty"`
+}
+
+func (s *WebhookSink) Name() string { return "webhook" }
+
+func (s *WebhookSink) Sync(ctx context.Context, states []consul.ServiceState) error {
+	if states == nil {
+		states = []consul.ServiceState{}
+	}
+	return s.post(ctx, webhookEvent{Event: "sync", Services: states})
+}
+
+func (s *WebhookSink) SyncService(ctx context.Context, st consul.ServiceState) error {
+	return s.post(ctx, webhookEvent{Event: "service", Service: &st})
+}
+
+func (s *WebhookSink) post(ctx context.Context, event webhookEvent) error {
+	body, err := json.Marshal(event)
+	if err != nil {
+		return fmt.Errorf("encoding %s event: %w", event.Event, err)
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, s.cfg.URL, bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("creating request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if s.cfg.TokenFile != "" {
+		token, err := os.ReadFile(s.cfg.TokenFile)
+		if err != nil {
+			return fmt.Errorf("reading webhook token: %w", err)
+		}
+		req.Header.Set("Authorization", "Bearer "+strings.TrimSpace(string(token)))
+	}
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("posting %s event: %w", event.Event, err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		msg, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+		return fmt.Errorf("webhook returned %d: %s", resp.StatusCode, string(msg))
+	}
+	io.Copy(io.Discard, resp.Body)
+	return nil
+}